@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// A11yConfig configures the accessibility audit of the rendered pages:
+//
+//	[a11y]
+//	enabled = true
+//	[a11y.rules]
+//	heading-skip = "off"
+//	[a11y.ignore]
+//	image-alt = ["gallery/*"]
+//
+// Findings of warning rules are logged, so --strict fails on them; error rules fail the build.
+type A11yConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Rules sets the severity of a rule: error, warning or off
+	Rules map[string]string `toml:"rules"`
+	// Ignore lists, per rule, globs of output paths such as "tags/*" the rule is not checked on
+	Ignore map[string][]string `toml:"ignore"`
+}
+
+// a11yRules lists the accessibility rules with their default severity
+var a11yRules = map[string]string{
+	"image-alt":    severityWarning,
+	"empty-link":   severityWarning,
+	"empty-button": severityWarning,
+	"heading-skip": severityWarning,
+	"missing-lang": severityWarning,
+}
+
+// a11yFinding is one problem of a page
+type a11yFinding struct {
+	offset int
+	rule   string
+	msg    string
+}
+
+// auditAccessibility checks every rendered page against the enabled rules, logging the findings
+// and returning an error when a rule with the error severity found any
+func (s *Site) auditAccessibility(outputDir string) error {
+	cfg := s.Config.A11y
+	if !cfg.Enabled {
+		return nil
+	}
+	severities := map[string]string{}
+	for rule, severity := range a11yRules {
+		severities[rule] = severity
+	}
+	for rule, severity := range cfg.Rules {
+		if _, ok := a11yRules[rule]; !ok {
+			return fmt.Errorf("unknown a11y rule %q", rule)
+		}
+		switch severity {
+		case severityError, severityWarning, severityOff:
+			severities[rule] = severity
+		default:
+			return fmt.Errorf("unknown severity %q for a11y rule %s, use error, warning or off", severity, rule)
+		}
+	}
+	for rule := range cfg.Ignore {
+		if _, ok := a11yRules[rule]; !ok {
+			return fmt.Errorf("unknown a11y rule %q", rule)
+		}
+	}
+
+	var files []string
+	for _, page := range s.AllPages {
+		files = append(files, filepath.ToSlash(page.outputPath))
+	}
+	sort.Strings(files)
+
+	counts := map[string]int{}
+	for _, rel := range files {
+		file, err := outputFile(outputDir, rel)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			// Pages that failed to render have no output
+			continue
+		}
+		for _, f := range auditHTML(string(data)) {
+			severity := severities[f.rule]
+			if severity == severityOff || a11yIgnored(cfg.Ignore[f.rule], rel) {
+				continue
+			}
+			counts[severity]++
+			line := strings.Count(string(data[:f.offset]), "\n") + 1
+			if severity == severityError {
+				failf("Error: Accessibility: %s:%d: %s (%s)", rel, line, f.msg, f.rule)
+			} else {
+				warnf("Accessibility: %s:%d: %s (%s)", rel, line, f.msg, f.rule)
+			}
+		}
+	}
+	if counts[severityError] > 0 {
+		return fmt.Errorf("%d accessibility errors", counts[severityError])
+	}
+	return nil
+}
+
+// a11yIgnored reports whether an output path matches one of the globs
+func a11yIgnored(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchContentPattern(pattern, rel) {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// a11yElement is an open element of the audit; links and buttons collect their accessible text
+type a11yElement struct {
+	name   string
+	offset int
+	hidden bool
+	text   strings.Builder
+	named  bool
+}
+
+// auditHTML returns the findings of every rule on a page
+func auditHTML(s string) []a11yFinding {
+	var findings []a11yFinding
+	report := func(offset int, rule, format string, args ...any) {
+		findings = append(findings, a11yFinding{offset: offset, rule: rule, msg: fmt.Sprintf(format, args...)})
+	}
+	var stack []*a11yElement
+	hidden := func() bool {
+		for _, e := range stack {
+			if e.hidden {
+				return true
+			}
+		}
+		return false
+	}
+	// label gives the open links and buttons accessible text
+	label := func(text string) {
+		if strings.TrimSpace(text) == "" || hidden() {
+			return
+		}
+		for _, e := range stack {
+			if e.name == "a" || e.name == "button" {
+				e.text.WriteString(text)
+			}
+		}
+	}
+	lastHeading := 0
+	// raw is the element whose raw text comes next
+	raw := ""
+
+	z := nethtml.NewTokenizer(strings.NewReader(s))
+	for offset := 0; ; {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		start := offset
+		offset += len(z.Raw())
+		switch tt {
+		case nethtml.TextToken:
+			// A <title> of an inline SVG names the link around it; other raw text is not read
+			if raw == "" || raw == "title" {
+				label(string(z.Text()))
+			}
+		case nethtml.EndTagToken:
+			raw = ""
+			tag, _ := z.TagName()
+			name := string(tag)
+			for j := len(stack) - 1; j >= 0; j-- {
+				if stack[j].name != name {
+					continue
+				}
+				e := stack[j]
+				stack = stack[:j]
+				if (name == "a" || name == "button") && !e.named && strings.TrimSpace(e.text.String()) == "" && !e.hidden {
+					rule := "empty-link"
+					if name == "button" {
+						rule = "empty-button"
+					}
+					report(e.offset, rule, "<%s> has no text or accessible name", name)
+				}
+				break
+			}
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			tag, more := z.TagName()
+			name := string(tag)
+			attrs := map[string]string{}
+			has := map[string]bool{}
+			for more {
+				var key, value []byte
+				key, value, more = z.TagAttr()
+				attrs[string(key)] = string(value)
+				has[string(key)] = true
+			}
+			named := strings.TrimSpace(attrs["aria-label"]) != "" || attrs["aria-labelledby"] != "" || strings.TrimSpace(attrs["title"]) != ""
+
+			switch name {
+			case "html":
+				if strings.TrimSpace(attrs["lang"]) == "" {
+					report(start, "missing-lang", "<html> has no lang attribute")
+				}
+			case "img", "area":
+				if !has["alt"] && attrs["role"] != "presentation" && attrs["role"] != "none" && attrs["aria-hidden"] != "true" {
+					report(start, "image-alt", "<%s src=%q> has no alt attribute, use alt=\"\" for decoration", name, attrs["src"]+attrs["href"])
+				}
+				label(attrs["alt"])
+			case "input":
+				if strings.EqualFold(attrs["type"], "image") && !has["alt"] && !named {
+					report(start, "image-alt", "<input type=image> has no alt attribute")
+				}
+			case "svg":
+				if named && attrs["aria-hidden"] != "true" {
+					label(attrs["aria-label"] + attrs["title"])
+				}
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				level := int(name[1] - '0')
+				if lastHeading > 0 && level > lastHeading+1 {
+					report(start, "heading-skip", "<%s> follows <h%d>, skipping a level", name, lastHeading)
+				}
+				lastHeading = level
+			}
+			if named && !hidden() {
+				for _, e := range stack {
+					if e.name == "a" || e.name == "button" {
+						e.named = true
+					}
+				}
+			}
+
+			switch {
+			case voidElements[name] || tt == nethtml.SelfClosingTagToken:
+			case rawTextElements[name]:
+				raw = name
+			default:
+				// Links without href are placeholders, not links
+				if name == "a" && !has["href"] {
+					named = true
+				}
+				stack = append(stack, &a11yElement{name: name, offset: start, hidden: attrs["aria-hidden"] == "true" || has["hidden"], named: named})
+			}
+		}
+	}
+	return findings
+}
+
+// rawTextElements hold text up to their end tag, which the tokenizer reads as one text token
+var rawTextElements = setOf("iframe", "noembed", "noframes", "noscript", "plaintext", "script", "style", "textarea", "title", "xmp")
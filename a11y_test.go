@@ -0,0 +1,36 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAuditHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want []string
+	}{
+		{"clean", `<html lang="en"><h1>a</h1><h2>b</h2><a href="/">Home</a><img src="a.png" alt=""></html>`, nil},
+		{"missing lang", `<html><p>x</p></html>`, []string{"missing-lang"}},
+		{"image without alt", `<html lang="en"><img src="a.png"></html>`, []string{"image-alt"}},
+		{"heading skip", `<html lang="en"><h1>a</h1><h3>b</h3></html>`, []string{"heading-skip"}},
+		{"empty link", `<html lang="en"><a href="/"> </a><button></button></html>`, []string{"empty-link", "empty-button"}},
+		{"link named by an image", `<html lang="en"><a href="/"><img src="a.png" alt="Home"></a></html>`, nil},
+		{"link named by an svg title", `<html lang="en"><a href="/"><svg><title>Home</title></svg></a></html>`, nil},
+		{"link text with a reference", `<html lang="en"><a href="/">&amp;</a></html>`, nil},
+		{"script is not text", `<html lang="en"><a href="/"><script>var a = "<b>x</b>"</script></a></html>`, []string{"empty-link"}},
+		{"placeholder link", `<html lang="en"><a></a></html>`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range auditHTML(tt.html) {
+				got = append(got, f.rule)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("auditHTML() rules = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
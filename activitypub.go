@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ActivityPubConfig publishes the site as a read-only fediverse account, @<username>@<host>,
+// with a WebFinger document, an actor and an outbox of the latest posts:
+//
+//	[activityPub]
+//	enabled = true
+//	username = "blog"
+//	inbox = "https://relay.example.com/inbox/blog"
+//
+// A static site cannot receive activities, so following the account needs an inbox served
+// elsewhere. WebFinger is only found at the root of the host, so the base URL must not have a path.
+type ActivityPubConfig struct {
+	Enabled  bool   `toml:"enabled"`
+	Username string `toml:"username"`
+	// Name and Summary default to the site title and description
+	Name    string `toml:"name"`
+	Summary string `toml:"summary"`
+	// Icon is the URL of the avatar
+	Icon  string `toml:"icon"`
+	Inbox string `toml:"inbox"`
+	// PublicKey is a PEM file of the project with the public key of the actor, which servers
+	// need to verify activities delivered on its behalf
+	PublicKey string `toml:"publicKey"`
+	// Items is the number of posts in the outbox, 20 by default
+	Items int `toml:"items"`
+}
+
+const (
+	activityStreamsContext  = "https://www.w3.org/ns/activitystreams"
+	activityStreamsPublic   = "https://www.w3.org/ns/activitystreams#Public"
+	activityPubDir          = "activitypub"
+	defaultActivityPubItems = 20
+)
+
+type webFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases"`
+	Links   []webFingerLink `json:"links"`
+}
+
+type webFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type"`
+	Href string `json:"href"`
+}
+
+type activityPubActor struct {
+	Context           []string              `json:"@context"`
+	ID                string                `json:"id"`
+	Type              string                `json:"type"`
+	PreferredUsername string                `json:"preferredUsername"`
+	Name              string                `json:"name"`
+	Summary           string                `json:"summary,omitempty"`
+	URL               string                `json:"url"`
+	Inbox             string                `json:"inbox"`
+	Outbox            string                `json:"outbox"`
+	Icon              *activityPubImage     `json:"icon,omitempty"`
+	PublicKey         *activityPubPublicKey `json:"publicKey,omitempty"`
+}
+
+type activityPubImage struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type activityPubPublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type activityPubOutbox struct {
+	Context      string                `json:"@context"`
+	ID           string                `json:"id"`
+	Type         string                `json:"type"`
+	TotalItems   int                   `json:"totalItems"`
+	OrderedItems []activityPubActivity `json:"orderedItems"`
+}
+
+type activityPubActivity struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Actor     string            `json:"actor"`
+	Published string            `json:"published"`
+	To        []string          `json:"to"`
+	Object    activityPubObject `json:"object"`
+}
+
+type activityPubObject struct {
+	ID           string           `json:"id"`
+	Type         string           `json:"type"`
+	Name         string           `json:"name"`
+	Content      string           `json:"content"`
+	URL          string           `json:"url"`
+	AttributedTo string           `json:"attributedTo"`
+	Published    string           `json:"published"`
+	To           []string         `json:"to"`
+	Tag          []activityPubTag `json:"tag,omitempty"`
+}
+
+type activityPubTag struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Href string `json:"href,omitempty"`
+}
+
+// activityPubActorURL returns the id of the actor of the site
+func (s *Site) activityPubActorURL() string {
+	return s.AbsURL(activityPubDir + "/actor.json")
+}
+
+// renderActivityPub writes .well-known/webfinger and the actor and outbox of the site
+func (s *Site) renderActivityPub(outputDir string) error {
+	cfg := s.Config.ActivityPub
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Username == "" {
+		return fmt.Errorf("activityPub.username is not set")
+	}
+	base, err := url.Parse(s.BaseURL)
+	if err != nil || base.Host == "" {
+		return fmt.Errorf("activityPub needs an absolute baseURL")
+	}
+	if strings.Trim(base.Path, "/") != "" {
+		warnf("WebFinger is only found at the root of %s, not below %s", base.Host, base.Path)
+	}
+	actorURL := s.activityPubActorURL()
+	outboxURL := s.AbsURL(activityPubDir + "/outbox.json")
+
+	finger := webFinger{
+		Subject: "acct:" + cfg.Username + "@" + base.Host,
+		Aliases: []string{actorURL},
+		Links: []webFingerLink{
+			{Rel: "self", Type: "application/activity+json", Href: actorURL},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: s.AbsURL("/")},
+		},
+	}
+
+	actor := activityPubActor{
+		Context:           []string{activityStreamsContext, "https://w3id.org/security/v1"},
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: cfg.Username,
+		Name:              cfg.Name,
+		Summary:           cfg.Summary,
+		URL:               s.AbsURL("/"),
+		Inbox:             cfg.Inbox,
+		Outbox:            outboxURL,
+	}
+	if actor.Name == "" {
+		actor.Name = s.Title
+	}
+	if actor.Summary == "" {
+		actor.Summary = s.Description
+	}
+	if actor.Inbox == "" {
+		// Servers require an inbox; deliveries to this one fail
+		actor.Inbox = s.AbsURL(activityPubDir + "/inbox")
+	}
+	if cfg.Icon != "" {
+		icon := cfg.Icon
+		if !strings.Contains(icon, "://") {
+			icon = s.AbsURL(icon)
+		}
+		actor.Icon = &activityPubImage{Type: "Image", URL: icon}
+	}
+	if cfg.PublicKey != "" {
+		file, err := projectPath(cfg.PublicKey)
+		if err == nil {
+			err = checkReadPath(file)
+		}
+		var pem []byte
+		if err == nil {
+			pem, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read activityPub.publicKey: %w", err)
+		}
+		actor.PublicKey = &activityPubPublicKey{ID: actorURL + "#main-key", Owner: actorURL, PublicKeyPem: string(pem)}
+	}
+
+	items := cfg.Items
+	if items <= 0 {
+		items = defaultActivityPubItems
+	}
+	outbox := activityPubOutbox{
+		Context:      activityStreamsContext,
+		ID:           outboxURL,
+		Type:         "OrderedCollection",
+		OrderedItems: []activityPubActivity{},
+	}
+	for _, p := range s.Pages {
+		if len(outbox.OrderedItems) == items {
+			break
+		}
+		outbox.OrderedItems = append(outbox.OrderedItems, s.activityPubCreate(p, actorURL))
+	}
+	outbox.TotalItems = len(outbox.OrderedItems)
+
+	documents := map[string]any{
+		".well-known/webfinger":         finger,
+		activityPubDir + "/actor.json":  actor,
+		activityPubDir + "/outbox.json": outbox,
+	}
+	for name, doc := range documents {
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		dest := filepath.Join(outputDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// activityPubCreate returns the Create activity of a post, an Article holding its summary
+func (s *Site) activityPubCreate(p *Page, actorURL string) activityPubActivity {
+	published := p.Date.UTC().Format(time.RFC3339)
+	article := activityPubObject{
+		ID:           p.Permalink,
+		Type:         "Article",
+		Name:         p.Title,
+		Content:      fmt.Sprintf(`<p>%s</p><p><a href="%s">%s</a></p>`, html.EscapeString(p.Summary()), html.EscapeString(p.Permalink), html.EscapeString(p.Permalink)),
+		URL:          p.Permalink,
+		AttributedTo: actorURL,
+		Published:    published,
+		To:           []string{activityStreamsPublic},
+	}
+	for _, term := range s.Taxonomies["tags"] {
+		for _, tagged := range term.Pages {
+			if tagged == p {
+				article.Tag = append(article.Tag, activityPubTag{
+					Type: "Hashtag",
+					Name: "#" + strings.ReplaceAll(term.Name, " ", ""),
+					Href: term.Page.Permalink,
+				})
+			}
+		}
+	}
+	return activityPubActivity{
+		ID:        p.Permalink + "#create",
+		Type:      "Create",
+		Actor:     actorURL,
+		Published: published,
+		To:        []string{activityStreamsPublic},
+		Object:    article,
+	}
+}
+
+// activityPubHeaders returns the content types ActivityPub servers expect of the documents
+func (s *Site) activityPubHeaders() map[string]map[string]string {
+	if !s.Config.ActivityPub.Enabled {
+		return nil
+	}
+	return map[string]map[string]string{
+		s.RelURL(".well-known/webfinger"): {
+			"Content-Type":                "application/jrd+json",
+			"Access-Control-Allow-Origin": "*",
+		},
+		s.RelURL(activityPubDir + "/*"): {
+			"Content-Type":                "application/activity+json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ContentAdapter turns every entry of a data file into a page, without a Markdown file:
+//
+//	[[contentAdapters]]
+//	data = "data/products.yaml"
+//	section = "products"
+//	layout = "product"
+//	permalink = "/products/:slug/"
+//
+// An entry is front matter, so it has the params, dates, draft state and taxonomies of a
+// content file; its content key holds the Markdown body.
+type ContentAdapter struct {
+	// Data is a YAML, TOML or JSON file holding a list of entries
+	Data string `toml:"data"`
+	// List selects the list inside the file by dot-separated keys, e.g. "catalog.items"; the file
+	// itself is the list by default
+	List    string `toml:"list"`
+	Section string `toml:"section"`
+	Layout  string `toml:"layout"`
+	// Permalink is the URL of each page, with :section, :slug, :year, :month, :day and :key for
+	// any other key of the entry; /section/:slug/ by default
+	Permalink string `toml:"permalink"`
+	// Title, Content and Slug name the keys holding the title, the Markdown body and the slug;
+	// the slug defaults to that of the title
+	Title   string `toml:"title"`
+	Content string `toml:"content"`
+	Slug    string `toml:"slug"`
+}
+
+// permalinkPattern matches the placeholders of an adapter permalink
+var permalinkPattern = regexp.MustCompile(`:(\w+)`)
+
+// adapterContent returns a content file for every entry of the configured content adapters
+func adapterContent(config Config) ([]contentFile, error) {
+	var files []contentFile
+	for _, adapter := range config.ContentAdapters {
+		found, err := adapter.files(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read content adapter %s: %w", adapter.Data, err)
+		}
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// files reads the entries of the data file and renders each as a content file
+func (a ContentAdapter) files(config Config) ([]contentFile, error) {
+	if a.Data == "" {
+		return nil, fmt.Errorf("no data file set")
+	}
+	file, err := projectPath(a.Data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReadPath(file); err != nil {
+		return nil, err
+	}
+	entries, err := readAdapterEntries(file, a.List)
+	if err != nil {
+		return nil, err
+	}
+	title, body, slugKey := a.Title, a.Content, a.Slug
+	if title == "" {
+		title = "title"
+	}
+	if body == "" {
+		body = "content"
+	}
+	permalink := a.Permalink
+	if permalink == "" {
+		permalink = urlPath(a.Section, ":slug", "/")
+	}
+	// Slugs follow the slugs setting of the site
+	site := newSite(config)
+
+	var files []contentFile
+	seen := map[string]int{}
+	for i, entry := range entries {
+		frontMatter := map[string]any{}
+		for k, v := range entry {
+			if k != body {
+				frontMatter[k] = v
+			}
+		}
+		if t, ok := entry[title].(string); ok {
+			frontMatter["title"] = t
+		}
+		if _, ok := frontMatter["layout"]; !ok && a.Layout != "" {
+			frontMatter["layout"] = a.Layout
+		}
+		content, _ := entry[body].(string)
+
+		slug := fmt.Sprint(entry[slugKey])
+		if slugKey == "" || entry[slugKey] == nil {
+			slug, _ = frontMatter["title"].(string)
+		}
+		slug = site.slug(slug)
+		if slug == "" {
+			return nil, fmt.Errorf("entry %d has no title or slug", i+1)
+		}
+		// Entries sharing a title still get a page each
+		if n := seen[slug]; n > 0 {
+			seen[slug]++
+			slug = fmt.Sprintf("%s-%d", slug, n+1)
+		} else {
+			seen[slug] = 1
+		}
+
+		meta, err := yaml.Marshal(frontMatter)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		files = append(files, contentFile{
+			Path:      file,
+			RelPath:   filepath.Join(filepath.FromSlash(a.Section), slug+".md"),
+			data:      []byte("---\n" + string(meta) + "---\n" + content),
+			permalink: expandPermalink(site, permalink, a.Section, slug, entry),
+		})
+	}
+	return files, nil
+}
+
+// readAdapterEntries decodes the data file and returns the list of entries at the key path
+func readAdapterEntries(file, list string) ([]map[string]any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &v)
+	case ".toml":
+		err = toml.Unmarshal(data, &v)
+	case ".json":
+		err = json.Unmarshal(data, &v)
+	default:
+		return nil, fmt.Errorf("unsupported data file type %s", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if list != "" {
+		for _, key := range strings.Split(list, ".") {
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("list %q not found", list)
+			}
+			v = m[key]
+		}
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a list of entries, set list to the key holding them")
+	}
+	entries := make([]map[string]any, 0, len(items))
+	for i, item := range items {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("entry %d is not a table", i+1)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// expandPermalink replaces the placeholders of an adapter permalink for one entry
+func expandPermalink(s *Site, pattern, section, slug string, entry map[string]any) string {
+	date := toTime(entry["date"])
+	expanded := permalinkPattern.ReplaceAllStringFunc(pattern, func(m string) string {
+		switch key := m[1:]; key {
+		case "section":
+			return section
+		case "slug":
+			return slug
+		case "year", "month", "day":
+			if date.IsZero() {
+				return ""
+			}
+			return date.Format(map[string]string{"year": "2006", "month": "01", "day": "02"}[key])
+		default:
+			if v, ok := entry[key]; ok && v != nil {
+				return s.slug(fmt.Sprint(v))
+			}
+			return ""
+		}
+	})
+	// Empty placeholders leave no empty path segments; a URL without extension is a directory
+	if path.Ext(expanded) == "" {
+		expanded += "/"
+	}
+	return urlPath(expanded)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html"
+	"html/template"
+	"image"
+	_ "image/gif"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// AMPConfig configures the AMP variant of pages, written below public/amp/ from the amp.html
+// layout of the site or theme, or a built-in one. Pages link their variant with rel="amphtml" and
+// variants link back with rel="canonical".
+type AMPConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Sections limits the variants to pages of these sections; front matter `amp: false` opts a
+	// page out and `amp: true` opts one in
+	Sections []string `toml:"sections"`
+}
+
+// ampFallbackHeight is the height of images whose size is unknown, which AMP requires
+const ampFallbackHeight = 300
+
+//go:embed embedded/amp.html
+var defaultAMPTemplate string
+
+var (
+	// ampScriptPattern matches script elements, which AMP pages may not carry except for JSON-LD
+	ampScriptPattern = regexp.MustCompile(`(?is)<script\b([^>]*)>.*?</script>`)
+	// ampStylePattern matches style elements, as the styles of AMP pages live in the head
+	ampStylePattern = regexp.MustCompile(`(?is)<style\b[^>]*>.*?</style>`)
+	// ampPicturePattern matches picture elements with the img inside them
+	ampPicturePattern = regexp.MustCompile(`(?is)<picture\b[^>]*>.*?(<img\b[^>]*>).*?</picture>`)
+	// ampImagePattern matches img elements
+	ampImagePattern = regexp.MustCompile(`(?i)<img\b([^>]*?)\s*/?>`)
+	// ampIframePattern matches iframes with their source
+	ampIframePattern = regexp.MustCompile(`(?is)<iframe\b[^>]*?\bsrc="([^"]*)"[^>]*>.*?</iframe>`)
+	// ampEventPattern matches inline event handlers
+	ampEventPattern = regexp.MustCompile(`(?i)\s+on[a-z]+="[^"]*"`)
+	// ampAttrPattern matches an attribute with a quoted value
+	ampAttrPattern = regexp.MustCompile(`([a-zA-Z-]+)="([^"]*)"`)
+)
+
+// ampEnabled reports whether the page gets an AMP variant
+func (s *Site) ampEnabled(p *Page) bool {
+	cfg := s.Config.Outputs.AMP
+	if !cfg.Enabled || p.Kind != KindPage {
+		return false
+	}
+	if enabled, ok := p.Params["amp"].(bool); ok {
+		return enabled
+	}
+	return len(cfg.Sections) == 0 || slices.Contains(cfg.Sections, p.Section)
+}
+
+// ampPath returns the slash-separated output path of the AMP variant of a page
+func ampPath(p *Page) string {
+	return path.Join("amp", filepath.ToSlash(p.outputPath))
+}
+
+// ampHook returns the page hook that converts the content of pages with an AMP variant into
+// AMPContent and sets their AMPLink, or nil when AMP is disabled
+func (s *Site) ampHook() pageHook {
+	if !s.Config.Outputs.AMP.Enabled {
+		return nil
+	}
+	return func(p *Page) error {
+		if !s.ampEnabled(p) {
+			return nil
+		}
+		p.AMPLink = s.AbsURL(ampPath(p))
+		p.AMPContent = template.HTML(s.ampContent(p, string(p.Content)))
+		return nil
+	}
+}
+
+// ampContent rewrites content into AMP HTML: links become absolute as the variant lives below
+// amp/, scripts, styles and event handlers are removed, images become amp-img and iframes links
+func (s *Site) ampContent(p *Page, content string) string {
+	content = absoluteLinks(content, p.Permalink)
+	content = ampScriptPattern.ReplaceAllStringFunc(content, func(script string) string {
+		if strings.Contains(strings.ToLower(ampScriptPattern.FindStringSubmatch(script)[1]), "application/ld+json") {
+			return script
+		}
+		return ""
+	})
+	content = ampStylePattern.ReplaceAllString(content, "")
+	content = ampEventPattern.ReplaceAllString(content, "")
+	content = ampPicturePattern.ReplaceAllString(content, "$1")
+	content = ampIframePattern.ReplaceAllStringFunc(content, func(iframe string) string {
+		src := ampIframePattern.FindStringSubmatch(iframe)[1]
+		return fmt.Sprintf(`<p><a href="%s">%s</a></p>`, src, src)
+	})
+	return ampImagePattern.ReplaceAllStringFunc(content, func(img string) string {
+		return s.ampImage(p, ampImagePattern.FindStringSubmatch(img)[1])
+	})
+}
+
+// ampImage returns the amp-img element of the attributes of an img. AMP needs the size of every
+// image; it is read from local files when the img has none.
+func (s *Site) ampImage(p *Page, attrs string) string {
+	values := map[string]string{}
+	var out strings.Builder
+	out.WriteString("<amp-img")
+	for _, m := range ampAttrPattern.FindAllStringSubmatch(attrs, -1) {
+		name := strings.ToLower(m[1])
+		switch name {
+		case "loading", "decoding", "style":
+			// amp-img loads lazily and is styled by the page
+			continue
+		}
+		values[name] = m[2]
+		if name != "width" && name != "height" {
+			fmt.Fprintf(&out, ` %s="%s"`, name, m[2])
+		}
+	}
+	width, height := values["width"], values["height"]
+	if width == "" || height == "" {
+		if config, ok := s.imageSize(p, html.UnescapeString(values["src"])); ok {
+			width, height = fmt.Sprint(config.Width), fmt.Sprint(config.Height)
+		}
+	}
+	if width != "" && height != "" {
+		fmt.Fprintf(&out, ` width="%s" height="%s" layout="responsive"`, width, height)
+	} else {
+		warnf("Unknown size of image %s in %s, using a height of %dpx for AMP", values["src"], p.File.Path, ampFallbackHeight)
+		fmt.Fprintf(&out, ` height="%d" layout="fixed-height"`, ampFallbackHeight)
+	}
+	out.WriteString("></amp-img>")
+	return out.String()
+}
+
+// imageSize decodes the size of a local image referenced by the content of a page
+func (s *Site) imageSize(p *Page, src string) (image.Config, bool) {
+	base, err := url.Parse(p.Permalink)
+	if err != nil {
+		return image.Config{}, false
+	}
+	ref, err := base.Parse(src)
+	if err != nil {
+		return image.Config{}, false
+	}
+	file := s.localImage(p, ref.String())
+	if file == "" || checkReadPath(file) != nil {
+		return image.Config{}, false
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return image.Config{}, false
+	}
+	defer f.Close()
+	config, _, err := image.DecodeConfig(f)
+	return config, err == nil
+}
+
+// renderAMPPages writes the AMP variant of every page with AMPContent and returns the number
+// written; unchanged variants are not rewritten
+func (s *Site) renderAMPPages(outputDir string, templates *TemplateCache) int {
+	if !s.Config.Outputs.AMP.Enabled {
+		return 0
+	}
+	tmpl, err := variantTemplate(templates, "amp.html", defaultAMPTemplate)
+	if err != nil {
+		warnf("%v", err)
+		return 0
+	}
+	written := 0
+	for _, page := range s.contentPages() {
+		if page.AMPLink == "" {
+			continue
+		}
+		if s.ctx.Err() != nil {
+			break
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, page); err != nil {
+			warnf("Failed to render AMP variant of %s: %v", page.RelPermalink, err)
+			continue
+		}
+		dest, err := outputFile(outputDir, ampPath(page))
+		if err != nil {
+			warnf("Skipping AMP variant of %s: %v", page.RelPermalink, err)
+			continue
+		}
+		if err := writeVariant(dest, buf.Bytes()); err != nil {
+			warnf("Failed to write AMP variant of %s: %v", page.RelPermalink, err)
+			continue
+		}
+		written++
+	}
+	return written
+}
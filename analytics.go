@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// AnalyticsConfig configures the providers injected by the _internal/analytics.html partial
+type AnalyticsConfig struct {
+	// Environments the scripts are injected in; production when empty
+	Environments []string `toml:"environments"`
+	// RespectDoNotTrack skips loading the scripts for visitors sending Do Not Track or Global
+	// Privacy Control
+	RespectDoNotTrack bool `toml:"respectDoNotTrack"`
+
+	GoatCounter GoatCounterConfig `toml:"goatcounter"`
+	Plausible   PlausibleConfig   `toml:"plausible"`
+	GA4         GA4Config         `toml:"ga4"`
+}
+
+// GoatCounterConfig names the GoatCounter site by its code, or by the URL of a self-hosted instance
+type GoatCounterConfig struct {
+	Code string `toml:"code"`
+	URL  string `toml:"url"`
+}
+
+// Endpoint returns the count URL of the site, or "" when GoatCounter is not configured
+func (c GoatCounterConfig) Endpoint() string {
+	switch {
+	case c.URL != "":
+		return c.URL
+	case c.Code != "":
+		return fmt.Sprintf("https://%s.goatcounter.com/count", c.Code)
+	}
+	return ""
+}
+
+// PlausibleConfig names the Plausible domain; Script replaces the hosted script for proxies or
+// self-hosted instances
+type PlausibleConfig struct {
+	Domain string `toml:"domain"`
+	Script string `toml:"script"`
+}
+
+// ScriptURL returns the script to load, the hosted one unless Script is set
+func (c PlausibleConfig) ScriptURL() string {
+	if c.Script != "" {
+		return c.Script
+	}
+	return "https://plausible.io/js/script.js"
+}
+
+// GA4Config holds the Google Analytics 4 measurement ID (G-XXXXXXX)
+type GA4Config struct {
+	MeasurementID string `toml:"measurementID"`
+}
+
+// AnalyticsEnabled reports whether a provider is configured and the build environment is one
+// the scripts are injected in
+func (s *Site) AnalyticsEnabled() bool {
+	cfg := s.Config.Analytics
+	if cfg.GoatCounter.Endpoint() == "" && cfg.Plausible.Domain == "" && cfg.GA4.MeasurementID == "" {
+		return false
+	}
+	environments := cfg.Environments
+	if len(environments) == 0 {
+		environments = []string{"production"}
+	}
+	return slices.Contains(environments, s.Hero.Environment)
+}
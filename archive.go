@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// KindArchive is the kind of the generated year and month archive pages
+const KindArchive = "archive"
+
+// ArchivePeriod describes the year, or year and month, of an archive page
+type ArchivePeriod struct {
+	Year  int
+	Month time.Month
+
+	// Months lists the month archive pages of a year archive, newest first
+	Months []*Page
+}
+
+// IsYear reports whether the archive covers a whole year
+func (a *ArchivePeriod) IsYear() bool {
+	return a.Month == 0
+}
+
+// buildArchives creates /YYYY/ and /YYYY/MM/ list pages for every dated page.
+// The year pages are exposed as .Site.Archives, newest first.
+func (s *Site) buildArchives() {
+	years := map[int]*Page{}
+	months := map[string]*Page{}
+	for _, p := range s.Pages {
+		if p.Date.IsZero() {
+			continue
+		}
+		year, month := p.Date.Year(), p.Date.Month()
+		yearPage, ok := years[year]
+		if !ok {
+			yearPage = &Page{
+				Site:    s,
+				Kind:    KindArchive,
+				Title:   fmt.Sprint(year),
+				Params:  map[string]any{},
+				Archive: &ArchivePeriod{Year: year},
+			}
+			s.setURL(yearPage, fmt.Sprintf("/%d/", year))
+			years[year] = yearPage
+		}
+		yearPage.Pages = append(yearPage.Pages, p)
+
+		key := fmt.Sprintf("%d/%02d", year, month)
+		monthPage, ok := months[key]
+		if !ok {
+			monthPage = &Page{
+				Site:    s,
+				Kind:    KindArchive,
+				Title:   fmt.Sprintf("%s %d", month, year),
+				Params:  map[string]any{},
+				Archive: &ArchivePeriod{Year: year, Month: month},
+			}
+			s.setURL(monthPage, urlPath(key, "/"))
+			monthPage.parent = yearPage
+			months[key] = monthPage
+			yearPage.Archive.Months = append(yearPage.Archive.Months, monthPage)
+		}
+		monthPage.Pages = append(monthPage.Pages, p)
+	}
+
+	for _, yearPage := range years {
+		s.Archives = append(s.Archives, yearPage)
+		s.AllPages = append(s.AllPages, yearPage)
+		s.AllPages = append(s.AllPages, yearPage.Archive.Months...)
+	}
+	sort.Slice(s.Archives, func(i, j int) bool { return s.Archives[i].Archive.Year > s.Archives[j].Archive.Year })
+}
+
+type opmlDocument struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Created string        `xml:"head>dateCreated"`
+	Outline []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Text    string `xml:"text,attr"`
+	Title   string `xml:"title,attr"`
+	Type    string `xml:"type,attr"`
+	XMLURL  string `xml:"xmlUrl,attr"`
+	HTMLURL string `xml:"htmlUrl,attr"`
+}
+
+// renderOPML writes feeds.opml listing the RSS feed of the site and of every section
+func (s *Site) renderOPML(outputDir string) error {
+	doc := opmlDocument{Version: "2.0", Title: s.Title, Created: s.Hero.BuildDate.Format(time.RFC1123Z)}
+	for _, list := range s.feedPages() {
+		title := s.Title
+		if !list.IsHome() {
+			title = list.Title + " on " + s.Title
+		}
+		doc.Outline = append(doc.Outline, opmlOutline{Text: title, Title: title, Type: "rss", XMLURL: list.RSSLink, HTMLURL: list.Permalink})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode OPML: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "feeds.opml"), append([]byte(xml.Header), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write feeds.opml: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+// ArchiveRule moves matching pages into the archived state: they are still rendered and listed
+// in their section and taxonomies, but left out of the home page list and the feeds, and
+// templates can mark them with .IsArchived. Every condition that is set must hold, e.g.
+//
+//	[[archiveRules]]
+//	section = "news"
+//	olderThanYears = 2
+//
+// The `archived` front matter overrides the rules for a page.
+type ArchiveRule struct {
+	// OlderThanYears matches pages dated more than this many years before the build
+	OlderThanYears int `toml:"olderThanYears"`
+	// Section matches the pages of a section
+	Section string `toml:"section"`
+	// Path is a glob over content paths, matching a file or a directory above it, e.g. "blog/2019*"
+	Path string `toml:"path"`
+}
+
+// matches reports whether the rule archives the page at the build time of its site
+func (r ArchiveRule) matches(p *Page) bool {
+	if r.OlderThanYears == 0 && r.Section == "" && r.Path == "" {
+		return false
+	}
+	if r.OlderThanYears > 0 {
+		cutoff := p.Site.Hero.BuildDate.AddDate(-r.OlderThanYears, 0, 0)
+		if p.Date.IsZero() || !p.Date.Before(cutoff) {
+			return false
+		}
+	}
+	if r.Section != "" && p.Section != r.Section {
+		return false
+	}
+	if r.Path != "" && (p.File == nil || !matchContentPattern(r.Path, p.File.Path)) {
+		return false
+	}
+	return true
+}
+
+// IsArchived reports whether an archive rule or the archived front matter archived the page
+func (p *Page) IsArchived() bool {
+	return p.archived
+}
+
+// applyArchiveRules marks the archived pages among the regular pages
+func (s *Site) applyArchiveRules() {
+	for _, p := range s.Pages {
+		if archived, ok := p.Params["archived"].(bool); ok {
+			p.archived = archived
+			continue
+		}
+		for _, rule := range s.Config.ArchiveRules {
+			if rule.matches(p) {
+				p.archived = true
+				break
+			}
+		}
+	}
+}
+
+// currentPages returns the pages that are not archived, for the home page list and the feeds
+func currentPages(pages []*Page) []*Page {
+	var current []*Page
+	for _, p := range pages {
+		if !p.archived {
+			current = append(current, p)
+		}
+	}
+	return current
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// artifactManifestName is the manifest of every output file, written by build.manifest
+const artifactManifestName = ".manifest.json"
+
+// writeArtifactManifest writes public/.manifest.json with the size and SHA-256 of every output
+// file and where it came from: the content file and templates of pages, and the source of static
+// files and fingerprinted assets. It runs last, so compressed copies are listed too. The manifest
+// has the format of `diff --save` and can be given to `diff --manifest`. It is published with the
+// site, so the files of unlisted pages are left out to keep their URLs and sources private.
+func (s *Site) writeArtifactManifest(outputDir string) error {
+	files, err := scanOutput(outputDir)
+	if err != nil {
+		return err
+	}
+	delete(files, artifactManifestName)
+
+	provenance := s.outputProvenance()
+	manifest := outputManifest{Generator: "herocgo " + version, Files: make([]outputFileInfo, 0, len(files))}
+	if dir := s.Config.Unlisted.dir(); dropUnlistedOutputs(files, dir) {
+		manifest.Unlisted = dir
+	}
+	for name, f := range files {
+		origin, ok := provenance[name]
+		if !ok {
+			// Compressed copies come from the file they compress
+			for _, ext := range compressExtensions {
+				if base, cut := strings.CutSuffix(name, ext); cut {
+					origin, ok = provenance[base]
+				}
+			}
+		}
+		if !ok {
+			if source := s.staticFile(name); source != "" {
+				origin.Source = projectRelative(source)
+			}
+		}
+		f.Source, f.Templates = origin.Source, origin.Templates
+		manifest.Files = append(manifest.Files, f)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.writeOutput(outputDir, artifactManifestName, append(data, '\n'))
+}
+
+// outputProvenance returns the sources of the output files of pages, their variants and the
+// fingerprinted assets, by slash-separated output path
+func (s *Site) outputProvenance() map[string]outputFileInfo {
+	provenance := map[string]outputFileInfo{}
+	templates := map[*Page]map[string]bool{}
+	if tc := s.templates; tc != nil {
+		tc.mu.Lock()
+		for layout, pages := range tc.pages {
+			for _, p := range pages {
+				if templates[p] == nil {
+					templates[p] = map[string]bool{}
+				}
+				for name := range tc.deps[layout] {
+					templates[p][name] = true
+				}
+			}
+		}
+		tc.mu.Unlock()
+	}
+	for p, names := range templates {
+		provenance[filepath.ToSlash(p.outputPath)] = outputFileInfo{Source: pageSource(p), Templates: sortedKeys(names)}
+	}
+	for _, p := range s.printPages() {
+		provenance[printPath(p)] = outputFileInfo{Source: pageSource(p), Templates: []string{"print.html"}}
+	}
+	for _, p := range s.contentPages() {
+		if p.AMPLink != "" {
+			provenance[ampPath(p)] = outputFileInfo{Source: pageSource(p), Templates: []string{"amp.html"}}
+		}
+	}
+	for _, asset := range s.assets.assets {
+		provenance[asset.name] = outputFileInfo{Source: projectRelative(asset.source)}
+	}
+	return provenance
+}
+
+// pageSource returns the content file of a page, or "" for generated pages such as taxonomies
+func pageSource(p *Page) string {
+	if p.File == nil {
+		return ""
+	}
+	return projectRelative(p.File.Filename)
+}
+
+// projectRelative returns a file path relative to the project directory with forward slashes,
+// or "" when it lies outside the project, as the published manifest must not reveal the layout
+// of the machine that built it
+func projectRelative(file string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil || !within(wd, abs) {
+		return ""
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
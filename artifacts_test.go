@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testUnlistedHash is the hash directory of an unlisted page in the tests
+const testUnlistedHash = "0123456789abcdef0123"
+
+// writeTestOutput writes an output directory with a file of the same contents for every name
+func writeTestOutput(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("contents of "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDropUnlistedOutputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		files   []string
+		want    []string
+		dropped bool
+	}{
+		{
+			name:  "no unlisted pages",
+			dir:   "unlisted",
+			files: []string{"index.html", "posts/a/index.html"},
+			want:  []string{"index.html", "posts/a/index.html"},
+		},
+		{
+			name: "page, resources and variants",
+			dir:  "unlisted",
+			files: []string{
+				"index.html",
+				"unlisted/" + testUnlistedHash + "/index.html",
+				"unlisted/" + testUnlistedHash + "/photo.jpg",
+				"unlisted/" + testUnlistedHash + "/index.html.gz",
+				"print/unlisted/" + testUnlistedHash + "/index.html",
+				"amp/unlisted/" + testUnlistedHash + "/index.html",
+				"og/unlisted/" + testUnlistedHash + "/index.1234.png",
+			},
+			want:    []string{"index.html"},
+			dropped: true,
+		},
+		{
+			name:    "configured directory",
+			dir:     "drafts/review",
+			files:   []string{"drafts/review/" + testUnlistedHash + "/index.html", "unlisted/" + testUnlistedHash + "x/index.html"},
+			want:    []string{"unlisted/" + testUnlistedHash + "x/index.html"},
+			dropped: true,
+		},
+		{
+			name:  "directories that are not hashes",
+			dir:   "unlisted",
+			files: []string{"unlisted/index.html", "unlisted/about/index.html", "unlisted/0123456789ABCDEF0123/index.html"},
+			want:  []string{"unlisted/0123456789ABCDEF0123/index.html", "unlisted/about/index.html", "unlisted/index.html"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]outputFileInfo{}
+			for _, name := range tt.files {
+				files[name] = outputFileInfo{Path: name}
+			}
+			dropped := dropUnlistedOutputs(files, tt.dir)
+			got := slices.Sorted(maps.Keys(files))
+			if dropped != tt.dropped || !slices.Equal(got, tt.want) {
+				t.Errorf("dropUnlistedOutputs() = %v, %v; want %v, %v", got, dropped, tt.want, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestWriteArtifactManifest(t *testing.T) {
+	dir := writeTestOutput(t,
+		"index.html",
+		"index.html.gz",
+		"style.css",
+		"unlisted/"+testUnlistedHash+"/index.html",
+		"print/unlisted/"+testUnlistedHash+"/index.html",
+	)
+	s := newSite(Config{})
+	if err := s.writeArtifactManifest(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, artifactManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), testUnlistedHash) {
+		t.Errorf("manifest lists the files of an unlisted page:\n%s", data)
+	}
+	var manifest outputManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range manifest.Files {
+		paths = append(paths, f.Path)
+		if len(f.SHA256) != 64 || f.Size == 0 {
+			t.Errorf("%s has size %d and hash %q", f.Path, f.Size, f.SHA256)
+		}
+	}
+	if want := []string{"index.html", "index.html.gz", "style.css"}; !slices.Equal(paths, want) {
+		t.Errorf("manifest files = %v; want %v", paths, want)
+	}
+	if manifest.Unlisted != "unlisted" {
+		t.Errorf("manifest unlisted = %q; want %q", manifest.Unlisted, "unlisted")
+	}
+
+	// The manifest of a directory describes it exactly, apart from the unlisted files
+	current, err := scanOutput(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(current, artifactManifestName)
+	dropUnlistedOutputs(current, manifest.Unlisted)
+	listed, err := loadOutputManifest(filepath.Join(dir, artifactManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := compareOutputs(listed, current); len(changes) > 0 {
+		t.Errorf("output does not match its manifest: %v", changes)
+	}
+}
+
+func TestProjectRelative(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"content/post.md", "content/post.md"},
+		{filepath.Join(wd, "static", "a.css"), "static/a.css"},
+		{filepath.Join(filepath.Dir(wd), "elsewhere", "a.css"), ""},
+		{"../theme/static/a.css", ""},
+		{t.TempDir(), ""},
+	}
+	for _, tt := range tests {
+		if got := projectRelative(tt.file); got != tt.want {
+			t.Errorf("projectRelative(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// authorsDir holds one profile per author, named by the ID used in front matter
+const authorsDir = "data/authors"
+
+// authorsTaxonomy is the URL path and taxonomy name of the author pages
+const authorsTaxonomy = "authors"
+
+// Author is a contributor, from a profile in data/authors or just the name used in front matter
+type Author struct {
+	ID      string
+	Name    string
+	Bio     template.HTML
+	Avatar  string
+	Email   string
+	Website string
+	// Social maps a network such as mastodon or github to the profile URL
+	Social map[string]string
+	Params map[string]any
+
+	// Pages are the pages written by the author, Page the generated author page
+	Pages []*Page
+	Page  *Page
+}
+
+// Permalink returns the absolute URL of the author page
+func (a *Author) Permalink() string {
+	return a.Page.Permalink
+}
+
+// RelPermalink returns the site-relative URL of the author page
+func (a *Author) RelPermalink() string {
+	return a.Page.RelPermalink
+}
+
+// Authors returns the authors of the page from the authors (or author) front matter; on an
+// author page it holds the author
+func (p *Page) Authors() []*Author {
+	return p.authors
+}
+
+// Author returns the first author of the page, or nil
+func (p *Page) Author() *Author {
+	if len(p.authors) == 0 {
+		return nil
+	}
+	return p.authors[0]
+}
+
+// buildAuthors reads the author profiles, attaches the authors to their pages and creates the
+// author list and detail pages under /authors/
+func (s *Site) buildAuthors() error {
+	authors := map[string]*Author{}
+	entries, err := os.ReadDir(authorsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read authors: %w", err)
+	}
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".toml" && ext != ".json") {
+			continue
+		}
+		author, err := s.readAuthor(filepath.Join(authorsDir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		authors[author.ID] = author
+	}
+	byName := map[string]*Author{}
+	for _, author := range authors {
+		byName[strings.ToLower(author.Name)] = author
+	}
+
+	for _, p := range s.Pages {
+		names := toStringSlice(p.Params["authors"])
+		if len(names) == 0 {
+			names = toStringSlice(p.Params["author"])
+		}
+		for _, name := range names {
+			author, ok := authors[name]
+			if !ok {
+				author, ok = byName[strings.ToLower(name)]
+			}
+			if !ok {
+				// Other names are matched by slug, so "Jane Doe" also finds jane-doe.yaml
+				id := s.slug(name)
+				if author, ok = authors[id]; !ok {
+					if len(entries) > 0 {
+						warnf("No profile in %s for author %q of %s", authorsDir, name, p.RelPermalink)
+					}
+					author = &Author{ID: id, Name: name, Params: map[string]any{}}
+					authors[id] = author
+				}
+			}
+			author.Pages = append(author.Pages, p)
+			p.authors = append(p.authors, author)
+		}
+	}
+	if len(authors) == 0 {
+		return nil
+	}
+
+	terms := make([]*Term, 0, len(authors))
+	for _, author := range authors {
+		author.Page = &Page{
+			Site:        s,
+			Kind:        KindTerm,
+			Title:       author.Name,
+			Description: plainify(string(author.Bio)),
+			Params:      author.Params,
+			Pages:       author.Pages,
+			Taxonomy:    authorsTaxonomy,
+			Term:        author.Name,
+			authors:     []*Author{author},
+		}
+		s.setURL(author.Page, urlPath(authorsTaxonomy, s.slug(author.ID), "/"))
+		s.Authors = append(s.Authors, author)
+		terms = append(terms, &Term{Name: author.Name, Slug: s.slug(author.ID), Pages: author.Pages, Page: author.Page})
+	}
+	sort.Slice(s.Authors, func(i, j int) bool { return s.Authors[i].Name < s.Authors[j].Name })
+	sort.Slice(terms, func(i, j int) bool { return terms[i].Name < terms[j].Name })
+	s.Taxonomies[authorsTaxonomy] = terms
+
+	list := &Page{
+		Site:     s,
+		Kind:     KindTaxonomy,
+		Title:    titleCase(authorsTaxonomy),
+		Params:   map[string]any{},
+		Taxonomy: authorsTaxonomy,
+		Terms:    terms,
+	}
+	s.setURL(list, urlPath(authorsTaxonomy, "/"))
+	s.AllPages = append(s.AllPages, list)
+	for _, author := range s.Authors {
+		author.Page.parent = list
+		s.AllPages = append(s.AllPages, author.Page)
+	}
+	return nil
+}
+
+// readAuthor reads an author profile; fields other than the known ones are kept in Params
+func (s *Site) readAuthor(file string) (*Author, error) {
+	fields, err := readDataFile(file)
+	if err != nil {
+		return nil, err
+	}
+	text := func(name string) string {
+		v, _ := fields[name].(string)
+		delete(fields, name)
+		return v
+	}
+	author := &Author{
+		ID:      strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
+		Name:    text("name"),
+		Avatar:  text("avatar"),
+		Email:   text("email"),
+		Website: text("website"),
+		Social:  map[string]string{},
+	}
+	if author.Name == "" {
+		author.Name = author.ID
+	}
+	bio, err := convertMarkdownToHTML([]byte(text("bio")))
+	if err != nil {
+		return nil, err
+	}
+	author.Bio = template.HTML(bio)
+	if author.Avatar == "" && author.Email != "" {
+		author.Avatar = gravatarURL(author.Email)
+	} else if author.Avatar != "" && !strings.Contains(author.Avatar, "://") {
+		author.Avatar = s.RelURL(author.Avatar)
+	}
+	if social, ok := fields["social"].(map[string]any); ok {
+		for network, link := range social {
+			author.Social[network] = fmt.Sprint(link)
+		}
+		delete(fields, "social")
+	}
+	author.Params = fields
+	return author, nil
+}
+
+// readDataFile parses a YAML, TOML or JSON data file into a map
+func readDataFile(file string) (map[string]any, error) {
+	if err := checkReadPath(file); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]any{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = json.Unmarshal(data, &fields)
+	case ".toml":
+		err = toml.Unmarshal(data, &fields)
+	default:
+		err = yaml.Unmarshal(data, &fields)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return fields, nil
+}
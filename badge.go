@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// badgeColors are the named colors of shields.io badges
+var badgeColors = map[string]string{
+	"brightgreen":   "#4c1",
+	"green":         "#97ca00",
+	"yellowgreen":   "#a4a61d",
+	"yellow":        "#dfb317",
+	"orange":        "#fe7d37",
+	"red":           "#e05d44",
+	"blue":          "#007ec6",
+	"lightgrey":     "#9f9f9f",
+	"grey":          "#555",
+	"gray":          "#555",
+	"success":       "#4c1",
+	"important":     "#fe7d37",
+	"critical":      "#e05d44",
+	"informational": "#007ec6",
+	"inactive":      "#9f9f9f",
+}
+
+// badgeSVG is the badge template function, returning an inline shields-style badge such as
+// {{ badge "build" "passing" "brightgreen" }}. The color is a shields.io name or a CSS color,
+// blue by default.
+func badgeSVG(label, message string, color ...string) (template.HTML, error) {
+	if len(color) > 1 {
+		return "", fmt.Errorf("badge takes a label, a message and at most one color")
+	}
+	fill := badgeColors["blue"]
+	if len(color) == 1 && color[0] != "" {
+		fill = color[0]
+		if named, ok := badgeColors[strings.ToLower(fill)]; ok {
+			fill = named
+		} else if !iconColorPattern.MatchString(fill) {
+			return "", fmt.Errorf("invalid badge color %q", fill)
+		}
+	}
+
+	// Each side has 5px of padding around its text
+	left := badgeTextWidth(label) + 10
+	right := badgeTextWidth(message) + 10
+	width := left + right
+	text := func(x int, s string) string {
+		s = template.HTMLEscapeString(s)
+		return fmt.Sprintf(`<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, x, s, x, s)
+	}
+	title := template.HTMLEscapeString(label + ": " + message)
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" class="badge" width="%d" height="20" viewBox="0 0 %d 20" role="img" aria-label="%s"><title>%s</title>`+
+		`<path fill="#555" d="M3 0h%dv20H3a3 3 0 0 1-3-3V3a3 3 0 0 1 3-3z"/><path fill="%s" d="M%d 0h%da3 3 0 0 1 3 3v14a3 3 0 0 1-3 3H%dz"/>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">%s%s</g></svg>`,
+		width, width, title, title, left-3, template.HTMLEscapeString(fill), left, right-3, left, text(left/2, label), text(left+right/2, message))
+	return template.HTML(svg), nil
+}
+
+// badgeTextWidth estimates the width in pixels of text in 11px Verdana
+func badgeTextWidth(s string) int {
+	width := 0.0
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("il.,:;|!'", r):
+			width += 3.5
+		case strings.ContainsRune("fjrtI()[] ", r):
+			width += 4.5
+		case strings.ContainsRune("mwMW@%", r):
+			width += 10.5
+		case r >= 'A' && r <= 'Z':
+			width += 7.5
+		case r >= '0' && r <= '9':
+			width += 7
+		case r < 128:
+			width += 6.5
+		default:
+			// Wide characters such as CJK take about a full em
+			width += 11
+		}
+	}
+	return int(width + 0.5)
+}
@@ -0,0 +1,51 @@
+package main
+
+// Parent returns the page the page is listed under: its section for regular pages, the
+// taxonomy for terms, the year for month archives and the home page otherwise; nil on the home page
+func (p *Page) Parent() *Page {
+	if p.Kind == KindHome {
+		return nil
+	}
+	if p.parent != nil {
+		return p.parent
+	}
+	return p.Site.Home
+}
+
+// Ancestors returns the parents of the page, nearest first and ending with the home page
+func (p *Page) Ancestors() []*Page {
+	var ancestors []*Page
+	for parent := p.Parent(); parent != nil; parent = parent.Parent() {
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+// Breadcrumbs returns the trail from the home page down to the page itself
+func (p *Page) Breadcrumbs() []*Page {
+	ancestors := p.Ancestors()
+	crumbs := make([]*Page, 0, len(ancestors)+1)
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		crumbs = append(crumbs, ancestors[i])
+	}
+	return append(crumbs, p)
+}
+
+// BreadcrumbList returns the schema.org BreadcrumbList of the breadcrumbs; templates print it
+// inside <script type="application/ld+json">, which encodes it as JSON
+func (p *Page) BreadcrumbList() map[string]any {
+	var items []map[string]any
+	for i, crumb := range p.Breadcrumbs() {
+		items = append(items, map[string]any{
+			"@type":    "ListItem",
+			"position": i + 1,
+			"name":     crumb.Title,
+			"item":     crumb.Permalink,
+		})
+	}
+	return map[string]any{
+		"@context":        "https://schema.org",
+		"@type":           "BreadcrumbList",
+		"itemListElement": items,
+	}
+}
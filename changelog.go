@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChangelogConfig generates a changelog page from the releases of the project:
+//
+//	[changelog]
+//	source = "CHANGELOG.md"
+//	path = "/changelog/"
+//
+// Source is "git" for the annotated tags of the repository, or a Markdown file with a level-2
+// heading per version, such as "## [1.2.0] - 2024-05-01" in the Keep a Changelog format.
+type ChangelogConfig struct {
+	Source string `toml:"source"`
+	// Path is the URL of the page, /changelog/ by default
+	Path string `toml:"path"`
+	// Title is the page title, Changelog by default
+	Title string `toml:"title"`
+	// Layout is the layout of the page, changelog by default; themes without a changelog.html
+	// render the page as a regular page holding every release
+	Layout string `toml:"layout"`
+}
+
+// Release is a version of the project, listed newest first by .Site.Releases
+type Release struct {
+	Version string
+	Date    time.Time
+	// Title is the subject of an annotated tag; Markdown changelogs have none
+	Title   string
+	Content template.HTML
+	// markdown is the source of Content
+	markdown string
+}
+
+var (
+	// releaseDatePattern finds the date of a changelog heading
+	releaseDatePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+	// releaseVersionPattern takes the version of a changelog heading out of brackets or a link
+	releaseVersionPattern = regexp.MustCompile(`^\[?([^\]\s]+)\]?`)
+)
+
+// loadReleases reads the releases of the changelog source, or none when no source is configured
+func loadReleases(ctx context.Context, cfg ChangelogConfig) ([]*Release, error) {
+	var releases []*Release
+	var err error
+	switch cfg.Source {
+	case "":
+		return nil, nil
+	case "git":
+		releases, err = gitReleases(ctx)
+	default:
+		releases, err = changelogReleases(cfg.Source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changelog: %w", err)
+	}
+	for _, r := range releases {
+		html, err := convertMarkdownToHTML([]byte(r.markdown))
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert release %s: %w", r.Version, err)
+		}
+		r.Content = template.HTML(html)
+	}
+	return releases, nil
+}
+
+// gitReleases reads the annotated tags of the repository, newest first; lightweight tags carry
+// no notes and are left out
+func gitReleases(ctx context.Context) ([]*Release, error) {
+	out, err := exec.CommandContext(ctx, "git", "for-each-ref", "--sort=-creatordate",
+		"--format=%(objecttype)%1f%(refname:short)%1f%(creatordate:iso-strict)%1f%(contents:subject)%1f%(contents:body)%1e",
+		"refs/tags").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list git tags: %w", err)
+	}
+	var releases []*Release
+	for _, record := range strings.Split(string(out), "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(fields) != 5 || fields[0] != "tag" {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[2])
+		releases = append(releases, &Release{
+			Version:  fields[1],
+			Date:     date,
+			Title:    fields[3],
+			markdown: strings.TrimSpace(fields[4]),
+		})
+	}
+	return releases, nil
+}
+
+// changelogReleases splits a Markdown changelog at its level-2 headings, in file order. The
+// text before the first version and an Unreleased section are left out.
+func changelogReleases(name string) ([]*Release, error) {
+	file, err := projectPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReadPath(file); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []*Release
+	var current *Release
+	var body strings.Builder
+	flush := func() {
+		if current != nil && !strings.EqualFold(current.Version, "unreleased") {
+			current.markdown = strings.TrimSpace(body.String())
+			releases = append(releases, current)
+		}
+		body.Reset()
+	}
+	inFence := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if heading, ok := strings.CutPrefix(line, "## "); ok && !inFence {
+			flush()
+			heading = strings.TrimSpace(heading)
+			current = &Release{Version: heading}
+			if m := releaseVersionPattern.FindStringSubmatch(heading); m != nil {
+				current.Version = m[1]
+			}
+			if date := releaseDatePattern.FindString(heading); date != "" {
+				current.Date, _ = time.Parse("2006-01-02", date)
+			}
+			continue
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return releases, nil
+}
+
+// changelogContent returns the content file of the changelog page, listing every release under
+// a heading of its own; the page is rendered but left out of lists and feeds
+func changelogContent(config Config, releases []*Release) (contentFile, error) {
+	cfg := config.Changelog
+	title, layout, permalink := cfg.Title, cfg.Layout, cfg.Path
+	if title == "" {
+		title = "Changelog"
+	}
+	if layout == "" {
+		layout = "changelog"
+	}
+	if permalink == "" {
+		permalink = "/changelog/"
+	}
+	frontMatter := map[string]any{"title": title, "layout": layout}
+	if len(releases) > 0 && !releases[0].Date.IsZero() {
+		frontMatter["date"] = releases[0].Date.Format(time.RFC3339)
+	}
+	meta, err := yaml.Marshal(frontMatter)
+	if err != nil {
+		return contentFile{}, err
+	}
+
+	var body strings.Builder
+	for _, r := range releases {
+		fmt.Fprintf(&body, "## %s", r.Version)
+		if r.Title != "" && r.Title != r.Version {
+			fmt.Fprintf(&body, ": %s", r.Title)
+		}
+		body.WriteString("\n\n")
+		if !r.Date.IsZero() {
+			fmt.Fprintf(&body, "*%s*\n\n", r.Date.Format("2006-01-02"))
+		}
+		if r.markdown != "" {
+			body.WriteString(r.markdown + "\n\n")
+		}
+	}
+	source := cfg.Source
+	if source == "git" {
+		source = "refs/tags"
+	}
+	return contentFile{
+		Path:       source,
+		RelPath:    "changelog.md",
+		data:       []byte("---\n" + string(meta) + "---\n" + body.String()),
+		permalink:  permalink,
+		standalone: true,
+	}, nil
+}
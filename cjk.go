@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+// CJKConfig configures Chinese, Japanese and Korean content:
+//
+//	[cjk]
+//	enabled = true
+//	summaryLength = 120
+//	transliterate = "pinyin --style normal"
+type CJKConfig struct {
+	// Enabled counts every Chinese and Japanese character as a word and cuts summaries by
+	// characters on pages holding such text; Korean is written with spaces and counted by words
+	Enabled bool `toml:"enabled"`
+	// SummaryLength is the number of characters of automatic summaries, 120 by default
+	SummaryLength int `toml:"summaryLength"`
+	// Transliterate is a command that reads text on stdin and writes it romanized, such as a
+	// pinyin or romaji converter; slugs of text with CJK characters are made from its output
+	Transliterate string `toml:"transliterate"`
+}
+
+// defaultCJKSummaryLength is the number of characters of CJK summaries when not configured
+const defaultCJKSummaryLength = 120
+
+// isCJKRune reports whether r is a Chinese or Japanese character, which is written without spaces
+func isCJKRune(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// hasCJK reports whether text holds Chinese, Japanese or Korean characters
+func hasCJK(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool { return isCJKRune(r) || unicode.Is(unicode.Hangul, r) }) >= 0
+}
+
+// cjkPage reports whether the page gets character-based counts and summaries
+func (p *Page) cjkPage() bool {
+	return p.Site != nil && p.Site.Config.CJK.Enabled && hasCJK(p.Plain())
+}
+
+// countCJKWords counts every Chinese and Japanese character as a word, and other text by spaces
+func countCJKWords(text string) int {
+	count := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJKRune(r):
+			count++
+			inWord = false
+		case unicode.IsSpace(r) || unicode.IsPunct(r) && r != '\'' && r != '-':
+			inWord = false
+		case !inWord:
+			count++
+			inWord = true
+		}
+	}
+	return count
+}
+
+// cjkSummary returns the first characters of the text, with runs of whitespace counting as one
+func cjkSummary(text string, length int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= length {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:length])) + "…"
+}
+
+// transliterate returns the romanized text of the cjk.transliterate command, caching it so
+// each title or term runs the command once; on failure the text is returned as it is
+func (s *Site) transliterate(text string) string {
+	command := s.Config.CJK.Transliterate
+	if strings.TrimSpace(command) == "" || !hasCJK(text) {
+		return text
+	}
+	s.slugMu.Lock()
+	defer s.slugMu.Unlock()
+	if romanized, ok := s.romanized[text]; ok {
+		return romanized
+	}
+	if s.romanized == nil {
+		s.romanized = map[string]string{}
+	}
+	romanized, err := runTransliteration(s, command, text)
+	if err != nil {
+		warnf("Failed to transliterate %q: %v", text, err)
+		romanized = text
+	}
+	s.romanized[text] = romanized
+	return romanized
+}
+
+// runTransliteration runs the command with text on stdin and returns its trimmed output
+func runTransliteration(s *Site, command, text string) (string, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("cjk.transliterate command is empty")
+	}
+	cmd := exec.CommandContext(s.ctx, fields[0], fields[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v: %s", fields[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PageGroup is one group returned by groupBy, in the order the key first appeared
+type PageGroup struct {
+	Key   any
+	Pages []*Page
+}
+
+// collectionFuncs returns the template functions that filter and combine collections
+func collectionFuncs() map[string]any {
+	return map[string]any{
+		"where":     where,
+		"intersect": intersect,
+		"union":     union,
+		"uniq":      uniq,
+		"first":     first,
+		"last":      last,
+		"after":     after,
+		"limit":     first,
+		"offset":    after,
+		"groupBy":   groupBy,
+		"sortBy":    sortBy,
+	}
+}
+
+// where filters a collection by a key path such as "Section" or "Params.tags".
+// It is called as `where COLL KEY VALUE` or `where COLL KEY OP VALUE`; OP is one of
+// = == != > >= < <= in "not in" intersect.
+func where(collection any, key string, args ...any) (any, error) {
+	op, match := "==", any(nil)
+	switch len(args) {
+	case 1:
+		match = args[0]
+	case 2:
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("where: operator must be a string, got %T", args[0])
+		}
+		op, match = s, args[1]
+	default:
+		return nil, fmt.Errorf("where: expected a value or an operator and a value")
+	}
+
+	seq, err := toSlice(collection)
+	if err != nil {
+		return nil, fmt.Errorf("where: %w", err)
+	}
+	out := reflect.MakeSlice(seq.Type(), 0, seq.Len())
+	for i := 0; i < seq.Len(); i++ {
+		item := seq.Index(i)
+		value, _ := lookupPath(item.Interface(), key)
+		ok, err := compareValues(value, op, match)
+		if err != nil {
+			return nil, fmt.Errorf("where: %w", err)
+		}
+		if ok {
+			out = reflect.Append(out, item)
+		}
+	}
+	return out.Interface(), nil
+}
+
+// intersect returns the items of a that are also in b
+func intersect(a, b any) (any, error) {
+	return combine(a, b, true)
+}
+
+// union returns the items of a followed by the items of b that are not in a
+func union(a, b any) (any, error) {
+	seqA, err := toSlice(a)
+	if err != nil {
+		return nil, fmt.Errorf("union: %w", err)
+	}
+	seqB, err := toSlice(b)
+	if err != nil {
+		return nil, fmt.Errorf("union: %w", err)
+	}
+	out := reflect.MakeSlice(seqA.Type(), 0, seqA.Len()+seqB.Len())
+	for i := 0; i < seqA.Len(); i++ {
+		out = reflect.Append(out, seqA.Index(i))
+	}
+	for i := 0; i < seqB.Len(); i++ {
+		item := seqB.Index(i)
+		if !containsValue(seqA, item.Interface()) && item.Type().AssignableTo(seqA.Type().Elem()) {
+			out = reflect.Append(out, item)
+		}
+	}
+	return uniq(out.Interface())
+}
+
+// combine keeps the items of a whose presence in b equals keep
+func combine(a, b any, keep bool) (any, error) {
+	seqA, err := toSlice(a)
+	if err != nil {
+		return nil, err
+	}
+	seqB, err := toSlice(b)
+	if err != nil {
+		return nil, err
+	}
+	out := reflect.MakeSlice(seqA.Type(), 0, seqA.Len())
+	for i := 0; i < seqA.Len(); i++ {
+		item := seqA.Index(i)
+		if containsValue(seqB, item.Interface()) == keep && !containsValue(out, item.Interface()) {
+			out = reflect.Append(out, item)
+		}
+	}
+	return out.Interface(), nil
+}
+
+// uniq removes duplicate items, keeping the first occurrence
+func uniq(collection any) (any, error) {
+	seq, err := toSlice(collection)
+	if err != nil {
+		return nil, fmt.Errorf("uniq: %w", err)
+	}
+	out := reflect.MakeSlice(seq.Type(), 0, seq.Len())
+	for i := 0; i < seq.Len(); i++ {
+		if !containsValue(out, seq.Index(i).Interface()) {
+			out = reflect.Append(out, seq.Index(i))
+		}
+	}
+	return out.Interface(), nil
+}
+
+// first returns the first n items of a collection
+func first(n int, collection any) (any, error) {
+	seq, err := toSlice(collection)
+	if err != nil {
+		return nil, fmt.Errorf("first: %w", err)
+	}
+	return seq.Slice(0, clamp(n, seq.Len())).Interface(), nil
+}
+
+// last returns the last n items of a collection
+func last(n int, collection any) (any, error) {
+	seq, err := toSlice(collection)
+	if err != nil {
+		return nil, fmt.Errorf("last: %w", err)
+	}
+	return seq.Slice(seq.Len()-clamp(n, seq.Len()), seq.Len()).Interface(), nil
+}
+
+// after returns the items of a collection after the first n
+func after(n int, collection any) (any, error) {
+	seq, err := toSlice(collection)
+	if err != nil {
+		return nil, fmt.Errorf("after: %w", err)
+	}
+	return seq.Slice(clamp(n, seq.Len()), seq.Len()).Interface(), nil
+}
+
+// groupBy groups pages by the value at a key path such as "Params.series" or "Section".
+// Pages with a list value appear in the group of every list item; pages without the key are skipped.
+func groupBy(pages []*Page, key string) []PageGroup {
+	var groups []PageGroup
+	index := map[string]int{}
+	for _, p := range pages {
+		value, ok := lookupPath(p, key)
+		if !ok || value == nil {
+			continue
+		}
+		keys := []any{value}
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice {
+			keys = keys[:0]
+			for i := 0; i < rv.Len(); i++ {
+				keys = append(keys, rv.Index(i).Interface())
+			}
+		}
+		for _, k := range keys {
+			id := fmt.Sprint(k)
+			i, ok := index[id]
+			if !ok {
+				i = len(groups)
+				index[id] = i
+				groups = append(groups, PageGroup{Key: k})
+			}
+			groups[i].Pages = append(groups[i].Pages, p)
+		}
+	}
+	return groups
+}
+
+// sortBy returns a copy of the collection sorted by a key path, "asc" (default) or "desc"
+func sortBy(collection any, key string, order ...string) (any, error) {
+	seq, err := toSlice(collection)
+	if err != nil {
+		return nil, fmt.Errorf("sortBy: %w", err)
+	}
+	out := reflect.MakeSlice(seq.Type(), seq.Len(), seq.Len())
+	reflect.Copy(out, seq)
+	desc := len(order) > 0 && strings.EqualFold(order[0], "desc")
+	sort.SliceStable(out.Interface(), func(i, j int) bool {
+		a, _ := lookupPath(out.Index(i).Interface(), key)
+		b, _ := lookupPath(out.Index(j).Interface(), key)
+		if desc {
+			a, b = b, a
+		}
+		less, _ := compareValues(a, "<", b)
+		return less
+	})
+	return out.Interface(), nil
+}
+
+// lookupPath resolves a dotted path of fields, zero-argument methods and map keys
+func lookupPath(item any, key string) (any, bool) {
+	current := reflect.ValueOf(item)
+	for _, part := range strings.Split(strings.TrimPrefix(key, "."), ".") {
+		if !current.IsValid() {
+			return nil, false
+		}
+		if m := current.MethodByName(part); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() >= 1 {
+			current = m.Call(nil)[0]
+			continue
+		}
+		for current.Kind() == reflect.Pointer || current.Kind() == reflect.Interface {
+			if current.IsNil() {
+				return nil, false
+			}
+			current = current.Elem()
+		}
+		switch current.Kind() {
+		case reflect.Struct:
+			field := current.FieldByName(part)
+			if !field.IsValid() || !field.CanInterface() {
+				return nil, false
+			}
+			current = field
+		case reflect.Map:
+			value := mapIndex(current, part)
+			if !value.IsValid() {
+				value = mapIndex(current, strings.ToLower(part))
+			}
+			if !value.IsValid() {
+				return nil, false
+			}
+			current = value
+		default:
+			return nil, false
+		}
+	}
+	if !current.IsValid() {
+		return nil, false
+	}
+	return current.Interface(), true
+}
+
+// mapIndex returns the value of a string key in a map, or the zero Value when the map has no
+// such key or is keyed by something other than strings
+func mapIndex(m reflect.Value, key string) reflect.Value {
+	k := reflect.ValueOf(key)
+	switch keyType := m.Type().Key(); {
+	case keyType.Kind() == reflect.String:
+		return m.MapIndex(k.Convert(keyType))
+	case keyType.Kind() == reflect.Interface && k.Type().Implements(keyType):
+		return m.MapIndex(k)
+	}
+	return reflect.Value{}
+}
+
+// compareValues applies a where operator to two values
+func compareValues(a any, op string, b any) (bool, error) {
+	switch op {
+	case "=", "==", "eq":
+		return equalValues(a, b), nil
+	case "!=", "<>", "ne":
+		return !equalValues(a, b), nil
+	case "in":
+		seq, err := toSlice(b)
+		if err != nil {
+			return false, err
+		}
+		return containsValue(seq, a), nil
+	case "not in":
+		seq, err := toSlice(b)
+		if err != nil {
+			return false, err
+		}
+		return !containsValue(seq, a), nil
+	case "intersect":
+		seqA, errA := toSlice(a)
+		seqB, errB := toSlice(b)
+		if errA != nil || errB != nil {
+			return false, nil
+		}
+		for i := 0; i < seqA.Len(); i++ {
+			if containsValue(seqB, seqA.Index(i).Interface()) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "<", "lt", "<=", "le", ">", "gt", ">=", "ge":
+		c, ok := orderValues(a, b)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case "<", "lt":
+			return c < 0, nil
+		case "<=", "le":
+			return c <= 0, nil
+		case ">", "gt":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	}
+	return false, fmt.Errorf("unknown operator %q", op)
+}
+
+// equalValues compares values loosely so that int and float64 front matter values match
+func equalValues(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return fa == fb
+		}
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Equal(tb)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// orderValues compares numbers, times and strings, reporting false when they are not comparable
+func orderValues(a, b any) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1, true
+			case fa > fb:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb), true
+		}
+	}
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return strings.Compare(sa, sb), true
+		}
+	}
+	return 0, false
+}
+
+// toFloat converts any numeric value to float64
+func toFloat(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// toSlice returns the reflect value of a slice or array, treating nil as an empty list
+func toSlice(collection any) (reflect.Value, error) {
+	if collection == nil {
+		return reflect.ValueOf([]any{}), nil
+	}
+	rv := reflect.ValueOf(collection)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return reflect.Value{}, fmt.Errorf("expected a list, got %T", collection)
+	}
+	return rv, nil
+}
+
+// containsValue reports whether seq holds an item equal to v
+func containsValue(seq reflect.Value, v any) bool {
+	for i := 0; i < seq.Len(); i++ {
+		if equalValues(seq.Index(i).Interface(), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// clamp limits n to the range 0..max
+func clamp(n, max int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
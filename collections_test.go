@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestLookupPath(t *testing.T) {
+	type name string
+	item := map[string]any{
+		"params": map[string]any{"author": "ana"},
+		"named":  map[name]int{"x": 1},
+		"counts": map[int]string{1: "one"},
+		"any":    map[any]any{"k": "v"},
+	}
+	tests := []struct {
+		key    string
+		want   any
+		wantOK bool
+	}{
+		{".params.author", "ana", true},
+		{"Params.Author", "ana", true},
+		{"named.x", 1, true},
+		{"counts.1", nil, false},
+		{"any.k", "v", true},
+		{"params.missing", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := lookupPath(item, tt.key)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("lookupPath(%q) = %v, %v, want %v, %v", tt.key, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
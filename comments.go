@@ -0,0 +1,291 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// commentsDir holds the static comments: one directory of entry files per page (as written by
+// Staticman) or Disqus XML exports
+const commentsDir = "data/comments"
+
+// Comment is a static comment; top-level comments hold their replies
+type Comment struct {
+	ID      string
+	Author  string
+	URL     string
+	Avatar  string
+	Date    time.Time
+	Content template.HTML
+	Replies []*Comment
+
+	parent string
+	// key identifies the page the comment belongs to, see commentKeys
+	key string
+}
+
+// loadComments attaches the comments of data/comments to their pages, threaded by parent and
+// sorted by date, oldest first
+func (s *Site) loadComments() error {
+	if _, err := os.Stat(commentsDir); os.IsNotExist(err) {
+		return nil
+	}
+	var comments []*Comment
+	err := filepath.WalkDir(commentsDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(commentsDir, p)
+		if err != nil {
+			return err
+		}
+		switch ext := strings.ToLower(filepath.Ext(p)); ext {
+		case ".xml":
+			found, err := readDisqusExport(p)
+			if err != nil {
+				return err
+			}
+			comments = append(comments, found...)
+		case ".yml", ".yaml", ".json", ".toml":
+			comment, err := readCommentEntry(p)
+			if err != nil {
+				return err
+			}
+			if dir := filepath.ToSlash(filepath.Dir(rel)); dir != "." {
+				comment.key = dir
+			}
+			comments = append(comments, comment)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read comments: %w", err)
+	}
+
+	// Disqus threads are keyed by URL path, which includes the base path of the site
+	basePath := "/"
+	if u, err := url.Parse(s.BaseURL); err == nil && u.Path != "" {
+		basePath = u.Path
+	}
+	for _, c := range comments {
+		if strings.HasPrefix(c.key, "/") {
+			key := strings.TrimPrefix(c.key, strings.TrimSuffix(basePath, "/"))
+			key = strings.TrimSuffix(strings.TrimSuffix(key, "index.html"), ".html")
+			c.key = strings.Trim(key, "/")
+		}
+	}
+
+	pages := map[string]*Page{}
+	for _, p := range s.Pages {
+		for _, key := range s.commentKeys(p) {
+			if _, taken := pages[key]; !taken {
+				pages[key] = p
+			}
+		}
+	}
+	byID := map[string]*Comment{}
+	for _, c := range comments {
+		if c.ID != "" {
+			byID[c.ID] = c
+		}
+	}
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].Date.Before(comments[j].Date) })
+	for _, c := range comments {
+		if parent := replyParent(c, byID); parent != nil {
+			parent.Replies = append(parent.Replies, c)
+			continue
+		}
+		page, ok := pages[c.key]
+		if !ok {
+			warnf("No page for comment %s of %q", c.ID, c.key)
+			continue
+		}
+		page.Comments = append(page.Comments, c)
+	}
+	return nil
+}
+
+// replyParent returns the comment c replies to, or nil for a top-level comment; a comment whose
+// parents lead back to it is kept top-level so threads stay trees
+func replyParent(c *Comment, byID map[string]*Comment) *Comment {
+	seen := map[*Comment]bool{c: true}
+	for p := byID[c.parent]; p != nil; p = byID[p.parent] {
+		if seen[p] {
+			warnf("Comment %s has a reply cycle among its parents, showing it top-level", c.ID)
+			return nil
+		}
+		seen[p] = true
+	}
+	return byID[c.parent]
+}
+
+// commentKeys returns the names comments may use for a page: its URL path without the site
+// base path and extension, its content path without extension, its slug (file or bundle name)
+// and its id, which survives renames
+func (s *Site) commentKeys(p *Page) []string {
+	out := strings.TrimSuffix(filepath.ToSlash(p.outputPath), "index.html")
+	out = strings.Trim(strings.TrimSuffix(out, ".html"), "/")
+	keys := []string{out}
+	if p.ID != "" {
+		keys = append(keys, p.ID)
+	}
+	if p.File != nil {
+		file := strings.TrimSuffix(p.File.Path, "."+p.File.Ext)
+		keys = append(keys, file, strings.TrimSuffix(file, "/index"))
+		keys = append(keys, path.Base(strings.TrimSuffix(file, "/index")))
+	}
+	return keys
+}
+
+// NumComments counts the comments of the page including replies
+func (p *Page) NumComments() int {
+	var count func(comments []*Comment) int
+	count = func(comments []*Comment) int {
+		n := len(comments)
+		for _, c := range comments {
+			n += count(c.Replies)
+		}
+		return n
+	}
+	return count(p.Comments)
+}
+
+// readCommentEntry reads a Staticman entry file. The field names of the common Staticman
+// configurations are accepted, e.g. message or comment for the text.
+func readCommentEntry(file string) (*Comment, error) {
+	fields, err := readDataFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read comment: %w", err)
+	}
+	field := func(names ...string) string {
+		for _, name := range names {
+			if v, ok := fields[name]; ok && v != nil {
+				return fmt.Sprint(v)
+			}
+		}
+		return ""
+	}
+
+	comment := &Comment{
+		ID:     field("_id", "id"),
+		Author: field("name", "author"),
+		URL:    field("url", "website"),
+		Avatar: field("avatar"),
+		parent: field("_parent", "parent", "replying_to_uid", "replying_to"),
+	}
+	if comment.ID == "" {
+		comment.ID = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if email := field("email"); comment.Avatar == "" && email != "" {
+		comment.Avatar = gravatarURL(email)
+	}
+	// Staticman stores dates as Unix timestamps unless configured otherwise
+	date := fields["date"]
+	if n, ok := toFloat(date); ok {
+		comment.Date = time.Unix(int64(n), 0).UTC()
+	} else if s, ok := date.(string); ok && strings.Trim(s, "0123456789") == "" && s != "" {
+		var n int64
+		fmt.Sscan(s, &n)
+		comment.Date = time.Unix(n, 0).UTC()
+	} else {
+		comment.Date = toTime(date)
+	}
+	content, err := convertMarkdownToHTML([]byte(field("message", "comment", "body")))
+	if err != nil {
+		return nil, err
+	}
+	comment.Content = template.HTML(content)
+	return comment, nil
+}
+
+// gravatarURL returns the Gravatar image of an email address
+func gravatarURL(email string) string {
+	sum := md5.Sum([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return fmt.Sprintf("https://www.gravatar.com/avatar/%x?d=identicon", sum)
+}
+
+// disqusExport is the part of a Disqus XML export used for comments
+type disqusExport struct {
+	Threads []struct {
+		ID   string `xml:"http://disqus.com/disqus-internals id,attr"`
+		Link string `xml:"link"`
+	} `xml:"thread"`
+	Posts []struct {
+		ID        string `xml:"http://disqus.com/disqus-internals id,attr"`
+		Message   string `xml:"message"`
+		CreatedAt string `xml:"createdAt"`
+		IsDeleted bool   `xml:"isDeleted"`
+		IsSpam    bool   `xml:"isSpam"`
+		Author    struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Thread struct {
+			ID string `xml:"http://disqus.com/disqus-internals id,attr"`
+		} `xml:"thread"`
+		Parent struct {
+			ID string `xml:"http://disqus.com/disqus-internals id,attr"`
+		} `xml:"parent"`
+	} `xml:"post"`
+}
+
+// readDisqusExport reads the visible comments of a Disqus XML export, keyed by the URL path of
+// their thread
+func readDisqusExport(file string) ([]*Comment, error) {
+	if err := checkReadPath(file); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var export disqusExport
+	if err := xml.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to parse Disqus export %s: %w", file, err)
+	}
+	threads := map[string]string{}
+	for _, t := range export.Threads {
+		if u, err := url.Parse(strings.TrimSpace(t.Link)); err == nil {
+			threads[t.ID] = u.Path
+		}
+	}
+
+	var comments []*Comment
+	for _, post := range export.Posts {
+		if post.IsDeleted || post.IsSpam {
+			continue
+		}
+		comments = append(comments, &Comment{
+			ID:      "disqus-" + post.ID,
+			Author:  post.Author.Name,
+			Date:    toTime(strings.TrimSpace(post.CreatedAt)),
+			Content: disqusMessage(post.Message),
+			parent:  "disqus-" + post.Parent.ID,
+			key:     threads[post.Thread.ID],
+		})
+	}
+	return comments, nil
+}
+
+// disqusMessage turns the HTML of a Disqus message into escaped paragraphs of its text, so the
+// export cannot inject markup into the site
+func disqusMessage(message string) template.HTML {
+	var b strings.Builder
+	text := strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</p>", "\n\n").Replace(message)
+	for _, paragraph := range strings.Split(plainify(text), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(paragraph))
+		}
+	}
+	return template.HTML(b.String())
+}
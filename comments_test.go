@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+func TestReplyParent(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	byID := map[string]*Comment{}
+	for _, c := range []*Comment{
+		{ID: "a"},
+		{ID: "b", parent: "a"},
+		{ID: "c", parent: "b"},
+		{ID: "self", parent: "self"},
+		{ID: "x", parent: "y"},
+		{ID: "y", parent: "x"},
+		{ID: "z", parent: "x"},
+		{ID: "orphan", parent: "missing"},
+	} {
+		byID[c.ID] = c
+	}
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"a", ""},
+		{"b", "a"},
+		{"c", "b"},
+		{"self", ""},
+		{"x", ""},
+		{"y", ""},
+		{"z", ""},
+		{"orphan", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if p := replyParent(byID[tt.id], byID); p != nil {
+			got = p.ID
+		}
+		if got != tt.want {
+			t.Errorf("replyParent(%s) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pageFields are the fields the content API can select, by name
+var pageFields = map[string]func(p *Page) any{
+	"id":           func(p *Page) any { return p.ID },
+	"title":        func(p *Page) any { return p.Title },
+	"kind":         func(p *Page) any { return p.Kind },
+	"section":      func(p *Page) any { return p.Section },
+	"description":  func(p *Page) any { return p.Description },
+	"summary":      func(p *Page) any { return p.Summary() },
+	"content":      func(p *Page) any { return string(p.Content) },
+	"plain":        func(p *Page) any { return p.Plain() },
+	"wordCount":    func(p *Page) any { return p.WordCount() },
+	"date":         func(p *Page) any { return apiTime(p.Date) },
+	"publishDate":  func(p *Page) any { return apiTime(p.PublishDate) },
+	"lastmod":      func(p *Page) any { return apiTime(p.Lastmod) },
+	"draft":        func(p *Page) any { return p.Draft },
+	"archived":     func(p *Page) any { return p.archived },
+	"weight":       func(p *Page) any { return p.Weight },
+	"permalink":    func(p *Page) any { return p.Permalink },
+	"relPermalink": func(p *Page) any { return p.RelPermalink },
+	"params":       func(p *Page) any { return p.Params },
+	"path": func(p *Page) any {
+		if p.File == nil {
+			return nil
+		}
+		return p.File.Path
+	},
+	"authors": func(p *Page) any {
+		names := []string{}
+		for _, a := range p.Authors() {
+			names = append(names, a.Name)
+		}
+		return names
+	},
+	"series": func(p *Page) any {
+		if p.series == nil {
+			return nil
+		}
+		return p.series.Name
+	},
+}
+
+// defaultPageFields are returned when a query selects no fields
+var defaultPageFields = []string{"title", "permalink", "date", "section"}
+
+// apiTime formats a date for the content API, null when it is not set
+func apiTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
+
+// handlePages answers GET /api/pages with the pages matching the filters of the query:
+// kind (page by default), section, a taxonomy name with a term (e.g. tags=go, or tag=go),
+// from and to dates, sort (date, lastmod, title or weight), order, limit, offset and fields,
+// a comma-separated selection that may include params.<key>
+func (ds *devServer) handlePages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	site := ds.site
+	if site == nil {
+		apiError(w, http.StatusServiceUnavailable, "the site has not been built yet")
+		return
+	}
+
+	fields := defaultPageFields
+	if v := query.Get("fields"); v != "" {
+		fields = strings.Split(v, ",")
+		for _, field := range fields {
+			if _, ok := pageFields[field]; !ok && !strings.HasPrefix(field, "params.") {
+				apiError(w, http.StatusBadRequest, "unknown field %q", field)
+				return
+			}
+		}
+	}
+	var from, to time.Time
+	for key, date := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := query.Get(key); v != "" {
+			t, err := parseAPIDate(v)
+			if err != nil {
+				apiError(w, http.StatusBadRequest, "%s must be a date like 2024-01-31", key)
+				return
+			}
+			*date = t
+		}
+	}
+	if !to.IsZero() && len(query.Get("to")) == len("2006-01-02") {
+		// A date without a time includes the whole day
+		to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	limit, offset := 20, 0
+	for key, n := range map[string]*int{"limit": &limit, "offset": &offset} {
+		if v := query.Get(key); v != "" {
+			value, err := strconv.Atoi(v)
+			if err != nil || value < 0 {
+				apiError(w, http.StatusBadRequest, "%s must be a number", key)
+				return
+			}
+			*n = value
+		}
+	}
+	limit = min(limit, 1000)
+
+	// Taxonomy filters narrow the pages to those of the term
+	terms := map[*Page]bool{}
+	filtered := false
+	for name, list := range site.Taxonomies {
+		values := query[name]
+		if name == "tags" {
+			values = append(values, query["tag"]...)
+		}
+		for _, value := range values {
+			found := map[*Page]bool{}
+			for _, term := range list {
+				if term.Slug == site.slug(value) || strings.EqualFold(term.Name, value) {
+					for _, p := range term.Pages {
+						found[p] = true
+					}
+				}
+			}
+			if !filtered {
+				terms, filtered = found, true
+				continue
+			}
+			for p := range terms {
+				if !found[p] {
+					delete(terms, p)
+				}
+			}
+		}
+	}
+
+	kind := query.Get("kind")
+	if kind == "" {
+		kind = KindPage
+	}
+	section := query.Get("section")
+	var pages []*Page
+	for _, p := range site.AllPages {
+		if (kind != "any" && p.Kind != kind) || p.Unlisted ||
+			(section != "" && p.Section != section) ||
+			(filtered && !terms[p]) ||
+			(!from.IsZero() && p.Date.Before(from)) ||
+			(!to.IsZero() && p.Date.After(to)) {
+			continue
+		}
+		pages = append(pages, p)
+	}
+
+	less := map[string]func(a, b *Page) bool{
+		"date":    func(a, b *Page) bool { return a.Date.Before(b.Date) },
+		"lastmod": func(a, b *Page) bool { return a.Lastmod.Before(b.Lastmod) },
+		"title":   func(a, b *Page) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) },
+		"weight":  func(a, b *Page) bool { return a.Weight < b.Weight },
+	}
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = "date"
+	}
+	compare, ok := less[sortBy]
+	if !ok {
+		apiError(w, http.StatusBadRequest, "sort must be date, lastmod, title or weight")
+		return
+	}
+	// Dates sort newest first unless asked otherwise
+	descending := sortBy == "date" || sortBy == "lastmod"
+	switch query.Get("order") {
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		if descending {
+			return compare(pages[j], pages[i])
+		}
+		return compare(pages[i], pages[j])
+	})
+
+	total := len(pages)
+	start, end := pageWindow(total, offset, limit)
+	pages = pages[start:end]
+	results := make([]map[string]any, len(pages))
+	for i, p := range pages {
+		result := map[string]any{}
+		for _, field := range fields {
+			if key, ok := strings.CutPrefix(field, "params."); ok {
+				result[field] = p.Params[key]
+			} else {
+				result[field] = pageFields[field](p)
+			}
+		}
+		results[i] = result
+	}
+	writeAPI(w, map[string]any{"total": total, "offset": offset, "pages": results})
+}
+
+// pageWindow returns the bounds of the page of results at offset, clamped to the total so that
+// no offset or limit can overflow
+func pageWindow(total, offset, limit int) (start, end int) {
+	start = min(offset, total)
+	return start, start + min(limit, total-start)
+}
+
+// taxonomyTerm is a term in the responses of the content API
+type taxonomyTerm struct {
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	Count     int    `json:"count"`
+	Permalink string `json:"permalink"`
+}
+
+// handleTaxonomies answers GET /api/taxonomies with the terms of every taxonomy, and
+// GET /api/taxonomies/{name} with those of one, most used first
+func (ds *devServer) handleTaxonomies(w http.ResponseWriter, r *http.Request) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	if ds.site == nil {
+		apiError(w, http.StatusServiceUnavailable, "the site has not been built yet")
+		return
+	}
+	name := r.PathValue("name")
+	result := map[string][]taxonomyTerm{}
+	for taxonomy, list := range ds.site.Taxonomies {
+		if name != "" && taxonomy != name {
+			continue
+		}
+		terms := []taxonomyTerm{}
+		for _, term := range list {
+			t := taxonomyTerm{Name: term.Name, Slug: term.Slug, Count: term.Count()}
+			if term.Page != nil {
+				t.Permalink = term.Page.Permalink
+			}
+			terms = append(terms, t)
+		}
+		slices.SortStableFunc(terms, func(a, b taxonomyTerm) int { return b.Count - a.Count })
+		result[taxonomy] = terms
+	}
+	if name != "" {
+		terms, ok := result[name]
+		if !ok {
+			apiError(w, http.StatusNotFound, "no taxonomy %q", name)
+			return
+		}
+		writeAPI(w, terms)
+		return
+	}
+	writeAPI(w, result)
+}
+
+// parseAPIDate parses a date or an RFC 3339 time
+func parseAPIDate(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, time.Local)
+}
+
+// writeAPI writes a JSON response of the content API
+func writeAPI(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// apiError writes a JSON error of the content API
+func apiError(w http.ResponseWriter, status int, format string, args ...any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// handleContentAPI registers the read-only content API on a mux
+func (ds *devServer) handleContentAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/pages", ds.handlePages)
+	mux.HandleFunc("GET /api/taxonomies", ds.handleTaxonomies)
+	mux.HandleFunc("GET /api/taxonomies/{name}", ds.handleTaxonomies)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPageWindow(t *testing.T) {
+	tests := []struct {
+		name                 string
+		total, offset, limit int
+		start, end           int
+	}{
+		{"first page", 50, 0, 20, 0, 20},
+		{"middle page", 50, 20, 20, 20, 40},
+		{"last partial page", 50, 40, 20, 40, 50},
+		{"offset at total", 50, 50, 20, 50, 50},
+		{"offset past total", 50, 80, 20, 50, 50},
+		{"zero limit", 50, 10, 0, 10, 10},
+		{"no pages", 0, 0, 20, 0, 0},
+		{"huge offset", 50, math.MaxInt, 20, 50, 50},
+		{"huge offset and limit", 50, math.MaxInt, math.MaxInt, 50, 50},
+		{"huge limit", 50, 10, math.MaxInt, 10, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := pageWindow(tt.total, tt.offset, tt.limit)
+			if start != tt.start || end != tt.end {
+				t.Errorf("pageWindow(%d, %d, %d) = %d, %d; want %d, %d", tt.total, tt.offset, tt.limit, start, end, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestContentAPIBeforeBuild(t *testing.T) {
+	ds := &devServer{}
+	mux := http.NewServeMux()
+	ds.handleContentAPI(mux)
+	for _, path := range []string{"/api/pages", "/api/taxonomies", "/api/taxonomies/tags"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %s = %d; want %d", path, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
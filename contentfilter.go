@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// BuildConfig configures which content a build includes and how its stylesheets are processed
+type BuildConfig struct {
+	Filter ContentFilter `toml:"filter"`
+	CSS    CSSConfig     `toml:"css"`
+	// Manifest writes public/.manifest.json, listing every output file with its size, SHA-256
+	// and the sources it was generated from
+	Manifest bool `toml:"manifest"`
+}
+
+// ContentFilter limits a build to part of the content, e.g. to build only the docs section of
+// a large site in CI:
+//
+//	[build.filter]
+//	include = ["docs"]
+//	exclude = ["docs/archive/*"]
+//
+// Patterns are globs over paths relative to content/ and match a file or any directory above it.
+// A page is built when it matches an include pattern, or there are none, and no exclude pattern.
+// Excluded pages are not loaded: lists, taxonomies, feeds and the series or related pages of the
+// built pages leave them out, links to them are kept as written, and their previous output is
+// left in place.
+type ContentFilter struct {
+	Include []string `toml:"include"`
+	Exclude []string `toml:"exclude"`
+}
+
+// active reports whether the filter excludes anything
+func (f ContentFilter) active() bool {
+	return len(f.Include) > 0 || len(f.Exclude) > 0
+}
+
+// validate checks the syntax of every pattern
+func (f ContentFilter) validate() error {
+	for _, pattern := range slices.Concat(f.Include, f.Exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid content pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the content file with the slash-separated path is built
+func (f ContentFilter) matches(rel string) bool {
+	included := len(f.Include) == 0
+	for _, pattern := range f.Include {
+		if matchContentPattern(pattern, rel) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, pattern := range f.Exclude {
+		if matchContentPattern(pattern, rel) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchContentPattern matches a glob against the path and each of its parent directories
+func matchContentPattern(pattern, rel string) bool {
+	pattern = strings.Trim(pattern, "/")
+	for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// contentFilterFlags registers --contentInclude and --contentExclude, which replace the
+// patterns of [build.filter]
+func contentFilterFlags(flags *flag.FlagSet, opts *buildOptions) {
+	flags.Func("contentInclude", "build only the content matching these comma-separated globs, e.g. docs", func(v string) error {
+		opts.ContentInclude = append(opts.ContentInclude, splitPatterns(v)...)
+		return nil
+	})
+	flags.Func("contentExclude", "skip the content matching these comma-separated globs, e.g. blog,drafts/*", func(v string) error {
+		opts.ContentExclude = append(opts.ContentExclude, splitPatterns(v)...)
+		return nil
+	})
+}
+
+// splitPatterns splits a comma-separated list of patterns
+func splitPatterns(v string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(v, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// filterContent drops the content files the filter excludes
+func filterContent(files []contentFile, filter ContentFilter) ([]contentFile, error) {
+	if !filter.active() {
+		return files, nil
+	}
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	kept := files[:0]
+	for _, f := range files {
+		if filter.matches(filepath.ToSlash(f.RelPath)) {
+			kept = append(kept, f)
+		}
+	}
+	log.Printf("Partial build: %d of %d content files match the content filter", len(kept), len(files))
+	return kept, nil
+}
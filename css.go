@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CSSConfig configures the post-processing of the stylesheets of the output:
+//
+//	[build.css]
+//	purge = true
+//	safelist = ["is-open", "js-*"]
+//	critical = true
+//
+// Purge removes the rules whose selectors name a class, id or element used by no HTML or
+// JavaScript file of the output; fingerprinted stylesheets get a new hash and the pages linking
+// them are updated. Critical inlines, into every page, the rules used by the pages of its layout
+// and loads its stylesheets without blocking rendering. The inline handler that does so needs
+// 'unsafe-hashes' under a Content-Security-Policy.
+type CSSConfig struct {
+	Purge bool `toml:"purge"`
+	// Safelist holds globs of classes, ids and elements that are always kept, such as those
+	// added by scripts from other sites
+	Safelist []string `toml:"safelist"`
+	Critical bool     `toml:"critical"`
+	// CriticalMaxSize is the most CSS in bytes inlined into a page, 14336 (one round trip) by
+	// default; layouts needing more are left alone
+	CriticalMaxSize int `toml:"criticalMaxSize"`
+}
+
+// defaultCriticalMaxSize fits the critical CSS into the first round trip along with the page
+const defaultCriticalMaxSize = 14 << 10
+
+var (
+	htmlElementPattern = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9-]*)`)
+	htmlClassPattern   = regexp.MustCompile(`(?is)\s(class|id)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	scriptTokenPattern = regexp.MustCompile(`[A-Za-z_][\w-]*`)
+	cssClassPattern    = regexp.MustCompile(`([.#])((?:[\w-]|\\.)+)`)
+	cssParensPattern   = regexp.MustCompile(`\([^()]*\)|\[[^\[\]]*\]`)
+	cssPseudoPattern   = regexp.MustCompile(`(^|[^\\])::?[\w-]+`)
+	cssTypePattern     = regexp.MustCompile(`^[a-zA-Z][\w-]*`)
+	cssEscapePattern   = regexp.MustCompile(`\\(.)`)
+	stylesheetPattern  = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	htmlAttrPattern    = regexp.MustCompile(`(?is)\s([a-z-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// cssUsage holds the element names, classes and ids that stylesheet rules are kept for
+type cssUsage struct {
+	tags, names map[string]bool
+	safelist    []string
+}
+
+func newCSSUsage(safelist []string) *cssUsage {
+	return &cssUsage{tags: map[string]bool{}, names: map[string]bool{}, safelist: safelist}
+}
+
+// addHTML records the elements, classes and ids of an HTML document
+func (u *cssUsage) addHTML(data []byte) {
+	for _, m := range htmlElementPattern.FindAllSubmatch(data, -1) {
+		u.tags[strings.ToLower(string(m[1]))] = true
+	}
+	for _, m := range htmlClassPattern.FindAllSubmatch(data, -1) {
+		for _, name := range strings.Fields(string(m[2]) + " " + string(m[3])) {
+			u.names[name] = true
+		}
+	}
+}
+
+// addScript records every word of a script as a possible class or id, since scripts add them
+func (u *cssUsage) addScript(data []byte) {
+	for _, token := range scriptTokenPattern.FindAll(data, -1) {
+		u.names[string(token)] = true
+	}
+}
+
+// safe reports whether a name matches the safelist
+func (u *cssUsage) safe(name string) bool {
+	for _, pattern := range u.safelist {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// keeps reports whether a selector may match, i.e. every class, id and element it names is used.
+// Arguments of pseudo-classes like :not() and attribute selectors are ignored, so selectors are
+// only removed when they cannot match.
+func (u *cssUsage) keeps(selector string) bool {
+	for {
+		stripped := cssParensPattern.ReplaceAllString(selector, "")
+		if stripped == selector {
+			break
+		}
+		selector = stripped
+	}
+	// Escaped colons like .md\:flex are part of a class
+	for {
+		stripped := cssPseudoPattern.ReplaceAllString(selector, "$1")
+		if stripped == selector {
+			break
+		}
+		selector = stripped
+	}
+	for _, m := range cssClassPattern.FindAllStringSubmatch(selector, -1) {
+		name := cssEscapePattern.ReplaceAllString(m[2], "$1")
+		if !u.names[name] && !u.safe(name) {
+			return false
+		}
+	}
+	for _, compound := range strings.FieldsFunc(selector, func(r rune) bool { return strings.ContainsRune(" \t\n\r>+~", r) }) {
+		tag := strings.ToLower(cssTypePattern.FindString(compound))
+		if tag != "" && !u.tags[tag] && !u.safe(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// purgeCSS returns the stylesheet without the rules the usage does not keep. Conditional group
+// rules such as @media are purged recursively and dropped once empty; other at-rules such as
+// @font-face and @keyframes are kept as written.
+func purgeCSS(css string, u *cssUsage) string {
+	var out strings.Builder
+	for _, rule := range splitCSSRules(css) {
+		switch {
+		case rule.raw:
+			out.WriteString(rule.prelude + "\n")
+			continue
+		case rule.block == nil:
+			out.WriteString(rule.prelude + ";\n")
+			continue
+		}
+		block := *rule.block
+		if strings.HasPrefix(rule.prelude, "@") {
+			name := strings.ToLower(strings.TrimPrefix(strings.Fields(rule.prelude)[0], "@"))
+			switch name {
+			case "media", "supports", "layer", "container", "document", "-moz-document", "scope":
+				block = purgeCSS(block, u)
+				if strings.TrimSpace(block) == "" {
+					continue
+				}
+			}
+			fmt.Fprintf(&out, "%s{%s}\n", rule.prelude, block)
+			continue
+		}
+		var kept []string
+		for _, selector := range splitCSSList(rule.prelude) {
+			if u.keeps(selector) {
+				kept = append(kept, selector)
+			}
+		}
+		if len(kept) > 0 {
+			fmt.Fprintf(&out, "%s{%s}\n", strings.Join(kept, ","), block)
+		}
+	}
+	return out.String()
+}
+
+// cssRule is a top-level rule of a stylesheet; statements like @import have no block and a
+// license comment is kept raw
+type cssRule struct {
+	prelude string
+	block   *string
+	raw     bool
+}
+
+// splitCSSRules splits a stylesheet into its top-level rules, dropping comments other than
+// /*! license comments
+func splitCSSRules(css string) []cssRule {
+	var rules []cssRule
+	var prelude strings.Builder
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		switch {
+		case c == '/' && i+1 < len(css) && css[i+1] == '*':
+			end := len(css)
+			if j := strings.Index(css[i+2:], "*/"); j >= 0 {
+				end = i + 2 + j + 2
+			}
+			if strings.HasPrefix(css[i:], "/*!") {
+				rules = append(rules, cssRule{prelude: css[i:end], raw: true})
+			}
+			i = end - 1
+		case c == '"' || c == '\'':
+			end := cssStringEnd(css, i)
+			prelude.WriteString(css[i:end])
+			i = end - 1
+		case c == ';':
+			if p := strings.TrimSpace(prelude.String()); p != "" {
+				rules = append(rules, cssRule{prelude: p})
+			}
+			prelude.Reset()
+		case c == '{':
+			end := cssBlockEnd(css, i)
+			block := css[i+1 : min(end, len(css))]
+			rules = append(rules, cssRule{prelude: strings.TrimSpace(prelude.String()), block: &block})
+			prelude.Reset()
+			i = end
+		default:
+			prelude.WriteByte(c)
+		}
+	}
+	return rules
+}
+
+// cssStringEnd returns the index after the string starting at i
+func cssStringEnd(css string, i int) int {
+	quote := css[i]
+	for j := i + 1; j < len(css); j++ {
+		switch css[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(css)
+}
+
+// cssBlockEnd returns the index of the brace closing the block opened at i
+func cssBlockEnd(css string, i int) int {
+	depth := 0
+	for j := i; j < len(css); j++ {
+		switch css[j] {
+		case '"', '\'':
+			j = cssStringEnd(css, j) - 1
+		case '/':
+			if j+1 < len(css) && css[j+1] == '*' {
+				if end := strings.Index(css[j+2:], "*/"); end >= 0 {
+					j += end + 3
+				} else {
+					return len(css)
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(css)
+}
+
+// splitCSSList splits a selector list at the commas outside parentheses and brackets
+func splitCSSList(list string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case '"', '\'':
+			i = cssStringEnd(list, i) - 1
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(list[start:]))
+}
+
+// outputUsage collects the usage of every HTML and JavaScript file of the output
+func outputUsage(outputDir string, safelist []string) (*cssUsage, error) {
+	u := newCSSUsage(safelist)
+	err := filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".html" && ext != ".js" && ext != ".mjs" {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if ext == ".html" {
+			u.addHTML(data)
+		} else {
+			u.addScript(data)
+		}
+		return nil
+	})
+	return u, err
+}
+
+// purgeStylesheets removes the unused rules of the stylesheets of the output and of the
+// fingerprinted stylesheets, which must not be written yet. A fingerprinted stylesheet gets
+// the name and integrity of its purged content, replaced in every HTML file.
+func (s *Site) purgeStylesheets(outputDir string) error {
+	cfg := s.Config.Build.CSS
+	if !cfg.Purge {
+		return nil
+	}
+	u, err := outputUsage(outputDir, cfg.Safelist)
+	if err != nil {
+		return fmt.Errorf("failed to scan output: %w", err)
+	}
+
+	var before, after int
+	err = filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(p), ".css") {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		purged := purgeCSS(string(data), u)
+		before, after = before+len(data), after+len(purged)
+		return os.WriteFile(p, []byte(purged), 0644)
+	})
+	if err != nil {
+		return err
+	}
+
+	replacer := s.purgeFingerprintedStylesheets(u, &before, &after)
+	if replacer != nil {
+		err = filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(p, ".html") {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if out := replacer.Replace(string(data)); out != string(data) {
+				return os.WriteFile(p, []byte(out), 0644)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if before > 0 {
+		log.Printf("Purged CSS from %d to %d bytes", before, after)
+	}
+	return nil
+}
+
+// purgeFingerprintedStylesheets purges the fingerprinted stylesheets in place and returns the
+// replacements of their old names and integrity values, or nil when none changed
+func (s *Site) purgeFingerprintedStylesheets(u *cssUsage, before, after *int) *strings.Replacer {
+	s.assets.mu.Lock()
+	defer s.assets.mu.Unlock()
+	names := make([]string, 0, len(s.assets.assets))
+	for name := range s.assets.assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var replacements []string
+	for _, name := range names {
+		asset := s.assets.assets[name]
+		if !strings.EqualFold(path.Ext(name), ".css") {
+			continue
+		}
+		data, err := os.ReadFile(asset.source)
+		if err != nil {
+			warnf("Failed to purge %s: %v", name, err)
+			continue
+		}
+		purged := []byte(purgeCSS(string(data), u))
+		*before, *after = *before+len(data), *after+len(purged)
+		if bytes.Equal(purged, data) {
+			continue
+		}
+		fingerprinted, integrity, err := s.fingerprintName(name, purged)
+		if err != nil {
+			warnf("Failed to purge %s: %v", name, err)
+			continue
+		}
+		// html/template writes the + of base64 in attributes as &#43;
+		escape := strings.NewReplacer("+", "&#43;").Replace
+		replacements = append(replacements, asset.name, fingerprinted, asset.integrity, integrity, escape(asset.integrity), escape(integrity))
+		asset.name, asset.integrity, asset.content = fingerprinted, integrity, purged
+	}
+	if len(replacements) == 0 {
+		return nil
+	}
+	return strings.NewReplacer(replacements...)
+}
+
+// inlineCriticalCSS inlines into every page the rules of its stylesheets used by the pages of
+// its layout, and makes the stylesheets load without blocking rendering. With touched set only
+// the layouts of those pages are processed, as after they were re-rendered.
+func (s *Site) inlineCriticalCSS(outputDir string, touched []*Page) error {
+	cfg := s.Config.Build.CSS
+	if !cfg.Critical || s.templates == nil {
+		return nil
+	}
+	if cfg.CriticalMaxSize == 0 {
+		cfg.CriticalMaxSize = defaultCriticalMaxSize
+	}
+	base, err := url.Parse(s.BaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid baseURL: %w", err)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	only := map[*Page]bool{}
+	for _, p := range touched {
+		only[p] = true
+	}
+	s.templates.mu.Lock()
+	layouts := map[string][]string{}
+	for layout, pages := range s.templates.pages {
+		if touched != nil && !slices.ContainsFunc(pages, func(p *Page) bool { return only[p] }) {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, page := range pages {
+			if !seen[page.outputPath] {
+				seen[page.outputPath] = true
+				layouts[layout] = append(layouts[layout], page.outputPath)
+			}
+		}
+	}
+	s.templates.mu.Unlock()
+
+	sheets := map[string]string{}
+	inlined := 0
+	for _, layout := range sortedLayouts(layouts) {
+		files := map[string][]byte{}
+		u := newCSSUsage(cfg.Safelist)
+		for _, rel := range layouts[layout] {
+			file, err := outputFile(outputDir, filepath.ToSlash(rel))
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				// Pages of earlier renders may be gone from the output
+				continue
+			}
+			files[file] = data
+			u.addHTML(data)
+		}
+
+		// The pages of a layout are written once all of them fit
+		critical := map[string]string{}
+		largest := 0
+		for file, data := range files {
+			rel, err := filepath.Rel(outputDir, file)
+			if err != nil {
+				return err
+			}
+			page := base.ResolveReference(&url.URL{Path: filepath.ToSlash(rel)})
+			out, size := s.withCriticalCSS(data, page, base, outputDir, u, sheets, critical)
+			files[file] = out
+			largest = max(largest, size)
+		}
+		if largest > cfg.CriticalMaxSize {
+			warnf("Not inlining critical CSS for layout %s, %d bytes is more than criticalMaxSize", layout, largest)
+			continue
+		}
+		for file, out := range files {
+			if err := os.WriteFile(file, out, 0644); err != nil {
+				return err
+			}
+			inlined++
+		}
+	}
+	if inlined > 0 {
+		log.Printf("Inlined critical CSS into %d pages", inlined)
+	}
+	return nil
+}
+
+// withCriticalCSS rewrites the blocking stylesheet links of a page, returning the page and the
+// size of the CSS inlined into it. sheets caches the stylesheets read and critical the critical
+// CSS of each stylesheet for the layout.
+func (s *Site) withCriticalCSS(data []byte, page, base *url.URL, outputDir string, u *cssUsage, sheets, critical map[string]string) ([]byte, int) {
+	size := 0
+	out := stylesheetPattern.ReplaceAllFunc(data, func(tag []byte) []byte {
+		attrs := map[string]string{}
+		for _, m := range htmlAttrPattern.FindAllSubmatch(tag, -1) {
+			attrs[strings.ToLower(string(m[1]))] = string(m[2]) + string(m[3]) + string(m[4])
+		}
+		if !strings.EqualFold(attrs["rel"], "stylesheet") || attrs["onload"] != "" {
+			return tag
+		}
+		if media := strings.ToLower(attrs["media"]); media != "" && media != "all" && media != "screen" {
+			return tag
+		}
+		ref, err := url.Parse(attrs["href"])
+		if err != nil {
+			return tag
+		}
+		target := page.ResolveReference(ref)
+		if target.Host != base.Host || !strings.HasPrefix(target.Path, base.Path) {
+			return tag
+		}
+		sitePath := strings.TrimPrefix(target.Path, base.Path)
+		css, ok := critical[sitePath]
+		if !ok {
+			sheet, ok := sheets[sitePath]
+			if !ok {
+				file, err := outputFile(outputDir, sitePath)
+				if err != nil {
+					return tag
+				}
+				content, err := os.ReadFile(file)
+				if err != nil {
+					return tag
+				}
+				sheet = string(content)
+				sheets[sitePath] = sheet
+			}
+			css = strings.TrimSpace(purgeCSS(sheet, u))
+			critical[sitePath] = css
+		}
+		size += len(css)
+		preload := bytes.Replace(tag, []byte(attrRaw(tag, "rel")), []byte(`rel="preload" as="style" onload="this.onload=null;this.rel='stylesheet'"`), 1)
+		return []byte(fmt.Sprintf("<style>%s</style>%s<noscript>%s</noscript>", css, preload, tag))
+	})
+	return out, size
+}
+
+// attrRaw returns an attribute of a tag as written, e.g. rel="stylesheet"
+func attrRaw(tag []byte, name string) string {
+	for _, m := range htmlAttrPattern.FindAllSubmatch(tag, -1) {
+		if strings.EqualFold(string(m[1]), name) {
+			return strings.TrimSpace(string(m[0]))
+		}
+	}
+	return ""
+}
+
+// sortedLayouts returns the layouts in name order
+func sortedLayouts(layouts map[string][]string) []string {
+	names := make([]string, 0, len(layouts))
+	for name := range layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DaemonConfig configures `daemon`, which rebuilds the site when a webhook fires, see [daemon]
+type DaemonConfig struct {
+	// Secret authenticates webhook calls and /status; HERO_DAEMON_SECRET overrides it
+	Secret string `toml:"secret"`
+	// Pull runs `git pull --ff-only` before every triggered build
+	Pull bool `toml:"pull"`
+	// Debounce collects triggers arriving in quick succession into one build, "2s" by default
+	Debounce string `toml:"debounce"`
+}
+
+// daemonStatus is the state reported by the status endpoint of the daemon
+type daemonStatus struct {
+	Builds    int        `json:"builds"`
+	Building  bool       `json:"building"`
+	Pending   bool       `json:"pending"`
+	Coalesced int        `json:"coalesced"`
+	LastStart *time.Time `json:"lastStart,omitempty"`
+	LastEnd   *time.Time `json:"lastEnd,omitempty"`
+	Duration  string     `json:"duration,omitempty"`
+	Pages     int        `json:"pages"`
+	Error     string     `json:"error,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// buildDaemon rebuilds the site from queued triggers, one build at a time
+type buildDaemon struct {
+	server   *devServer
+	secret   string
+	pull     bool
+	debounce time.Duration
+
+	// trigger holds at most one queued build; further triggers join it
+	trigger chan string
+
+	mu     sync.RWMutex
+	status daemonStatus
+}
+
+// runDaemon implements `daemon`: it builds the site into public/ and rebuilds it whenever an
+// authenticated POST reaches /hooks/rebuild, e.g. from a CMS or a git hosting webhook
+func runDaemon(args []string) {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	opts := buildOptions{PublicDir: "./public/"}
+	flags.StringVar(&opts.Environment, "environment", envOr("HERO_ENVIRONMENT", "production"), "build environment exposed to templates as hero.Environment")
+	port := flags.Int("port", 8080, "port to listen on")
+	bind := flags.String("bind", "localhost", "interface to listen on, e.g. 0.0.0.0 for every interface")
+	publishFlags(flags, &opts)
+	flags.Parse(args)
+
+	config, err := loadEnvironmentConfig(opts.Environment)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	cfg := config.Daemon
+	secret := envOr("HERO_DAEMON_SECRET", cfg.Secret)
+	if secret == "" {
+		log.Fatalf("Set daemon.secret or HERO_DAEMON_SECRET to authenticate webhooks")
+	}
+	debounce := 2 * time.Second
+	if cfg.Debounce != "" {
+		if debounce, err = time.ParseDuration(cfg.Debounce); err != nil {
+			log.Fatalf("Invalid daemon.debounce %q: %v", cfg.Debounce, err)
+		}
+	}
+
+	daemon := &buildDaemon{
+		server:   &devServer{opts: opts},
+		secret:   secret,
+		pull:     cfg.Pull,
+		debounce: debounce,
+		trigger:  make(chan string, 1),
+	}
+	daemon.trigger <- "startup"
+	go daemon.run()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hooks/rebuild", daemon.handleTrigger)
+	mux.HandleFunc("GET /status", daemon.handleStatus)
+	daemon.server.handleContentAPI(mux)
+	addr := net.JoinHostPort(*bind, strconv.Itoa(*port))
+	fmt.Printf("Listening for rebuild webhooks at http://%s/hooks/rebuild\n", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+// run builds once per queued trigger, waiting for the debounce period to pass without new
+// triggers first
+func (d *buildDaemon) run() {
+	for reason := range d.trigger {
+		timer := time.NewTimer(d.debounce)
+	collect:
+		for {
+			select {
+			case more := <-d.trigger:
+				reason = more
+				d.mu.Lock()
+				d.status.Coalesced++
+				d.mu.Unlock()
+				timer.Reset(d.debounce)
+			case <-timer.C:
+				break collect
+			}
+		}
+		d.build(reason)
+	}
+}
+
+// build pulls the repository when configured and rebuilds the site, recording the outcome
+func (d *buildDaemon) build(reason string) {
+	start := time.Now()
+	d.mu.Lock()
+	d.status.Building, d.status.Pending, d.status.Reason = true, false, reason
+	d.status.LastStart = &start
+	d.mu.Unlock()
+
+	log.Printf("Rebuilding (%s)", reason)
+	var err error
+	if d.pull {
+		if _, pullErr := gitOutput("", "pull", "--ff-only", "--quiet"); pullErr != nil {
+			err = fmt.Errorf("failed to pull: %w", pullErr)
+		}
+	}
+	if err == nil {
+		err = d.server.rebuild()
+	}
+	if err != nil {
+		log.Printf("Rebuild failed: %v", err)
+	}
+
+	end := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status.Builds++
+	d.status.Building = false
+	d.status.LastEnd = &end
+	d.status.Duration = end.Sub(start).Round(time.Millisecond).String()
+	d.status.Error = ""
+	if err != nil {
+		d.status.Error = err.Error()
+	}
+	d.server.mu.RLock()
+	if d.server.site != nil {
+		d.status.Pages = len(d.server.site.AllPages)
+	}
+	d.server.mu.RUnlock()
+}
+
+// handleTrigger queues a rebuild for an authenticated webhook; a trigger arriving while one is
+// already queued joins it
+func (d *buildDaemon) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "could not read body", http.StatusBadRequest)
+		return
+	}
+	if !d.authorized(r, body) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	reason := "webhook"
+	if event := r.Header.Get("X-GitHub-Event"); event != "" {
+		reason += " " + event
+	} else if event := r.Header.Get("X-Gitlab-Event"); event != "" {
+		reason += " " + event
+	}
+
+	queued := true
+	select {
+	case d.trigger <- reason:
+	default:
+		queued = false
+	}
+	d.mu.Lock()
+	d.status.Pending = true
+	if !queued {
+		d.status.Coalesced++
+	}
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]bool{"queued": queued, "coalesced": !queued})
+}
+
+// authorized checks the shared secret as a bearer token, an X-Hero-Secret or X-Gitlab-Token
+// header, or a GitHub X-Hub-Signature-256 HMAC of the body
+func (d *buildDaemon) authorized(r *http.Request, body []byte) bool {
+	equal := func(given string) bool {
+		return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(d.secret)) == 1
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && equal(token) {
+		return true
+	}
+	if equal(r.Header.Get("X-Hero-Secret")) || equal(r.Header.Get("X-Gitlab-Token")) {
+		return true
+	}
+	if signature, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="); ok {
+		mac := hmac.New(sha256.New, []byte(d.secret))
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(signature), []byte(expected))
+	}
+	return false
+}
+
+// handleStatus reports the queue and the outcome of the last build as JSON to authenticated
+// requests, as build errors may name files of the server
+func (d *buildDaemon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !d.authorized(r, nil) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	d.mu.RLock()
+	status := d.status
+	d.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(status)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Date sources that can be listed in [frontmatter] besides front matter keys
+const (
+	dateSourceGit         = ":git"
+	dateSourceFileModTime = ":fileModTime"
+	dateSourceFilename    = ":filename"
+	dateSourceDefault     = ":default"
+)
+
+// FrontMatterConfig lists, for the title and every page date, the front matter keys and special
+// sources (":git", ":fileModTime", ":filename") tried in order; ":default" expands to the built-in list.
+// For the title, ":filename" is the file or bundle name without a date prefix, de-slugified.
+type FrontMatterConfig struct {
+	Title       []string `toml:"title"`
+	Date        []string `toml:"date"`
+	PublishDate []string `toml:"publishDate"`
+	Lastmod     []string `toml:"lastmod"`
+	ExpiryDate  []string `toml:"expiryDate"`
+}
+
+// Built-in date sources, used for dates that are not configured
+var (
+	defaultTitleSources       = []string{"title", dateSourceFilename}
+	defaultDateSources        = []string{"date", "publishDate", "pubDate", "published", "lastmod"}
+	defaultPublishDateSources = []string{"publishDate", "pubDate", "published", "date"}
+	defaultLastmodSources     = []string{dateSourceGit, "lastmod", "modified", "updated", "date", "publishDate"}
+	defaultExpiryDateSources  = []string{"expiryDate", "unpublishDate"}
+)
+
+// filenameDatePattern matches a date prefix such as 2024-05-01-my-post
+var filenameDatePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})[-_]`)
+
+// resolveDates sets Date, PublishDate, Lastmod and ExpiryDate from the configured sources.
+// GitInfo must already be attached for ":git" to apply.
+func (s *Site) resolveDates(p *Page) {
+	fm := s.Config.FrontMatter
+	p.Date = s.firstDate(p, fm.Date, defaultDateSources)
+	p.PublishDate = s.firstDate(p, fm.PublishDate, defaultPublishDateSources)
+	p.Lastmod = s.firstDate(p, fm.Lastmod, defaultLastmodSources)
+	p.ExpiryDate = s.firstDate(p, fm.ExpiryDate, defaultExpiryDateSources)
+}
+
+// resolveTitle sets the title from the configured sources when the page has none, so pages
+// with missing or malformed front matter are not rendered without a title
+func (s *Site) resolveTitle(p *Page) {
+	sources := s.Config.FrontMatter.Title
+	if len(sources) == 0 || slices.Contains(sources, dateSourceDefault) {
+		sources = defaultTitleSources
+	}
+	for _, source := range sources {
+		var title string
+		switch source {
+		case dateSourceFilename:
+			title = filenameTitle(p.File)
+		default:
+			title, _ = frontMatterValue(p.Params, source).(string)
+		}
+		if title != "" {
+			p.Title = title
+			return
+		}
+	}
+}
+
+// filenameTitle de-slugifies the file name, or the directory name of a bundle, without its date prefix
+func filenameTitle(file *File) string {
+	name := contentName(file)
+	if m := filenameDatePattern.FindStringSubmatch(name); m != nil {
+		name = name[len(m[0]):]
+	}
+	return titleCase(strings.ReplaceAll(name, "_", "-"))
+}
+
+// contentName returns the base file name of a page, or the directory name for an index file
+func contentName(file *File) string {
+	if file == nil {
+		return ""
+	}
+	name := file.BaseFileName
+	if name == "index" || name == "_index" {
+		name = path.Base(strings.TrimSuffix(file.Dir, "/"))
+		if name == "." || name == "/" {
+			return ""
+		}
+	}
+	return name
+}
+
+// firstDate returns the first non-zero date among the sources, using defaults when none are configured
+func (s *Site) firstDate(p *Page, sources, defaults []string) time.Time {
+	if len(sources) == 0 {
+		sources = defaults
+	}
+	for _, source := range sources {
+		var date time.Time
+		switch source {
+		case dateSourceDefault:
+			date = s.firstDate(p, defaults, defaults)
+		case dateSourceGit:
+			if p.GitInfo != nil {
+				date = p.GitInfo.AuthorDate
+			}
+		case dateSourceFileModTime:
+			if p.source != nil {
+				if info, err := os.Stat(p.source.Path); err == nil {
+					date = info.ModTime()
+				}
+			}
+		case dateSourceFilename:
+			date = filenameDate(p.File)
+		default:
+			date = toTime(frontMatterValue(p.Params, source))
+		}
+		if !date.IsZero() {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// filenameDate parses a date prefix of the file name, or of the directory name for a bundle
+func filenameDate(file *File) time.Time {
+	if m := filenameDatePattern.FindStringSubmatch(contentName(file)); m != nil {
+		return toTime(m[1])
+	}
+	return time.Time{}
+}
+
+// frontMatterValue looks a key up in front matter params, ignoring case as migrated content varies
+func frontMatterValue(params map[string]any, key string) any {
+	if v, ok := params[key]; ok {
+		return v
+	}
+	for k, v := range params {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+// formatDate renders a date for readers with the given layout, falling back to dateFormat and
+// then to the date layout of the site language, with month and day names in that language
+func (s *Site) formatDate(t time.Time, layout string) string {
+	l := s.locale()
+	if layout == "" {
+		layout = s.Config.DateFormat
+	}
+	if layout == "" {
+		layout = l.dateFormat
+	}
+	return l.formatTime(t, layout)
+}
+
+// timeTag renders a <time> element with a machine-readable datetime attribute and a human-readable
+// text; the optional layout overrides dateFormat. Zero and unparsable dates render nothing.
+func (s *Site) timeTag(v any, layout ...string) template.HTML {
+	t := toTime(v)
+	if t.IsZero() {
+		return ""
+	}
+	machine := t.Format(time.RFC3339)
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Location() == time.UTC {
+		// A plain date in front matter has no time of day worth claiming
+		machine = t.Format("2006-01-02")
+	}
+	human := s.formatDate(t, strings.Join(layout, ""))
+	return template.HTML(fmt.Sprintf(`<time datetime="%s">%s</time>`, machine, html.EscapeString(human)))
+}
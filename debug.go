@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/template/parse"
+	"time"
+)
+
+// debugNamespace is returned by the debug template function, for {{ debug.Dump . }} and
+// {{ debug.Printf "format" args }}
+type debugNamespace struct{}
+
+// Dump returns a readable outline of a value for a <pre> block, following pointers two levels
+// deep and showing at most ten items of a list
+func (debugNamespace) Dump(v any) string {
+	var b strings.Builder
+	dumpValue(&b, reflect.ValueOf(v), 0, map[uintptr]bool{})
+	return b.String()
+}
+
+// Printf logs a formatted message to the build console and outputs nothing
+func (debugNamespace) Printf(format string, args ...any) string {
+	log.Printf("Template: "+format, args...)
+	return ""
+}
+
+// Limits of debug.Dump, which would otherwise print the whole site from any page
+const (
+	dumpMaxDepth = 2
+	dumpMaxItems = 10
+)
+
+// dumpValue writes v indented by depth, marking values already printed above it
+func dumpValue(b *strings.Builder, v reflect.Value, depth int, visiting map[uintptr]bool) {
+	indent := strings.Repeat("  ", depth+1)
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		if v.Kind() == reflect.Pointer {
+			if visiting[v.Pointer()] {
+				fmt.Fprintf(b, "<cycle %s>", v.Type())
+				return
+			}
+			visiting[v.Pointer()] = true
+			defer delete(visiting, v.Pointer())
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		b.WriteString("nil")
+		return
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		b.WriteString(t.Format(time.RFC3339))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if depth >= dumpMaxDepth {
+			fmt.Fprintf(b, "%s{…}", v.Type())
+			return
+		}
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		fmt.Fprintf(b, "%s {\n", v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fmt.Fprintf(b, "%s%s: ", indent, field.Name)
+			dumpValue(b, v.Field(i), depth+1, visiting)
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "%s}", indent[2:])
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		b.WriteString("{\n")
+		for _, key := range keys {
+			fmt.Fprintf(b, "%s%v: ", indent, key)
+			dumpValue(b, v.MapIndex(key), depth+1, visiting)
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "%s}", indent[2:])
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			fmt.Fprintf(b, "%q", v.Bytes())
+			return
+		}
+		fmt.Fprintf(b, "[%d items\n", v.Len())
+		for i := 0; i < v.Len() && i < dumpMaxItems; i++ {
+			b.WriteString(indent)
+			dumpValue(b, v.Index(i), depth+1, visiting)
+			b.WriteString("\n")
+		}
+		if v.Len() > dumpMaxItems {
+			fmt.Fprintf(b, "%s…\n", indent)
+		}
+		fmt.Fprintf(b, "%s]", indent[2:])
+	case reflect.String:
+		fmt.Fprintf(b, "%q", v.String())
+	default:
+		fmt.Fprintf(b, "%v", v.Interface())
+	}
+}
+
+// templateMetrics counts the executions and cumulative time of every template; the time of a
+// template includes the partials it executes
+type templateMetrics struct {
+	mu    sync.Mutex
+	stats map[string]*templateStat
+}
+
+type templateStat struct {
+	Name  string
+	Count int
+	Total time.Duration
+}
+
+// templateRun is an execution of a template in progress
+type templateRun struct {
+	name  string
+	start time.Time
+}
+
+// Names of the functions the instrumented templates call
+const (
+	metricStartFunc = "_hero_template_start"
+	metricEndFunc   = "_hero_template_end"
+)
+
+func newTemplateMetrics() *templateMetrics {
+	return &templateMetrics{stats: map[string]*templateStat{}}
+}
+
+// funcs returns the functions called by instrumented templates
+func (m *templateMetrics) funcs() template.FuncMap {
+	return template.FuncMap{
+		metricStartFunc: func(name string) templateRun { return templateRun{name: name, start: time.Now()} },
+		metricEndFunc: func(run templateRun) string {
+			elapsed := time.Since(run.start)
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			stat, ok := m.stats[run.name]
+			if !ok {
+				stat = &templateStat{Name: run.name}
+				m.stats[run.name] = stat
+			}
+			stat.Count++
+			stat.Total += elapsed
+			return ""
+		},
+	}
+}
+
+// instrument makes every template of the set, including those of define blocks, record its
+// executions. It must run before the first execution, which escapes the templates.
+func (m *templateMetrics) instrument(tmpl *template.Template) error {
+	return wrapTemplates(tmpl, func(name string) string {
+		return fmt.Sprintf("{{$__hero_run := %s %q}}{{%s $__hero_run}}", metricStartFunc, name, metricEndFunc)
+	}, m.funcs())
+}
+
+// wrapTemplates adds the two actions of text(name) around every template of the set, the first
+// before its content and the second after it. Blocks like "main" are defined by many layouts, so
+// they are named with their file.
+func wrapTemplates(tmpl *template.Template, text func(name string) string, funcs template.FuncMap) error {
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		name := t.Name()
+		if t.Tree.ParseName != "" && t.Tree.ParseName != name {
+			name += " (" + t.Tree.ParseName + ")"
+		}
+		trees, err := parse.Parse("wrap", text(name), "", "", funcs)
+		if err != nil {
+			return fmt.Errorf("failed to instrument template %s: %w", t.Name(), err)
+		}
+		nodes := trees["wrap"].Root.Nodes
+		root := t.Tree.Root
+		root.Nodes = append(append([]parse.Node{nodes[0]}, root.Nodes...), nodes[1])
+	}
+	return nil
+}
+
+// report writes the templates by cumulative time, slowest first
+func (m *templateMetrics) report(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make([]*templateStat, 0, len(m.stats))
+	for _, stat := range m.stats {
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Total > stats[j].Total })
+	fmt.Fprintln(w, "--- Template Metrics ---")
+	fmt.Fprintf(w, "%12s  %12s  %6s  %s\n", "cumulative", "average", "count", "template")
+	for _, stat := range stats {
+		average := stat.Total / time.Duration(stat.Count)
+		fmt.Fprintf(w, "%12v  %12v  %6d  %s\n", stat.Total.Round(time.Microsecond), average.Round(time.Microsecond), stat.Count, stat.Name)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Kinds of deprecated names
+const (
+	deprecatedConfig      = "config key"
+	deprecatedFrontMatter = "front matter key"
+	deprecatedTemplate    = "template function"
+)
+
+// deprecation is a name that still works but has been replaced. Config keys are dotted paths.
+type deprecation struct {
+	Kind  string
+	Old   string
+	New   string
+	Since string
+}
+
+// deprecations lists every deprecated name; `migrate config` rewrites the config keys
+var deprecations = []deprecation{
+	{Kind: deprecatedConfig, Old: "paginate", New: "pagination.pagerSize", Since: "0.1.0"},
+	{Kind: deprecatedFrontMatter, Old: "pubDate", New: "publishDate", Since: "0.1.0"},
+	{Kind: deprecatedFrontMatter, Old: "unpublishDate", New: "expiryDate", Since: "0.1.0"},
+	{Kind: deprecatedTemplate, Old: "urlize", New: "slugify", Since: "0.1.0"},
+}
+
+// deprecationLog counts the uses of deprecated names during a build so each is reported once
+type deprecationLog struct {
+	mu     sync.Mutex
+	counts map[deprecation]int
+	where  map[deprecation]string
+}
+
+// deprecated records a use of a deprecated name; where is an example location for the report
+func (s *Site) deprecated(kind, old, where string) {
+	for _, d := range deprecations {
+		if d.Kind != kind || d.Old != old {
+			continue
+		}
+		s.deprecations.mu.Lock()
+		if s.deprecations.counts == nil {
+			s.deprecations.counts = map[deprecation]int{}
+			s.deprecations.where = map[deprecation]string{}
+		}
+		s.deprecations.counts[d]++
+		if _, ok := s.deprecations.where[d]; !ok {
+			s.deprecations.where[d] = where
+		}
+		s.deprecations.mu.Unlock()
+		return
+	}
+}
+
+// checkFrontMatterDeprecations records the deprecated keys of a page's front matter
+func (s *Site) checkFrontMatterDeprecations(p *Page) {
+	for _, d := range deprecations {
+		if d.Kind != deprecatedFrontMatter {
+			continue
+		}
+		if _, ok := p.Params[d.Old]; ok {
+			s.deprecated(d.Kind, d.Old, p.File.Path)
+		}
+	}
+}
+
+// checkConfigDeprecations records the deprecated keys set in the configuration files
+func (s *Site) checkConfigDeprecations(files []string) {
+	for _, file := range files {
+		raw, err := readRawConfig(file)
+		if err != nil {
+			continue
+		}
+		for _, d := range deprecations {
+			if _, ok := lookupKey(raw, d.Old); d.Kind == deprecatedConfig && ok {
+				s.deprecated(d.Kind, d.Old, file)
+			}
+		}
+	}
+}
+
+// reportDeprecations prints one warning per deprecated name used in the build, with a count
+// and a migration hint
+func (s *Site) reportDeprecations() {
+	s.deprecations.mu.Lock()
+	defer s.deprecations.mu.Unlock()
+	used := make([]deprecation, 0, len(s.deprecations.counts))
+	for d := range s.deprecations.counts {
+		used = append(used, d)
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i].Kind+used[i].Old < used[j].Kind+used[j].Old })
+	for _, d := range used {
+		hint := fmt.Sprintf("use %s instead", d.New)
+		if d.Kind == deprecatedConfig {
+			hint += " or run `migrate config`"
+		}
+		warnf("Deprecated since %s: %s %q used %d time(s), e.g. in %s; %s",
+			d.Since, d.Kind, d.Old, s.deprecations.counts[d], s.deprecations.where[d], hint)
+	}
+}
+
+// runMigrate implements `migrate config`, which moves deprecated keys of config.toml to their
+// replacements. Only the lines of those keys change, so comments and layout are kept. The
+// original file is kept as config.toml.bak.
+func runMigrate(args []string) {
+	if len(args) != 1 || args[0] != "config" {
+		log.Fatalf("Usage: migrate config")
+	}
+	const path = "config.toml"
+	raw, err := readRawConfig(path)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read config: %v", err)
+	}
+	text := string(original)
+	migrated := 0
+	for _, d := range deprecations {
+		value, ok := lookupKey(raw, d.Old)
+		if d.Kind != deprecatedConfig || !ok {
+			continue
+		}
+		_, exists := lookupKey(raw, d.New)
+		if text, err = migrateConfigText(text, d.Old, d.New, exists); err != nil {
+			log.Fatalf("Failed to migrate %s: %v, move it by hand", d.Old, err)
+		}
+		if !exists {
+			setKey(raw, d.New, value)
+		}
+		deleteKey(raw, d.Old)
+		fmt.Printf("%s -> %s\n", d.Old, d.New)
+		migrated++
+	}
+	if migrated == 0 {
+		fmt.Println("No deprecated config keys found")
+		return
+	}
+
+	// The edited text must hold exactly the migrated config
+	check := map[string]any{}
+	if err := toml.Unmarshal([]byte(text), &check); err != nil || !reflect.DeepEqual(check, raw) {
+		log.Fatalf("Failed to migrate config in place, move the keys by hand")
+	}
+	if err := os.WriteFile(path+".bak", original, 0644); err != nil {
+		log.Fatalf("Failed to back up config: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		log.Fatalf("Failed to write config: %v", err)
+	}
+	fmt.Printf("Migrated %d key(s), the previous config is in %s.bak\n", migrated, path)
+}
+
+// readRawConfig parses a configuration file into nested maps
+func readRawConfig(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]any{}
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// lookupKey returns the value at a dotted key path of nested maps
+func lookupKey(raw map[string]any, key string) (any, bool) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := raw[part].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		raw = next
+	}
+	value, ok := raw[parts[len(parts)-1]]
+	return value, ok
+}
+
+// setKey stores a value at a dotted key path, creating the tables on the way
+func setKey(raw map[string]any, key string, value any) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := raw[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			raw[part] = next
+		}
+		raw = next
+	}
+	raw[parts[len(parts)-1]] = value
+}
+
+// deleteKey removes the value at a dotted key path
+func deleteKey(raw map[string]any, key string) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := raw[part].(map[string]any)
+		if !ok {
+			return
+		}
+		raw = next
+	}
+	delete(raw, parts[len(parts)-1])
+}
+
+// configHeaderPattern matches the header of a table of a TOML file, capturing its name
+var configHeaderPattern = regexp.MustCompile(`^\[\s*([\w.-]+)\s*\]`)
+
+// migrateConfigText moves the key old of a TOML file to name, keeping the value and the rest of
+// the file as written; old is deleted when name is already set
+func migrateConfigText(text, old, name string, exists bool) (string, error) {
+	lines := strings.SplitAfter(text, "\n")
+	start, end, ok := configKeySpan(lines, old)
+	if !ok {
+		return "", fmt.Errorf("%s is not written as a plain key", old)
+	}
+	moved := slices.Clone(lines[start:end])
+	rest := slices.Concat(lines[:start], lines[end:])
+	if exists {
+		return strings.Join(rest, ""), nil
+	}
+	oldTable, _ := splitKeyPath(old)
+	table, key := splitKeyPath(name)
+	_, value, _ := strings.Cut(moved[0], "=")
+	if table == oldTable {
+		indent := moved[0][:len(moved[0])-len(strings.TrimLeft(moved[0], " \t"))]
+		moved[0] = indent + key + " =" + value
+		return strings.Join(slices.Insert(rest, start, moved...), ""), nil
+	}
+	moved[0] = key + " =" + value
+	if at, ok := configTableEnd(rest, table); ok {
+		return strings.Join(slices.Insert(rest, at, moved...), ""), nil
+	}
+	newline := "\n"
+	if strings.Contains(text, "\r\n") {
+		newline = "\r\n"
+	}
+	out := strings.Join(rest, "")
+	if out != "" && !strings.HasSuffix(out, "\n") {
+		out += newline
+	}
+	if table != "" {
+		out += newline + "[" + table + "]" + newline
+	}
+	return out + strings.Join(moved, ""), nil
+}
+
+// configKeySpan returns the lines of a dotted key of a TOML file, from the key to the line
+// before the next key or table without the blank lines and comments in between
+func configKeySpan(lines []string, dotted string) (int, int, bool) {
+	table, key := splitKeyPath(dotted)
+	current := ""
+	for i, line := range lines {
+		if strings.HasPrefix(line, "[") {
+			current = "["
+			if m := configHeaderPattern.FindStringSubmatch(line); m != nil {
+				current = m[1]
+			}
+			continue
+		}
+		m := tomlBoundaryPattern.FindStringSubmatch(line)
+		if m == nil || current != table || m[2] != key {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !tomlBoundaryPattern.MatchString(lines[end]) {
+			end++
+		}
+		for end > i+1 && isSpanTrailer(lines[end-1]) {
+			end--
+		}
+		return i, end, true
+	}
+	return 0, 0, false
+}
+
+// configTableEnd returns the line after the last key of a table of a TOML file
+func configTableEnd(lines []string, table string) (int, bool) {
+	for i, line := range lines {
+		if m := configHeaderPattern.FindStringSubmatch(line); m == nil || m[1] != table {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !strings.HasPrefix(lines[end], "[") {
+			end++
+		}
+		for end > i+1 && isSpanTrailer(lines[end-1]) {
+			end--
+		}
+		return end, true
+	}
+	return 0, false
+}
+
+// splitKeyPath splits a dotted key into its table and its last key
+func splitKeyPath(dotted string) (string, string) {
+	if i := strings.LastIndex(dotted, "."); i >= 0 {
+		return dotted[:i], dotted[i+1:]
+	}
+	return "", dotted
+}
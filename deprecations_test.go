@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestMigrateConfigText(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		exists bool
+		want   string
+	}{
+		{
+			name: "new table at the end",
+			text: "# site\npaginate = 7 # per page\ntitle = \"x\"\n\n[params]\n  a = 1\n",
+			want: "# site\ntitle = \"x\"\n\n[params]\n  a = 1\n\n[pagination]\npagerSize = 7 # per page\n",
+		},
+		{
+			name: "into the existing table",
+			text: "paginate = 3\n[pagination]\n# sizes\npath = \"p\"\n\n[x]\ny = 1\n",
+			want: "[pagination]\n# sizes\npath = \"p\"\npagerSize = 3\n\n[x]\ny = 1\n",
+		},
+		{
+			name:   "already set",
+			text:   "paginate = 3\n\n[pagination]\npagerSize = 4\n",
+			exists: true,
+			want:   "\n[pagination]\npagerSize = 4\n",
+		},
+		{
+			name: "multiline value",
+			text: "paginate = [\n  1,\n]\n",
+			want: "\n[pagination]\npagerSize = [\n  1,\n]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := migrateConfigText(tt.text, "paginate", "pagination.pagerSize", tt.exists)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("migrateConfigText() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := migrateConfigText("\"paginate\" = 3\n", "paginate", "pagination.pagerSize", false); err == nil {
+		t.Error("migrateConfigText() with a quoted key succeeded, want an error")
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// diffDir receives the build the diff command compares, so public/ is left untouched
+var diffDir = filepath.Join(".herocgo", "diff")
+
+// maxDiffEdits bounds the line diff of a file; larger rewrites are reported without content
+const maxDiffEdits = 2000
+
+// outputFileInfo is a file of a build output
+type outputFileInfo struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Source and Templates are the project file and the templates the file was generated from,
+	// recorded by the artifact manifest
+	Source    string   `json:"source,omitempty"`
+	Templates []string `json:"templates,omitempty"`
+}
+
+// outputManifest lists the files of a build output, as stored by `diff --save` and the
+// artifact manifest
+type outputManifest struct {
+	Generator string `json:"generator,omitempty"`
+	// Unlisted is the directory of unlisted pages when their output files were left out
+	Unlisted string           `json:"unlisted,omitempty"`
+	Files    []outputFileInfo `json:"files"`
+}
+
+// outputChange is a file added, removed or changed between two builds
+type outputChange struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+// runDiff implements `diff`: it builds the site into a scratch directory and lists the files
+// added, removed and changed compared to public/ (or another directory or a stored manifest),
+// with line diffs of the HTML files
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	opts := buildOptions{PublicDir: diffDir}
+	flags.StringVar(&opts.Environment, "environment", envOr("HERO_ENVIRONMENT", "production"), "build environment exposed to templates as hero.Environment")
+	publishFlags(flags, &opts)
+	against := flags.String("against", "public", "output directory of the previous build")
+	manifest := flags.String("manifest", "", "compare against a manifest stored with --save instead of a directory")
+	save := flags.String("save", "", "write the manifest of the new build to this file")
+	format := flags.String("format", "text", "output format: text or json")
+	contextLines := flags.Int("context", 3, "lines of context around HTML changes")
+	nameOnly := flags.Bool("name-only", false, "list the changed files without their content")
+	exitCode := flags.Bool("exit-code", false, "exit with status 1 when the output changed")
+	flags.Parse(args)
+	if *format != "text" && *format != "json" {
+		log.Fatalf("Unknown format %q, use text or json", *format)
+	}
+
+	var previous map[string]outputFileInfo
+	var err error
+	if *manifest != "" {
+		previous, err = loadOutputManifest(*manifest)
+	} else {
+		previous, err = scanOutput(*against)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read previous build: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	_, current, err := scratchBuild(ctx, opts)
+	if err != nil {
+		log.Fatalf("Failed to build site: %v", err)
+	}
+	if *save != "" {
+		if err := saveOutputManifest(*save, current); err != nil {
+			log.Fatalf("Failed to write manifest: %v", err)
+		}
+	}
+
+	changes := compareOutputs(previous, current)
+	if *manifest == "" && !*nameOnly {
+		for i, change := range changes {
+			if change.Action != "changed" || !strings.HasSuffix(change.Path, ".html") {
+				continue
+			}
+			diff, err := diffFiles(filepath.Join(*against, filepath.FromSlash(change.Path)), filepath.Join(diffDir, filepath.FromSlash(change.Path)), change.Path, *contextLines)
+			if err != nil {
+				warnf("Could not diff %s: %v", change.Path, err)
+			}
+			changes[i].Diff = diff
+		}
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if changes == nil {
+			changes = []outputChange{}
+		}
+		if err := encoder.Encode(changes); err != nil {
+			log.Fatalf("Failed to encode changes: %v", err)
+		}
+	} else {
+		counts := map[string]int{}
+		for _, change := range changes {
+			counts[change.Action]++
+			fmt.Printf("%s %s\n", strings.ToUpper(change.Action[:1]), change.Path)
+		}
+		for _, change := range changes {
+			if change.Diff != "" {
+				fmt.Print("\n" + change.Diff)
+			}
+		}
+		fmt.Printf("\n%d added, %d changed, %d removed\n", counts["added"], counts["changed"], counts["removed"])
+	}
+	if *exitCode && len(changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+// scratchBuild builds the site into the emptied opts.PublicDir and returns its files
+func scratchBuild(ctx context.Context, opts buildOptions) (buildStats, map[string]outputFileInfo, error) {
+	if err := os.RemoveAll(opts.PublicDir); err != nil {
+		return buildStats{}, nil, fmt.Errorf("failed to clear %s: %w", opts.PublicDir, err)
+	}
+	_, stats, err := buildSite(ctx, opts)
+	if err != nil {
+		return stats, nil, err
+	}
+	files, err := scanOutput(opts.PublicDir)
+	if err != nil {
+		return stats, nil, fmt.Errorf("failed to read build: %w", err)
+	}
+	return stats, files, nil
+}
+
+// scanOutput hashes every file below dir; a missing directory is an empty output
+func scanOutput(dir string) (map[string]outputFileInfo, error) {
+	files := map[string]outputFileInfo{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		size, err := io.Copy(h, f)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		files[name] = outputFileInfo{Path: name, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}
+		return nil
+	})
+	return files, err
+}
+
+// loadOutputManifest reads the files of a manifest by path
+func loadOutputManifest(file string) (map[string]outputFileInfo, error) {
+	manifest, err := readOutputManifest(file)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]outputFileInfo{}
+	for _, f := range manifest.Files {
+		files[f.Path] = f
+	}
+	return files, nil
+}
+
+// readOutputManifest reads a manifest saved by saveOutputManifest or written by build.manifest
+func readOutputManifest(file string) (outputManifest, error) {
+	var manifest outputManifest
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("could not parse %s: %w", file, err)
+	}
+	return manifest, nil
+}
+
+// saveOutputManifest writes the files of an output sorted by path
+func saveOutputManifest(file string, files map[string]outputFileInfo) error {
+	manifest := outputManifest{Files: make([]outputFileInfo, 0, len(files))}
+	for _, f := range files {
+		manifest.Files = append(manifest.Files, f)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// compareOutputs lists the files added, removed and changed from previous to current by path
+func compareOutputs(previous, current map[string]outputFileInfo) []outputChange {
+	var changes []outputChange
+	for name, f := range current {
+		old, ok := previous[name]
+		switch {
+		case !ok:
+			changes = append(changes, outputChange{Path: name, Action: "added"})
+		case old.SHA256 != f.SHA256:
+			changes = append(changes, outputChange{Path: name, Action: "changed"})
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			changes = append(changes, outputChange{Path: name, Action: "removed"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// diffFiles returns the unified diff of two text files labelled with name
+func diffFiles(oldFile, newFile, name string, contextLines int) (string, error) {
+	oldData, err := os.ReadFile(oldFile)
+	if err != nil {
+		return "", err
+	}
+	newData, err := os.ReadFile(newFile)
+	if err != nil {
+		return "", err
+	}
+	edits, ok := lineEdits(splitLines(string(oldData)), splitLines(string(newData)))
+	if !ok {
+		return fmt.Sprintf("--- a/%s\n+++ b/%s\n(too many changes to show)\n", name, name), nil
+	}
+	return unifiedDiff(edits, name, contextLines), nil
+}
+
+// splitLines splits text into lines without their line endings
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// lineEdit is one line of an edit script: ' ' kept, '-' removed or '+' added
+type lineEdit struct {
+	op   byte
+	text string
+}
+
+// lineEdits returns the shortest edit script from a to b (Myers' algorithm), or false when it
+// needs more than maxDiffEdits edits
+func lineEdits(a, b []string) ([]lineEdit, bool) {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	// trace[d] holds v[k] for -(d-1) <= k <= d-1 as it was before step d
+	var trace [][]int
+	found := false
+	for d := 0; d <= n+m && !found; d++ {
+		if d > maxDiffEdits {
+			return nil, false
+		}
+		if d == 0 {
+			trace = append(trace, nil)
+		} else {
+			trace = append(trace, append([]int(nil), v[offset-d+1:offset+d]...))
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	var edits []lineEdit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := func(k int) int { return trace[d][k+d-1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, lineEdit{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if x == prevX {
+			edits = append(edits, lineEdit{'+', b[y-1]})
+			y--
+		} else {
+			edits = append(edits, lineEdit{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, lineEdit{' ', a[x-1]})
+		x, y = x-1, y-1
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits, true
+}
+
+// unifiedDiff formats an edit script as hunks with contextLines lines around the changes
+func unifiedDiff(edits []lineEdit, name string, contextLines int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", name, name)
+	// Line numbers of each edit in the old and new file, counting from 1
+	oldLine, newLine := make([]int, len(edits)), make([]int, len(edits))
+	o, n := 1, 1
+	for i, e := range edits {
+		oldLine[i], newLine[i] = o, n
+		if e.op != '+' {
+			o++
+		}
+		if e.op != '-' {
+			n++
+		}
+	}
+
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+		start := max(i-contextLines, 0)
+		// Extend the hunk while the next change is within twice the context
+		end, kept := i, 0
+		for j := i; j < len(edits) && kept <= 2*contextLines; j++ {
+			if edits[j].op == ' ' {
+				kept++
+			} else {
+				end, kept = j, 0
+			}
+		}
+		stop := min(end+1+contextLines, len(edits))
+
+		var oldCount, newCount int
+		for _, e := range edits[start:stop] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+		oldStart, newStart := oldLine[start], newLine[start]
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, e := range edits[start:stop] {
+			b.WriteByte(e.op)
+			b.WriteString(e.text)
+			b.WriteByte('\n')
+		}
+		i = stop
+	}
+	return b.String()
+}
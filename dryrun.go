@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// runDryRun builds the site into a scratch directory and prints the files of public/ the build
+// would create and update, and those it would leave behind from earlier builds. The scratch
+// directory is removed afterwards, and holds the cache entries the build creates, so public/,
+// .herocgo/cache and herocgo.lock are left as they are. External commands, such as those of
+// text-to-speech, and API requests still run.
+func runDryRun(ctx context.Context, opts buildOptions) {
+	target := opts.PublicDir
+	previous, err := scanOutput(target)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", target, err)
+	}
+	// The scratch directory stays in the project, where --safe allows reading the build
+	if err := os.MkdirAll(".herocgo", os.ModePerm); err != nil {
+		log.Fatalf("Failed to create scratch directory: %v", err)
+	}
+	scratch, err := os.MkdirTemp(".herocgo", "dry-run-")
+	if err != nil {
+		log.Fatalf("Failed to create scratch directory: %v", err)
+	}
+	opts.PublicDir = filepath.Join(scratch, "public")
+	opts.DryRun = true
+	scratchCacheDir = filepath.Join(scratch, "cache")
+	stats, current, err := scratchBuild(ctx, opts)
+	scratchCacheDir = ""
+	os.RemoveAll(scratch)
+	if err != nil {
+		log.Fatalf("Failed to build site: %v", err)
+	}
+
+	counts := map[string]int{}
+	for _, change := range compareOutputs(previous, current) {
+		// The build does not delete files, so removed ones stay behind as stale output
+		action := map[string]string{"added": "create", "changed": "update", "removed": "stale"}[change.Action]
+		counts[action]++
+		fmt.Printf("%-6s %s\n", action, filepath.ToSlash(filepath.Join(target, filepath.FromSlash(change.Path))))
+	}
+	fmt.Println("--- Dry Run ---")
+	fmt.Printf("Would create: %d\n", counts["create"])
+	fmt.Printf("Would update: %d\n", counts["update"])
+	fmt.Printf("Unchanged: %d\n", len(current)-counts["create"]-counts["update"])
+	fmt.Printf("Stale (not removed): %d\n", counts["stale"])
+	fmt.Printf("Total Pages: %d\n", stats.Pages)
+	fmt.Printf("Total Build Time: %v\n", stats.Duration)
+}
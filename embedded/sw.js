@@ -0,0 +1,43 @@
+// Service worker generated by herocgo; precached files are listed in precache-manifest.json
+const CACHE = "herocgo-{{ .Version }}";
+const PRECACHE = [
+{{- range .Precache }}
+  {{ printf "%q" .URL }},
+{{- end }}
+];
+const OFFLINE = {{ printf "%q" .Offline }};
+
+self.addEventListener("install", (event) => {
+  event.waitUntil(
+    caches.open(CACHE).then((cache) => cache.addAll(PRECACHE)).then(() => self.skipWaiting())
+  );
+});
+
+self.addEventListener("activate", (event) => {
+  event.waitUntil(
+    caches.keys()
+      .then((keys) => Promise.all(keys.filter((key) => key.startsWith("herocgo-") && key !== CACHE).map((key) => caches.delete(key))))
+      .then(() => self.clients.claim())
+  );
+});
+
+self.addEventListener("fetch", (event) => {
+  const request = event.request;
+  if (request.method !== "GET" || new URL(request.url).origin !== self.location.origin) {
+    return;
+  }
+  if (request.mode === "navigate") {
+    // Pages come from the network so they stay fresh, and from the cache when offline
+    event.respondWith(
+      fetch(request)
+        .then((response) => {
+          const copy = response.clone();
+          caches.open(CACHE).then((cache) => cache.put(request, copy));
+          return response;
+        })
+        .catch(() => caches.match(request).then((cached) => cached || (OFFLINE && caches.match(OFFLINE)) || Response.error()))
+    );
+    return;
+  }
+  event.respondWith(caches.match(request).then((cached) => cached || fetch(request)));
+});
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// decodeContent strips a byte order mark and transcodes UTF-16 to UTF-8, returning the name of
+// the encoding that needed handling, or "" when the content was plain UTF-8
+func decodeContent(content []byte) ([]byte, string, error) {
+	switch {
+	case bytes.HasPrefix(content, bomUTF8):
+		return content[len(bomUTF8):], "UTF-8 with BOM", nil
+	case bytes.HasPrefix(content, bomUTF16LE):
+		decoded, err := decodeUTF16(content[2:], binary.LittleEndian)
+		return decoded, "UTF-16LE", err
+	case bytes.HasPrefix(content, bomUTF16BE):
+		decoded, err := decodeUTF16(content[2:], binary.BigEndian)
+		return decoded, "UTF-16BE", err
+	}
+	return content, "", nil
+}
+
+// decodeUTF16 converts UTF-16 code units in the given byte order to UTF-8
+func decodeUTF16(content []byte, order binary.ByteOrder) ([]byte, error) {
+	if len(content)%2 != 0 {
+		return nil, fmt.Errorf("UTF-16 content has an odd number of bytes")
+	}
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		units[i] = order.Uint16(content[2*i:])
+	}
+	return []byte(string(utf16.Decode(units))), nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event is the `event:` front matter of a page, for conference and meetup sites
+type Event struct {
+	Start    time.Time
+	End      time.Time
+	Location string
+	// AllDay is set explicitly or inferred when start and end are plain dates
+	AllDay bool
+	// URL is the registration or stream link, when it differs from the page
+	URL string
+}
+
+// Upcoming reports whether the event has not ended yet
+func (e *Event) Upcoming() bool {
+	end := e.End
+	if end.IsZero() {
+		end = e.Start
+		if e.AllDay {
+			end = end.AddDate(0, 0, 1)
+		}
+	}
+	return end.After(time.Now())
+}
+
+// newEvent reads the event front matter of a page; it returns nil when there is none.
+// A timezone field such as "Europe/Lisbon" places start and end times without offset in that zone.
+func newEvent(v any, file string) *Event {
+	fields, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	event := &Event{Start: toTime(fields["start"]), End: toTime(fields["end"])}
+	if event.Start.IsZero() {
+		warnf("Ignoring event without start in %s", file)
+		return nil
+	}
+	event.Location, _ = fields["location"].(string)
+	event.URL, _ = fields["url"].(string)
+
+	midnight := func(t time.Time) bool {
+		return t.IsZero() || (t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Location() == time.UTC)
+	}
+	if allDay, ok := fields["allDay"].(bool); ok {
+		event.AllDay = allDay
+	} else {
+		event.AllDay = midnight(event.Start) && midnight(event.End)
+	}
+
+	if zone, ok := fields["timezone"].(string); ok && !event.AllDay {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			warnf("Unknown event timezone %q in %s", zone, file)
+			return event
+		}
+		for _, t := range []*time.Time{&event.Start, &event.End} {
+			if !t.IsZero() && t.Location() == time.UTC {
+				*t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+			}
+		}
+	}
+	if !event.End.IsZero() && event.End.Before(event.Start) {
+		warnf("Event ends before it starts in %s", file)
+	}
+	return event
+}
+
+// setCalendarLink sets the calendar URL of a section with events
+func (s *Site) setCalendarLink(list *Page) {
+	if list.Kind != KindSection {
+		return
+	}
+	for _, p := range list.Pages {
+		if p.Event != nil {
+			list.CalendarLink = strings.TrimSuffix(list.Permalink, "/") + "/calendar.ics"
+			return
+		}
+	}
+}
+
+// renderCalendars writes an iCalendar file with the events of each section as calendar.ics
+func (s *Site) renderCalendars(outputDir string) (int, error) {
+	var written int
+	for _, list := range s.Sections {
+		if list.CalendarLink == "" {
+			continue
+		}
+		var b strings.Builder
+		line := func(name, value string) {
+			writeICSLine(&b, name+":"+value)
+		}
+		line("BEGIN", "VCALENDAR")
+		line("VERSION", "2.0")
+		line("PRODID", "-//herocgo//herocgo "+version+"//EN")
+		line("CALSCALE", "GREGORIAN")
+		line("X-WR-CALNAME", icsText(list.Title+" on "+s.Title))
+		stamp := s.Hero.BuildDate.UTC().Format("20060102T150405Z")
+		for _, p := range list.Pages {
+			e := p.Event
+			if e == nil {
+				continue
+			}
+			line("BEGIN", "VEVENT")
+			line("UID", icsText(p.Permalink))
+			line("DTSTAMP", stamp)
+			if e.AllDay {
+				// The end date of all-day events is exclusive
+				end := e.End
+				if end.IsZero() {
+					end = e.Start
+				}
+				writeICSLine(&b, "DTSTART;VALUE=DATE:"+e.Start.Format("20060102"))
+				writeICSLine(&b, "DTEND;VALUE=DATE:"+end.AddDate(0, 0, 1).Format("20060102"))
+			} else {
+				line("DTSTART", e.Start.UTC().Format("20060102T150405Z"))
+				if !e.End.IsZero() {
+					line("DTEND", e.End.UTC().Format("20060102T150405Z"))
+				}
+			}
+			line("SUMMARY", icsText(p.Title))
+			if summary := p.Summary(); summary != "" {
+				line("DESCRIPTION", icsText(summary))
+			}
+			if e.Location != "" {
+				line("LOCATION", icsText(e.Location))
+			}
+			url := p.Permalink
+			if e.URL != "" {
+				url = e.URL
+			}
+			line("URL", url)
+			if !p.Lastmod.IsZero() {
+				line("LAST-MODIFIED", p.Lastmod.UTC().Format("20060102T150405Z"))
+			}
+			line("END", "VEVENT")
+		}
+		line("END", "VCALENDAR")
+
+		dest, err := outputFile(outputDir, path.Join(filepath.ToSlash(filepath.Dir(list.outputPath)), "calendar.ics"))
+		if err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(dest, []byte(b.String()), 0644); err != nil {
+			return written, fmt.Errorf("failed to write calendar %s: %w", dest, err)
+		}
+		written++
+	}
+	return written, nil
+}
+
+// icsText escapes a TEXT value of an iCalendar property
+func icsText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeICSLine writes a content line, folded after 75 octets without splitting a character
+func writeICSLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards their length
+		limit = 74
+	}
+	b.WriteString(line + "\r\n")
+}
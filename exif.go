@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Exif is the camera metadata of a JPEG image resource
+type Exif struct {
+	Date         time.Time
+	Make         string
+	Model        string
+	Lens         string
+	ExposureTime string
+	FNumber      float64
+	ISO          int
+	FocalLength  float64
+	Orientation  int
+	// Lat and Long are only set when the image has a location and GPS is not stripped
+	Lat         float64
+	Long        float64
+	HasLocation bool
+}
+
+// imageInfo is what is known of an image resource after it was analysed once
+type imageInfo struct {
+	width, height int
+	exif          *Exif
+	// thumb is the image scaled down to at most thumbSize pixels wide for colors and placeholders
+	thumb *image.RGBA
+}
+
+// thumbSize is the width of the thumbnail colors and placeholders are computed from
+const thumbSize = 32
+
+// analyse decodes the image resource once; resources that are not decodable images report zero values
+func (r *Resource) analyse() *imageInfo {
+	r.imageOnce.Do(func() {
+		r.image = &imageInfo{}
+		if r.ResourceType != "image" || r.SourcePath == "" || checkReadPath(r.SourcePath) != nil {
+			return
+		}
+		data, err := os.ReadFile(r.SourcePath)
+		if err != nil {
+			return
+		}
+		if exif, err := readExif(data); err == nil {
+			if r.stripGPS {
+				exif.Lat, exif.Long, exif.HasLocation = 0, 0, false
+			}
+			r.image.exif = exif
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		b := img.Bounds()
+		r.image.width, r.image.height = b.Dx(), b.Dy()
+		source := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(source, source.Bounds(), img, b.Min, draw.Src)
+		width := min(thumbSize, b.Dx())
+		r.image.thumb = scaleImage(source, width, max(1, b.Dy()*width/b.Dx()))
+	})
+	return r.image
+}
+
+// Width returns the width of an image resource in pixels, or 0
+func (r *Resource) Width() int {
+	return r.analyse().width
+}
+
+// Height returns the height of an image resource in pixels, or 0
+func (r *Resource) Height() int {
+	return r.analyse().height
+}
+
+// Exif returns the camera metadata of a JPEG resource, or nil when it has none
+func (r *Resource) Exif() *Exif {
+	return r.analyse().exif
+}
+
+// Palette returns up to n of the most common colors of an image as #rrggbb, most common first
+func (r *Resource) Palette(n int) []string {
+	thumb := r.analyse().thumb
+	if thumb == nil {
+		return nil
+	}
+	// Colors are bucketed to 4 bits per channel; each bucket reports the mean of its pixels
+	type bucket struct{ r, g, b, count int }
+	buckets := map[int]*bucket{}
+	for i := 0; i+3 < len(thumb.Pix); i += 4 {
+		red, green, blue := int(thumb.Pix[i]), int(thumb.Pix[i+1]), int(thumb.Pix[i+2])
+		if thumb.Pix[i+3] < 128 {
+			continue
+		}
+		key := red>>4<<8 | green>>4<<4 | blue>>4
+		if buckets[key] == nil {
+			buckets[key] = &bucket{}
+		}
+		bk := buckets[key]
+		bk.r, bk.g, bk.b, bk.count = bk.r+red, bk.g+green, bk.b+blue, bk.count+1
+	}
+	sorted := make([]*bucket, 0, len(buckets))
+	for _, bk := range buckets {
+		sorted = append(sorted, bk)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].r+sorted[i].g+sorted[i].b < sorted[j].r+sorted[j].g+sorted[j].b
+	})
+	var colors []string
+	for _, bk := range sorted[:min(n, len(sorted))] {
+		colors = append(colors, fmt.Sprintf("#%02x%02x%02x", bk.r/bk.count, bk.g/bk.count, bk.b/bk.count))
+	}
+	return colors
+}
+
+// DominantColor returns the most common color of an image as #rrggbb, or ""
+func (r *Resource) DominantColor() string {
+	if colors := r.Palette(1); len(colors) > 0 {
+		return colors[0]
+	}
+	return ""
+}
+
+// Placeholder returns a tiny blurred JPEG of the image as a data URL, for use as a low quality
+// image placeholder while the full image loads
+func (r *Resource) Placeholder() template.URL {
+	thumb := r.analyse().thumb
+	if thumb == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 40}); err != nil {
+		return ""
+	}
+	return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+// BlurHash returns the BlurHash (https://blurha.sh) of the image with 4x3 components, or ""
+func (r *Resource) BlurHash() string {
+	thumb := r.analyse().thumb
+	if thumb == nil {
+		return ""
+	}
+	return blurHash(thumb, 4, 3)
+}
+
+// blurHash encodes the image as cx x cy DCT components in the BlurHash format
+func blurHash(img *image.RGBA, cx, cy int) string {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	toLinear := func(v uint8) float64 {
+		c := float64(v) / 255
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	toSRGB := func(v float64) int {
+		v = math.Max(0, math.Min(1, v))
+		if v <= 0.0031308 {
+			return int(v*12.92*255 + 0.5)
+		}
+		return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+	}
+	signPow := func(v, exp float64) float64 {
+		return math.Copysign(math.Pow(math.Abs(v), exp), v)
+	}
+
+	factors := make([][3]float64, 0, cx*cy)
+	for j := 0; j < cy; j++ {
+		for i := 0; i < cx; i++ {
+			var f [3]float64
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := normalisation * math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) * math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+					p := img.PixOffset(x, y)
+					f[0] += basis * toLinear(img.Pix[p])
+					f[1] += basis * toLinear(img.Pix[p+1])
+					f[2] += basis * toLinear(img.Pix[p+2])
+				}
+			}
+			scale := 1 / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString(base83(cx-1+(cy-1)*9, 1))
+	maxAC := 0.0
+	for _, f := range factors[1:] {
+		maxAC = math.Max(maxAC, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+	}
+	quantisedMax := 0
+	if len(factors) > 1 {
+		quantisedMax = int(math.Max(0, math.Min(82, math.Floor(maxAC*166-0.5))))
+		maxAC = float64(quantisedMax+1) / 166
+	}
+	out.WriteString(base83(quantisedMax, 1))
+	dc := factors[0]
+	out.WriteString(base83(toSRGB(dc[0])<<16+toSRGB(dc[1])<<8+toSRGB(dc[2]), 4))
+	for _, f := range factors[1:] {
+		q := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxAC, 0.5)*9+9.5))))
+		}
+		out.WriteString(base83(q(f[0])*19*19+q(f[1])*19+q(f[2]), 2))
+	}
+	return out.String()
+}
+
+// base83 encodes n in the given number of BlurHash base 83 digits
+func base83(n, digits int) string {
+	const chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+	out := make([]byte, digits)
+	for i := digits - 1; i >= 0; i-- {
+		out[i] = chars[n%83]
+		n /= 83
+	}
+	return string(out)
+}
+
+// EXIF tags read from the image, the Exif and the GPS IFDs
+const (
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagExposureTime     = 0x829a
+	tagFNumber          = 0x829d
+	tagISO              = 0x8827
+	tagDateTimeOriginal = 0x9003
+	tagFocalLength      = 0x920a
+	tagLensModel        = 0xa434
+	tagGPSLatitudeRef   = 1
+	tagGPSLatitude      = 2
+	tagGPSLongitudeRef  = 3
+	tagGPSLongitude     = 4
+)
+
+// tiffTypeSizes are the byte sizes of the TIFF field types
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// tiffEntry is an IFD entry; value is the offset of its value within the TIFF data
+type tiffEntry struct {
+	typ   uint16
+	count int
+	value int
+}
+
+// tiffData is the TIFF structure holding the EXIF data of a JPEG
+type tiffData struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// exifSegment returns the TIFF data of the EXIF APP1 segment of a JPEG, sharing memory with data
+func exifSegment(data []byte) (*tiffData, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, fmt.Errorf("not a JPEG")
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker, length := data[i+1], int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xda || length < 2 || i+2+length > len(data) {
+			break
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) && len(segment) > 14 {
+			t := &tiffData{data: segment[6:]}
+			switch string(t.data[:2]) {
+			case "II":
+				t.order = binary.LittleEndian
+			case "MM":
+				t.order = binary.BigEndian
+			default:
+				return nil, fmt.Errorf("invalid TIFF header")
+			}
+			return t, nil
+		}
+		i += 2 + length
+	}
+	return nil, fmt.Errorf("no EXIF data")
+}
+
+// ifd reads the entries of the IFD at offset
+func (t *tiffData) ifd(offset int) map[uint16]tiffEntry {
+	entries := map[uint16]tiffEntry{}
+	if offset <= 0 || offset+2 > len(t.data) {
+		return entries
+	}
+	n := int(t.order.Uint16(t.data[offset:]))
+	for i := 0; i < n; i++ {
+		at := offset + 2 + i*12
+		if at+12 > len(t.data) {
+			break
+		}
+		e := tiffEntry{typ: t.order.Uint16(t.data[at+2:]), count: int(t.order.Uint32(t.data[at+4:])), value: at + 8}
+		if size := tiffTypeSizes[e.typ] * e.count; size > 4 {
+			e.value = int(t.order.Uint32(t.data[at+8:]))
+		}
+		if e.value+tiffTypeSizes[e.typ]*e.count > len(t.data) {
+			continue
+		}
+		entries[t.order.Uint16(t.data[at:])] = e
+	}
+	return entries
+}
+
+// str returns the value of an ASCII entry, or ""
+func (t *tiffData) str(e tiffEntry, ok bool) string {
+	if !ok || e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(t.data[e.value:e.value+e.count]), "\x00"))
+}
+
+// uint returns the first value of a SHORT or LONG entry, or 0
+func (t *tiffData) uint(e tiffEntry, ok bool) int {
+	switch {
+	case !ok:
+		return 0
+	case e.typ == 3:
+		return int(t.order.Uint16(t.data[e.value:]))
+	case e.typ == 4:
+		return int(t.order.Uint32(t.data[e.value:]))
+	}
+	return 0
+}
+
+// rationals returns the numerators and denominators of a RATIONAL entry
+func (t *tiffData) rationals(e tiffEntry, ok bool) [][2]uint32 {
+	if !ok || e.typ != 5 {
+		return nil
+	}
+	out := make([][2]uint32, e.count)
+	for i := range out {
+		at := e.value + i*8
+		out[i] = [2]uint32{t.order.Uint32(t.data[at:]), t.order.Uint32(t.data[at+4:])}
+	}
+	return out
+}
+
+// rational returns the first value of a RATIONAL entry as a float, or 0
+func (t *tiffData) rational(e tiffEntry, ok bool) float64 {
+	if r := t.rationals(e, ok); len(r) > 0 && r[0][1] != 0 {
+		return float64(r[0][0]) / float64(r[0][1])
+	}
+	return 0
+}
+
+// readExif reads the camera metadata of a JPEG file
+func readExif(data []byte) (*Exif, error) {
+	t, err := exifSegment(data)
+	if err != nil {
+		return nil, err
+	}
+	ifd0 := t.ifd(int(t.order.Uint32(t.data[4:])))
+	exif := &Exif{
+		Make:        t.str(lookupEntry(ifd0, tagMake)),
+		Model:       t.str(lookupEntry(ifd0, tagModel)),
+		Orientation: t.uint(lookupEntry(ifd0, tagOrientation)),
+	}
+	sub := t.ifd(t.uint(lookupEntry(ifd0, tagExifIFD)))
+	date := t.str(lookupEntry(sub, tagDateTimeOriginal))
+	if date == "" {
+		date = t.str(lookupEntry(ifd0, tagDateTime))
+	}
+	exif.Date, _ = time.Parse("2006:01:02 15:04:05", date)
+	exif.Lens = t.str(lookupEntry(sub, tagLensModel))
+	exif.FNumber = t.rational(lookupEntry(sub, tagFNumber))
+	exif.FocalLength = t.rational(lookupEntry(sub, tagFocalLength))
+	exif.ISO = t.uint(lookupEntry(sub, tagISO))
+	if r := t.rationals(lookupEntry(sub, tagExposureTime)); len(r) > 0 && r[0][1] != 0 {
+		if r[0][0] < r[0][1] && r[0][0] != 0 {
+			exif.ExposureTime = fmt.Sprintf("1/%d", int(math.Round(float64(r[0][1])/float64(r[0][0]))))
+		} else {
+			exif.ExposureTime = fmt.Sprintf("%g", float64(r[0][0])/float64(r[0][1]))
+		}
+	}
+
+	gps := t.ifd(t.uint(lookupEntry(ifd0, tagGPSIFD)))
+	degrees := func(tag, refTag uint16, negative string) (float64, bool) {
+		r := t.rationals(lookupEntry(gps, tag))
+		if len(r) != 3 || r[0][1] == 0 || r[1][1] == 0 || r[2][1] == 0 {
+			return 0, false
+		}
+		v := float64(r[0][0])/float64(r[0][1]) + float64(r[1][0])/float64(r[1][1])/60 + float64(r[2][0])/float64(r[2][1])/3600
+		if t.str(lookupEntry(gps, refTag)) == negative {
+			v = -v
+		}
+		return v, true
+	}
+	lat, okLat := degrees(tagGPSLatitude, tagGPSLatitudeRef, "S")
+	long, okLong := degrees(tagGPSLongitude, tagGPSLongitudeRef, "W")
+	if okLat && okLong {
+		exif.Lat, exif.Long, exif.HasLocation = lat, long, true
+	}
+	return exif, nil
+}
+
+// lookupEntry returns the entry of a tag and whether the IFD has it
+func lookupEntry(ifd map[uint16]tiffEntry, tag uint16) (tiffEntry, bool) {
+	e, ok := ifd[tag]
+	return e, ok
+}
+
+// stripGPS blanks the GPS IFD of a JPEG file in place, keeping the rest of its EXIF data.
+// Files without GPS data are left untouched.
+func stripGPS(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	t, err := exifSegment(data)
+	if err != nil {
+		return nil
+	}
+	offset := t.uint(lookupEntry(t.ifd(int(t.order.Uint32(t.data[4:]))), tagGPSIFD))
+	gps := t.ifd(offset)
+	if len(gps) == 0 {
+		return nil
+	}
+	// t.data shares its memory with data, so clearing it edits the file contents
+	for _, e := range gps {
+		clear(t.data[e.value : e.value+max(4, tiffTypeSizes[e.typ]*e.count)])
+	}
+	n := int(t.order.Uint16(t.data[offset:]))
+	clear(t.data[offset:min(len(t.data), offset+2+n*12)])
+	return os.WriteFile(file, data, 0644)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testTIFF returns TIFF data with an IFD0 holding Make and Orientation in the byte order
+func testTIFF(order binary.ByteOrder, make string, orientation uint16) []byte {
+	var b bytes.Buffer
+	if order == binary.LittleEndian {
+		b.WriteString("II")
+	} else {
+		b.WriteString("MM")
+	}
+	binary.Write(&b, order, uint16(42))
+	binary.Write(&b, order, uint32(8))
+	// Two entries follow the count, then the next IFD offset and the Make string
+	value := make + "\x00"
+	binary.Write(&b, order, uint16(2))
+	binary.Write(&b, order, uint16(tagMake))
+	binary.Write(&b, order, uint16(2))
+	binary.Write(&b, order, uint32(len(value)))
+	binary.Write(&b, order, uint32(8+2+2*12+4))
+	binary.Write(&b, order, uint16(tagOrientation))
+	binary.Write(&b, order, uint16(3))
+	binary.Write(&b, order, uint32(1))
+	binary.Write(&b, order, orientation)
+	binary.Write(&b, order, uint16(0))
+	binary.Write(&b, order, uint32(0))
+	b.WriteString(value)
+	return b.Bytes()
+}
+
+// testJPEG returns the start of a JPEG with the segments, each given as marker and payload
+func testJPEG(segments ...[]byte) []byte {
+	data := []byte{0xff, 0xd8}
+	for _, s := range segments {
+		data = append(data, 0xff, s[0])
+		data = binary.BigEndian.AppendUint16(data, uint16(len(s)+1))
+		data = append(data, s[1:]...)
+	}
+	return append(data, 0xff, 0xda, 0, 2)
+}
+
+// exifPayload returns an APP1 segment holding the TIFF data
+func exifPayload(tiff []byte) []byte {
+	return append([]byte{0xe1, 'E', 'x', 'i', 'f', 0, 0}, tiff...)
+}
+
+func TestReadExif(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		wantErr     bool
+		make        string
+		orientation int
+	}{
+		{"not a JPEG", []byte("GIF89a"), true, "", 0},
+		{"empty", nil, true, "", 0},
+		{"no EXIF", testJPEG([]byte{0xe0, 'J', 'F', 'I', 'F', 0}), true, "", 0},
+		{"little endian", testJPEG(exifPayload(testTIFF(binary.LittleEndian, "Canon", 6))), false, "Canon", 6},
+		{"big endian", testJPEG(exifPayload(testTIFF(binary.BigEndian, "Nikon", 1))), false, "Nikon", 1},
+		{"after another segment", testJPEG([]byte{0xe0, 'J', 'F', 'I', 'F', 0}, exifPayload(testTIFF(binary.LittleEndian, "Sony", 3))), false, "Sony", 3},
+		{"invalid TIFF header", testJPEG(exifPayload(append([]byte("XX"), testTIFF(binary.LittleEndian, "Canon", 1)[2:]...))), true, "", 0},
+		{"segment length zero", []byte{0xff, 0xd8, 0xff, 0xe1, 0, 0, 'E', 'x'}, true, "", 0},
+		{"segment length one", []byte{0xff, 0xd8, 0xff, 0xe1, 0, 1, 'E', 'x'}, true, "", 0},
+		{"segment past the end", []byte{0xff, 0xd8, 0xff, 0xe1, 0xff, 0xff, 'E', 'x'}, true, "", 0},
+		{"truncated marker", []byte{0xff, 0xd8, 0xff, 0xe1, 0}, true, "", 0},
+		{"IFD offset past the end", testJPEG(exifPayload([]byte{'I', 'I', 42, 0, 0xff, 0xff, 0, 0, 0, 0, 0, 0})), false, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exif, err := readExif(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readExif() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if exif.Make != tt.make || exif.Orientation != tt.orientation {
+				t.Errorf("readExif() = make %q, orientation %d; want %q, %d", exif.Make, exif.Orientation, tt.make, tt.orientation)
+			}
+		})
+	}
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// exportDir is where exports are written unless --output is given
+const exportDir = "export"
+
+// ExportConfig configures `export`:
+//
+//	[export]
+//	cover = "assets/book-cover.jpg"
+type ExportConfig struct {
+	// Cover is a project image used as the cover of EPUB exports; a single exported section
+	// otherwise uses the cover of its section page
+	Cover string `toml:"cover"`
+}
+
+// exportChapter is one page of an export
+type exportChapter struct {
+	ID      string
+	Title   string
+	Date    time.Time
+	Content template.HTML
+}
+
+// exportData is the context of the export templates
+type exportData struct {
+	Title    string
+	Lang     string
+	Author   string
+	Print    bool
+	Chapters []exportChapter
+	// Identifier, Modified, Cover and Images are only used by EPUB packages
+	Identifier string
+	Modified   string
+	Cover      *exportImage
+	Images     []*exportImage
+}
+
+// exportImage is an image file embedded in an EPUB package
+type exportImage struct {
+	ID        string
+	Href      string
+	MediaType string
+	source    string
+}
+
+// exportHTMLTemplate renders the combined HTML and print exports
+const exportHTMLTemplate = `<!DOCTYPE html>
+<html lang="{{ .Lang }}">
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { max-width: 42em; margin: 2em auto; padding: 0 1em; font-family: Georgia, serif; line-height: 1.5; }
+img { max-width: 100%; }
+pre { overflow-x: auto; }
+nav li a { text-decoration: none; }
+{{- if .Print }}
+@page { size: A4; margin: 2cm; }
+body { max-width: none; margin: 0; font-size: 11pt; }
+.chapter { break-before: page; }
+pre, blockquote, img, table { break-inside: avoid; }
+h1, h2, h3 { break-after: avoid; }
+a { color: inherit; }
+{{- end }}
+</style>
+</head>
+<body>
+<header><h1>{{ .Title }}</h1>{{ with .Author }}<p>{{ . }}</p>{{ end }}</header>
+<nav id="toc"><h2>Contents</h2><ol>
+{{- range .Chapters }}
+<li><a href="#{{ .ID }}">{{ .Title }}</a></li>
+{{- end }}
+</ol></nav>
+{{- range .Chapters }}
+<section class="chapter" id="{{ .ID }}">
+<h1>{{ .Title }}</h1>
+{{ .Content }}
+</section>
+{{- end }}
+</body>
+</html>
+`
+
+// EPUB package documents, text templates that escape their values with xml; writeEPUB prefixes
+// each with the XML declaration
+const (
+	epubContainer = `<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>
+`
+	epubPackageTemplate = `<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id" xml:lang="{{ .Lang | xml }}">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="id">{{ .Identifier | xml }}</dc:identifier>
+<dc:title>{{ .Title | xml }}</dc:title>
+<dc:language>{{ .Lang | xml }}</dc:language>
+{{- with .Author }}
+<dc:creator>{{ . | xml }}</dc:creator>
+{{- end }}
+<meta property="dcterms:modified">{{ .Modified | xml }}</meta>
+{{- if .Cover }}
+<meta name="cover" content="cover-image"/>
+{{- end }}
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+{{- with .Cover }}
+<item id="cover-image" href="{{ .Href | xml }}" media-type="{{ .MediaType | xml }}" properties="cover-image"/>
+<item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>
+{{- end }}
+{{- range .Images }}
+<item id="{{ .ID | xml }}" href="{{ .Href | xml }}" media-type="{{ .MediaType | xml }}"/>
+{{- end }}
+{{- range .Chapters }}
+<item id="{{ .ID | xml }}" href="{{ .ID | xml }}.xhtml" media-type="application/xhtml+xml"/>
+{{- end }}
+</manifest>
+<spine>
+{{- if .Cover }}
+<itemref idref="cover"/>
+{{- end }}
+{{- range .Chapters }}
+<itemref idref="{{ .ID | xml }}"/>
+{{- end }}
+</spine>
+</package>
+`
+	epubNavTemplate = `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{ .Lang | xml }}">
+<head><title>{{ .Title | xml }}</title></head>
+<body>
+<nav epub:type="toc" id="toc"><h1>Contents</h1><ol>
+{{- range .Chapters }}
+<li><a href="{{ .ID | xml }}.xhtml">{{ .Title | xml }}</a></li>
+{{- end }}
+</ol></nav>
+</body>
+</html>
+`
+	epubCoverTemplate = `<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="{{ .Lang | xml }}">
+<head><title>{{ .Title | xml }}</title><style>body { margin: 0; text-align: center; } img { max-width: 100%; max-height: 100vh; }</style></head>
+<body>
+<img src="{{ .Cover.Href | xml }}" alt="{{ .Title | xml }}"/>
+</body>
+</html>
+`
+	epubChapterTemplate = `<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="{{ .Lang | xml }}">
+<head><title>{{ .Chapter.Title | xml }}</title></head>
+<body>
+<h1>{{ .Chapter.Title | xml }}</h1>
+{{ .Chapter.Content }}
+</body>
+</html>
+`
+)
+
+var (
+	// relativeLinkPattern matches src and href attributes that are not absolute URLs or fragments
+	relativeLinkPattern = regexp.MustCompile(`(?i)\b(src|href)="([^"#:][^"]*)"`)
+	// imageSourcePattern matches the src attribute of img elements
+	imageSourcePattern = regexp.MustCompile(`(?i)(<img\b[^>]*?\bsrc=")([^"]+)(")`)
+)
+
+// epubMediaTypes are the image types EPUB readers must support
+var epubMediaTypes = map[string]string{
+	".gif":  "image/gif",
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+// runExport implements `export html|epub|print`, which combines the pages of the selected
+// sections into a single file in weight order with a table of contents, and `export newsletter`,
+// which writes the newest posts as email HTML, MJML and plain text for newsletter services
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	sections := flags.String("sections", "", "comma-separated sections to export instead of all pages")
+	section := flags.String("section", "", "single section to export as a book titled after the section")
+	output := flags.String("output", "", "file to write instead of export/<site>.<ext>")
+	environment := flags.String("environment", envOr("HERO_ENVIRONMENT", "production"), "environment whose config is used")
+	limit := flags.Int("limit", 5, "number of posts in a newsletter, 0 for all")
+	since := flags.String("since", "", "date (YYYY-MM-DD) of the oldest post in a newsletter")
+	full := flags.Bool("full", false, "include the whole content of posts in a newsletter instead of their summary")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		log.Fatalf("Usage: export html|epub|print|newsletter [--section name | --sections a,b] [--output file]")
+	}
+	format := positional[0]
+	if format != "html" && format != "epub" && format != "print" && format != "newsletter" {
+		log.Fatalf("Unknown export format %q, use html, epub, print or newsletter", format)
+	}
+	opts := newsletterOptions{Limit: *limit, Full: *full}
+	if *since != "" {
+		date, err := time.Parse("2006-01-02", *since)
+		if err != nil {
+			log.Fatalf("Invalid --since date %q, use YYYY-MM-DD", *since)
+		}
+		opts.Since = date
+	}
+
+	site, _, err := loadSite(buildOptions{Environment: *environment})
+	if err != nil {
+		log.Fatalf("Failed to load site: %v", err)
+	}
+	if *section != "" && *sections != "" {
+		log.Fatalf("Use either --section or --sections")
+	}
+	var selected []string
+	for _, section := range strings.Split(*sections+","+*section, ",") {
+		if section = strings.TrimSpace(section); section != "" {
+			selected = append(selected, section)
+		}
+	}
+	if format == "newsletter" {
+		data := site.newsletterData(selected, opts)
+		if len(data.Posts) == 0 {
+			log.Fatalf("No posts for the newsletter")
+		}
+		base := *output
+		if base == "" {
+			base = filepath.Join(exportDir, "newsletter")
+		}
+		written, err := site.writeNewsletter(base, data)
+		if err != nil {
+			log.Fatalf("Failed to export: %v", err)
+		}
+		fmt.Printf("Exported %d posts to %s\n", len(data.Posts), strings.Join(written, ", "))
+		return
+	}
+	if format == "epub" {
+		// Images of the static directories are embedded in the package
+		themeDir, err := resolveThemeDir(site.Config)
+		if err == nil {
+			site.staticDirs, err = siteStaticDirs(site.Config, themeDir)
+		}
+		if err != nil {
+			log.Fatalf("Failed to resolve static files: %v", err)
+		}
+	}
+	data, err := site.exportData(selected, format)
+	if err != nil {
+		log.Fatalf("Failed to export: %v", err)
+	}
+	if len(data.Chapters) == 0 {
+		log.Fatalf("No pages to export")
+	}
+
+	dest := *output
+	if dest == "" {
+		name := site.slug(data.Title)
+		if name == "" {
+			name = "site"
+		}
+		switch format {
+		case "epub":
+			name += ".epub"
+		case "print":
+			name += "-print.html"
+		default:
+			name += ".html"
+		}
+		dest = filepath.Join(exportDir, name)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		log.Fatalf("Failed to create export directory: %v", err)
+	}
+	file, err := os.Create(dest)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", dest, err)
+	}
+	defer file.Close()
+
+	if format == "epub" {
+		err = writeEPUB(file, data)
+	} else {
+		err = template.Must(template.New("export").Parse(exportHTMLTemplate)).Execute(file, data)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write %s: %v", dest, err)
+	}
+	fmt.Printf("Exported %d pages to %s\n", len(data.Chapters), dest)
+}
+
+// exportData collects the regular pages of the sections (all when none are given) in weight
+// order; pages without a weight follow, oldest first. A single section is exported under the
+// title of its section page.
+func (s *Site) exportData(sections []string, format string) (exportData, error) {
+	var pages []*Page
+	for _, p := range s.Pages {
+		if len(sections) == 0 || slices.Contains(sections, p.Section) {
+			pages = append(pages, p)
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		a, b := pages[i], pages[j]
+		if (a.Weight == 0) != (b.Weight == 0) {
+			return a.Weight != 0
+		}
+		if a.Weight != b.Weight {
+			return a.Weight < b.Weight
+		}
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		return a.Title < b.Title
+	})
+
+	var sectionPage *Page
+	if len(sections) == 1 {
+		for _, p := range s.Sections {
+			if p.Section == sections[0] {
+				sectionPage = p
+			}
+		}
+	}
+	data := exportData{Title: s.Title, Lang: s.Lang(), Print: format == "print"}
+	if sectionPage != nil && sectionPage.Title != "" {
+		data.Title = sectionPage.Title
+	}
+	data.Author, _ = s.Params["author"].(string)
+	sum := sha256.Sum256([]byte(s.BaseURL + "\x00" + s.Title + "\x00" + strings.Join(sections, ",")))
+	data.Identifier = fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+	data.Modified = s.Hero.BuildDate.UTC().Format("2006-01-02T15:04:05Z")
+
+	for i, p := range pages {
+		content := absoluteLinks(string(p.Content), p.Permalink)
+		if format == "epub" {
+			// EPUB content documents are XHTML, so the content the site rendered is written again
+			// as XML
+			xhtml, err := toXHTML(content)
+			if err != nil {
+				return data, fmt.Errorf("failed to convert %s: %w", p.File.Path, err)
+			}
+			content = s.embedImages(&data, p, xhtml)
+		}
+		data.Chapters = append(data.Chapters, exportChapter{
+			ID:      fmt.Sprintf("chapter-%d", i+1),
+			Title:   p.Title,
+			Date:    p.Date,
+			Content: template.HTML(content),
+		})
+	}
+	if format == "epub" {
+		cover, err := s.exportCover(sectionPage)
+		if err != nil {
+			return data, err
+		}
+		if cover != "" {
+			data.Cover = &exportImage{ID: "cover-image", Href: "images/cover" + strings.ToLower(filepath.Ext(cover)), MediaType: epubMediaTypes[strings.ToLower(filepath.Ext(cover))], source: cover}
+		}
+	}
+	return data, nil
+}
+
+// exportCover returns the file of the EPUB cover: export.cover, or else the local cover of the
+// exported section page
+func (s *Site) exportCover(sectionPage *Page) (string, error) {
+	if s.Config.Export.Cover != "" {
+		file, err := projectPath(s.Config.Export.Cover)
+		if err != nil {
+			return "", fmt.Errorf("invalid export.cover: %w", err)
+		}
+		if _, err := os.Stat(file); err != nil {
+			return "", fmt.Errorf("invalid export.cover: %w", err)
+		}
+		if _, ok := epubMediaTypes[strings.ToLower(filepath.Ext(file))]; !ok {
+			return "", fmt.Errorf("export.cover %s is not a JPEG, PNG, GIF, SVG or WebP image", s.Config.Export.Cover)
+		}
+		return file, nil
+	}
+	if sectionPage != nil && sectionPage.cover != nil {
+		file := s.localImage(sectionPage, sectionPage.cover.Permalink)
+		if _, ok := epubMediaTypes[strings.ToLower(filepath.Ext(file))]; ok {
+			return file, nil
+		}
+	}
+	return "", nil
+}
+
+// localImage returns the file of an image URL of the page: a bundle resource or a static file
+// of the site. It returns "" for other URLs.
+func (s *Site) localImage(p *Page, src string) string {
+	for _, res := range p.Resources {
+		if res.Permalink == src && res.SourcePath != "" {
+			return res.SourcePath
+		}
+	}
+	site, err := url.Parse(s.AbsURL("/"))
+	if err != nil {
+		return ""
+	}
+	if u, err := url.Parse(src); err == nil && u.Host == site.Host {
+		if rel, ok := strings.CutPrefix(u.Path, site.Path); ok {
+			return s.staticFile(rel)
+		}
+	}
+	return ""
+}
+
+// embedImages adds the local images of the chapter content to the package and points the img
+// elements at them. Images are found among the page resources and the static files by their
+// URL; others keep their absolute URL, which readers may not load.
+func (s *Site) embedImages(data *exportData, p *Page, content string) string {
+	return imageSourcePattern.ReplaceAllStringFunc(content, func(match string) string {
+		m := imageSourcePattern.FindStringSubmatch(match)
+		src := html.UnescapeString(m[2])
+		file := s.localImage(p, src)
+		if file == "" {
+			warnf("Image %s of %s is not embedded in the EPUB", src, p.File.Path)
+			return match
+		}
+		mediaType, ok := epubMediaTypes[strings.ToLower(filepath.Ext(file))]
+		if !ok {
+			warnf("Image %s of %s is not a type EPUB readers support", src, p.File.Path)
+			return match
+		}
+		for _, image := range data.Images {
+			if image.source == file {
+				return m[1] + image.Href + m[3]
+			}
+		}
+		id := fmt.Sprintf("image-%d", len(data.Images)+1)
+		image := &exportImage{ID: id, Href: "images/" + id + strings.ToLower(filepath.Ext(file)), MediaType: mediaType, source: file}
+		data.Images = append(data.Images, image)
+		return m[1] + image.Href + m[3]
+	})
+}
+
+// toXHTML parses rendered HTML content and writes it back as well-formed XHTML, with void
+// elements closed, attributes quoted and named character references resolved
+func toXHTML(content string) (string, error) {
+	body := &nethtml.Node{Type: nethtml.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := nethtml.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, node := range nodes {
+		if err := nethtml.Render(&b, node); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// absoluteLinks resolves the relative links and images of page content against its permalink,
+// so they keep working once the content is moved out of the site
+func absoluteLinks(content, permalink string) string {
+	base, err := url.Parse(permalink)
+	if err != nil {
+		return content
+	}
+	return relativeLinkPattern.ReplaceAllStringFunc(content, func(match string) string {
+		m := relativeLinkPattern.FindStringSubmatch(match)
+		ref, err := url.Parse(m[2])
+		if err != nil {
+			return match
+		}
+		return fmt.Sprintf(`%s="%s"`, m[1], base.ResolveReference(ref))
+	})
+}
+
+// epubFile is a templated document of an EPUB package
+type epubFile struct {
+	name   string
+	source string
+	data   any
+}
+
+// writeEPUB writes an EPUB 3 package with one content document per chapter
+func writeEPUB(w io.Writer, data exportData) error {
+	zw := zip.NewWriter(w)
+	// The mimetype entry must come first and be stored uncompressed
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []epubFile{
+		{"META-INF/container.xml", epubContainer, nil},
+		{"OEBPS/content.opf", epubPackageTemplate, data},
+		{"OEBPS/nav.xhtml", epubNavTemplate, data},
+	}
+	for _, chapter := range data.Chapters {
+		files = append(files, epubFile{"OEBPS/" + chapter.ID + ".xhtml", epubChapterTemplate, struct {
+			Lang    string
+			Chapter exportChapter
+		}{data.Lang, chapter}})
+	}
+
+	if data.Cover != nil {
+		files = append(files, epubFile{"OEBPS/cover.xhtml", epubCoverTemplate, data})
+	}
+
+	for _, f := range files {
+		entry, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(entry, xml.Header); err != nil {
+			return err
+		}
+		tmpl, err := texttemplate.New(f.name).Funcs(texttemplate.FuncMap{"xml": xmlEscape}).Parse(f.source)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", f.name, err)
+		}
+		if err := tmpl.Execute(entry, f.data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+
+	images := data.Images
+	if data.Cover != nil {
+		images = append([]*exportImage{data.Cover}, images...)
+	}
+	for _, image := range images {
+		if err := addEPUBImage(zw, image); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// xmlEscape escapes text for XML content and attribute values
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// addEPUBImage copies an image file into the package
+func addEPUBImage(zw *zip.Writer, image *exportImage) error {
+	if err := checkReadPath(image.source); err != nil {
+		return err
+	}
+	src, err := os.Open(image.source)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+	defer src.Close()
+	// Images are already compressed
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: "OEBPS/" + image.Href, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, src)
+	return err
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	Generator     string    `xml:"generator"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        string        `xml:"guid"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Description string        `xml:"description"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Language    string         `json:"language,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html,omitempty"`
+	Summary       string               `json:"summary,omitempty"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	Title       string `json:"title,omitempty"`
+	SizeInBytes int64  `json:"size_in_bytes,omitempty"`
+}
+
+// JSONFeedConfig configures the JSON Feed written next to every RSS feed
+type JSONFeedConfig struct {
+	Enabled     bool `toml:"enabled"`
+	Limit       int  `toml:"limit"`
+	FullContent bool `toml:"fullContent"`
+}
+
+// feedPages returns the home and section pages that get their own feeds
+func (s *Site) feedPages() []*Page {
+	return append([]*Page{s.Home}, s.Sections...)
+}
+
+// termFeedPages returns the term pages that get their own feeds when termFeeds is set
+func (s *Site) termFeedPages() []*Page {
+	var terms []*Page
+	for _, term := range s.termPages() {
+		if term.RSSLink != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// feedItems returns the pages of a list page that are not archived, limited to the configured feed size
+func (s *Site) feedItems(list *Page, limit int) []*Page {
+	items := currentPages(list.Pages)
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// renderFeeds writes an RSS feed, and optionally a JSON Feed, for the home page, every section
+// and, with termFeeds, every taxonomy term
+func (s *Site) renderFeeds(outputDir string) (int, error) {
+	var written int
+	for _, list := range append(s.feedPages(), s.termFeedPages()...) {
+		channel := rssChannel{
+			Title:         s.Title,
+			Link:          list.Permalink,
+			Description:   s.Description,
+			Language:      s.LanguageCode,
+			Generator:     "herocgo " + version,
+			LastBuildDate: s.Hero.BuildDate.Format(time.RFC1123Z),
+		}
+		if !list.IsHome() {
+			channel.Title = list.Title + " on " + s.Title
+		}
+		for _, p := range s.feedItems(list, s.Config.RSSLimit) {
+			item := rssItem{Title: p.Title, Link: p.Permalink, GUID: p.Permalink, Description: p.Summary()}
+			if !p.Date.IsZero() {
+				item.PubDate = p.Date.Format(time.RFC1123Z)
+			}
+			if audio := p.Resources.ByType("audio"); len(audio) > 0 {
+				item.Enclosure = &rssEnclosure{URL: audio[0].Permalink, Type: audio[0].MediaType, Length: audio[0].Size()}
+			}
+			channel.Items = append(channel.Items, item)
+		}
+
+		data, err := xml.MarshalIndent(rssFeed{Version: "2.0", Channel: channel}, "", "  ")
+		if err != nil {
+			return written, fmt.Errorf("failed to encode feed for %s: %w", list.RelPermalink, err)
+		}
+		dest, err := outputFile(outputDir, path.Join(filepath.ToSlash(filepath.Dir(list.outputPath)), "index.xml"))
+		if err != nil {
+			return written, err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return written, fmt.Errorf("failed to create feed directory: %w", err)
+		}
+		if err := os.WriteFile(dest, append([]byte(xml.Header), data...), 0644); err != nil {
+			return written, fmt.Errorf("failed to write feed %s: %w", dest, err)
+		}
+		written++
+
+		if s.Config.JSONFeed.Enabled {
+			if err := s.writeJSONFeed(outputDir, list); err != nil {
+				return written, err
+			}
+			written++
+		}
+	}
+	return written, nil
+}
+
+// writeJSONFeed writes the JSON Feed 1.1 document of a list page as feed.json
+func (s *Site) writeJSONFeed(outputDir string, list *Page) error {
+	cfg := s.Config.JSONFeed
+	limit := cfg.Limit
+	if limit == 0 {
+		limit = s.Config.RSSLimit
+	}
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       s.Title,
+		HomePageURL: list.Permalink,
+		FeedURL:     list.JSONFeedLink,
+		Description: s.Description,
+		Language:    s.LanguageCode,
+		Items:       []jsonFeedItem{},
+	}
+	if !list.IsHome() {
+		feed.Title = list.Title + " on " + s.Title
+	}
+	for _, p := range s.feedItems(list, limit) {
+		item := jsonFeedItem{ID: p.Permalink, URL: p.Permalink, Title: p.Title, Image: p.OGImage}
+		if cfg.FullContent {
+			item.ContentHTML = string(p.Content)
+		} else {
+			item.Summary = p.Summary()
+		}
+		if !p.Date.IsZero() {
+			item.DatePublished = p.Date.Format(time.RFC3339)
+		}
+		item.Tags = toStringSlice(p.Params["tags"])
+		for _, audio := range p.Resources.ByType("audio") {
+			item.Attachments = append(item.Attachments, jsonFeedAttachment{
+				URL:         audio.Permalink,
+				MimeType:    audio.MediaType,
+				Title:       audio.Title,
+				SizeInBytes: audio.Size(),
+			})
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	data, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON feed for %s: %w", list.RelPermalink, err)
+	}
+	dest, err := outputFile(outputDir, path.Join(filepath.ToSlash(filepath.Dir(list.outputPath)), "feed.json"))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return fmt.Errorf("failed to write JSON feed %s: %w", dest, err)
+	}
+	return nil
+}
+
+// fileSize returns the size of a file, or zero when it cannot be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// setFeedLinks assigns the RSS and JSON Feed URLs of a home or section page
+func (s *Site) setFeedLinks(list *Page) {
+	list.RSSLink = strings.TrimSuffix(list.Permalink, "/") + "/index.xml"
+	if s.Config.JSONFeed.Enabled {
+		list.JSONFeedLink = strings.TrimSuffix(list.Permalink, "/") + "/feed.json"
+	}
+	s.podcastLink(list)
+	s.setCalendarLink(list)
+}
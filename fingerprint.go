@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FingerprintConfig configures the fingerprint and integrity template functions
+type FingerprintConfig struct {
+	// Algorithm is sha256 (the default), sha384 or sha512
+	Algorithm string `toml:"algorithm"`
+	// Manifest is the output file mapping logical to fingerprinted names, assets.json by default
+	Manifest string `toml:"manifest"`
+}
+
+// fingerprintedAsset is a static file published under a name containing its hash
+type fingerprintedAsset struct {
+	source    string
+	name      string
+	integrity string
+	// content replaces the source when set, e.g. by the purged CSS of a stylesheet
+	content []byte
+}
+
+// assetManifest collects the assets fingerprinted by templates during a build
+type assetManifest struct {
+	mu     sync.Mutex
+	assets map[string]*fingerprintedAsset
+}
+
+// fingerprint returns the static file of the theme or a module, hashing it on first use. The
+// hash is added before the extension: css/style.css becomes css/style.<hash>.css.
+func (s *Site) fingerprint(name string) (*fingerprintedAsset, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	s.assets.mu.Lock()
+	defer s.assets.mu.Unlock()
+	if asset, ok := s.assets.assets[name]; ok {
+		return asset, nil
+	}
+
+	source := s.staticFile(name)
+	if source == "" {
+		return nil, fmt.Errorf("no static file %s to fingerprint", name)
+	}
+	if err := checkReadPath(source); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	asset := &fingerprintedAsset{source: source}
+	if asset.name, asset.integrity, err = s.fingerprintName(name, data); err != nil {
+		return nil, err
+	}
+	if s.assets.assets == nil {
+		s.assets.assets = map[string]*fingerprintedAsset{}
+	}
+	s.assets.assets[name] = asset
+	return asset, nil
+}
+
+// fingerprintName returns the fingerprinted name and the integrity value of a file's content
+func (s *Site) fingerprintName(name string, data []byte) (string, string, error) {
+	algorithm := s.Config.Fingerprint.Algorithm
+	var h hash.Hash
+	switch algorithm {
+	case "", "sha256":
+		algorithm, h = "sha256", sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return "", "", fmt.Errorf("unsupported fingerprint algorithm %q", algorithm)
+	}
+	h.Write(data)
+	sum := h.Sum(nil)
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum)[:16] + ext, algorithm + "-" + base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// fingerprintURL is the fingerprint template function, returning the URL of the fingerprinted file
+func (s *Site) fingerprintURL(name string) (string, error) {
+	asset, err := s.fingerprint(name)
+	if err != nil {
+		return "", err
+	}
+	return s.RelURL(asset.name), nil
+}
+
+// integrity is the integrity template function, returning the Subresource Integrity value of a file
+func (s *Site) integrity(name string) (string, error) {
+	asset, err := s.fingerprint(name)
+	if err != nil {
+		return "", err
+	}
+	return asset.integrity, nil
+}
+
+// writeFingerprintedAssets copies the fingerprinted files next to their originals and writes
+// the manifest mapping logical to fingerprinted names
+func (s *Site) writeFingerprintedAssets(outputDir string) error {
+	if len(s.assets.assets) == 0 {
+		return nil
+	}
+	manifest := map[string]string{}
+	for name, asset := range s.assets.assets {
+		dest, err := outputFile(outputDir, asset.name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return err
+		}
+		if asset.content != nil {
+			err = os.WriteFile(dest, asset.content, 0644)
+		} else {
+			_, err = copyFile(asset.source, dest)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", asset.name, err)
+		}
+		manifest[name] = asset.name
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := s.Config.Fingerprint.Manifest
+	if name == "" {
+		name = "assets.json"
+	}
+	dest, err := outputFile(outputDir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return fmt.Errorf("failed to write asset manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fmEdit is one change of `fm set`
+type fmEdit struct {
+	key string
+	// op is "=" to set, "?=" to set when missing, "+=" to add to a list and "-=" to remove from it
+	op    string
+	value any
+}
+
+// fmCondition is a --where condition of `fm set`
+type fmCondition struct {
+	key    string
+	negate bool
+	value  string
+}
+
+// runSet implements `fm set [--where key=value]... edit...`, which changes the front matter of
+// the matching content files in bulk. An edit is key=value, key?=value (a default, kept when the
+// key exists), key+=value (adds to a list) or key-=value (removes from a list, and the key once
+// it is empty); values are read as YAML, so true, 3 and [a, b] are a boolean, a number and a
+// list. --rename and --delete change keys. Only the lines of the changed keys are rewritten, so
+// comments and the order of the others are kept. For example,
+// `fm set --where section=posts tags+=golang --dry-run`.
+func runSet(args []string) {
+	flags := flag.NewFlagSet("fm set", flag.ExitOnError)
+	var conditions []fmCondition
+	var renames [][2]string
+	var deletes []string
+	flags.Func("where", "only change files where key=value or key!=value; section and path (a glob) are built in, list values match an item", func(v string) error {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("want key=value or key!=value")
+		}
+		condition := fmCondition{key: key, value: value}
+		if key, ok := strings.CutSuffix(key, "!"); ok {
+			condition.key, condition.negate = key, true
+		}
+		conditions = append(conditions, condition)
+		return nil
+	})
+	flags.Func("rename", "rename a key, as old=new", func(v string) error {
+		old, name, ok := strings.Cut(v, "=")
+		if !ok || old == "" || name == "" {
+			return fmt.Errorf("want old=new")
+		}
+		renames = append(renames, [2]string{old, name})
+		return nil
+	})
+	flags.Func("delete", "delete a key", func(v string) error {
+		deletes = append(deletes, v)
+		return nil
+	})
+	dryRun := flags.Bool("dry-run", false, "show the changes as a diff without writing them")
+	positional := parseInterspersed(flags, args)
+
+	var edits []fmEdit
+	for _, arg := range positional {
+		edit, err := parseFMEdit(arg)
+		if err != nil {
+			log.Fatalf("Invalid edit %q: %v", arg, err)
+		}
+		edits = append(edits, edit)
+	}
+	if len(edits) == 0 && len(renames) == 0 && len(deletes) == 0 {
+		log.Fatalf("Usage: fm set [--where key=value]... [--rename old=new] [--delete key] [key=value|key?=value|key+=value|key-=value]...")
+	}
+
+	edit := func(doc *fmDocument) bool {
+		for _, condition := range conditions {
+			if condition.matches(doc) == condition.negate {
+				return false
+			}
+		}
+		changed := false
+		for _, rename := range renames {
+			changed = doc.renameKey(rename[0], rename[1]) || changed
+		}
+		for _, key := range deletes {
+			if _, ok := doc.params[key]; ok {
+				delete(doc.params, key)
+				changed = true
+			}
+		}
+		for _, e := range edits {
+			changed = e.apply(doc) || changed
+		}
+		if changed {
+			doc.parseDates()
+		}
+		return changed
+	}
+	if err := rewriteContent(edit, (*fmDocument).patch, *dryRun); err != nil {
+		log.Fatalf("Failed to edit front matter: %v", err)
+	}
+}
+
+// parseFMEdit parses key=value, key?=value, key+=value or key-=value
+func parseFMEdit(arg string) (fmEdit, error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok {
+		return fmEdit{}, fmt.Errorf("want key=value, key?=value, key+=value or key-=value")
+	}
+	e := fmEdit{key: key, op: "="}
+	for _, op := range []string{"?", "+", "-"} {
+		if k, ok := strings.CutSuffix(key, op); ok {
+			e.key, e.op = k, op+"="
+			break
+		}
+	}
+	if e.key == "" {
+		return fmEdit{}, fmt.Errorf("missing key")
+	}
+	if err := yaml.Unmarshal([]byte(value), &e.value); err != nil {
+		return fmEdit{}, err
+	}
+	if e.value == nil && value != "null" && value != "~" {
+		e.value = value
+	}
+	return e, nil
+}
+
+// apply changes the document, reporting whether it changed
+func (e fmEdit) apply(doc *fmDocument) bool {
+	current, exists := doc.params[e.key]
+	switch e.op {
+	case "?=":
+		if exists {
+			return false
+		}
+	case "+=":
+		list := fmList(current)
+		added := false
+		for _, item := range fmList(e.value) {
+			if !slices.ContainsFunc(list, func(v any) bool { return fmt.Sprint(v) == fmt.Sprint(item) }) {
+				list = append(list, item)
+				added = true
+			}
+		}
+		if !added {
+			return false
+		}
+		doc.setKey(e.key, list)
+		return true
+	case "-=":
+		if !exists {
+			return false
+		}
+		remove := fmList(e.value)
+		removed := func(v any) bool {
+			return slices.ContainsFunc(remove, func(item any) bool { return fmt.Sprint(v) == fmt.Sprint(item) })
+		}
+		list, isList := current.([]any)
+		if !isList {
+			if !removed(current) {
+				return false
+			}
+			delete(doc.params, e.key)
+			return true
+		}
+		kept := slices.DeleteFunc(slices.Clone(list), removed)
+		switch {
+		case len(kept) == len(list):
+			return false
+		case len(kept) == 0:
+			delete(doc.params, e.key)
+		default:
+			doc.params[e.key] = kept
+		}
+		return true
+	}
+	if exists && fmt.Sprint(current) == fmt.Sprint(e.value) {
+		return false
+	}
+	doc.setKey(e.key, e.value)
+	return true
+}
+
+// fmList returns a list value as a list and any other value as a list of one
+func fmList(v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return slices.Clone(v)
+	}
+	return []any{v}
+}
+
+// matches reports whether the document satisfies the condition, ignoring negation
+func (c fmCondition) matches(doc *fmDocument) bool {
+	switch c.key {
+	case "section":
+		return fileSection(doc.file) == c.value
+	case "path":
+		return matchContentPattern(c.value, strings.ReplaceAll(doc.file.RelPath, `\`, "/"))
+	}
+	value, ok := doc.params[c.key]
+	if !ok {
+		return c.value == ""
+	}
+	return slices.ContainsFunc(fmList(value), func(v any) bool { return fmt.Sprint(v) == c.value })
+}
+
+// setKey sets a key, adding it after the existing keys when new
+func (doc *fmDocument) setKey(key string, value any) {
+	if !slices.Contains(doc.keys, key) {
+		doc.keys = append(doc.keys, key)
+	}
+	doc.params[key] = value
+}
+
+// renameKey renames a key in place, reporting whether the key existed; an existing key with the
+// new name is replaced
+func (doc *fmDocument) renameKey(old, name string) bool {
+	value, ok := doc.params[old]
+	if !ok || old == name {
+		return false
+	}
+	delete(doc.params, old)
+	if doc.renamed == nil {
+		doc.renamed = map[string]string{}
+	}
+	source := old
+	for key, renamed := range doc.renamed {
+		if renamed == old {
+			source = key
+		}
+	}
+	doc.renamed[source] = name
+	doc.keys = slices.DeleteFunc(doc.keys, func(key string) bool { return key == name })
+	if i := slices.Index(doc.keys, old); i >= 0 {
+		doc.keys[i] = name
+	} else {
+		doc.keys = append(doc.keys, name)
+	}
+	doc.params[name] = value
+	return true
+}
+
+// tomlBoundaryPattern matches the lines of TOML front matter that start a key or a table,
+// capturing the key
+var tomlBoundaryPattern = regexp.MustCompile(`^(?:\[\[?\s*([\w-]+)|\s*([\w.-]+)\s*=|\s*"([^"]*)"\s*=|\s*'([^']*)'\s*=)`)
+
+// plainKeyPattern matches the keys written without quotes in YAML and TOML
+var plainKeyPattern = regexp.MustCompile(`^[\w-]+$`)
+
+// patch writes the document with only the changed keys edited in the text of the front matter,
+// so the comments, order and formatting of the other keys are kept. Documents without front
+// matter, and TOML tables, which cannot be edited line by line, are encoded in full.
+func (doc *fmDocument) patch() ([]byte, error) {
+	if doc.metaEnd == 0 {
+		return doc.encode()
+	}
+	lines := strings.SplitAfter(string(doc.data[doc.metaStart:doc.metaEnd]), "\n")
+	lines = lines[:len(lines)-1]
+	spans, tables, err := doc.keySpans(lines)
+	if err != nil {
+		return nil, err
+	}
+	original := maps.Clone(doc.original)
+	parseDateKeys(original)
+
+	// encodeKey writes one key as front matter lines
+	encodeKey := func(key string) ([]string, error) {
+		encode := encodeYAMLFrontMatter
+		if doc.format == formatTOML {
+			encode = encodeTOMLFrontMatter
+		}
+		data, err := encode(doc.params, []string{key})
+		if err != nil {
+			return nil, err
+		}
+		return strings.SplitAfter(strings.ReplaceAll(strings.TrimSuffix(string(data), "\n"), "\n", doc.newline)+doc.newline, doc.newline), nil
+	}
+	// Each span is replaced by its new lines, nil to keep it
+	replaced := map[string][]string{}
+	targets := map[string]bool{}
+	for key := range doc.original {
+		if _, ok := spans[key]; ok {
+			continue
+		}
+		// A key that could not be found in the text can only be kept as it is
+		if value, ok := doc.params[key]; !ok || doc.renamed[key] != "" || !reflect.DeepEqual(original[key], value) {
+			return doc.encode()
+		}
+		targets[key] = true
+	}
+	for key, span := range spans {
+		target := key
+		if name, ok := doc.renamed[key]; ok {
+			target = name
+		}
+		value, ok := doc.params[target]
+		renamedOver := false
+		for source, name := range doc.renamed {
+			renamedOver = renamedOver || (name == key && source != key)
+		}
+		if doc.format == formatTOML && (tables[key] || (ok && isTOMLTable(value))) &&
+			(!ok || target != key || !reflect.DeepEqual(original[key], value) || renamedOver) {
+			return doc.encode()
+		}
+		switch {
+		case !ok || renamedOver && target == key:
+			// The comment lines right above a deleted key go with it
+			for span[0] > 0 && strings.HasPrefix(lines[span[0]-1], "#") {
+				span[0]--
+			}
+			spans[key] = span
+			replaced[key] = []string{}
+		case !reflect.DeepEqual(original[key], value):
+			if replaced[key], err = encodeKey(target); err != nil {
+				return nil, err
+			}
+		case target != key:
+			first := lines[span[0]]
+			indent := len(first) - len(strings.TrimLeft(first, " \t"))
+			if rest, found := strings.CutPrefix(first[indent:], key); found && plainKeyPattern.MatchString(target) &&
+				strings.IndexAny(rest, ":= \t") == 0 {
+				replaced[key] = append([]string{first[:indent] + target + rest}, lines[span[0]+1:span[1]]...)
+			} else if replaced[key], err = encodeKey(target); err != nil {
+				return nil, err
+			}
+		}
+		targets[target] = true
+	}
+
+	// New keys go after the other top-level keys
+	var added []string
+	for _, key := range doc.keys {
+		if _, ok := doc.params[key]; !ok || targets[key] {
+			continue
+		}
+		if doc.format == formatTOML && isTOMLTable(doc.params[key]) {
+			return doc.encode()
+		}
+		keyLines, err := encodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, keyLines...)
+		targets[key] = true
+	}
+	insert := len(lines)
+	if doc.format == formatTOML {
+		for i, line := range lines {
+			if strings.HasPrefix(line, "[") {
+				insert = i
+				break
+			}
+		}
+		for insert > 0 && isSpanTrailer(lines[insert-1]) {
+			insert--
+		}
+	}
+
+	var out strings.Builder
+	out.Write(doc.data[:doc.metaStart])
+	for i := 0; i <= len(lines); i++ {
+		if i == insert {
+			out.WriteString(strings.Join(added, ""))
+		}
+		if i == len(lines) {
+			break
+		}
+		if key, ok := keySpanAt(spans, i); ok && replaced[key] != nil {
+			out.WriteString(strings.Join(replaced[key], ""))
+			i = spans[key][1] - 1
+			continue
+		}
+		out.WriteString(lines[i])
+	}
+	out.Write(doc.data[doc.metaEnd:])
+	return []byte(out.String()), nil
+}
+
+// keySpans returns the lines of every top-level key of the front matter, from the key to the
+// line before the next key without the blank lines and comments in between, and which keys are
+// TOML tables
+func (doc *fmDocument) keySpans(lines []string) (map[string][2]int, map[string]bool, error) {
+	var starts []int
+	var names []string
+	tables := map[string]bool{}
+	if doc.format == formatTOML {
+		inTable := false
+		for i, line := range lines {
+			m := tomlBoundaryPattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			isTable := strings.HasPrefix(line, "[")
+			if inTable && !isTable {
+				continue
+			}
+			inTable = inTable || isTable
+			key := m[1] + m[2] + m[3] + m[4]
+			if slices.Contains(names, key) {
+				key = ""
+			}
+			if isTable && key != "" {
+				tables[key] = true
+			}
+			starts, names = append(starts, i), append(names, key)
+		}
+	} else {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(strings.Join(lines, "")), &node); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse YAML front matter: %w", err)
+		}
+		if len(node.Content) > 0 && node.Content[0].Kind == yaml.MappingNode {
+			mapping := node.Content[0].Content
+			for i := 0; i < len(mapping); i += 2 {
+				starts, names = append(starts, mapping[i].Line-1), append(names, mapping[i].Value)
+			}
+		}
+	}
+	spans := map[string][2]int{}
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		for end > start+1 && isSpanTrailer(lines[end-1]) {
+			end--
+		}
+		if names[i] != "" {
+			spans[names[i]] = [2]int{start, end}
+		}
+	}
+	return spans, tables, nil
+}
+
+// isSpanTrailer reports whether a front matter line is blank or a comment that starts the line
+func isSpanTrailer(line string) bool {
+	return strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#")
+}
+
+// keySpanAt returns the key whose span starts at the line
+func keySpanAt(spans map[string][2]int, line int) (string, bool) {
+	for key, span := range spans {
+		if span[0] == line {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPatchFrontMatter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		edit  func(doc *fmDocument)
+		want  string
+	}{
+		{
+			name:  "set keeps comments and order",
+			input: "---\n# page\ntitle: Hello # greeting\ntags:\n  - go # first\ndraft: true\n---\nBody\n",
+			edit:  func(doc *fmDocument) { doc.setKey("draft", false) },
+			want:  "---\n# page\ntitle: Hello # greeting\ntags:\n  - go # first\ndraft: false\n---\nBody\n",
+		},
+		{
+			name:  "new key goes last",
+			input: "---\ntitle: Hello\n\n# trailing\n---\nBody\n",
+			edit:  func(doc *fmDocument) { doc.setKey("weight", 3) },
+			want:  "---\ntitle: Hello\n\n# trailing\nweight: 3\n---\nBody\n",
+		},
+		{
+			name:  "delete takes its comment",
+			input: "---\ntitle: Hello\n\n# who wrote it\nauthor: me\nweight: 1\n---\nBody\n",
+			edit:  func(doc *fmDocument) { delete(doc.params, "author") },
+			want:  "---\ntitle: Hello\n\nweight: 1\n---\nBody\n",
+		},
+		{
+			name:  "rename keeps the value as written",
+			input: "---\nold: [a,  b] # list\ntitle: Hello\n---\n",
+			edit:  func(doc *fmDocument) { doc.renameKey("old", "new") },
+			want:  "---\nnew: [a,  b] # list\ntitle: Hello\n---\n",
+		},
+		{
+			name:  "toml key before the tables",
+			input: "+++\n# page\ntitle = 'B'\n\n[author]\nname = 'me'\n+++\nBody\n",
+			edit:  func(doc *fmDocument) { doc.setKey("draft", true) },
+			want:  "+++\n# page\ntitle = 'B'\ndraft = true\n\n[author]\nname = 'me'\n+++\nBody\n",
+		},
+		{
+			name:  "toml multiline value",
+			input: "+++\ntags = [\n  'x', # keep\n]\ntitle = 'B'\n+++\n",
+			edit:  func(doc *fmDocument) { doc.setKey("tags", []any{"x", "y"}) },
+			want:  "+++\ntags = ['x', 'y']\ntitle = 'B'\n+++\n",
+		},
+		{
+			name:  "crlf",
+			input: "---\r\ntitle: x\r\n---\r\nBody\r\n",
+			edit:  func(doc *fmDocument) { doc.setKey("draft", true) },
+			want:  "---\r\ntitle: x\r\ndraft: true\r\n---\r\nBody\r\n",
+		},
+		{
+			name:  "no front matter",
+			input: "Body\n",
+			edit:  func(doc *fmDocument) { doc.setKey("title", "x") },
+			want:  "---\ntitle: x\n---\nBody\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "page.md")
+			if err := os.WriteFile(path, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			doc, err := readFMDocument(contentFile{Path: path, RelPath: "page.md"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(doc)
+			doc.parseDates()
+			got, err := doc.patch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("patch() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
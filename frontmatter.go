@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Front matter formats, named by their delimiters
+const (
+	formatYAML = "yaml"
+	formatTOML = "toml"
+)
+
+// canonicalKeys are written first, in this order, by `fm normalize`; taxonomies follow, then the
+// other keys alphabetically
+var canonicalKeys = []string{"id", "title", "slug", "description", "date", "publishDate", "lastmod", "expiryDate", "draft", "unlisted", "weight", "layout"}
+
+// dateKeys hold dates, written as dates when they have no time of day
+var dateKeys = []string{"date", "publishDate", "lastmod", "expiryDate"}
+
+// tomlKeyPattern matches a top-level key or table header of TOML front matter
+var tomlKeyPattern = regexp.MustCompile(`^(?:\[\[?\s*([\w-]+)|([\w-]+)\s*=)`)
+
+// fmDocument is a content file split into its parsed front matter and its body
+type fmDocument struct {
+	file   contentFile
+	data   []byte
+	format string
+	// keys lists the top-level keys in the order they were written
+	keys   []string
+	params map[string]any
+	// body is everything after the closing delimiter line, byte for byte
+	body    []byte
+	newline string
+	// metaStart and metaEnd bound the front matter lines between the delimiters in data, zero
+	// when the file has no front matter
+	metaStart, metaEnd int
+	// original holds the front matter as read, and renamed the new names of its renamed keys
+	original map[string]any
+	renamed  map[string]string
+}
+
+// runFrontMatter implements `fm`, which rewrites the front matter of the content files
+func runFrontMatter(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("Usage: fm normalize|set [flags]")
+	}
+	switch args[0] {
+	case "normalize":
+		runNormalize(args[1:])
+	case "set":
+		runSet(args[1:])
+	default:
+		log.Fatalf("Unknown fm command: %s", args[0])
+	}
+}
+
+// runNormalize implements `fm normalize`: it rewrites every front matter in one format with the
+// keys in canonical order, consistent key case and dates, leaving the content after it as it is.
+// Comments in rewritten front matter are not kept.
+func runNormalize(args []string) {
+	flags := flag.NewFlagSet("fm normalize", flag.ExitOnError)
+	format := flags.String("format", formatYAML, "front matter format to write: yaml (---) or toml (+++)")
+	slugs := flags.Bool("slugs", false, "add a slug field with the file or bundle name to pages without one")
+	dryRun := flags.Bool("dry-run", false, "show the changes as a diff without writing them")
+	flags.Parse(args)
+	if *format != formatYAML && *format != formatTOML {
+		log.Fatalf("Unknown format %q, use yaml or toml", *format)
+	}
+
+	config, err := loadEnvironmentConfig(envOr("HERO_ENVIRONMENT", "production"))
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	known := slices.Concat(canonicalKeys, config.taxonomyNames())
+
+	edit := func(doc *fmDocument) bool {
+		doc.renameFoldedKeys(known)
+		for key, value := range doc.params {
+			doc.params[key] = normalizeImportedValue(value)
+		}
+		doc.parseDates()
+		if name := contentName(newFile(doc.file)); *slugs && doc.params["slug"] == nil && name != "" && !isBranchFile(doc.file) {
+			doc.params["slug"] = strings.TrimPrefix(name, filenameDatePattern.FindString(name))
+			doc.keys = append(doc.keys, "slug")
+		}
+		doc.keys = canonicalOrder(doc.keys, config.taxonomyNames())
+		doc.format = *format
+		return true
+	}
+	if err := rewriteContent(edit, (*fmDocument).encode, *dryRun); err != nil {
+		log.Fatalf("Failed to normalize front matter: %v", err)
+	}
+}
+
+// isBranchFile reports whether the file supplies a home or section list page
+func isBranchFile(file contentFile) bool {
+	rel := strings.ReplaceAll(file.RelPath, `\`, "/")
+	return rel == "index.md" || strings.HasSuffix(rel, "_index.md")
+}
+
+// rewriteContent applies edit to the front matter of every content file and writes the files
+// it changed with encode, or prints their diff on a dry run
+func rewriteContent(edit func(doc *fmDocument) bool, encode func(doc *fmDocument) ([]byte, error), dryRun bool) error {
+	files, _, err := collectContent("./content/")
+	if err != nil {
+		return fmt.Errorf("failed to read content directory: %w", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].RelPath < files[j].RelPath })
+
+	changed := 0
+	for _, file := range files {
+		doc, err := readFMDocument(file)
+		if err != nil {
+			warnf("Skipping %s: %v", file.RelPath, err)
+			continue
+		}
+		if doc == nil {
+			continue
+		}
+		if !edit(doc) {
+			continue
+		}
+		data, err := encode(doc)
+		if err != nil {
+			return fmt.Errorf("failed to encode front matter of %s: %w", file.RelPath, err)
+		}
+		if bytes.Equal(data, doc.data) {
+			continue
+		}
+		changed++
+		if dryRun {
+			edits, ok := lineEdits(splitLines(string(doc.data)), splitLines(string(data)))
+			if !ok {
+				fmt.Printf("--- a/%s\n+++ b/%s\n(too many changes to show)\n", file.RelPath, file.RelPath)
+				continue
+			}
+			fmt.Print(unifiedDiff(edits, file.RelPath, 3))
+			continue
+		}
+		if err := os.WriteFile(file.Path, data, 0644); err != nil {
+			return err
+		}
+	}
+	verb := "Rewrote"
+	if dryRun {
+		verb = "Would rewrite"
+	}
+	fmt.Printf("%s %d of %d files\n", verb, changed, len(files))
+	return nil
+}
+
+// readFMDocument splits a content file into its front matter and body, returning nil for files
+// that are not UTF-8 without a BOM, as they are never rewritten
+func readFMDocument(file contentFile) (*fmDocument, error) {
+	data, err := os.ReadFile(file.Path)
+	if err != nil {
+		return nil, err
+	}
+	if _, encoding, _ := decodeContent(data); encoding != "" {
+		warnf("Skipping %s, only UTF-8 files without a BOM are rewritten", file.RelPath)
+		return nil, nil
+	}
+	doc := &fmDocument{file: file, data: data, format: formatYAML, params: map[string]any{}, body: data, newline: "\n"}
+	if first, _, _ := bytes.Cut(data, []byte("\n")); bytes.HasSuffix(first, []byte("\r")) {
+		doc.newline = "\r\n"
+	}
+	if !bytes.HasPrefix(data, []byte("---")) && !bytes.HasPrefix(data, []byte("+++")) {
+		return doc, nil
+	}
+
+	// Find the closing delimiter in the original bytes, so the body is kept exactly
+	delimiter := string(data[:3])
+	pos := bytes.IndexByte(data, '\n') + 1
+	doc.metaStart = pos
+	var meta []byte
+	found := false
+	for pos > 0 && pos < len(data) {
+		line, next := data[pos:], len(data)
+		if end := bytes.IndexByte(line, '\n'); end >= 0 {
+			line, next = line[:end], pos+end+1
+		}
+		if string(bytes.TrimRight(line, " \t\r")) == delimiter {
+			doc.body = data[next:]
+			doc.metaEnd = pos
+			found = true
+			break
+		}
+		meta = append(append(meta, line...), '\n')
+		pos = next
+	}
+	if !found {
+		return nil, fmt.Errorf("no closing %s delimiter", delimiter)
+	}
+	meta = bytes.ReplaceAll(meta, []byte("\r\n"), []byte("\n"))
+
+	if delimiter == "---" {
+		var node yaml.Node
+		if err := yaml.Unmarshal(meta, &node); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML front matter: %w", err)
+		}
+		if len(node.Content) > 0 && node.Content[0].Kind == yaml.MappingNode {
+			for i := 0; i < len(node.Content[0].Content); i += 2 {
+				doc.keys = append(doc.keys, node.Content[0].Content[i].Value)
+			}
+		}
+		if err := yaml.Unmarshal(meta, &doc.params); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML front matter: %w", err)
+		}
+	} else {
+		doc.format = formatTOML
+		if err := toml.Unmarshal(meta, &doc.params); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML front matter: %w", err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(meta))
+		for scanner.Scan() {
+			if m := tomlKeyPattern.FindStringSubmatch(strings.TrimSpace(scanner.Text())); m != nil {
+				if key := m[1] + m[2]; !slices.Contains(doc.keys, key) {
+					doc.keys = append(doc.keys, key)
+				}
+			}
+		}
+	}
+	if doc.params == nil {
+		doc.params = map[string]any{}
+	}
+	doc.original = maps.Clone(doc.params)
+	// Keys the scan missed, e.g. quoted TOML keys, go last
+	var missing []string
+	for key := range doc.params {
+		if !slices.Contains(doc.keys, key) {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	doc.keys = append(doc.keys, missing...)
+	return doc, nil
+}
+
+// parseDates turns the date keys into times, so they are written as dates rather than strings
+func (doc *fmDocument) parseDates() {
+	parseDateKeys(doc.params)
+}
+
+// parseDateKeys turns the date keys of front matter values into times
+func parseDateKeys(params map[string]any) {
+	for _, key := range dateKeys {
+		if t := toTime(params[key]); !t.IsZero() {
+			params[key] = t
+		}
+	}
+}
+
+// renameFoldedKeys spells keys that differ from a known key only in case like the known key
+func (doc *fmDocument) renameFoldedKeys(known []string) {
+	for i, key := range doc.keys {
+		for _, want := range known {
+			if key == want || !strings.EqualFold(key, want) {
+				continue
+			}
+			if _, exists := doc.params[want]; exists {
+				break
+			}
+			doc.params[want] = doc.params[key]
+			delete(doc.params, key)
+			doc.keys[i] = want
+			break
+		}
+	}
+}
+
+// canonicalOrder sorts keys into the order of canonicalKeys, then taxonomies, then the rest
+// alphabetically
+func canonicalOrder(keys, taxonomies []string) []string {
+	rank := func(key string) int {
+		if i := slices.Index(canonicalKeys, key); i >= 0 {
+			return i
+		}
+		if slices.Contains(taxonomies, key) {
+			return len(canonicalKeys)
+		}
+		return len(canonicalKeys) + 1
+	}
+	sorted := slices.Clone(keys)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ri, rj := rank(sorted[i]), rank(sorted[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if ri >= len(canonicalKeys) {
+			return sorted[i] < sorted[j]
+		}
+		return false
+	})
+	return sorted
+}
+
+// encode writes the front matter in the document format with its keys in order, followed by the
+// body; a document without keys is written as its body alone
+func (doc *fmDocument) encode() ([]byte, error) {
+	var keys []string
+	for _, key := range doc.keys {
+		if _, ok := doc.params[key]; ok && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return doc.body, nil
+	}
+
+	var meta []byte
+	var err error
+	delimiter := "---"
+	if doc.format == formatTOML {
+		delimiter = "+++"
+		meta, err = encodeTOMLFrontMatter(doc.params, keys)
+	} else {
+		meta, err = encodeYAMLFrontMatter(doc.params, keys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := delimiter + "\n" + string(meta) + delimiter + "\n"
+	if doc.newline != "\n" {
+		out = strings.ReplaceAll(out, "\n", doc.newline)
+	}
+	return append([]byte(out), doc.body...), nil
+}
+
+// fmDate returns a time without a time of day as a date only
+func fmDate(t time.Time) (string, bool) {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 && t.Location() == time.UTC {
+		return t.Format("2006-01-02"), true
+	}
+	return t.Format(time.RFC3339), false
+}
+
+// encodeYAMLFrontMatter writes the keys in order as a YAML mapping
+func encodeYAMLFrontMatter(params map[string]any, keys []string) ([]byte, error) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range keys {
+		value := &yaml.Node{}
+		if t, ok := params[key].(time.Time); ok {
+			formatted, _ := fmDate(t)
+			value = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: formatted}
+		} else if err := value.Encode(params[key]); err != nil {
+			return nil, err
+		} else {
+			shortenYAMLDates(value)
+		}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(mapping); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// shortenYAMLDates writes the nested times without a time of day as dates
+func shortenYAMLDates(node *yaml.Node) {
+	if node.Tag == "!!timestamp" {
+		if t, err := time.Parse(time.RFC3339Nano, node.Value); err == nil {
+			node.Value, _ = fmDate(t)
+		}
+	}
+	for _, child := range node.Content {
+		shortenYAMLDates(child)
+	}
+}
+
+// tomlDates turns the times without a time of day into TOML local dates
+func tomlDates(value any) any {
+	switch v := value.(type) {
+	case time.Time:
+		if _, dateOnly := fmDate(v); dateOnly {
+			return toml.LocalDate{Year: v.Year(), Month: int(v.Month()), Day: v.Day()}
+		}
+	case map[string]any:
+		for k, item := range v {
+			v[k] = tomlDates(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = tomlDates(item)
+		}
+	}
+	return value
+}
+
+// encodeTOMLFrontMatter writes the keys in order as TOML, tables after the other keys as TOML
+// requires
+func encodeTOMLFrontMatter(params map[string]any, keys []string) ([]byte, error) {
+	var values, tables []byte
+	for _, key := range keys {
+		value := tomlDates(params[key])
+		data, err := toml.Marshal(map[string]any{key: value})
+		if err != nil {
+			return nil, err
+		}
+		if isTOMLTable(value) {
+			tables = append(append(tables, '\n'), data...)
+		} else {
+			values = append(values, data...)
+		}
+	}
+	return append(values, tables...), nil
+}
+
+// isTOMLTable reports whether a value is written as a table or an array of tables
+func isTOMLTable(value any) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		return true
+	case []any:
+		return len(v) > 0 && !slices.ContainsFunc(v, func(item any) bool {
+			_, ok := item.(map[string]any)
+			return !ok
+		})
+	}
+	return false
+}
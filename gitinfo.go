@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+// GitInfo is the last commit that touched a content file
+type GitInfo struct {
+	Hash            string
+	AbbreviatedHash string
+	AuthorName      string
+	AuthorEmail     string
+	AuthorDate      time.Time
+	Subject         string
+}
+
+// RepositoryConfig describes where the site source is hosted, for edit and history links.
+// EditURL and HistoryURL are patterns where {url}, {branch} and {path} are replaced;
+// GitHub and GitLab patterns are used when they are empty.
+type RepositoryConfig struct {
+	URL        string `toml:"url"`
+	Branch     string `toml:"branch"`
+	ContentDir string `toml:"contentDir"`
+	EditURL    string `toml:"editURL"`
+	HistoryURL string `toml:"historyURL"`
+}
+
+// loadGitInfo reads the latest commit of every file below contentDir in a single git call.
+// The result is keyed by the slash-separated path relative to contentDir; names are read
+// NUL-separated, as git quotes those with special or non-ASCII characters otherwise.
+func loadGitInfo(ctx context.Context, contentDir string) (map[string]*GitInfo, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", contentDir, "log", "-z", "--relative", "--name-only",
+		"--format=%x1e%H%x1f%h%x1f%an%x1f%ae%x1f%aI%x1f%s", "--", ".").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git log: %w", err)
+	}
+
+	infos := map[string]*GitInfo{}
+	for _, record := range strings.Split(string(out), "\x1e") {
+		header, files, _ := strings.Cut(record, "\x00")
+		fields := strings.Split(header, "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[4])
+		info := &GitInfo{
+			Hash:            fields[0],
+			AbbreviatedHash: fields[1],
+			AuthorName:      fields[2],
+			AuthorEmail:     fields[3],
+			AuthorDate:      date,
+			Subject:         fields[5],
+		}
+		for _, file := range strings.Split(strings.TrimPrefix(files, "\n"), "\x00") {
+			// The log is newest first, so keep the first commit seen for each file
+			if _, seen := infos[file]; file != "" && !seen {
+				infos[file] = info
+			}
+		}
+	}
+	return infos, nil
+}
+
+// useGitInfo loads the git history of the content directory so loadContent can attach
+// the last commit of every page source, which the ":git" date source reads
+func (s *Site) useGitInfo(contentDir string) error {
+	infos, err := loadGitInfo(s.ctx, contentDir)
+	if err != nil {
+		return err
+	}
+	s.gitInfos = infos
+	return nil
+}
+
+// repositoryLink expands an edit or history URL pattern for a content file
+func (s *Site) repositoryLink(pattern, githubPattern, gitlabPattern string, file *File) string {
+	repo := s.Config.Repository
+	if repo.URL == "" || file == nil {
+		return ""
+	}
+	if pattern == "" {
+		pattern = githubPattern
+		if strings.Contains(repo.URL, "gitlab") {
+			pattern = gitlabPattern
+		}
+	}
+	branch := repo.Branch
+	if branch == "" {
+		branch = "main"
+	}
+	contentDir := repo.ContentDir
+	if contentDir == "" {
+		contentDir = "content"
+	}
+	return strings.NewReplacer(
+		"{url}", strings.TrimSuffix(repo.URL, "/"),
+		"{branch}", branch,
+		"{path}", path.Join(contentDir, file.Path),
+	).Replace(pattern)
+}
+
+// EditURL returns the link to edit the page source on the configured repository host
+func (p *Page) EditURL() string {
+	return p.Site.repositoryLink(p.Site.Config.Repository.EditURL,
+		"{url}/edit/{branch}/{path}", "{url}/-/edit/{branch}/{path}", p.File)
+}
+
+// HistoryURL returns the link to the commit history of the page source
+func (p *Page) HistoryURL() string {
+	return p.Site.repositoryLink(p.Site.Config.Repository.HistoryURL,
+		"{url}/commits/{branch}/{path}", "{url}/-/commits/{branch}/{path}", p.File)
+}
+
+// CommitURL returns the link to the last commit that changed the page source
+func (p *Page) CommitURL() string {
+	repo := p.Site.Config.Repository
+	if repo.URL == "" || p.GitInfo == nil {
+		return ""
+	}
+	if strings.Contains(repo.URL, "gitlab") {
+		return strings.TrimSuffix(repo.URL, "/") + "/-/commit/" + p.GitInfo.Hash
+	}
+	return strings.TrimSuffix(repo.URL, "/") + "/commit/" + p.GitInfo.Hash
+}
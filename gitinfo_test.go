@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadGitInfo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=Ana", "-c", "user.email=ana@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, data string) {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "--quiet")
+	write("école.md", "a")
+	write("posts/a b.md", "a")
+	write("日本/index.md", "a")
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "first")
+	write("école.md", "b")
+	git("commit", "--quiet", "-am", "second")
+
+	infos, err := loadGitInfo(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"école.md": "second", "posts/a b.md": "first", "日本/index.md": "first"}
+	if len(infos) != len(want) {
+		t.Errorf("loadGitInfo() has %d files, want %d: %v", len(infos), len(want), infos)
+	}
+	for file, subject := range want {
+		if info := infos[file]; info == nil || info.Subject != subject {
+			t.Errorf("loadGitInfo()[%q] = %+v, want subject %q", file, info, subject)
+		}
+	}
+}
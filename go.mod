@@ -5,5 +5,6 @@ go 1.23.2
 require (
     github.com/yuin/goldmark v1.7.4
     github.com/pelletier/go-toml/v2 v2.2.3
+    golang.org/x/net v0.38.0
     gopkg.in/yaml.v3 v3.0.1
 )
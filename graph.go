@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// linkGraph is the graph of the links between the pages of the site and their taxonomy terms
+type linkGraph struct {
+	Nodes []linkNode `json:"nodes"`
+	Edges []linkEdge `json:"edges"`
+}
+
+// linkNode is a page of the link graph; Orphan marks regular pages no other page links to
+type linkNode struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Kind     string `json:"kind"`
+	Section  string `json:"section,omitempty"`
+	Inbound  int    `json:"inbound"`
+	Outbound int    `json:"outbound"`
+	Orphan   bool   `json:"orphan,omitempty"`
+}
+
+// linkEdge is a link from one page to another, or the membership of a page in a term, with the
+// taxonomy as its type
+type linkEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+// Backlinks returns the pages whose content links to the page, in site order
+func (p *Page) Backlinks() []*Page {
+	return p.backlinks
+}
+
+// linkTargets returns the listed pages the content of each page links to, by source page.
+// Links are resolved against the page, so relative and absolute URLs of the site count alike;
+// unlisted pages are neither sources nor targets so their URLs stay out of listed pages.
+func (s *Site) linkTargets() map[*Page][]*Page {
+	byPath := map[string]*Page{}
+	for _, p := range s.AllPages {
+		if !p.Unlisted {
+			byPath[linkKey(p.RelPermalink)] = p
+		}
+	}
+	site, _ := url.Parse(s.AbsURL("/"))
+	targets := map[*Page][]*Page{}
+	for _, p := range s.AllPages {
+		if p.Unlisted || p.Content == "" {
+			continue
+		}
+		base, err := url.Parse(p.Permalink)
+		if err != nil {
+			continue
+		}
+		seen := map[*Page]bool{p: true}
+		for _, link := range extractLinks(string(p.Content)) {
+			u, err := base.Parse(link)
+			if err != nil || (site != nil && site.Host != "" && u.Host != site.Host) {
+				continue
+			}
+			if target, ok := byPath[linkKey(u.Path)]; ok && !seen[target] {
+				seen[target] = true
+				targets[p] = append(targets[p], target)
+			}
+		}
+	}
+	return targets
+}
+
+// linkKey normalizes a URL path so /a/, /a/index.html and /a match
+func linkKey(p string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(p, "index.html"), "/")
+}
+
+// setBacklinks sets the backlinks of every page from the links of the others
+func (s *Site) setBacklinks() {
+	targets := s.linkTargets()
+	for _, p := range s.AllPages {
+		for _, target := range targets[p] {
+			target.backlinks = append(target.backlinks, p)
+		}
+	}
+}
+
+// linkGraph builds the graph of the listed pages, their links and their terms
+func (s *Site) linkGraph() linkGraph {
+	graph := linkGraph{Nodes: []linkNode{}, Edges: []linkEdge{}}
+	targets := s.linkTargets()
+	nodes := map[*Page]int{}
+	for _, p := range s.AllPages {
+		if p.Unlisted {
+			continue
+		}
+		nodes[p] = len(graph.Nodes)
+		graph.Nodes = append(graph.Nodes, linkNode{ID: p.RelPermalink, Title: p.Title, Kind: p.Kind, Section: p.Section})
+	}
+	for _, p := range s.AllPages {
+		for _, target := range targets[p] {
+			graph.Edges = append(graph.Edges, linkEdge{Source: p.RelPermalink, Target: target.RelPermalink, Type: "link"})
+			graph.Nodes[nodes[p]].Outbound++
+			graph.Nodes[nodes[target]].Inbound++
+		}
+	}
+	for _, taxonomy := range slices.Sorted(maps.Keys(s.Taxonomies)) {
+		for _, term := range s.Taxonomies[taxonomy] {
+			if term.Page == nil {
+				continue
+			}
+			for _, p := range term.Pages {
+				if _, ok := nodes[p]; ok {
+					graph.Edges = append(graph.Edges, linkEdge{Source: p.RelPermalink, Target: term.Page.RelPermalink, Type: taxonomy})
+				}
+			}
+		}
+	}
+	for i := range graph.Nodes {
+		graph.Nodes[i].Orphan = graph.Nodes[i].Kind == KindPage && graph.Nodes[i].Inbound == 0
+	}
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Target < b.Target
+	})
+	return graph
+}
+
+// writeDOT writes the graph in the Graphviz DOT language; orphans are red and term memberships
+// dashed
+func (g linkGraph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph site {\n  rankdir=LR;\n  node [shape=box, fontname=\"Helvetica\"];\n")
+	for _, n := range g.Nodes {
+		attrs := fmt.Sprintf("label=%q", n.Title)
+		switch {
+		case n.Orphan:
+			attrs += ", color=red"
+		case n.Kind == KindTerm || n.Kind == KindTaxonomy:
+			attrs += ", shape=ellipse"
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", n.ID, attrs)
+	}
+	for _, e := range g.Edges {
+		if e.Type == "link" {
+			fmt.Fprintf(&b, "  %q -> %q;\n", e.Source, e.Target)
+		} else {
+			fmt.Fprintf(&b, "  %q -> %q [style=dashed, label=%q];\n", e.Source, e.Target, e.Type)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// runGraph implements `graph`, which writes the link graph of the site as JSON or DOT
+func runGraph(args []string) {
+	flags := flag.NewFlagSet("graph", flag.ExitOnError)
+	format := flags.String("format", "json", "output format: json or dot")
+	output := flags.String("output", "", "file to write instead of stdout")
+	environment := flags.String("environment", envOr("HERO_ENVIRONMENT", "production"), "environment whose config is used")
+	orphans := flags.Bool("orphans", false, "only list the pages no other page links to")
+	flags.Parse(args)
+	if *format != "json" && *format != "dot" {
+		log.Fatalf("Unknown format %q, use json or dot", *format)
+	}
+
+	site, _, err := loadSite(buildOptions{Environment: *environment})
+	if err != nil {
+		log.Fatalf("Failed to load site: %v", err)
+	}
+	graph := site.linkGraph()
+	if *orphans {
+		for _, n := range graph.Nodes {
+			if n.Orphan {
+				fmt.Println(n.ID)
+			}
+		}
+		return
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if *format == "dot" {
+		err = graph.writeDOT(w)
+	} else {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(graph)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write graph: %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ServerConfig configures the host configuration written next to the site
+type ServerConfig struct {
+	// Headers maps URL patterns such as "/*" to the response headers set for them
+	Headers map[string]map[string]string `toml:"headers"`
+}
+
+// CSPConfig builds the Content-Security-Policy header for "/*". With AutoHash, the hashes of
+// every inline script and style of the rendered pages are added to script-src and style-src,
+// which start from the sources of default-src when they are not configured.
+type CSPConfig struct {
+	AutoHash   bool              `toml:"autoHash"`
+	Directives map[string]string `toml:"directives"`
+}
+
+// headerRule is one entry of headers.json
+type headerRule struct {
+	For    string            `json:"for"`
+	Values map[string]string `json:"values"`
+}
+
+var (
+	inlineScriptPattern = regexp.MustCompile(`(?is)<script([^>]*)>(.*?)</script>`)
+	inlineStylePattern  = regexp.MustCompile(`(?is)<style[^>]*>(.*?)</style>`)
+	scriptTypePattern   = regexp.MustCompile(`(?i)\btype\s*=\s*"?([^"\s>]+)`)
+)
+
+// renderHeaders writes the configured headers as a Netlify _headers file and a generic
+// headers.json, adding the Content-Security-Policy when one is configured
+func (s *Site) renderHeaders(outputDir string) error {
+	headers := map[string]map[string]string{}
+	for pattern, values := range s.Config.Server.Headers {
+		headers[pattern] = map[string]string{}
+		for name, value := range values {
+			headers[pattern][name] = value
+		}
+	}
+	// Configured headers win over the defaults of generated documents
+	for _, generated := range []map[string]map[string]string{s.activityPubHeaders(), s.pwaHeaders()} {
+		for pattern, values := range generated {
+			if headers[pattern] == nil {
+				headers[pattern] = map[string]string{}
+			}
+			for name, value := range values {
+				if _, ok := headers[pattern][name]; !ok {
+					headers[pattern][name] = value
+				}
+			}
+		}
+	}
+	csp := s.Config.CSP
+	if csp.AutoHash || len(csp.Directives) > 0 {
+		policy, err := contentSecurityPolicy(outputDir, csp)
+		if err != nil {
+			return err
+		}
+		if headers["/*"] == nil {
+			headers["/*"] = map[string]string{}
+		}
+		if _, ok := headers["/*"]["Content-Security-Policy"]; !ok {
+			headers["/*"]["Content-Security-Policy"] = policy
+		}
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	patterns := make([]string, 0, len(headers))
+	for pattern := range headers {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	var netlify strings.Builder
+	rules := make([]headerRule, 0, len(patterns))
+	for _, pattern := range patterns {
+		names := make([]string, 0, len(headers[pattern]))
+		for name := range headers[pattern] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(&netlify, pattern)
+		for _, name := range names {
+			fmt.Fprintf(&netlify, "  %s: %s\n", name, headers[pattern][name])
+		}
+		rules = append(rules, headerRule{For: pattern, Values: headers[pattern]})
+	}
+
+	if err := os.WriteFile(filepath.Join(outputDir, "_headers"), []byte(netlify.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write _headers: %w", err)
+	}
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode headers: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "headers.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write headers.json: %w", err)
+	}
+	return nil
+}
+
+// contentSecurityPolicy joins the configured directives, default-src first, adding the hashes
+// of the inline scripts and styles found in the HTML output when AutoHash is set
+func contentSecurityPolicy(outputDir string, cfg CSPConfig) (string, error) {
+	directives := map[string]string{}
+	for name, value := range cfg.Directives {
+		directives[name] = value
+	}
+	if cfg.AutoHash {
+		scripts, styles, err := inlineHashes(outputDir)
+		if err != nil {
+			return "", err
+		}
+		for name, hashes := range map[string][]string{"script-src": scripts, "style-src": styles} {
+			if len(hashes) == 0 {
+				continue
+			}
+			// A missing directive falls back to default-src, so it starts from its sources
+			value, ok := directives[name]
+			if !ok {
+				value, ok = directives["default-src"]
+			}
+			if !ok {
+				value = "'self'"
+			}
+			if strings.TrimSpace(value) == "'none'" {
+				// 'none' cannot be combined with other sources
+				value = ""
+			}
+			directives[name] = strings.TrimSpace(value + " " + strings.Join(hashes, " "))
+		}
+	}
+
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == "default-src") != (names[j] == "default-src") {
+			return names[i] == "default-src"
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, strings.TrimSpace(name+" "+directives[name]))
+	}
+	return strings.Join(parts, "; "), nil
+}
+
+// inlineHashes returns the distinct CSP source expressions ('sha256-...') of the inline scripts
+// and styles of every HTML file in the output. Scripts with a src or a non-JavaScript type,
+// such as JSON islands, are not executed and need no hash.
+func inlineHashes(outputDir string) ([]string, []string, error) {
+	scripts, styles := map[string]bool{}, map[string]bool{}
+	err := filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".html") {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		for _, m := range inlineScriptPattern.FindAllSubmatch(data, -1) {
+			attrs := string(m[1])
+			if strings.Contains(strings.ToLower(attrs), "src=") {
+				continue
+			}
+			if t := scriptTypePattern.FindStringSubmatch(attrs); t != nil {
+				kind := strings.ToLower(strings.Trim(t[1], `'"`))
+				if kind != "module" && !strings.Contains(kind, "javascript") {
+					continue
+				}
+			}
+			scripts[cspHash(m[2])] = true
+		}
+		for _, m := range inlineStylePattern.FindAllSubmatch(data, -1) {
+			styles[cspHash(m[1])] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash inline scripts: %w", err)
+	}
+	return sortedKeys(scripts), sortedKeys(styles), nil
+}
+
+// cspHash returns the CSP source expression of an inline script or style
+func cspHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContentSecurityPolicy(t *testing.T) {
+	dir := t.TempDir()
+	page := `<script>a()</script><script src="/x.js"></script>`
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash := "'sha256-qVpDBgj7bpq5hMAcGp3AOc79J3Y1Z4HvySTwKrWDoy4='"
+	tests := []struct {
+		name       string
+		directives map[string]string
+		want       string
+	}{
+		{"no directives", nil, "script-src 'self' " + hash},
+		{"from default-src", map[string]string{"default-src": "'self' https://cdn.example.com data:"},
+			"default-src 'self' https://cdn.example.com data:; script-src 'self' https://cdn.example.com data: " + hash},
+		{"configured script-src", map[string]string{"default-src": "'self' data:", "script-src": "'self'"},
+			"default-src 'self' data:; script-src 'self' " + hash},
+		{"default-src none", map[string]string{"default-src": "'none'"}, "default-src 'none'; script-src " + hash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := contentSecurityPolicy(dir, CSPConfig{AutoHash: true, Directives: tt.directives})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("contentSecurityPolicy() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"io/fs"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template/parse"
+	"time"
+)
+
+// use records that a page is rendered with a layout
+func (tc *TemplateCache) use(layout string, p *Page) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.pages[layout] = append(tc.pages[layout], p)
+}
+
+// invalidate drops the parsed layouts that execute one of the changed template files and returns
+// the pages rendered with them. Layouts that failed to parse have no dependencies and are
+// always dropped.
+func (tc *TemplateCache) invalidate(changed []string) []*Page {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	var pages []*Page
+	for layout, used := range tc.pages {
+		deps, parsed := tc.deps[layout]
+		affected := !parsed
+		for _, name := range changed {
+			affected = affected || deps[name]
+		}
+		if !affected {
+			continue
+		}
+		delete(tc.templates, layout)
+		delete(tc.deps, layout)
+		delete(tc.pages, layout)
+		pages = append(pages, used...)
+	}
+	return pages
+}
+
+// templateDeps returns the template files a layout executes, starting from base.html and the
+// layout and following template and block calls
+func templateDeps(tmpl *template.Template, layout string) map[string]bool {
+	deps := map[string]bool{}
+	seen := map[string]bool{}
+	var visit func(name string)
+	var walk func(node parse.Node)
+	visit = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		t := tmpl.Lookup(name)
+		if t == nil || t.Tree == nil {
+			return
+		}
+		// Templates defined inside a file belong to that file
+		deps[t.Tree.ParseName] = true
+		walk(t.Tree.Root)
+	}
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n != nil {
+				for _, child := range n.Nodes {
+					walk(child)
+				}
+			}
+		case *parse.IfNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			visit(n.Name)
+		}
+	}
+	visit("base.html")
+	visit(layout)
+	return deps
+}
+
+// layoutStamps returns the modification stamps of the files of the layout roots by template name
+func layoutStamps(roots []mountDir) map[string]string {
+	stamps := map[string]string{}
+	for _, root := range roots {
+		filepath.WalkDir(root.dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(root.dir, path)
+			if err != nil {
+				return nil
+			}
+			name := filepath.ToSlash(filepath.Join(filepath.FromSlash(root.prefix), rel))
+			// An earlier root hides the same name in a later one
+			if _, ok := stamps[name]; !ok {
+				stamps[name] = fileStamps([]string{path})[path]
+			}
+			return nil
+		})
+	}
+	return stamps
+}
+
+// watchTemplates polls the layout directories. Edited templates are re-parsed and only the pages
+// whose layouts execute them are re-rendered; added or removed templates can change the layout
+// of any page and rebuild the site.
+func (ds *devServer) watchTemplates(interval time.Duration) {
+	var last map[string]string
+	var lastRoots []mountDir
+	for range time.Tick(interval) {
+		ds.mu.RLock()
+		roots := ds.site.templates.roots
+		ds.mu.RUnlock()
+		current := layoutStamps(roots)
+		if !slices.Equal(roots, lastRoots) {
+			// The site was rebuilt with other layout directories
+			last, lastRoots = current, roots
+			continue
+		}
+		if maps.Equal(last, current) {
+			continue
+		}
+		var changed []string
+		added := len(current) != len(last)
+		for name, stamp := range current {
+			previous, ok := last[name]
+			if !ok {
+				added = true
+			} else if previous != stamp {
+				changed = append(changed, name)
+			}
+		}
+		last = current
+		slices.Sort(changed)
+
+		outputs := slices.ContainsFunc(changed, func(name string) bool { return strings.HasPrefix(name, "outputs/") })
+		if added || outputs || ds.rewritesOutput() {
+			log.Printf("Templates changed, rebuilding")
+			if err := ds.rebuild(); err != nil {
+				log.Printf("Rebuild failed, still serving the previous build: %v", err)
+			}
+			continue
+		}
+		ds.rerender(changed)
+	}
+}
+
+// rewritesOutput reports whether steps after rendering rewrite the pages or derive files from
+// them: link rewriting, CSS purging, CSP hashes, the PWA precache, compressed copies and the
+// artifact manifest. Re-rendered pages would leave their results stale, so a change rebuilds.
+func (ds *devServer) rewritesOutput() bool {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	config := ds.site.Config
+	return ds.opts.LinkMode != "" || config.Links.Mode != "" ||
+		config.Build.CSS.Purge ||
+		config.CSP.AutoHash || len(config.CSP.Directives) > 0 ||
+		config.PWA.Enabled ||
+		len(config.Compress.Formats) > 0 ||
+		config.Build.Manifest
+}
+
+// rerender re-renders the pages whose layouts execute one of the changed template files
+func (ds *devServer) rerender(changed []string) {
+	if ds.cacheHeaders {
+		// Runs once the lock below is released
+		defer ds.refreshManifest()
+	}
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	site := ds.site
+	start := time.Now()
+	pages := site.templates.invalidate(changed)
+	// The build context ended with the build
+	site.ctx = context.Background()
+	rendered := 0
+	for _, p := range pages {
+		if err := site.renderPage(p, ds.opts.PublicDir, site.templates); err != nil {
+			failf("Failed to render %s: %v", p.RelPermalink, err)
+			if site.options.KeepGoing {
+				site.renderErrorPage(ds.opts.PublicDir, p, err)
+			}
+			continue
+		}
+		rendered++
+	}
+	if err := site.writeFingerprintedAssets(ds.opts.PublicDir); err != nil {
+		failf("Failed to write fingerprinted assets: %v", err)
+	}
+	// Re-rendered pages link their stylesheets again instead of inlining the critical rules
+	if len(pages) > 0 {
+		if err := site.inlineCriticalCSS(ds.opts.PublicDir, pages); err != nil {
+			failf("Failed to inline critical CSS: %v", err)
+		}
+	}
+	log.Printf("Changed %s, re-rendered %d of %d pages in %v", strings.Join(changed, ", "), rendered, len(site.AllPages), time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// Names of the functions the templates call under --validate-html
+const (
+	validateStartFunc = "_hero_validate_start"
+	validateEndFunc   = "_hero_validate_end"
+)
+
+// htmlValidator checks the structure of every rendered page for --validate-html and attributes
+// the problems to the templates that wrote them. The templates record where their output starts
+// and ends, so pages are executed one at a time while it is on.
+type htmlValidator struct {
+	exec  sync.Mutex
+	out   *bytes.Buffer
+	stack []templateSpan
+	spans []templateSpan
+
+	mu       sync.Mutex
+	problems int
+	pages    int
+	invalid  int
+}
+
+// templateSpan is the output of one execution of a template
+type templateSpan struct {
+	name       string
+	start, end int
+}
+
+// htmlProblem is a structural error at an offset of a page
+type htmlProblem struct {
+	offset int
+	msg    string
+}
+
+func newHTMLValidator() *htmlValidator {
+	return &htmlValidator{}
+}
+
+// funcs returns the functions called by instrumented templates
+func (v *htmlValidator) funcs() template.FuncMap {
+	return template.FuncMap{
+		validateStartFunc: func(name string) string {
+			if v.out != nil {
+				v.stack = append(v.stack, templateSpan{name: name, start: v.out.Len()})
+			}
+			return ""
+		},
+		validateEndFunc: func() string {
+			if v.out != nil && len(v.stack) > 0 {
+				span := v.stack[len(v.stack)-1]
+				v.stack = v.stack[:len(v.stack)-1]
+				span.end = v.out.Len()
+				v.spans = append(v.spans, span)
+			}
+			return ""
+		},
+	}
+}
+
+// instrument makes every template of the set record the output it writes
+func (v *htmlValidator) instrument(tmpl *template.Template) error {
+	return wrapTemplates(tmpl, func(name string) string {
+		return fmt.Sprintf("{{%s %q}}{{%s}}", validateStartFunc, name, validateEndFunc)
+	}, v.funcs())
+}
+
+// execute runs the template of a page into buf and returns the spans of the templates executed
+func (v *htmlValidator) execute(tmpl *template.Template, buf *bytes.Buffer, page *Page) ([]templateSpan, error) {
+	v.exec.Lock()
+	defer v.exec.Unlock()
+	v.out, v.stack, v.spans = buf, nil, nil
+	defer func() { v.out = nil }()
+	err := tmpl.ExecuteTemplate(buf, "base.html", page)
+	return v.spans, err
+}
+
+// check validates a rendered page, logging each problem as a warning with its line and template
+func (v *htmlValidator) check(name string, data []byte, spans []templateSpan) {
+	problems := validateHTML(data)
+	v.mu.Lock()
+	v.pages++
+	v.problems += len(problems)
+	if len(problems) > 0 {
+		v.invalid++
+	}
+	v.mu.Unlock()
+	for _, p := range problems {
+		location := fmt.Sprintf("%s:%d", name, bytes.Count(data[:p.offset], []byte("\n"))+1)
+		if tmpl := spanAt(spans, p.offset); tmpl != "" {
+			location += " (" + tmpl + ")"
+		}
+		warnf("Invalid HTML in %s: %s", location, p.msg)
+	}
+}
+
+// report prints the totals of the build
+func (v *htmlValidator) report() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.problems == 0 {
+		log.Printf("HTML validation passed for %d pages", v.pages)
+		return
+	}
+	log.Printf("HTML validation found %d problems in %d of %d pages", v.problems, v.invalid, v.pages)
+}
+
+// spanAt returns the innermost template whose output contains the offset
+func spanAt(spans []templateSpan, offset int) string {
+	best := -1
+	for i, span := range spans {
+		if span.start <= offset && offset < span.end && (best < 0 || span.start >= spans[best].start && span.end-span.start <= spans[best].end-spans[best].start) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return spans[best].name
+}
+
+var (
+	// voidElements have no end tag
+	voidElements = setOf("area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr")
+	// optionalEndElements may be closed implicitly
+	optionalEndElements = setOf("html", "head", "body", "p", "li", "dt", "dd", "option", "optgroup", "rb", "rt", "rtc", "rp", "tr", "td", "th", "thead", "tbody", "tfoot", "colgroup", "caption")
+	// paragraphClosers close an open <p>
+	paragraphClosers = setOf("address", "article", "aside", "blockquote", "center", "details", "dialog", "dir", "div", "dl", "fieldset", "figcaption", "figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hgroup", "hr", "li", "dd", "dt", "main", "menu", "nav", "ol", "p", "pre", "search", "section", "summary", "table", "ul")
+	// scopeBoundaries stop the search for an open element to close implicitly
+	scopeBoundaries = setOf("html", "table", "td", "th", "caption", "button", "template", "object", "marquee", "applet")
+	headings        = setOf("h1", "h2", "h3", "h4", "h5", "h6")
+)
+
+func setOf(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// openElement is an element on the stack of the validator
+type openElement struct {
+	name    string
+	offset  int
+	foreign bool
+}
+
+// htmlChecker walks a page as an HTML5 parser would, without building a tree
+type htmlChecker struct {
+	data     string
+	stack    []openElement
+	problems []htmlProblem
+	ids      map[string]int
+	// closedParagraphs remembers the elements that closed an open <p>, for the </p> left over
+	closedParagraphs []openElement
+}
+
+// validateHTML reports unclosed and stray tags, nesting violations and duplicate ids and
+// attributes of a page. The page is read by the HTML5 tokenizer, which handles raw text,
+// comments and character references, and the open elements are tracked as a parser would.
+func validateHTML(data []byte) []htmlProblem {
+	c := &htmlChecker{data: string(data), ids: map[string]int{}}
+	z := nethtml.NewTokenizer(bytes.NewReader(data))
+	for offset := 0; ; {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		size := len(z.Raw())
+		switch tt {
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			name, more := z.TagName()
+			var attrs []string
+			for more {
+				var key, value []byte
+				key, value, more = z.TagAttr()
+				attrs = append(attrs, string(key), string(value))
+			}
+			c.startTag(string(name), offset, attrs, tt == nethtml.SelfClosingTagToken)
+			// Foreign elements such as an SVG <style> hold markup, not raw text
+			if c.inForeign() {
+				z.NextIsNotRawText()
+			}
+			z.AllowCDATA(c.inForeign())
+		case nethtml.EndTagToken:
+			name, _ := z.TagName()
+			c.endTag(string(name), offset)
+			z.AllowCDATA(c.inForeign())
+		}
+		offset += size
+	}
+	for j := len(c.stack) - 1; j >= 0; j-- {
+		if e := c.stack[j]; !optionalEndElements[e.name] {
+			c.report(e.offset, "unclosed <%s>", e.name)
+		}
+	}
+	sort.SliceStable(c.problems, func(i, j int) bool { return c.problems[i].offset < c.problems[j].offset })
+	return c.problems
+}
+
+// line returns the line number of an offset
+func (c *htmlChecker) line(offset int) int {
+	return strings.Count(c.data[:offset], "\n") + 1
+}
+
+func (c *htmlChecker) report(offset int, format string, args ...any) {
+	c.problems = append(c.problems, htmlProblem{offset: offset, msg: fmt.Sprintf(format, args...)})
+}
+
+// inForeign reports whether the current element is inside <svg> or <math>
+func (c *htmlChecker) inForeign() bool {
+	return len(c.stack) > 0 && c.stack[len(c.stack)-1].foreign
+}
+
+// inScope returns the index of the innermost open element with the name, or -1 when it is not
+// open or a boundary comes first
+func (c *htmlChecker) inScope(name string, boundaries map[string]bool) int {
+	for j := len(c.stack) - 1; j >= 0; j-- {
+		switch {
+		case c.stack[j].name == name:
+			return j
+		case boundaries[c.stack[j].name]:
+			return -1
+		}
+	}
+	return -1
+}
+
+// closeTo pops the open elements down to index j, reporting those that cannot be closed
+// implicitly
+func (c *htmlChecker) closeTo(j int, by string) {
+	for k := len(c.stack) - 1; k > j; k-- {
+		if e := c.stack[k]; !optionalEndElements[e.name] {
+			c.report(e.offset, "unclosed <%s>, closed by %s", e.name, by)
+		}
+	}
+	c.stack = c.stack[:j]
+}
+
+func (c *htmlChecker) startTag(name string, offset int, attrs []string, selfClosing bool) {
+	seen := map[string]bool{}
+	for k := 0; k < len(attrs); k += 2 {
+		attr := strings.ToLower(attrs[k])
+		if seen[attr] {
+			c.report(offset, "duplicate attribute %s on <%s>", attr, name)
+		}
+		seen[attr] = true
+		if attr == "id" && attrs[k+1] != "" {
+			if first, ok := c.ids[attrs[k+1]]; ok {
+				c.report(offset, "duplicate id %q, first used on line %d", attrs[k+1], c.line(first))
+				continue
+			}
+			c.ids[attrs[k+1]] = offset
+		}
+	}
+
+	foreign := c.inForeign() || name == "svg" || name == "math"
+	if foreign {
+		if !selfClosing {
+			c.stack = append(c.stack, openElement{name: name, offset: offset, foreign: true})
+		}
+		return
+	}
+
+	if paragraphClosers[name] {
+		if j := c.inScope("p", scopeBoundaries); j >= 0 {
+			if name != "p" {
+				c.closedParagraphs = append(c.closedParagraphs, openElement{name: name, offset: offset})
+			}
+			c.closeTo(j, "<"+name+">")
+		}
+	}
+	switch {
+	case name == "li":
+		c.closeOpen("li", offset, "ul", "ol", "menu")
+	case name == "dt" || name == "dd":
+		c.closeOpen("dt", offset, "dl")
+		c.closeOpen("dd", offset, "dl")
+	case name == "option":
+		c.closeOpen("option", offset, "select", "datalist", "optgroup")
+	case name == "optgroup":
+		c.closeOpen("option", offset, "select")
+		c.closeOpen("optgroup", offset, "select")
+	case name == "tr":
+		c.closeOpen("tr", offset, "table", "thead", "tbody", "tfoot")
+	case name == "td" || name == "th":
+		c.closeOpen("td", offset, "tr", "table")
+		c.closeOpen("th", offset, "tr", "table")
+	case name == "thead" || name == "tbody" || name == "tfoot":
+		for _, section := range []string{"thead", "tbody", "tfoot"} {
+			c.closeOpen(section, offset, "table")
+		}
+	case name == "a" || name == "form" || name == "button":
+		if j := c.inScope(name, scopeBoundaries); j >= 0 {
+			c.report(offset, "<%s> inside <%s>", name, name)
+		}
+	case headings[name]:
+		if top := len(c.stack) - 1; top >= 0 && headings[c.stack[top].name] {
+			c.report(offset, "<%s> inside <%s>", name, c.stack[top].name)
+		}
+	}
+
+	switch {
+	case voidElements[name]:
+	case selfClosing:
+		c.report(offset, "<%s/> is not self-closing in HTML and stays open", name)
+	default:
+		if name == "p" {
+			c.closedParagraphs = nil
+		}
+		c.stack = append(c.stack, openElement{name: name, offset: offset})
+	}
+}
+
+// closeOpen implicitly closes an open element before a sibling, e.g. an <li> before the next
+func (c *htmlChecker) closeOpen(name string, offset int, boundaries ...string) {
+	if j := c.inScope(name, setOf(append(setNames(scopeBoundaries), boundaries...)...)); j >= 0 {
+		c.closeTo(j, "<"+name+">")
+	}
+}
+
+func (c *htmlChecker) endTag(name string, offset int) {
+	if voidElements[name] {
+		c.report(offset, "end tag </%s> of a void element", name)
+		return
+	}
+	for j := len(c.stack) - 1; j >= 0; j-- {
+		if c.stack[j].name == name {
+			c.closeTo(j, "</"+name+">")
+			return
+		}
+	}
+	switch {
+	case name == "p" && len(c.closedParagraphs) > 0:
+		closer := c.closedParagraphs[len(c.closedParagraphs)-1]
+		c.closedParagraphs = c.closedParagraphs[:len(c.closedParagraphs)-1]
+		c.report(closer.offset, "<%s> inside <p>, which closes the paragraph and leaves its </p> stray", closer.name)
+	case name == "html" || name == "head" || name == "body":
+		// Browsers open these implicitly, so their end tags may have nothing to close
+	default:
+		c.report(offset, "stray end tag </%s>", name)
+	}
+}
+
+// setNames returns the names of a set
+func setNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValidateHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want []string
+	}{
+		{"valid", `<!DOCTYPE html><html><head><title>a < b</title></head><body><p>x<br>y</p></body></html>`, nil},
+		{"implied end tags", `<ul><li>a<li>b</ul><p>one<p>two<table><tr><td>1<td>2</table>`, nil},
+		{"raw text", `<script>if (a < b) { document.write("</div>") }</script><style>p > a {}</style>`, nil},
+		{"comment with markup", `<!-- <div> --><p>x</p>`, nil},
+		{"unclosed", `<div><span>x</div>`, []string{"unclosed <span>, closed by </div>"}},
+		{"stray end tag", `<p>x</p></div>`, []string{"stray end tag </div>"}},
+		{"void end tag", `<br></br>`, []string{"end tag </br> of a void element"}},
+		{"self-closing div", `<div/>x`, []string{"<div/> is not self-closing in HTML and stays open"}},
+		{"paragraph closed by a block", `<p><div>x</div></p>`, []string{"<div> inside <p>, which closes the paragraph and leaves its </p> stray"}},
+		{"duplicates", `<a id="x" href="/" href="/"></a><b id="x"></b>`, []string{"duplicate attribute href on <a>", `duplicate id "x", first used on line 1`}},
+		{"svg", `<svg><style>.a{}</style><path d="M0"/><title>t</title></svg>`, nil},
+		{"nested links", `<a href="/"><a href="/b">x</a></a>`, []string{"<a> inside <a>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range validateHTML([]byte(tt.html)) {
+				got = append(got, p.msg)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("validateHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// servedFile is an output file of the dev server in its build manifest
+type servedFile struct {
+	outputFileInfo
+	// ModTime is when the content of the file last changed, across rebuilds
+	ModTime time.Time
+}
+
+// refreshManifest hashes the output after a build. Every build rewrites every file, so files
+// with the content of the previous build get their previous modification time back, and
+// Last-Modified, like ETag, only changes with the content
+func (ds *devServer) refreshManifest() {
+	files, err := scanOutput(ds.opts.PublicDir)
+	if err != nil {
+		failf("Failed to scan %s: %v", ds.opts.PublicDir, err)
+		return
+	}
+	ds.mu.RLock()
+	previousBuild := ds.manifest
+	ds.mu.RUnlock()
+	now := time.Now()
+	manifest := make(map[string]servedFile, len(files))
+	for name, info := range files {
+		file := servedFile{outputFileInfo: info, ModTime: now}
+		if previous, ok := previousBuild[name]; ok && previous.SHA256 == info.SHA256 {
+			file.ModTime = previous.ModTime
+			if err := os.Chtimes(filepath.Join(ds.opts.PublicDir, filepath.FromSlash(name)), file.ModTime, file.ModTime); err != nil {
+				failf("Failed to restore the modification time of %s: %v", name, err)
+			}
+		}
+		manifest[name] = file
+	}
+	ds.mu.Lock()
+	ds.manifest = manifest
+	ds.mu.Unlock()
+}
+
+// withCacheHeaders sets the ETag of the requested file from the build manifest and asks
+// browsers to revalidate, so reloads are answered with 304 Not Modified until the file changes.
+// The file server then checks If-None-Match and If-Modified-Since against it
+func (ds *devServer) withCacheHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+		}
+		ds.mu.RLock()
+		file, ok := ds.manifest[name]
+		ds.mu.RUnlock()
+		if ok {
+			w.Header().Set("ETag", `"`+file.SHA256[:32]+`"`)
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// i18nDir holds the translation files of the project, one <lang>.toml of key = "text" pairs; the
+// i18n directory of the theme supplies the keys the project does not translate
+const i18nDir = "i18n"
+
+var (
+	// i18nCallPattern matches the keys of i18n calls in templates
+	i18nCallPattern = regexp.MustCompile("\\bi18n\\s+(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+	bareKeyPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// loadTranslations reads the translations of a language from the theme and the project
+func loadTranslations(themeDir, lang string) (map[string]string, error) {
+	translations := map[string]string{}
+	for _, dir := range []string{filepath.Join(themeDir, i18nDir), i18nDir} {
+		found, err := readTranslationFile(filepath.Join(dir, lang+".toml"))
+		if err != nil {
+			return nil, err
+		}
+		for key, text := range found {
+			if text != "" {
+				translations[key] = text
+			}
+		}
+	}
+	return translations, nil
+}
+
+// readTranslationFile returns the strings of a translation file; a missing file has none
+func readTranslationFile(file string) (map[string]string, error) {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return nil, nil
+	}
+	fields, err := readDataFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read translations: %w", err)
+	}
+	translations := map[string]string{}
+	for key, v := range fields {
+		text, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("translation %q in %s is not a string", key, file)
+		}
+		translations[key] = text
+	}
+	return translations, nil
+}
+
+// translate is the i18n template function, returning the text of the key in the site language
+// or the key itself when it is not translated. A text may use the optional data, as in
+// {{ i18n "readingTime" .ReadingTime }} with readingTime = "{{ . }} min read".
+func (s *Site) translate(key string, data ...any) (string, error) {
+	text, ok := s.translations[key]
+	if !ok {
+		return key, nil
+	}
+	if len(data) == 0 || !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(key).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse translation %q: %w", key, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data[0]); err != nil {
+		return "", fmt.Errorf("failed to execute translation %q: %w", key, err)
+	}
+	return buf.String(), nil
+}
+
+// runI18n implements `i18n extract [lang...]` and `i18n report`
+func runI18n(args []string) {
+	if len(args) == 0 || (args[0] != "extract" && args[0] != "report") {
+		log.Fatalf("Usage: i18n extract [<lang>...] | i18n report")
+	}
+	flags := flag.NewFlagSet("i18n "+args[0], flag.ExitOnError)
+	positional := parseInterspersed(flags, args[1:])
+
+	config, err := loadConfig("config.toml")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	themeDir, err := resolveThemeDir(config)
+	if err != nil {
+		log.Fatalf("Failed to resolve theme: %v", err)
+	}
+	keys, err := templateI18nKeys(config, themeDir)
+	if err != nil {
+		log.Fatalf("Failed to scan templates: %v", err)
+	}
+	langs := positional
+	if len(langs) == 0 {
+		langs, err = translationLanguages(newSite(config).Lang())
+		if err != nil {
+			log.Fatalf("Failed to list translations: %v", err)
+		}
+	}
+
+	if args[0] == "report" {
+		if len(positional) > 0 {
+			log.Fatalf("Usage: i18n report")
+		}
+		if err := reportTranslations(os.Stdout, themeDir, keys, langs); err != nil {
+			log.Fatalf("Failed to report translations: %v", err)
+		}
+		return
+	}
+	for _, lang := range langs {
+		added, err := extractTranslations(lang, keys)
+		if err != nil {
+			log.Fatalf("Failed to extract translations: %v", err)
+		}
+		fmt.Printf("%s: %d keys added\n", filepath.Join(i18nDir, lang+".toml"), added)
+	}
+}
+
+// templateI18nKeys returns the sorted keys of the i18n calls in every layout of the site
+func templateI18nKeys(config Config, themeDir string) ([]string, error) {
+	mounts, err := mountDirs(config, mountLayouts)
+	if err != nil {
+		return nil, err
+	}
+	roots := []string{filepath.Join(themeDir, "layouts")}
+	for _, m := range mounts {
+		roots = append(roots, m.dir)
+	}
+	found := map[string]bool{}
+	for _, root := range roots {
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if err := checkReadPath(p); err != nil {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			for _, m := range i18nCallPattern.FindAllStringSubmatch(string(data), -1) {
+				key, err := strconv.Unquote(m[1])
+				if err != nil {
+					return fmt.Errorf("%s: invalid i18n key %s", p, m[1])
+				}
+				found[key] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sortedKeys(found), nil
+}
+
+// translationLanguages returns the site language and the languages of the project translations
+func translationLanguages(siteLang string) ([]string, error) {
+	langs := map[string]bool{siteLang: true}
+	entries, err := os.ReadDir(i18nDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".toml"); ok && !entry.IsDir() {
+			langs[name] = true
+		}
+	}
+	return sortedKeys(langs), nil
+}
+
+// extractTranslations appends an empty stub for every key the translation file of the language
+// lacks, leaving its existing content and comments as they are, and returns the number added
+func extractTranslations(lang string, keys []string) (int, error) {
+	file := filepath.Join(i18nDir, lang+".toml")
+	existing, err := readTranslationFile(file)
+	if err != nil {
+		return 0, err
+	}
+	var stubs strings.Builder
+	for _, key := range keys {
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		if !bareKeyPattern.MatchString(key) {
+			key = strconv.Quote(key)
+		}
+		fmt.Fprintf(&stubs, "%s = \"\"\n", key)
+	}
+	if stubs.Len() == 0 {
+		return 0, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	if len(data) > 0 {
+		data = append(data, '\n')
+	}
+	data = append(data, "# Untranslated keys found by `i18n extract`\n"+stubs.String()...)
+	if err := os.MkdirAll(i18nDir, os.ModePerm); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return 0, err
+	}
+	return strings.Count(stubs.String(), "\n"), nil
+}
+
+// reportTranslations prints how many of the template keys each language translates, followed by
+// the missing keys and the translated keys no template uses
+func reportTranslations(w io.Writer, themeDir string, keys, langs []string) error {
+	fmt.Fprintf(w, "%d keys used in templates\n", len(keys))
+	for _, lang := range langs {
+		translations, err := loadTranslations(themeDir, lang)
+		if err != nil {
+			return err
+		}
+		var missing, unused []string
+		used := map[string]bool{}
+		for _, key := range keys {
+			used[key] = true
+			if _, ok := translations[key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+		for key := range translations {
+			if !used[key] {
+				unused = append(unused, key)
+			}
+		}
+		sort.Strings(unused)
+		coverage := 100.0
+		if len(keys) > 0 {
+			coverage = float64(len(keys)-len(missing)) * 100 / float64(len(keys))
+		}
+		fmt.Fprintf(w, "\n%s: %d/%d translated (%.0f%%)\n", lang, len(keys)-len(missing), len(keys), coverage)
+		if len(missing) > 0 {
+			fmt.Fprintf(w, "  missing: %s\n", strings.Join(missing, ", "))
+		}
+		if len(unused) > 0 {
+			fmt.Fprintf(w, "  unused: %s\n", strings.Join(unused, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// iconSet loads the SVG icons of the icon template function from assets/icons of the project,
+// then of the theme, and keeps the <symbol> of every icon and color used so far
+type iconSet struct {
+	dirs []string
+
+	mu      sync.Mutex
+	icons   map[string]*svgIcon
+	symbols map[string]string
+}
+
+// svgIcon is the parsed content of an icon file
+type svgIcon struct {
+	viewBox string
+	// paint holds the fill and stroke attributes of the root <svg>, written on the <symbol>
+	paint string
+	inner string
+}
+
+var (
+	iconNamePattern  = regexp.MustCompile(`^[a-z0-9_-]+(/[a-z0-9_-]+)*$`)
+	svgOpenPattern   = regexp.MustCompile(`(?is)<svg\b[^>]*>`)
+	svgAttrPattern   = regexp.MustCompile(`(?is)\b(viewBox|width|height)\s*=\s*["']([^"']*)["']`)
+	svgPaintPattern  = regexp.MustCompile(`(?is)\s(fill|fill-rule|clip-rule|stroke|stroke-width|stroke-linecap|stroke-linejoin)\s*=\s*["']([^"']*)["']`)
+	svgNoisePattern  = regexp.MustCompile(`(?s)<\?xml.*?\?>|<!--.*?-->|<!DOCTYPE[^>]*>`)
+	svgColorAttr     = regexp.MustCompile(`\b(fill|stroke)\s*=\s*"([^"]*)"`)
+	svgColorStyle    = regexp.MustCompile(`\b(fill|stroke)\s*:\s*([^;"]+)`)
+	iconUsePattern   = regexp.MustCompile(`<use href="#(icon\.[a-z0-9_.:-]+)"`)
+	bodyOpenPattern  = regexp.MustCompile(`(?i)<body\b[^>]*>`)
+	iconColorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+|(rgb|hsl)a?\([0-9.,%\s]+\))$`)
+	iconIDPattern    = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// icon returns an inline SVG referencing the icon of assets/icons/<name>.svg, recolored when a
+// color is given, e.g. {{ icon "star" }} or {{ icon "star" "currentColor" }}. The icon itself is
+// written once per page, into a hidden sprite after <body>. Icons without colors of their own
+// take the color of the text.
+func (s *Site) icon(name string, color ...string) (template.HTML, error) {
+	if !iconNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid icon name %q", name)
+	}
+	if len(color) > 1 {
+		return "", fmt.Errorf("icon takes a name and at most one color")
+	}
+	// Symbol ids are namespaced by the icon set, the directory of the icon, and the color with
+	// separators icon names cannot hold, so no two icons share an id
+	id := "icon." + strings.ReplaceAll(name, "/", ".")
+	fill := ""
+	if len(color) == 1 && color[0] != "" {
+		fill = color[0]
+		if !iconColorPattern.MatchString(fill) {
+			return "", fmt.Errorf("invalid icon color %q", fill)
+		}
+		id += ":" + strings.Trim(iconIDPattern.ReplaceAllString(strings.ToLower(fill), "-"), "-")
+	}
+	if err := s.icons.symbol(name, id, fill); err != nil {
+		return "", err
+	}
+	class := "icon icon-" + strings.ReplaceAll(name, "/", "-")
+	return template.HTML(fmt.Sprintf(`<svg class="%s" fill="currentColor" aria-hidden="true" focusable="false"><use href="#%s"></use></svg>`, class, id)), nil
+}
+
+// symbol registers the <symbol> with the id for an icon in a color, loading the icon on first use
+func (set *iconSet) symbol(name, id, color string) error {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if _, ok := set.symbols[id]; ok {
+		return nil
+	}
+	icon, ok := set.icons[name]
+	if !ok {
+		var err error
+		if icon, err = set.load(name); err != nil {
+			return err
+		}
+		if set.icons == nil {
+			set.icons = map[string]*svgIcon{}
+			set.symbols = map[string]string{}
+		}
+		set.icons[name] = icon
+	}
+	paint, inner := icon.paint, icon.inner
+	if color != "" {
+		paint, inner = recolorSVG(paint, color), recolorSVG(inner, color)
+		// Shapes without a fill of their own take the one of the symbol
+		if !strings.Contains(paint, ` fill="`) {
+			paint += fmt.Sprintf(` fill="%s"`, color)
+		}
+	}
+	viewBox := ""
+	if icon.viewBox != "" {
+		viewBox = fmt.Sprintf(` viewBox="%s"`, template.HTMLEscapeString(icon.viewBox))
+	}
+	set.symbols[id] = fmt.Sprintf(`<symbol id="%s"%s%s>%s</symbol>`, id, viewBox, paint, inner)
+	return nil
+}
+
+// load reads and parses the first icon file with the name
+func (set *iconSet) load(name string) (*svgIcon, error) {
+	for _, dir := range set.dirs {
+		path := filepath.Join(dir, filepath.FromSlash(name)+".svg")
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := checkReadPath(path); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read icon %s: %w", name, err)
+		}
+		icon, err := parseSVGIcon(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read icon %s: %w", path, err)
+		}
+		return icon, nil
+	}
+	return nil, fmt.Errorf("icon %q not found in %s", name, strings.Join(set.dirs, ", "))
+}
+
+// parseSVGIcon takes the viewBox, the paint attributes and the content of the root <svg> element
+// of an icon file, deriving the viewBox from the width and height when missing
+func parseSVGIcon(data string) (*svgIcon, error) {
+	data = svgNoisePattern.ReplaceAllString(data, "")
+	open := svgOpenPattern.FindStringIndex(data)
+	end := strings.LastIndex(strings.ToLower(data), "</svg>")
+	if open == nil || end < open[1] {
+		return nil, fmt.Errorf("no <svg> element")
+	}
+	attrs := map[string]string{}
+	for _, m := range svgAttrPattern.FindAllStringSubmatch(data[open[0]:open[1]], -1) {
+		attrs[strings.ToLower(m[1])] = m[2]
+	}
+	var paint strings.Builder
+	for _, m := range svgPaintPattern.FindAllStringSubmatch(data[open[0]:open[1]], -1) {
+		fmt.Fprintf(&paint, ` %s="%s"`, strings.ToLower(m[1]), template.HTMLEscapeString(m[2]))
+	}
+	viewBox := attrs["viewbox"]
+	if viewBox == "" && attrs["width"] != "" && attrs["height"] != "" {
+		viewBox = fmt.Sprintf("0 0 %s %s", strings.TrimSuffix(attrs["width"], "px"), strings.TrimSuffix(attrs["height"], "px"))
+	}
+	return &svgIcon{viewBox: viewBox, paint: paint.String(), inner: strings.TrimSpace(data[open[1]:end])}, nil
+}
+
+// recolorSVG replaces the fill and stroke colors of an icon, leaving those set to none
+func recolorSVG(inner, color string) string {
+	replace := func(pattern *regexp.Regexp, format string) {
+		inner = pattern.ReplaceAllStringFunc(inner, func(m string) string {
+			parts := pattern.FindStringSubmatch(m)
+			if v := strings.TrimSpace(parts[2]); v == "none" || v == "" {
+				return m
+			}
+			return fmt.Sprintf(format, parts[1], color)
+		})
+	}
+	replace(svgColorAttr, `%s="%s"`)
+	replace(svgColorStyle, `%s:%s`)
+	return inner
+}
+
+// withIconSprite inserts a hidden sprite with the symbols of the icons a page uses after its
+// <body> tag, or at the start of a page without one; pages without icons are returned unchanged
+func (set *iconSet) withIconSprite(html []byte) []byte {
+	matches := iconUsePattern.FindAllSubmatch(html, -1)
+	if len(matches) == 0 {
+		return html
+	}
+	set.mu.Lock()
+	used := map[string]string{}
+	for _, m := range matches {
+		if symbol, ok := set.symbols[string(m[1])]; ok {
+			used[string(m[1])] = symbol
+		}
+	}
+	set.mu.Unlock()
+	if len(used) == 0 {
+		return html
+	}
+	ids := make([]string, 0, len(used))
+	for id := range used {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var sprite bytes.Buffer
+	sprite.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" style="display:none">`)
+	for _, id := range ids {
+		sprite.WriteString(used[id])
+	}
+	sprite.WriteString(`</svg>`)
+
+	at := 0
+	if loc := bodyOpenPattern.FindIndex(html); loc != nil {
+		at = loc[1]
+	}
+	out := make([]byte, 0, len(html)+sprite.Len())
+	out = append(out, html[:at]...)
+	out = append(out, sprite.Bytes()...)
+	return append(out, html[at:]...)
+}
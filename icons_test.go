@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIconSymbol(t *testing.T) {
+	// Icons are read from the project, which is the working directory
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	icons := map[string]string{
+		"star.svg":         `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24"><path d="M1"/></svg>`,
+		"feather/star.svg": `<svg viewBox="0 0 24 24" fill='none' stroke="currentColor" stroke-width="2"><path d="M2"/></svg>`,
+		"red.svg":          `<svg viewBox="0 0 24 24"><path fill="#000" d="M3"/></svg>`,
+	}
+	for name, data := range icons {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		color string
+		id    string
+		want  string
+	}{
+		{"star", "", "icon.star", `<symbol id="icon.star" viewBox="0 0 24 24"><path d="M1"/></symbol>`},
+		{"star", "red", "icon.star:red", `<symbol id="icon.star:red" viewBox="0 0 24 24" fill="red"><path d="M1"/></symbol>`},
+		{"feather/star", "", "icon.feather.star", `<symbol id="icon.feather.star" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M2"/></symbol>`},
+		{"feather/star", "blue", "icon.feather.star:blue", `<symbol id="icon.feather.star:blue" viewBox="0 0 24 24" fill="none" stroke="blue" stroke-width="2"><path d="M2"/></symbol>`},
+		{"red", "#f00", "icon.red:f00", `<symbol id="icon.red:f00" viewBox="0 0 24 24" fill="#f00"><path fill="#f00" d="M3"/></symbol>`},
+	}
+	site := &Site{}
+	site.icons.dirs = []string{dir}
+	for _, tt := range tests {
+		if _, err := site.icon(tt.name, tt.color); err != nil {
+			t.Fatalf("icon(%q, %q): %v", tt.name, tt.color, err)
+		}
+		if got := site.icons.symbols[tt.id]; got != tt.want {
+			t.Errorf("symbol %s =\n%s\nwant\n%s", tt.id, got, tt.want)
+		}
+	}
+}
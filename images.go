@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Register GIF decoding for image.Decode
+	"image/jpeg"
+	_ "image/png" // Register PNG decoding for image.Decode
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Recommended dimensions for og:image and twitter:image previews
+const (
+	socialImageWidth  = 1200
+	socialImageHeight = 630
+)
+
+// Cover returns the page cover image: the `cover` front matter value or a bundle file named cover.*
+func (p *Page) Cover() *Resource {
+	return p.cover
+}
+
+// resolveCover finds the cover of a page after its bundle resources are known
+func (s *Site) resolveCover(p *Page) {
+	if v, ok := p.Params["cover"].(string); ok && v != "" {
+		if res := p.Resources.GetMatch(v); res != nil {
+			p.cover = res
+			return
+		}
+		// Not a bundle file: treat it as a site path or an external URL
+		res := &Resource{Name: v, Title: v, Params: map[string]any{}, ResourceType: "image", RelPermalink: v, Permalink: v}
+		if !strings.Contains(v, "://") {
+			res.RelPermalink = s.RelURL(v)
+			res.Permalink = s.AbsURL(v)
+		}
+		p.cover = res
+		return
+	}
+	for _, res := range p.Resources.ByType("image") {
+		if strings.TrimSuffix(path.Base(res.RelPath), path.Ext(res.RelPath)) == "cover" || res.Name == "cover" {
+			p.cover = res
+			return
+		}
+	}
+}
+
+// processCovers writes social preview variants of bundle covers and sets each page's OGImage
+func (s *Site) processCovers(outputDir string) {
+	var wg sync.WaitGroup
+	for _, page := range s.AllPages {
+		cover := page.cover
+		if cover == nil {
+			continue
+		}
+		page.OGImage = cover.Permalink
+		if cover.SourcePath == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(page *Page, cover *Resource) {
+			defer wg.Done()
+			name := fmt.Sprintf("%s_%dx%d.jpg", strings.TrimSuffix(cover.RelPath, path.Ext(cover.RelPath)), socialImageWidth, socialImageHeight)
+			dest, err := outputFile(outputDir, path.Join(filepath.ToSlash(filepath.Dir(page.outputPath)), name))
+			if err != nil {
+				warnf("Skipping social image for %s: %v", page.RelPermalink, err)
+				return
+			}
+			if err := resizeImageFill(cover.SourcePath, dest, socialImageWidth, socialImageHeight); err != nil {
+				warnf("Failed to create social image for %s: %v", page.RelPermalink, err)
+				return
+			}
+			// Only bundle pages have local covers, and their permalinks end with a slash
+			page.OGImage = page.Permalink + name
+		}(page, cover)
+	}
+	wg.Wait()
+}
+
+// resizeImageFill scales and center-crops an image to exactly width x height and writes it as JPEG
+func resizeImageFill(src, dest string, width, height int) error {
+	if err := checkReadPath(src); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	img, _, err := image.Decode(in)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// Crop the source to the target aspect ratio around its center
+	b := img.Bounds()
+	cropW, cropH := b.Dx(), b.Dy()
+	if cropW*height > cropH*width {
+		cropW = cropH * width / height
+	} else {
+		cropH = cropW * height / width
+	}
+	if cropW == 0 || cropH == 0 {
+		return fmt.Errorf("image is too small to resize")
+	}
+	crop := image.Rect(0, 0, cropW, cropH).Add(image.Pt(b.Min.X+(b.Dx()-cropW)/2, b.Min.Y+(b.Dy()-cropH)/2))
+	source := image.NewRGBA(image.Rect(0, 0, cropW, cropH))
+	draw.Draw(source, source.Bounds(), img, crop.Min, draw.Src)
+
+	out := scaleImage(source, width, height)
+
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return jpeg.Encode(file, out, &jpeg.Options{Quality: 85})
+}
+
+// scaleImage resizes an image by averaging the source pixels covered by each target pixel
+func scaleImage(src *image.RGBA, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					i := src.PixOffset(sx, sy)
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					b += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ImagingConfig configures the responsive variants generated for Markdown images of bundles
+type ImagingConfig struct {
+	// Responsive adds srcset, sizes and the intrinsic size to Markdown images of bundle files
+	Responsive bool   `toml:"responsive"`
+	Widths     []int  `toml:"widths"`
+	Sizes      string `toml:"sizes"`
+	Quality    int    `toml:"quality"`
+	// Formats lists extra formats such as webp or avif offered through <picture>. They are
+	// written by external encoders, configured in Encoders with {input}, {output} and {quality}.
+	Formats  []string          `toml:"formats"`
+	Encoders map[string]string `toml:"encoders"`
+
+	// StripGPS removes the location from the EXIF data of published JPEG files and from .Exif
+	StripGPS bool `toml:"stripGPS"`
+}
+
+// defaultEncoders convert an image into the extra formats
+var defaultEncoders = map[string]string{
+	"webp": "cwebp -quiet -q {quality} {input} -o {output}",
+	"avif": "avifenc -q {quality} {input} {output}",
+}
+
+var (
+	imgTagPattern  = regexp.MustCompile(`<img\s[^>]*>`)
+	imgSrcPattern  = regexp.MustCompile(`\ssrc="([^"]*)"`)
+	imgSizePattern = regexp.MustCompile(`\s(?:width|height|srcset|sizes)=`)
+)
+
+// imageVariant is one width of a responsive image in one format
+type imageVariant struct {
+	name  string
+	width int
+}
+
+// processImages writes the responsive variants of the bundle images used in Markdown content and
+// rewrites their <img> tags, before templates execute
+func (s *Site) processImages(outputDir string) {
+	cfg := s.Config.Imaging
+	if !cfg.Responsive {
+		return
+	}
+	if len(cfg.Widths) == 0 {
+		cfg.Widths = []int{480, 800, 1200, 1600}
+	}
+	sort.Ints(cfg.Widths)
+	if cfg.Sizes == "" {
+		cfg.Sizes = "100vw"
+	}
+	if cfg.Quality == 0 {
+		cfg.Quality = 85
+	}
+
+	var wg sync.WaitGroup
+	for _, page := range s.contentPages() {
+		if len(page.Resources.ByType("image")) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(page *Page) {
+			defer wg.Done()
+			if s.ctx.Err() != nil {
+				return
+			}
+			defer trackTask(s.ctx, "responsive images %s", page.RelPermalink)()
+			pageDir := filepath.Join(outputDir, filepath.Dir(page.outputPath))
+			content := imgTagPattern.ReplaceAllStringFunc(string(page.Content), func(tag string) string {
+				out, err := responsiveImage(tag, page, pageDir, cfg)
+				if err != nil {
+					warnf("Skipping responsive image in %s: %v", page.RelPermalink, err)
+					return tag
+				}
+				return out
+			})
+			page.Content = template.HTML(content)
+		}(page)
+	}
+	wg.Wait()
+}
+
+// responsiveImage writes the variants of the bundle image an <img> tag refers to and returns the
+// tag with srcset, sizes and intrinsic dimensions, wrapped in <picture> when extra formats are
+// configured. Tags of other images are returned unchanged.
+func responsiveImage(tag string, page *Page, pageDir string, cfg ImagingConfig) (string, error) {
+	m := imgSrcPattern.FindStringSubmatch(tag)
+	if m == nil || imgSizePattern.MatchString(tag) {
+		return tag, nil
+	}
+	src, err := url.PathUnescape(m[1])
+	if err != nil {
+		return tag, nil
+	}
+	var res *Resource
+	for _, r := range page.Resources.ByType("image") {
+		if r.RelPath == strings.TrimPrefix(src, "./") {
+			res = r
+		}
+	}
+	ext := strings.ToLower(path.Ext(src))
+	if res == nil || (ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".gif") {
+		return tag, nil
+	}
+
+	if err := checkReadPath(res.SourcePath); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(res.SourcePath)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(res.SourcePath)
+	if err != nil {
+		return "", err
+	}
+	config, _, err := image.DecodeConfig(file)
+	file.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", res.RelPath, err)
+	}
+
+	// The variants of a GIF are PNGs, keeping transparency without animation
+	variantExt := ext
+	if ext == ".gif" {
+		variantExt = ".png"
+	}
+	base := strings.TrimSuffix(res.RelPath, path.Ext(res.RelPath))
+	key := fmt.Sprintf("%s\x00%d\x00%d\x00%d", res.SourcePath, info.Size(), info.ModTime().UnixNano(), cfg.Quality)
+
+	var variants []imageVariant
+	for _, width := range cfg.Widths {
+		if width >= config.Width {
+			break
+		}
+		name := fmt.Sprintf("%s_%dw%s", base, width, variantExt)
+		height := config.Height * width / config.Width
+		err := writeCachedVariant(key+fmt.Sprintf("\x00%d", width), variantExt, filepath.Join(pageDir, filepath.FromSlash(name)), func(dest string) error {
+			return resizeImage(res.SourcePath, dest, width, height, cfg.Quality)
+		})
+		if err != nil {
+			return "", err
+		}
+		variants = append(variants, imageVariant{name: name, width: width})
+	}
+	variants = append(variants, imageVariant{name: res.RelPath, width: config.Width})
+
+	sources := ""
+	for _, format := range cfg.Formats {
+		command := cfg.Encoders[format]
+		if command == "" {
+			command = defaultEncoders[format]
+		}
+		if command == "" {
+			return "", fmt.Errorf("no encoder configured for %s", format)
+		}
+		var converted []imageVariant
+		for _, v := range variants {
+			name := strings.TrimSuffix(v.name, path.Ext(v.name)) + "." + format
+			input := filepath.Join(pageDir, filepath.FromSlash(v.name))
+			if v.name == res.RelPath {
+				input = res.SourcePath
+			}
+			err := writeCachedVariant(key+fmt.Sprintf("\x00%d\x00%s", v.width, command), "."+format, filepath.Join(pageDir, filepath.FromSlash(name)), func(dest string) error {
+				return encodeImage(page.Site.ctx, command, input, dest, cfg.Quality)
+			})
+			if err != nil {
+				return "", err
+			}
+			converted = append(converted, imageVariant{name: name, width: v.width})
+		}
+		sources += fmt.Sprintf(`<source type="image/%s" srcset="%s" sizes="%s">`, format, srcset(converted), template.HTMLEscapeString(cfg.Sizes))
+	}
+
+	attrs := fmt.Sprintf(` srcset="%s" sizes="%s" width="%d" height="%d" loading="lazy" decoding="async"`,
+		srcset(variants), template.HTMLEscapeString(cfg.Sizes), config.Width, config.Height)
+	out := strings.TrimSuffix(strings.TrimSuffix(tag, ">"), "/") + attrs + ">"
+	if sources != "" {
+		out = "<picture>" + sources + out + "</picture>"
+	}
+	return out, nil
+}
+
+// srcset formats image variants as a srcset attribute value
+func srcset(variants []imageVariant) string {
+	parts := make([]string, 0, len(variants))
+	for _, v := range variants {
+		parts = append(parts, template.HTMLEscapeString((&url.URL{Path: v.name}).EscapedPath())+" "+strconv.Itoa(v.width)+"w")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeCachedVariant copies a generated image from the cache to dest, creating it first when the
+// cache has no entry for the key
+func writeCachedVariant(key, ext, dest string, create func(dest string) error) error {
+	sum := sha256.Sum256([]byte(key))
+	name := "images/" + hex.EncodeToString(sum[:16]) + ext
+	cached := cacheReadPath(name)
+	if _, err := os.Stat(cached); err != nil {
+		cached = cacheWritePath(name)
+		if err := os.MkdirAll(filepath.Dir(cached), os.ModePerm); err != nil {
+			return err
+		}
+		if err := create(cached); err != nil {
+			os.Remove(cached)
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	_, err := copyFile(cached, dest)
+	return err
+}
+
+// resizeImage scales an image to width x height and writes it as JPEG, or as PNG when dest ends in .png
+func resizeImage(src, dest string, width, height, quality int) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	img, _, err := image.Decode(in)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	source := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(source, source.Bounds(), img, img.Bounds().Min, draw.Src)
+	out := scaleImage(source, width, max(height, 1))
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if strings.HasSuffix(dest, ".png") {
+		return png.Encode(file, out)
+	}
+	return jpeg.Encode(file, out, &jpeg.Options{Quality: quality})
+}
+
+// encodeImage runs an external encoder command, replacing {quality} before {input} and {output}
+func encodeImage(ctx context.Context, command, input, output string, quality int) error {
+	return encodeFile(ctx, strings.ReplaceAll(command, "{quality}", strconv.Itoa(quality)), input, output)
+}
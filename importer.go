@@ -0,0 +1,625 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"html"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// importReportFile lists what an import could not convert
+const importReportFile = "import-report.md"
+
+// importedPage is a content file produced by an importer
+type importedPage struct {
+	// RelPath is the slash-separated path below content/
+	RelPath     string
+	FrontMatter importedFrontMatter
+	Body        string
+	// Source names the original file or item in the report
+	Source string
+	Notes  []string
+}
+
+// importedFrontMatter keeps the common keys first in written front matter
+type importedFrontMatter struct {
+	Title      string         `yaml:"title"`
+	Date       *time.Time     `yaml:"date,omitempty"`
+	Draft      bool           `yaml:"draft,omitempty"`
+	Tags       []string       `yaml:"tags,omitempty"`
+	Categories []string       `yaml:"categories,omitempty"`
+	Extra      map[string]any `yaml:",inline"`
+}
+
+// importer collects pages and the constructs that need manual attention
+type importer struct {
+	src   string
+	pages []*importedPage
+	// files are bundle and asset files copied as they are, keyed by their path below content/
+	files map[string]string
+	notes []string
+}
+
+// runImport implements `import hugo|jekyll|wordpress-xml <src>`
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	force := flags.Bool("force", false, "overwrite existing content files")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 2 {
+		log.Fatalf("Usage: import hugo|jekyll|wordpress-xml <src> [--force]")
+	}
+
+	im := &importer{src: positional[1], files: map[string]string{}}
+	var err error
+	switch positional[0] {
+	case "hugo":
+		err = im.importHugo()
+	case "jekyll":
+		err = im.importJekyll()
+	case "wordpress-xml":
+		err = im.importWordPress()
+	default:
+		log.Fatalf("Unknown source %q, use hugo, jekyll or wordpress-xml", positional[0])
+	}
+	if err != nil {
+		log.Fatalf("Failed to import %s: %v", im.src, err)
+	}
+
+	written, skipped, err := im.write(*force)
+	if err != nil {
+		log.Fatalf("Failed to write imported content: %v", err)
+	}
+	if err := im.writeReport(positional[0], written, skipped); err != nil {
+		log.Fatalf("Failed to write %s: %v", importReportFile, err)
+	}
+	fmt.Printf("Imported %d pages and %d files (%d skipped), see %s\n", written, len(im.files), len(skipped), importReportFile)
+}
+
+// write creates the content files, leaving existing ones alone unless force is set
+func (im *importer) write(force bool) (int, []string, error) {
+	var written int
+	var skipped []string
+	for _, page := range im.pages {
+		dest, err := outputFile("content", page.RelPath)
+		if err != nil {
+			return written, skipped, err
+		}
+		if _, err := os.Stat(dest); err == nil && !force {
+			skipped = append(skipped, page.RelPath)
+			continue
+		}
+		fm, err := yaml.Marshal(page.FrontMatter)
+		if err != nil {
+			return written, skipped, fmt.Errorf("failed to encode front matter of %s: %w", page.Source, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return written, skipped, err
+		}
+		content := "---\n" + string(fm) + "---\n\n" + strings.TrimSpace(page.Body) + "\n"
+		if err := os.WriteFile(dest, []byte(content), 0644); err != nil {
+			return written, skipped, err
+		}
+		written++
+	}
+	for rel, src := range im.files {
+		dest, err := outputFile("content", rel)
+		if err != nil {
+			return written, skipped, err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return written, skipped, err
+		}
+		// The import source lies outside the project, so copyFile's path check does not apply
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return written, skipped, err
+		}
+		if err := os.WriteFile(dest, data, 0644); err != nil {
+			return written, skipped, err
+		}
+	}
+	return written, skipped, nil
+}
+
+// writeReport writes the migration report naming every construct that needs manual attention
+func (im *importer) writeReport(kind string, written int, skipped []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Import report\n\nImported %d pages and %d files from %s (%s) on %s.\n",
+		written, len(im.files), im.src, kind, time.Now().Format("2006-01-02"))
+	if len(im.notes) > 0 {
+		b.WriteString("\n## Site\n\n")
+		for _, note := range im.notes {
+			fmt.Fprintf(&b, "- %s\n", note)
+		}
+	}
+	if len(skipped) > 0 {
+		b.WriteString("\n## Skipped (already exist, use --force)\n\n")
+		for _, rel := range skipped {
+			fmt.Fprintf(&b, "- content/%s\n", rel)
+		}
+	}
+	b.WriteString("\n## Needs attention\n\n")
+	attention := 0
+	for _, page := range im.pages {
+		if len(page.Notes) == 0 {
+			continue
+		}
+		attention++
+		fmt.Fprintf(&b, "### content/%s\n\nFrom %s\n\n", page.RelPath, page.Source)
+		for _, note := range page.Notes {
+			fmt.Fprintf(&b, "- %s\n", note)
+		}
+		b.WriteString("\n")
+	}
+	if attention == 0 {
+		b.WriteString("Nothing, every page converted cleanly.\n")
+	}
+	return os.WriteFile(importReportFile, []byte(b.String()), 0644)
+}
+
+// note records a construct of the page that was not converted
+func (p *importedPage) note(format string, args ...any) {
+	p.Notes = append(p.Notes, fmt.Sprintf(format, args...))
+}
+
+// newImportedPage converts front matter params into the written front matter, noting unsupported keys
+func newImportedPage(rel, source string, params map[string]any, unsupported map[string]string) *importedPage {
+	page := &importedPage{RelPath: rel, Source: source, FrontMatter: importedFrontMatter{Extra: map[string]any{}}}
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := params[key]
+		switch strings.ToLower(key) {
+		case "title":
+			page.FrontMatter.Title = fmt.Sprint(value)
+		case "date":
+			if t := toTime(value); !t.IsZero() {
+				page.FrontMatter.Date = &t
+			}
+		case "draft":
+			page.FrontMatter.Draft, _ = value.(bool)
+		case "tags":
+			page.FrontMatter.Tags = splitTerms(value)
+		case "categories", "category":
+			page.FrontMatter.Categories = append(page.FrontMatter.Categories, splitTerms(value)...)
+		default:
+			if hint, ok := unsupported[strings.ToLower(key)]; ok {
+				page.note("front matter `%s: %v` is not supported: %s", key, value, hint)
+				continue
+			}
+			page.FrontMatter.Extra[key] = normalizeImportedValue(value)
+		}
+	}
+	return page
+}
+
+// splitTerms accepts a list or a space-separated string of taxonomy terms
+func splitTerms(value any) []string {
+	if s, ok := value.(string); ok {
+		return strings.Fields(s)
+	}
+	return toStringSlice(value)
+}
+
+// normalizeImportedValue turns TOML dates into times so they are written as YAML timestamps
+func normalizeImportedValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = normalizeImportedValue(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeImportedValue(item)
+		}
+	default:
+		if t := toTime(value); !t.IsZero() {
+			if _, isString := value.(string); !isString {
+				return t
+			}
+		}
+	}
+	return value
+}
+
+// hugoUnsupported are Hugo front matter keys without an equivalent here
+var hugoUnsupported = map[string]string{
+	"url":     "the page keeps the URL of its file path",
+	"slug":    "rename the file to change its URL",
+	"aliases": "add redirects on the host",
+	"type":    "choose a layout with `layout` instead",
+}
+
+var (
+	hugoShortcodePattern = regexp.MustCompile(`\{\{[<%]\s*(/?)(\w+)\s*(.*?)\s*[>%]\}\}`)
+	hugoHighlightPattern = regexp.MustCompile(`(?s)\{\{[<%]\s*highlight\s+"?(\w*)"?[^>%]*[>%]\}\}\n?(.*?)\{\{[<%]\s*/highlight\s*[>%]\}\}`)
+	shortcodeArgPattern  = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|(\S+))`)
+)
+
+// importHugo converts the content directory of a Hugo site, copying bundle files as they are
+func (im *importer) importHugo() error {
+	contentDir := filepath.Join(im.src, "content")
+	if _, err := os.Stat(contentDir); err != nil {
+		return fmt.Errorf("%s has no content directory", im.src)
+	}
+	if _, err := os.Stat(filepath.Join(im.src, "static")); err == nil {
+		im.notes = append(im.notes, "`static/` was not imported; copy it into the static directory of the theme")
+	}
+	for _, name := range []string{"config.toml", "hugo.toml", "config.yaml", "hugo.yaml"} {
+		if data, err := os.ReadFile(filepath.Join(im.src, name)); err == nil && strings.Contains(string(data), "permalinks") {
+			im.notes = append(im.notes, fmt.Sprintf("`permalinks` in %s are not supported; pages use URLs of their file paths", name))
+		}
+	}
+
+	return filepath.WalkDir(contentDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(contentDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !strings.HasSuffix(rel, ".md") && !strings.HasSuffix(rel, ".markdown") {
+			im.files[rel] = p
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		fm, body, err := extractFrontMatter(data)
+		rel = strings.TrimSuffix(strings.TrimSuffix(rel, ".markdown"), ".md") + ".md"
+		page := newImportedPage(rel, p, fm.Params, hugoUnsupported)
+		if err != nil {
+			page.note("front matter could not be parsed and was dropped: %v", err)
+		}
+		page.Body = convertHugoShortcodes(string(body), page)
+		im.pages = append(im.pages, page)
+		return nil
+	})
+}
+
+// convertHugoShortcodes rewrites the common built-in shortcodes to Markdown and notes the rest
+func convertHugoShortcodes(body string, page *importedPage) string {
+	body = hugoHighlightPattern.ReplaceAllString(body, "```$1\n$2```")
+	return hugoShortcodePattern.ReplaceAllStringFunc(body, func(match string) string {
+		m := hugoShortcodePattern.FindStringSubmatch(match)
+		closing, name, args := m[1] != "", m[2], shortcodeArgs(m[3])
+		switch {
+		case closing:
+		case name == "figure" && args["src"] != "":
+			title := ""
+			if caption := args["caption"] + args["title"]; caption != "" {
+				title = fmt.Sprintf(" %q", caption)
+			}
+			return fmt.Sprintf("![%s](%s%s)", args["alt"], args["src"], title)
+		case name == "youtube" && args["0"]+args["id"] != "":
+			return fmt.Sprintf("[YouTube video](https://www.youtube.com/watch?v=%s)", args["0"]+args["id"])
+		case name == "vimeo" && args["0"]+args["id"] != "":
+			return fmt.Sprintf("[Vimeo video](https://vimeo.com/%s)", args["0"]+args["id"])
+		case (name == "ref" || name == "relref") && args["0"] != "":
+			return contentURL(args["0"])
+		}
+		page.note("shortcode `%s` was left as is", match)
+		return match
+	})
+}
+
+// shortcodeArgs parses named (key="value") and positional arguments; positional ones are keyed "0", "1", ...
+func shortcodeArgs(s string) map[string]string {
+	args := map[string]string{}
+	for _, m := range shortcodeArgPattern.FindAllStringSubmatch(s, -1) {
+		args[m[1]] = m[2] + m[3]
+	}
+	if len(args) == 0 {
+		for i, field := range strings.Fields(s) {
+			args[fmt.Sprint(i)] = strings.Trim(field, `"`)
+		}
+	}
+	return args
+}
+
+// contentURL returns the site path a content file is published at
+func contentURL(rel string) string {
+	rel = strings.TrimPrefix(strings.Trim(rel, `"`), "/")
+	switch {
+	case path.Base(rel) == "index.md" || path.Base(rel) == "_index.md":
+		return urlPath(path.Dir(rel), "/")
+	case strings.HasSuffix(rel, ".md"):
+		return urlPath(strings.TrimSuffix(rel, ".md") + ".html")
+	}
+	return urlPath(rel)
+}
+
+// jekyllUnsupported are Jekyll front matter keys without an equivalent here
+var jekyllUnsupported = map[string]string{
+	"permalink":         "the page keeps the URL of its file path",
+	"excerpt_separator": "summaries use the description or the first words",
+}
+
+var (
+	jekyllHighlightPattern = regexp.MustCompile(`(?s)\{%-?\s*highlight\s+(\w+)[^%]*%\}\n?(.*?)\{%-?\s*endhighlight\s*-?%\}`)
+	jekyllPostURLPattern   = regexp.MustCompile(`\{%-?\s*(?:post_url|link)\s+(\S+)\s*-?%\}`)
+	jekyllSiteURLPattern   = regexp.MustCompile(`\{\{-?\s*site\.(?:baseurl|url)\s*-?\}\}`)
+	jekyllRawPattern       = regexp.MustCompile(`\{%-?\s*(?:end)?raw\s*-?%\}`)
+	liquidPattern          = regexp.MustCompile(`\{%.*?%\}|\{\{.*?\}\}`)
+)
+
+// importJekyll converts _posts, _drafts and the Markdown pages at the root of a Jekyll site
+func (im *importer) importJekyll() error {
+	if _, err := os.Stat(filepath.Join(im.src, "_posts")); err != nil {
+		return fmt.Errorf("%s has no _posts directory", im.src)
+	}
+	for _, dir := range []string{"_posts", "_drafts"} {
+		root := filepath.Join(im.src, dir)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return filepath.SkipAll
+			}
+			if err != nil || d.IsDir() || !isMarkdownFile(p) {
+				return err
+			}
+			name := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+			date := filenameDatePattern.FindString(name)
+			name = strings.TrimPrefix(name, date)
+			page, err := im.importJekyllFile(p, "posts/"+name+".md")
+			if err != nil {
+				return err
+			}
+			if page.FrontMatter.Date == nil && date != "" {
+				t := toTime(strings.TrimRight(date, "-_"))
+				page.FrontMatter.Date = &t
+			}
+			page.FrontMatter.Draft = page.FrontMatter.Draft || dir == "_drafts"
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(im.src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isMarkdownFile(name) || strings.EqualFold(strings.TrimSuffix(name, filepath.Ext(name)), "README") {
+			continue
+		}
+		rel := strings.TrimSuffix(name, filepath.Ext(name)) + ".md"
+		if rel == "index.md" {
+			rel = "_index.md"
+		}
+		if _, err := im.importJekyllFile(filepath.Join(im.src, name), rel); err != nil {
+			return err
+		}
+	}
+	im.notes = append(im.notes, "Jekyll permalinks (/:year/:month/:day/:title.html) are not kept; posts are published under /posts/")
+	return nil
+}
+
+// importJekyllFile converts one Jekyll Markdown file and its Liquid tags
+func (im *importer) importJekyllFile(src, rel string) (*importedPage, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	fm, body, err := extractFrontMatter(data)
+	if layout, ok := fm.Params["layout"].(string); ok {
+		delete(fm.Params, "layout")
+		if layout != "post" && layout != "page" && layout != "default" {
+			fm.Params["layout"] = layout
+		}
+	}
+	page := newImportedPage(rel, src, fm.Params, jekyllUnsupported)
+	if err != nil {
+		page.note("front matter could not be parsed and was dropped: %v", err)
+	}
+
+	text := jekyllHighlightPattern.ReplaceAllString(string(body), "```$1\n$2```")
+	text = jekyllRawPattern.ReplaceAllString(text, "")
+	text = jekyllSiteURLPattern.ReplaceAllString(text, "")
+	text = jekyllPostURLPattern.ReplaceAllStringFunc(text, func(match string) string {
+		target := jekyllPostURLPattern.FindStringSubmatch(match)[1]
+		target = strings.TrimPrefix(strings.TrimSuffix(target, path.Ext(target)), "_posts/")
+		return contentURL("posts/" + strings.TrimPrefix(target, filenameDatePattern.FindString(target)) + ".md")
+	})
+	for _, match := range liquidPattern.FindAllString(text, -1) {
+		page.note("Liquid `%s` was left as is", match)
+	}
+	page.Body = text
+	im.pages = append(im.pages, page)
+	return page, nil
+}
+
+// isMarkdownFile reports whether the file name has a Markdown extension
+func isMarkdownFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// wxrItem is a post, page or attachment of a WordPress export
+type wxrItem struct {
+	Title      string        `xml:"title"`
+	Link       string        `xml:"link"`
+	Creator    string        `xml:"creator"`
+	Content    string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostName   string        `xml:"post_name"`
+	PostDate   string        `xml:"post_date"`
+	PostType   string        `xml:"post_type"`
+	Status     string        `xml:"status"`
+	Categories []wxrCategory `xml:"category"`
+}
+
+type wxrCategory struct {
+	Domain string `xml:"domain,attr"`
+	Name   string `xml:",chardata"`
+}
+
+var (
+	wpShortcodePattern   = regexp.MustCompile(`\[(/?)(\w+)([^\]]*)\]`)
+	htmlTagNamePattern   = regexp.MustCompile(`<(\w+)[\s>/]`)
+	headingMarkerPattern = regexp.MustCompile(`<<h([1-6])>>`)
+	blankLinesPattern    = regexp.MustCompile(`\n{3,}`)
+	entityPattern        = regexp.MustCompile(`&(?:#[0-9]+|#[xX][0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+)
+
+// importWordPress converts the posts and pages of a WordPress export (WXR) file
+func (im *importer) importWordPress() error {
+	data, err := os.ReadFile(im.src)
+	if err != nil {
+		return err
+	}
+	var export struct {
+		Items []wxrItem `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("failed to parse WordPress export: %w", err)
+	}
+
+	attachments := 0
+	for _, item := range export.Items {
+		var section string
+		switch item.PostType {
+		case "post":
+			section = "posts/"
+		case "page":
+		case "attachment":
+			attachments++
+			continue
+		default:
+			continue
+		}
+		// WordPress stores titles and terms with HTML character references
+		title := html.UnescapeString(item.Title)
+		name := slugify(item.PostName, SlugTransliterate)
+		if name == "" {
+			name = slugify(title, SlugTransliterate)
+		}
+		if name == "" {
+			continue
+		}
+
+		params := map[string]any{"title": title, "draft": item.Status != "publish"}
+		if t := toTime(item.PostDate); !t.IsZero() {
+			params["date"] = t
+		}
+		if item.Creator != "" {
+			params["author"] = item.Creator
+		}
+		var tags, categories []string
+		for _, c := range item.Categories {
+			switch c.Domain {
+			case "post_tag":
+				tags = append(tags, html.UnescapeString(c.Name))
+			case "category":
+				if c.Name != "Uncategorized" {
+					categories = append(categories, html.UnescapeString(c.Name))
+				}
+			}
+		}
+		page := newImportedPage(section+name+".md", "item "+item.Link, params, nil)
+		page.FrontMatter.Tags, page.FrontMatter.Categories = tags, categories
+		if item.Link != "" {
+			page.note("the original URL %s is not redirected", item.Link)
+		}
+		page.Body = convertWordPressContent(item.Content, page)
+		im.pages = append(im.pages, page)
+	}
+	if attachments > 0 {
+		im.notes = append(im.notes, fmt.Sprintf("%d media attachments were not downloaded; image links still point to the old site", attachments))
+	}
+	return nil
+}
+
+// htmlReplacements convert the HTML WordPress stores to Markdown, in order
+var htmlReplacements = []struct {
+	pattern *regexp.Regexp
+	replace string
+}{
+	{regexp.MustCompile(`(?is)<pre[^>]*>\s*(?:<code[^>]*>)?(.*?)(?:</code>)?\s*</pre>`), "\n```\n$1\n```\n"},
+	{regexp.MustCompile(`(?is)<h([1-6])[^>]*>(.*?)</h[1-6]>`), "\n\n<<h$1>> $2\n\n"},
+	{regexp.MustCompile(`(?is)<(?:strong|b)>(.*?)</(?:strong|b)>`), "**$1**"},
+	{regexp.MustCompile(`(?is)<(?:em|i)>(.*?)</(?:em|i)>`), "*$1*"},
+	{regexp.MustCompile(`(?is)<code>(.*?)</code>`), "`$1`"},
+	{regexp.MustCompile(`(?is)<img[^>]*?src="([^"]*)"[^>]*?alt="([^"]*)"[^>]*>`), "![$2]($1)"},
+	{regexp.MustCompile(`(?is)<img[^>]*?src="([^"]*)"[^>]*>`), "![]($1)"},
+	{regexp.MustCompile(`(?is)<a[^>]*?href="([^"]*)"[^>]*>(.*?)</a>`), "[$2]($1)"},
+	{regexp.MustCompile(`(?is)<li[^>]*>(.*?)</li>`), "- $1\n"},
+	{regexp.MustCompile(`(?is)</?(?:ul|ol)[^>]*>`), "\n"},
+	{regexp.MustCompile(`(?is)<blockquote[^>]*>(.*?)</blockquote>`), "\n> $1\n"},
+	{regexp.MustCompile(`(?i)<br\s*/?>`), "  \n"},
+	{regexp.MustCompile(`(?i)</?p[^>]*>`), "\n\n"},
+	{regexp.MustCompile(`<!--.*?-->`), ""},
+}
+
+// unescapeMarkdown resolves the character references of converted content. Code is literal in
+// Markdown, so its references are all resolved; in text, those of <, > and & are kept so they
+// are not read as markup.
+func unescapeMarkdown(content string) string {
+	blocks := strings.Split(content, "```")
+	for i, block := range blocks {
+		if i%2 == 1 {
+			blocks[i] = html.UnescapeString(block)
+			continue
+		}
+		spans := strings.Split(block, "`")
+		for j, span := range spans {
+			spans[j] = entityPattern.ReplaceAllStringFunc(span, func(ref string) string {
+				if c := html.UnescapeString(ref); j%2 == 1 || (c != "<" && c != ">" && c != "&") {
+					return c
+				}
+				return ref
+			})
+		}
+		blocks[i] = strings.Join(spans, "`")
+	}
+	return strings.Join(blocks, "```")
+}
+
+// convertWordPressContent converts post HTML and shortcodes to Markdown, noting what is left over
+func convertWordPressContent(content string, page *importedPage) string {
+	content = wpShortcodePattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := wpShortcodePattern.FindStringSubmatch(match)[2]
+		switch name {
+		case "caption", "embed":
+			return ""
+		}
+		page.note("shortcode `%s` was left as is", match)
+		return match
+	})
+	for _, r := range htmlReplacements {
+		content = r.pattern.ReplaceAllString(content, r.replace)
+	}
+	// Headings were marked before emphasis rules ran so their level survives
+	content = headingMarkerPattern.ReplaceAllStringFunc(content, func(m string) string {
+		return strings.Repeat("#", int(m[3]-'0'))
+	})
+	content = blankLinesPattern.ReplaceAllString(content, "\n\n")
+	content = unescapeMarkdown(content)
+
+	seen := map[string]bool{}
+	for _, m := range htmlTagNamePattern.FindAllStringSubmatch(content, -1) {
+		if tag := strings.ToLower(m[1]); !seen[tag] {
+			seen[tag] = true
+			page.note("HTML `<%s>` is not converted and raw HTML is not rendered", tag)
+		}
+	}
+	return content
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestUnescapeMarkdown(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"Tom&#8217;s caf&eacute;&nbsp;list", "Tom’s café\u00a0list"},
+		{"a &lt;b&gt; &amp; c", "a &lt;b&gt; &amp; c"},
+		{"use `a &lt; b` here", "use `a < b` here"},
+		{"```go\nif a &lt; b &amp;&amp; c {}\n```\n&lt;", "```go\nif a < b && c {}\n```\n&lt;"},
+		{"AT&T & co", "AT&T & co"},
+	}
+	for _, tt := range tests {
+		if got := unescapeMarkdown(tt.content); got != tt.want {
+			t.Errorf("unescapeMarkdown(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// IndieWebConfig holds the endpoints advertised by the _internal/indieweb.html partial
+type IndieWebConfig struct {
+	Webmention            string   `toml:"webmention"`
+	Pingback              string   `toml:"pingback"`
+	Micropub              string   `toml:"micropub"`
+	AuthorizationEndpoint string   `toml:"authorizationEndpoint"`
+	TokenEndpoint         string   `toml:"tokenEndpoint"`
+	Me                    []string `toml:"me"`
+
+	// ExportWebmentions writes webmentions.json listing the outgoing links of every page
+	ExportWebmentions bool `toml:"exportWebmentions"`
+}
+
+var hrefPattern = regexp.MustCompile(`(?i)<a\s[^>]*href="([^"]+)"`)
+
+// extractLinks returns the distinct link targets of an HTML fragment in document order
+func extractLinks(content string) []string {
+	var links []string
+	seen := map[string]bool{}
+	for _, m := range hrefPattern.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			links = append(links, m[1])
+		}
+	}
+	return links
+}
+
+// OutgoingLinks returns the absolute links of the page content that point to other hosts
+func (p *Page) OutgoingLinks() []string {
+	own, _ := url.Parse(p.Site.BaseURL)
+	var links []string
+	for _, link := range extractLinks(string(p.Content)) {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if own != nil && u.Host == own.Host {
+			continue
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+type webmentionSource struct {
+	Source  string   `json:"source"`
+	Targets []string `json:"targets"`
+}
+
+// renderWebmentions writes webmentions.json so a deploy step can send webmentions for new links
+func (s *Site) renderWebmentions(outputDir string) error {
+	if !s.Config.IndieWeb.ExportWebmentions {
+		return nil
+	}
+	sources := []webmentionSource{}
+	for _, p := range s.Pages {
+		if targets := p.OutgoingLinks(); len(targets) > 0 {
+			sources = append(sources, webmentionSource{Source: p.Permalink, Targets: targets})
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Source < sources[j].Source })
+
+	data, err := json.MarshalIndent(sources, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode webmentions: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "webmentions.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write webmentions.json: %w", err)
+	}
+	return nil
+}
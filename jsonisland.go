@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+)
+
+// jsonIslandAutoGzip is the encoded size above which the "auto" mode of jsonIsland compresses
+const jsonIslandAutoGzip = 32 * 1024
+
+// jsonIsland serializes data into a <script type="application/json"> element with the given id.
+// The JSON is compact and HTML-safe, so "</script>" and U+2028 in the data cannot break out.
+// The optional mode is "gzip" to always compress or "auto" to compress above 32 KiB; compressed
+// islands hold base64 and carry data-encoding="gzip+base64", which pages decode with
+// new Response(blob.stream().pipeThrough(new DecompressionStream("gzip"))).json().
+func jsonIsland(id string, data any, mode ...string) (template.HTML, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("jsonIsland %q: %w", id, err)
+	}
+
+	compress := false
+	if len(mode) > 0 {
+		switch mode[0] {
+		case "gzip":
+			compress = true
+		case "auto":
+			compress = len(encoded) > jsonIslandAutoGzip
+		case "":
+		default:
+			return "", fmt.Errorf("jsonIsland %q: unknown mode %q", id, mode[0])
+		}
+	}
+
+	attrs := fmt.Sprintf(`type="application/json" id="%s"`, html.EscapeString(id))
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(encoded); err != nil {
+			return "", fmt.Errorf("jsonIsland %q: %w", id, err)
+		}
+		if err := zw.Close(); err != nil {
+			return "", fmt.Errorf("jsonIsland %q: %w", id, err)
+		}
+		attrs += ` data-encoding="gzip+base64"`
+		encoded = []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	}
+	return template.HTML("<script " + attrs + ">" + string(encoded) + "</script>"), nil
+}
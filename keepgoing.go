@@ -0,0 +1,78 @@
+package main
+
+import (
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// contentError is a content file that failed to load, shown as an error page with --keep-going
+type contentError struct {
+	file contentFile
+	err  error
+}
+
+// errorPageTemplate is the placeholder written in place of a page that failed
+var errorPageTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Build error: {{ .Title }}</title>
+<style>body{font-family:sans-serif;max-width:60rem;margin:2rem auto;padding:0 1rem}pre{background:#fee;border-left:4px solid #c00;padding:1rem;white-space:pre-wrap}</style>
+</head>
+<body>
+<h1>Build error</h1>
+<p>{{ if .Source }}<code>{{ .Source }}</code>{{ else }}This page{{ end }} could not be built:</p>
+<pre>{{ .Error }}</pre>
+</body>
+</html>
+`))
+
+// writeErrorPage writes the error page of a failed page to its output path below outputDir
+func writeErrorPage(outputDir, outputPath, title, source string, cause error) error {
+	dest, err := outputFile(outputDir, filepath.ToSlash(outputPath))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if title == "" {
+		title = source
+	}
+	return errorPageTemplate.Execute(file, map[string]string{"Title": title, "Source": source, "Error": cause.Error()})
+}
+
+// renderErrorPage replaces the output of a page that failed to render
+func (s *Site) renderErrorPage(outputDir string, p *Page, cause error) {
+	source := ""
+	if p.source != nil {
+		source = "content/" + filepath.ToSlash(p.source.RelPath)
+	}
+	if err := writeErrorPage(outputDir, p.outputPath, p.Title, source, cause); err != nil {
+		failf("Failed to write error page for %s: %v", p.RelPermalink, err)
+	}
+}
+
+// renderLoadErrors writes an error page at the URL of every content file that failed to load
+func (s *Site) renderLoadErrors(outputDir string) {
+	for _, failed := range s.loadErrors {
+		rel := filepath.ToSlash(failed.file.RelPath)
+		outputPath := strings.TrimSuffix(rel, ".md") + ".html"
+		if failed.file.IsBundle {
+			outputPath = path.Join(path.Dir(rel), "index.html")
+		}
+		outputPath = strings.TrimPrefix(sanitizeURLPath(outputPath), "/")
+		if err := writeErrorPage(outputDir, outputPath, "", "content/"+rel, failed.err); err != nil {
+			failf("Failed to write error page for %s: %v", rel, err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Link rewriting modes
+const (
+	// LinksAbsolute prefixes every internal link with the base URL
+	LinksAbsolute = "absolute"
+	// LinksRelative makes internal links relative to the page, with explicit index.html, so the
+	// output can be browsed from file://
+	LinksRelative = "relative"
+	// LinksCDN points internal asset links at the CDN host
+	LinksCDN = "cdn"
+)
+
+// defaultCDNExtensions are the asset types served from the CDN unless extensions are configured
+var defaultCDNExtensions = []string{"css", "js", "mjs", "png", "jpg", "jpeg", "gif", "svg", "webp", "avif", "ico", "woff", "woff2", "mp3", "mp4", "webm", "pdf"}
+
+// LinksConfig configures the link rewriting pass that runs over the rendered HTML
+type LinksConfig struct {
+	Mode string `toml:"mode"`
+	// CDN is the base URL that replaces the site base URL in cdn mode
+	CDN        string   `toml:"cdn"`
+	Extensions []string `toml:"extensions"`
+}
+
+var (
+	startTagPattern   = regexp.MustCompile(`<[a-zA-Z][^>]*>`)
+	linkAttrPattern   = regexp.MustCompile(`(?i)(\s(?:href|src|poster|action)=")([^"]*)(")`)
+	srcsetAttrPattern = regexp.MustCompile(`(?i)(\s(?:srcset|imagesrcset)=")([^"]*)(")`)
+	canonicalPattern  = regexp.MustCompile(`(?i)\brel="(?:canonical|alternate)"`)
+)
+
+// rewriteLinks rewrites the internal links of every HTML file of the output in the configured mode
+func (s *Site) rewriteLinks(outputDir string) error {
+	cfg := s.Config.Links
+	if s.options.LinkMode != "" {
+		cfg.Mode = s.options.LinkMode
+	}
+	if cfg.Mode == "" {
+		return nil
+	}
+	base, err := url.Parse(s.BaseURL)
+	if err != nil || base.Host == "" {
+		return fmt.Errorf("link mode %s needs an absolute baseURL", cfg.Mode)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	var rewrite func(target *url.URL, sitePath, fileDir string) string
+	switch cfg.Mode {
+	case LinksAbsolute:
+		rewrite = func(target *url.URL, _, _ string) string { return target.String() }
+	case LinksRelative:
+		rewrite = func(target *url.URL, sitePath, fileDir string) string {
+			if sitePath == "" || strings.HasSuffix(sitePath, "/") {
+				sitePath += "index.html"
+			}
+			rel := relativePath(fileDir, sitePath)
+			if target.RawQuery != "" {
+				rel += "?" + target.RawQuery
+			}
+			if target.Fragment != "" {
+				rel += "#" + target.Fragment
+			}
+			return rel
+		}
+	case LinksCDN:
+		if cfg.CDN == "" {
+			return fmt.Errorf("link mode cdn needs links.cdn")
+		}
+		extensions := cfg.Extensions
+		if len(extensions) == 0 {
+			extensions = defaultCDNExtensions
+		}
+		rewrite = func(target *url.URL, sitePath, _ string) string {
+			if !slices.Contains(extensions, strings.ToLower(strings.TrimPrefix(path.Ext(sitePath), "."))) {
+				return ""
+			}
+			cdn := strings.TrimSuffix(cfg.CDN, "/") + "/" + sitePath
+			if target.RawQuery != "" {
+				cdn += "?" + target.RawQuery
+			}
+			return cdn
+		}
+	default:
+		return fmt.Errorf("unknown link mode %q, use absolute, relative or cdn", cfg.Mode)
+	}
+
+	return filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".html") {
+			return err
+		}
+		rel, err := filepath.Rel(outputDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		page := base.ResolveReference(&url.URL{Path: rel})
+		fileDir := path.Dir(rel)
+
+		// rewriteURL returns the link rewritten for the mode, or unchanged when it is not
+		// internal or the mode leaves it alone
+		rewriteURL := func(link string) string {
+			if link == "" || strings.HasPrefix(link, "#") {
+				return link
+			}
+			ref, err := url.Parse(link)
+			if err != nil {
+				return link
+			}
+			target := page.ResolveReference(ref)
+			if target.Host != base.Host || !strings.HasPrefix(target.Path, base.Path) {
+				return link
+			}
+			if rewritten := rewrite(target, strings.TrimPrefix(target.Path, base.Path), fileDir); rewritten != "" {
+				return rewritten
+			}
+			return link
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		out := startTagPattern.ReplaceAllStringFunc(string(data), func(tag string) string {
+			// Canonical and alternate links name the published URL, not a file
+			if canonicalPattern.MatchString(tag) {
+				return tag
+			}
+			tag = linkAttrPattern.ReplaceAllStringFunc(tag, func(attr string) string {
+				m := linkAttrPattern.FindStringSubmatch(attr)
+				return m[1] + rewriteURL(m[2]) + m[3]
+			})
+			// Every candidate of a srcset is a link of its own
+			return srcsetAttrPattern.ReplaceAllStringFunc(tag, func(attr string) string {
+				m := srcsetAttrPattern.FindStringSubmatch(attr)
+				candidates := strings.Split(m[2], ",")
+				changed := false
+				for i, candidate := range candidates {
+					fields := strings.Fields(candidate)
+					if len(fields) == 0 {
+						continue
+					}
+					if link := rewriteURL(fields[0]); link != fields[0] {
+						fields[0], changed = link, true
+					}
+					candidates[i] = strings.Join(fields, " ")
+				}
+				if !changed {
+					return attr
+				}
+				return m[1] + strings.Join(candidates, ", ") + m[3]
+			})
+		})
+		if out == string(data) {
+			return nil
+		}
+		return os.WriteFile(p, []byte(out), 0644)
+	})
+}
+
+// relativePath returns the slash-separated path of target relative to the directory dir
+func relativePath(dir, target string) string {
+	var from []string
+	if dir != "." && dir != "" {
+		from = strings.Split(dir, "/")
+	}
+	to := strings.Split(target, "/")
+	common := 0
+	for common < len(from) && common < len(to)-1 && from[common] == to[common] {
+		common++
+	}
+	up := strings.Repeat("../", len(from)-common)
+	return up + strings.Join(to[common:], "/")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// LintConfig configures the lint command, see [lint]
+type LintConfig struct {
+	// Rules sets the severity of a rule: error, warning, info or off
+	Rules          map[string]string `toml:"rules"`
+	MaxTitleLength int               `toml:"maxTitleLength"`
+}
+
+// Lint severities; errors make the lint command fail
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+	severityOff     = "off"
+)
+
+// lintRules lists the built-in rules with their default severity
+var lintRules = map[string]string{
+	"missing-alt":         severityWarning,
+	"missing-description": severityWarning,
+	"long-title":          severityWarning,
+	"own-domain-link":     severityWarning,
+	"todo":                severityInfo,
+}
+
+// lintFinding is one problem reported by the lint command
+type lintFinding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+var (
+	markdownImagePattern = regexp.MustCompile(`!\[([^\]]*)\]\(`)
+	htmlImagePattern     = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+	altAttrPattern       = regexp.MustCompile(`(?i)\salt\s*=\s*("[^"]*\S[^"]*"|'[^']*\S[^']*'|[^\s"'>]+)`)
+	todoPattern          = regexp.MustCompile(`\b(TODO|FIXME|XXX)\b`)
+	fencePattern         = regexp.MustCompile("^\\s*(```|~~~)")
+)
+
+// runLint checks the content against the lint rules and prints the findings as text or JSON
+func runLint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	format := flags.String("format", "text", "output format: text or json")
+	environment := flags.String("environment", envOr("HERO_ENVIRONMENT", "production"), "environment whose config is used")
+	flags.Parse(args)
+	if *format != "text" && *format != "json" {
+		log.Fatalf("Unknown format %q, use text or json", *format)
+	}
+
+	site, _, err := loadSite(buildOptions{Environment: *environment, BuildDrafts: true, BuildFuture: true, BuildExpired: true})
+	if err != nil {
+		log.Fatalf("Failed to load site: %v", err)
+	}
+	severities, err := lintSeverities(site.Config.Lint)
+	if err != nil {
+		log.Fatalf("Failed to configure lint: %v", err)
+	}
+	findings := site.lint(severities)
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if findings == nil {
+			findings = []lintFinding{}
+		}
+		if err := encoder.Encode(findings); err != nil {
+			log.Fatalf("Failed to encode findings: %v", err)
+		}
+	} else {
+		for _, f := range findings {
+			fmt.Printf("%s:%d: %s: %s (%s)\n", f.File, f.Line, f.Severity, f.Message, f.Rule)
+		}
+	}
+
+	counts := map[string]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	if *format == "text" {
+		fmt.Printf("%d errors, %d warnings, %d info\n", counts[severityError], counts[severityWarning], counts[severityInfo])
+	}
+	if counts[severityError] > 0 {
+		os.Exit(1)
+	}
+}
+
+// lintSeverities merges the configured severities over the defaults
+func lintSeverities(cfg LintConfig) (map[string]string, error) {
+	severities := map[string]string{}
+	for rule, severity := range lintRules {
+		severities[rule] = severity
+	}
+	for rule, severity := range cfg.Rules {
+		if _, ok := lintRules[rule]; !ok {
+			return nil, fmt.Errorf("unknown rule %q", rule)
+		}
+		switch severity {
+		case severityError, severityWarning, severityInfo, severityOff:
+			severities[rule] = severity
+		default:
+			return nil, fmt.Errorf("unknown severity %q for %s, use error, warning, info or off", severity, rule)
+		}
+	}
+	return severities, nil
+}
+
+// lint runs the enabled rules over every content page, sorted by file and line
+func (s *Site) lint(severities map[string]string) []lintFinding {
+	maxTitle := s.Config.Lint.MaxTitleLength
+	if maxTitle == 0 {
+		maxTitle = 70
+	}
+	var host string
+	if u, err := url.Parse(s.BaseURL); err == nil {
+		host = u.Host
+	}
+
+	var findings []lintFinding
+	for _, p := range s.contentPages() {
+		// Pages of content adapters have no Markdown file to point at
+		if p.source == nil || p.source.data != nil {
+			continue
+		}
+		file := filepath.ToSlash(filepath.Clean(p.source.Path))
+		// Body lines are numbered after the front matter
+		offset := 0
+		if data, err := os.ReadFile(p.source.Path); err == nil {
+			full := strings.ReplaceAll(string(data), "\r\n", "\n")
+			offset = strings.Count(full, "\n") - strings.Count(p.RawContent, "\n")
+		}
+		report := func(rule string, line int, format string, args ...any) {
+			if severity := severities[rule]; severity != severityOff {
+				findings = append(findings, lintFinding{File: file, Line: line, Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...)})
+			}
+		}
+
+		if p.Description == "" {
+			report("missing-description", 1, "page has no description")
+		}
+		if n := utf8.RuneCountInString(p.Title); n > maxTitle {
+			report("long-title", frontMatterLine(p.RawFrontMatter, "title"), "title is %d characters long, more than %d", n, maxTitle)
+		}
+
+		inFence := false
+		for i, line := range strings.Split(p.RawContent, "\n") {
+			number := offset + i + 1
+			if fencePattern.MatchString(line) {
+				inFence = !inFence
+			}
+			if m := todoPattern.FindString(line); m != "" {
+				report("todo", number, "%s marker left in content", m)
+			}
+			if inFence {
+				continue
+			}
+			for _, m := range markdownImagePattern.FindAllStringSubmatch(line, -1) {
+				if strings.TrimSpace(m[1]) == "" {
+					report("missing-alt", number, "image has no alt text")
+				}
+			}
+			for _, tag := range htmlImagePattern.FindAllString(line, -1) {
+				if !altAttrPattern.MatchString(tag) {
+					report("missing-alt", number, "image has no alt text")
+				}
+			}
+			if host != "" {
+				for _, scheme := range []string{"http://", "https://"} {
+					if strings.Contains(line, "("+scheme+host) || strings.Contains(line, `"`+scheme+host) || strings.Contains(line, "<"+scheme+host) {
+						report("own-domain-link", number, "absolute link to %s, use a relative link", host)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+// frontMatterLine returns the line of a key in the front matter, counting the opening delimiter
+// as line 1, or 1 when the key is not found
+func frontMatterLine(raw, key string) int {
+	for i, line := range strings.Split(raw, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, key) {
+			rest := strings.TrimSpace(strings.TrimPrefix(trimmed, key))
+			if strings.HasPrefix(rest, ":") || strings.HasPrefix(rest, "=") {
+				return i + 2
+			}
+		}
+	}
+	return 1
+}
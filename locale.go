@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// locale holds the names and number conventions of a language
+type locale struct {
+	months, shortMonths []string
+	days, shortDays     []string
+	decimal, group      string
+	// currency places the amount # and the symbol ¤
+	currency string
+	// dateFormat is the human-readable date layout used when dateFormat is not configured
+	dateFormat string
+}
+
+// locales are the built-in locales by language; months are in the form used within dates, which
+// differs from the standalone one in some languages
+var locales = map[string]locale{
+	"en": {
+		months:      []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths: []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		days:        []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		shortDays:   []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		decimal:     ".",
+		group:       ",",
+		currency:    "¤#",
+		dateFormat:  "January 2, 2006",
+	},
+	"de": {
+		months:      []string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: []string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		days:        []string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortDays:   []string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+		decimal:     ",",
+		group:       ".",
+		currency:    "#\u00a0¤",
+		dateFormat:  "2. January 2006",
+	},
+	"fr": {
+		months:      []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: []string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		days:        []string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		shortDays:   []string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		decimal:     ",",
+		group:       "\u202f",
+		currency:    "#\u00a0¤",
+		dateFormat:  "2 January 2006",
+	},
+	"es": {
+		months:      []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: []string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		days:        []string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		shortDays:   []string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		decimal:     ",",
+		group:       ".",
+		currency:    "#\u00a0¤",
+		dateFormat:  "2 de January de 2006",
+	},
+	"it": {
+		months:      []string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths: []string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		days:        []string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		shortDays:   []string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+		decimal:     ",",
+		group:       ".",
+		currency:    "#\u00a0¤",
+		dateFormat:  "2 January 2006",
+	},
+	"pt": {
+		months:      []string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		shortMonths: []string{"jan.", "fev.", "mar.", "abr.", "mai.", "jun.", "jul.", "ago.", "set.", "out.", "nov.", "dez."},
+		days:        []string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		shortDays:   []string{"dom.", "seg.", "ter.", "qua.", "qui.", "sex.", "sáb."},
+		decimal:     ",",
+		group:       ".",
+		currency:    "¤\u00a0#",
+		dateFormat:  "2 de January de 2006",
+	},
+	"nl": {
+		months:      []string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths: []string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		days:        []string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		shortDays:   []string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+		decimal:     ",",
+		group:       ".",
+		currency:    "¤\u00a0#",
+		dateFormat:  "2 January 2006",
+	},
+	"sv": {
+		months:      []string{"januari", "februari", "mars", "april", "maj", "juni", "juli", "augusti", "september", "oktober", "november", "december"},
+		shortMonths: []string{"jan.", "feb.", "mars", "apr.", "maj", "juni", "juli", "aug.", "sep.", "okt.", "nov.", "dec."},
+		days:        []string{"söndag", "måndag", "tisdag", "onsdag", "torsdag", "fredag", "lördag"},
+		shortDays:   []string{"sön", "mån", "tis", "ons", "tors", "fre", "lör"},
+		decimal:     ",",
+		group:       "\u00a0",
+		currency:    "#\u00a0¤",
+		dateFormat:  "2 January 2006",
+	},
+	"pl": {
+		months:      []string{"stycznia", "lutego", "marca", "kwietnia", "maja", "czerwca", "lipca", "sierpnia", "września", "października", "listopada", "grudnia"},
+		shortMonths: []string{"sty", "lut", "mar", "kwi", "maj", "cze", "lip", "sie", "wrz", "paź", "lis", "gru"},
+		days:        []string{"niedziela", "poniedziałek", "wtorek", "środa", "czwartek", "piątek", "sobota"},
+		shortDays:   []string{"niedz.", "pon.", "wt.", "śr.", "czw.", "pt.", "sob."},
+		decimal:     ",",
+		group:       "\u00a0",
+		currency:    "#\u00a0¤",
+		dateFormat:  "2 January 2006",
+	},
+	"ru": {
+		months:      []string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"},
+		shortMonths: []string{"янв.", "февр.", "мар.", "апр.", "мая", "июн.", "июл.", "авг.", "сент.", "окт.", "нояб.", "дек."},
+		days:        []string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"},
+		shortDays:   []string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"},
+		decimal:     ",",
+		group:       "\u00a0",
+		currency:    "#\u00a0¤",
+		dateFormat:  "2 January 2006",
+	},
+	"ja": {
+		months:      []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		shortMonths: []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		days:        []string{"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+		shortDays:   []string{"日", "月", "火", "水", "木", "金", "土"},
+		decimal:     ".",
+		group:       ",",
+		currency:    "¤#",
+		dateFormat:  "2006年1月2日",
+	},
+	"zh": {
+		months:      []string{"一月", "二月", "三月", "四月", "五月", "六月", "七月", "八月", "九月", "十月", "十一月", "十二月"},
+		shortMonths: []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		days:        []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+		shortDays:   []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"},
+		decimal:     ".",
+		group:       ",",
+		currency:    "¤#",
+		dateFormat:  "2006年1月2日",
+	},
+	"ko": {
+		months:      []string{"1월", "2월", "3월", "4월", "5월", "6월", "7월", "8월", "9월", "10월", "11월", "12월"},
+		shortMonths: []string{"1월", "2월", "3월", "4월", "5월", "6월", "7월", "8월", "9월", "10월", "11월", "12월"},
+		days:        []string{"일요일", "월요일", "화요일", "수요일", "목요일", "금요일", "토요일"},
+		shortDays:   []string{"일", "월", "화", "수", "목", "금", "토"},
+		decimal:     ".",
+		group:       ",",
+		currency:    "¤#",
+		dateFormat:  "2006년 1월 2일",
+	},
+}
+
+// currencySymbols are the symbols of common ISO 4217 codes; other codes are printed as they are
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "¥", "KRW": "₩", "INR": "₹",
+	"RUB": "₽", "BRL": "R$", "PLN": "zł", "SEK": "kr", "CHF": "CHF", "CAD": "CA$", "AUD": "A$",
+}
+
+// locale returns the locale of the site language, English when there is no built-in one
+func (s *Site) locale() locale {
+	if l, ok := locales[s.Lang()]; ok {
+		return l
+	}
+	return locales["en"]
+}
+
+// formatTime formats t like time.Format, with month and day names in the language of the locale
+func (l locale) formatTime(t time.Time, layout string) string {
+	var b strings.Builder
+	for layout != "" {
+		// Names are written directly; the chunks between them are left to time.Format
+		i, name := nextNameToken(layout)
+		b.WriteString(t.Format(layout[:i]))
+		if name == "" {
+			break
+		}
+		switch name {
+		case "January":
+			b.WriteString(l.months[t.Month()-1])
+		case "Jan":
+			b.WriteString(l.shortMonths[t.Month()-1])
+		case "Monday":
+			b.WriteString(l.days[t.Weekday()])
+		case "Mon":
+			b.WriteString(l.shortDays[t.Weekday()])
+		}
+		layout = layout[i+len(name):]
+	}
+	return b.String()
+}
+
+// nextNameToken finds the first month or weekday name of a time layout, as time.Format reads them
+func nextNameToken(layout string) (int, string) {
+	lower := func(i int) bool { return i < len(layout) && layout[i] >= 'a' && layout[i] <= 'z' }
+	for i := 0; i < len(layout); i++ {
+		rest := layout[i:]
+		switch {
+		case strings.HasPrefix(rest, "January"):
+			return i, "January"
+		case strings.HasPrefix(rest, "Jan") && !lower(i+3):
+			return i, "Jan"
+		case strings.HasPrefix(rest, "Monday"):
+			return i, "Monday"
+		case strings.HasPrefix(rest, "Mon") && !lower(i+3):
+			return i, "Mon"
+		}
+	}
+	return len(layout), ""
+}
+
+// formatNumber formats n with the given number of decimals and the separators of the locale
+func (l locale) formatNumber(n float64, precision int) string {
+	s := strconv.FormatFloat(math.Abs(n), 'f', precision, 64)
+	whole, fraction, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	if n < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteString("-")
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(l.decimal + fraction)
+	}
+	return b.String()
+}
+
+// timeNamespace is returned by the time template function, for {{ time.Format "2 January 2006" .Date }}
+type timeNamespace struct {
+	site *Site
+}
+
+// Format formats a date, or a string holding one, with month and day names in the site language
+func (ns timeNamespace) Format(layout string, v any) (string, error) {
+	t := toTime(v)
+	if t.IsZero() {
+		return "", fmt.Errorf("time.Format: %v is not a date", v)
+	}
+	return ns.site.locale().formatTime(t, layout), nil
+}
+
+// MonthName returns the name of the month of a date, or of a month number, in the site language
+func (ns timeNamespace) MonthName(v any) (string, error) {
+	month, err := monthOf(v)
+	if err != nil {
+		return "", err
+	}
+	return ns.site.locale().months[month-1], nil
+}
+
+// WeekdayName returns the name of the weekday of a date in the site language
+func (ns timeNamespace) WeekdayName(v any) (string, error) {
+	t := toTime(v)
+	if t.IsZero() {
+		return "", fmt.Errorf("time.WeekdayName: %v is not a date", v)
+	}
+	return ns.site.locale().days[t.Weekday()], nil
+}
+
+// monthOf returns the month of a date or a month number from 1 to 12
+func monthOf(v any) (time.Month, error) {
+	if n, ok := toFloat(v); ok {
+		if n < 1 || n > 12 || n != math.Trunc(n) {
+			return 0, fmt.Errorf("time.MonthName: %v is not a month", v)
+		}
+		return time.Month(n), nil
+	}
+	t := toTime(v)
+	if t.IsZero() {
+		return 0, fmt.Errorf("time.MonthName: %v is not a date", v)
+	}
+	return t.Month(), nil
+}
+
+// langNamespace is returned by the lang template function, for {{ lang.FormatNumber 2 .Params.price }}
+type langNamespace struct {
+	site *Site
+}
+
+// FormatNumber formats a number with the given number of decimals and the separators of the site language
+func (ns langNamespace) FormatNumber(precision int, v any) (string, error) {
+	n, ok := toFloat(v)
+	if !ok {
+		return "", fmt.Errorf("lang.FormatNumber: %v is not a number", v)
+	}
+	return ns.site.locale().formatNumber(n, precision), nil
+}
+
+// FormatPercent formats a number as a percentage, so 12.5 becomes "12.5%" in English
+func (ns langNamespace) FormatPercent(precision int, v any) (string, error) {
+	n, err := ns.FormatNumber(precision, v)
+	if err != nil {
+		return "", err
+	}
+	if ns.site.locale().decimal == "," {
+		// Languages with a decimal comma put a non-breaking space before the sign
+		return n + "\u00a0%", nil
+	}
+	return n + "%", nil
+}
+
+// FormatCurrency formats an amount of an ISO 4217 currency the way the site language writes
+// prices, e.g. {{ lang.FormatCurrency 2 "EUR" 1234.5 }} is "1.234,50 €" in German
+func (ns langNamespace) FormatCurrency(precision int, currency string, v any) (string, error) {
+	n, ok := toFloat(v)
+	if !ok {
+		return "", fmt.Errorf("lang.FormatCurrency: %v is not a number", v)
+	}
+	symbol, ok := currencySymbols[strings.ToUpper(currency)]
+	if !ok {
+		symbol = strings.ToUpper(currency)
+	}
+	l := ns.site.locale()
+	amount := l.formatNumber(math.Abs(n), precision)
+	text := strings.Replace(strings.Replace(l.currency, "#", amount, 1), "¤", symbol, 1)
+	if n < 0 && amount != l.formatNumber(0, precision) {
+		text = "-" + text
+	}
+	return text, nil
+}
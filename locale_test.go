@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocaleFormatting(t *testing.T) {
+	date := time.Date(2024, time.March, 4, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		lang     string
+		date     string
+		short    string
+		number   string
+		percent  string
+		code     string
+		currency string
+	}{
+		{"en-US", "Monday, March 4, 2024", "Mon Mar 4", "-1,234,567.89", "12.5%", "usd", "$1,234.50"},
+		{"de", "Montag, 4. März 2024", "Mo. März 4", "-1.234.567,89", "12,5\u00a0%", "eur", "1.234,50\u00a0€"},
+		{"fr", "lundi, 4 mars 2024", "lun. mars 4", "-1\u202f234\u202f567,89", "12,5\u00a0%", "eur", "1\u202f234,50\u00a0€"},
+		{"xx", "Monday, March 4, 2024", "Mon Mar 4", "-1,234,567.89", "12.5%", "usd", "$1,234.50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			site := &Site{LanguageCode: tt.lang}
+			l := site.locale()
+			if got := l.formatTime(date, "Monday, "+l.dateFormat); got != tt.date {
+				t.Errorf("formatTime(long) = %q, want %q", got, tt.date)
+			}
+			if got := l.formatTime(date, "Mon Jan 2"); got != tt.short {
+				t.Errorf("formatTime(short) = %q, want %q", got, tt.short)
+			}
+			if got := l.formatNumber(-1234567.891, 2); got != tt.number {
+				t.Errorf("formatNumber() = %q, want %q", got, tt.number)
+			}
+			ns := langNamespace{site: site}
+			if got, err := ns.FormatPercent(1, 12.5); err != nil || got != tt.percent {
+				t.Errorf("FormatPercent() = %q, %v, want %q", got, err, tt.percent)
+			}
+			if got, err := ns.FormatCurrency(2, tt.code, 1234.5); err != nil || got != tt.currency {
+				t.Errorf("FormatCurrency(%s) = %q, %v, want %q", tt.code, got, err, tt.currency)
+			}
+		})
+	}
+}
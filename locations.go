@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Location is the `location:` front matter of a page, given as a table such as
+// {lat: 38.72, lng: -9.14, name: Lisbon} or as "38.72, -9.14"
+type Location struct {
+	Lat  float64
+	Lng  float64
+	Name string
+}
+
+// LocationsConfig configures the map outputs of located pages; locations.geojson is written
+// whenever a page has a location
+type LocationsConfig struct {
+	// KML also writes locations.kml for Google Earth and other GIS tools
+	KML bool `toml:"kml"`
+}
+
+// newLocation reads the location front matter of a page; it returns nil when there is none.
+// Plain place names without coordinates are left to templates.
+func newLocation(v any, file string) *Location {
+	var loc Location
+	var lat, lng any
+	switch fields := v.(type) {
+	case nil:
+		return nil
+	case string:
+		parts := strings.Split(fields, ",")
+		if len(parts) != 2 {
+			return nil
+		}
+		lat, lng = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	case []any:
+		if len(fields) != 2 {
+			warnf("Ignoring location that is not [lat, lng] in %s", file)
+			return nil
+		}
+		lat, lng = fields[0], fields[1]
+	case map[string]any:
+		loc.Name, _ = fields["name"].(string)
+		lat = fields["lat"]
+		for _, key := range []string{"lng", "lon", "long"} {
+			if lng == nil {
+				lng = fields[key]
+			}
+		}
+	default:
+		return nil
+	}
+
+	var ok bool
+	if loc.Lat, ok = coordinate(lat); !ok || loc.Lat < -90 || loc.Lat > 90 {
+		if _, isString := v.(string); !isString {
+			warnf("Ignoring location with invalid latitude %v in %s", lat, file)
+		}
+		return nil
+	}
+	if loc.Lng, ok = coordinate(lng); !ok || loc.Lng < -180 || loc.Lng > 180 {
+		if _, isString := v.(string); !isString {
+			warnf("Ignoring location with invalid longitude %v in %s", lng, file)
+		}
+		return nil
+	}
+	return &loc
+}
+
+// coordinate reads a number from front matter, also when written as a string
+func coordinate(v any) (float64, bool) {
+	if s, ok := v.(string); ok {
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	}
+	return toFloat(v)
+}
+
+// LocatedPages returns the regular pages that have a location, in the order of .Site.Pages
+func (s *Site) LocatedPages() []*Page {
+	var pages []*Page
+	for _, p := range s.Pages {
+		if p.Location != nil {
+			pages = append(pages, p)
+		}
+	}
+	return pages
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   geoJSONPoint      `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type string `json:"type"`
+	// Coordinates are longitude first, as GeoJSON requires
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONProperties struct {
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Date     string `json:"date,omitempty"`
+	Summary  string `json:"summary,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Namespace  string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	// Coordinates are "lng,lat" as KML requires
+	Coordinates string `xml:"Point>coordinates"`
+}
+
+// renderLocations writes locations.geojson, and locations.kml when enabled, with a point for
+// every located page; sites without located pages get neither
+func (s *Site) renderLocations(outputDir string) (int, error) {
+	pages := s.LocatedPages()
+	if len(pages) == 0 {
+		return 0, nil
+	}
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	kml := kmlDocument{Namespace: "http://www.opengis.net/kml/2.2", Name: s.Title}
+	for _, p := range pages {
+		feature := geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONPoint{Type: "Point", Coordinates: [2]float64{p.Location.Lng, p.Location.Lat}},
+			Properties: geoJSONProperties{
+				Title:    p.Title,
+				URL:      p.Permalink,
+				Summary:  p.Summary(),
+				Location: p.Location.Name,
+			},
+		}
+		if !p.Date.IsZero() {
+			feature.Properties.Date = p.Date.Format(time.RFC3339)
+		}
+		collection.Features = append(collection.Features, feature)
+		kml.Placemarks = append(kml.Placemarks, kmlPlacemark{
+			Name:        p.Title,
+			Description: p.Permalink,
+			Coordinates: strconv.FormatFloat(p.Location.Lng, 'f', -1, 64) + "," + strconv.FormatFloat(p.Location.Lat, 'f', -1, 64),
+		})
+	}
+
+	data, err := json.MarshalIndent(collection, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode locations: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "locations.geojson"), data, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write locations.geojson: %w", err)
+	}
+	if !s.Config.Locations.KML {
+		return 1, nil
+	}
+	data, err = xml.MarshalIndent(kml, "", "  ")
+	if err != nil {
+		return 1, fmt.Errorf("failed to encode KML: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "locations.kml"), append([]byte(xml.Header), data...), 0644); err != nil {
+		return 1, fmt.Errorf("failed to write locations.kml: %w", err)
+	}
+	return 2, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// lockFileName records the resolved versions of remote dependencies for reproducible builds
+const lockFileName = "herocgo.lock"
+
+// LockFile is the content of herocgo.lock
+type LockFile struct {
+	Modules []LockedModule `toml:"module,omitempty"`
+	// Themes are fetched with `mod get <path>@<version>` and resolve the theme config value
+	Themes []LockedModule `toml:"theme,omitempty"`
+	// Data are the API responses of repoData the site was built with
+	Data []LockedData `toml:"data,omitempty"`
+}
+
+// LockedModule is the commit a module import was resolved to
+type LockedModule struct {
+	Path   string `toml:"path"`
+	Ref    string `toml:"ref"`
+	Commit string `toml:"commit"`
+}
+
+// LockedData is the SHA-256 checksum of a fetched API response
+type LockedData struct {
+	URL    string `toml:"url"`
+	SHA256 string `toml:"sha256"`
+}
+
+// loadLockFile reads herocgo.lock, returning an empty lock when it does not exist
+func loadLockFile() (*LockFile, error) {
+	lock := &LockFile{}
+	data, err := os.ReadFile(lockFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", lockFileName, err)
+	}
+	if err := toml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", lockFileName, err)
+	}
+	return lock, nil
+}
+
+// save writes the lock file with entries in a stable order
+func (l *LockFile) save() error {
+	sort.Slice(l.Modules, func(i, j int) bool { return l.Modules[i].Path < l.Modules[j].Path })
+	sort.Slice(l.Themes, func(i, j int) bool { return l.Themes[i].Path < l.Themes[j].Path })
+	sort.Slice(l.Data, func(i, j int) bool { return l.Data[i].URL < l.Data[j].URL })
+	data, err := toml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %w", lockFileName, err)
+	}
+	header := "# Generated by herocgo. Do not edit; run `mod update` to change locked versions.\n\n"
+	return os.WriteFile(lockFileName, append([]byte(header), data...), 0644)
+}
+
+// module returns the locked entry of a module import, or nil
+func (l *LockFile) module(m ModuleImport) *LockedModule {
+	for i := range l.Modules {
+		if l.Modules[i].Path == m.Path && l.Modules[i].Ref == m.Ref {
+			return &l.Modules[i]
+		}
+	}
+	return nil
+}
+
+// setModule records the commit a module import resolved to and reports whether the lock changed
+func (l *LockFile) setModule(m ModuleImport, commit string) bool {
+	if locked := l.module(m); locked != nil {
+		changed := locked.Commit != commit
+		locked.Commit = commit
+		return changed
+	}
+	// Drop a stale entry for the same repository locked at another ref
+	for i := range l.Modules {
+		if l.Modules[i].Path == m.Path {
+			l.Modules = append(l.Modules[:i], l.Modules[i+1:]...)
+			break
+		}
+	}
+	l.Modules = append(l.Modules, LockedModule{Path: m.Path, Ref: m.Ref, Commit: commit})
+	return true
+}
+
+// theme returns the locked theme whose path or repository name is name, or nil
+func (l *LockFile) theme(name string) *LockedModule {
+	for i := range l.Themes {
+		if l.Themes[i].Path == name || themeName(l.Themes[i].Path) == name {
+			return &l.Themes[i]
+		}
+	}
+	return nil
+}
+
+// setTheme records the version and commit of a fetched theme, replacing an earlier version
+func (l *LockFile) setTheme(m ModuleImport, commit string) {
+	for i := range l.Themes {
+		if l.Themes[i].Path == m.Path {
+			l.Themes[i] = LockedModule{Path: m.Path, Ref: m.Ref, Commit: commit}
+			return
+		}
+	}
+	l.Themes = append(l.Themes, LockedModule{Path: m.Path, Ref: m.Ref, Commit: commit})
+}
+
+// data returns the locked checksum of an API response, or "" when it is not locked
+func (l *LockFile) data(apiURL string) string {
+	for _, locked := range l.Data {
+		if locked.URL == apiURL {
+			return locked.SHA256
+		}
+	}
+	return ""
+}
+
+// setData records the checksum of an API response and reports whether the lock changed
+func (l *LockFile) setData(apiURL, sum string) bool {
+	for i := range l.Data {
+		if l.Data[i].URL == apiURL {
+			changed := l.Data[i].SHA256 != sum
+			l.Data[i].SHA256 = sum
+			return changed
+		}
+	}
+	l.Data = append(l.Data, LockedData{URL: apiURL, SHA256: sum})
+	return true
+}
+
+// pruneData removes the responses that were not requested and reports whether any were removed
+func (l *LockFile) pruneData(requested map[string]bool) bool {
+	kept := l.Data[:0]
+	for _, locked := range l.Data {
+		if requested[locked.URL] {
+			kept = append(kept, locked)
+		}
+	}
+	removed := len(kept) != len(l.Data)
+	l.Data = kept
+	return removed
+}
+
+// prune removes entries for modules that are no longer imported and reports whether any were removed
+func (l *LockFile) prune(imports []ModuleImport) bool {
+	kept := l.Modules[:0]
+	for _, locked := range l.Modules {
+		for _, m := range imports {
+			if m.Path == locked.Path && m.Ref == locked.Ref {
+				kept = append(kept, locked)
+				break
+			}
+		}
+	}
+	removed := len(kept) != len(l.Modules)
+	l.Modules = kept
+	return removed
+}
+
+// verifyModules checks the module checkouts against herocgo.lock before a build.
+// With frozen set any difference is an error; otherwise the lock file is brought up to date,
+// unless this is a dry run or a safe build, which writes nothing outside of the output.
+func verifyModules(config Config, frozen, dryRun bool) error {
+	lock, err := loadLockFile()
+	if err != nil {
+		return err
+	}
+	changed := lock.prune(config.Module.Imports)
+	for _, m := range config.Module.Imports {
+		commit, err := checkoutCommit(m.dir())
+		if err != nil {
+			return fmt.Errorf("module %s is not downloaded, run `mod get`", m.Path)
+		}
+		if lock.setModule(m, commit) {
+			changed = true
+			if frozen {
+				return fmt.Errorf("module %s@%s is at %s which does not match %s", m.Path, m.Ref, commit, lockFileName)
+			}
+		}
+	}
+	if changed && frozen {
+		return fmt.Errorf("%s lists modules that are no longer imported", lockFileName)
+	}
+	if changed && dryRun {
+		log.Printf("Would update %s to the checked out module commits", lockFileName)
+		return nil
+	}
+	if changed && safeMode {
+		warnf("%s does not match the checked out module commits and is not updated by --safe", lockFileName)
+		return nil
+	}
+	if changed {
+		log.Printf("Updated %s to the checked out module commits", lockFileName)
+		return lock.save()
+	}
+	return nil
+}
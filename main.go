@@ -1,227 +1,663 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"html"
-	"io"          // Ensure io is imported for io.Copy
+	"io" // Ensure io is imported for io.Copy
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
-	"sync"
-	"text/template"
+	"syscall"
 	"time"
 
 	"github.com/pelletier/go-toml/v2"
-	"gopkg.in/yaml.v3"
-	"github.com/yuin/goldmark"
 )
 
-// Structs for front matter and configuration
-type FrontMatter struct {
-	Title       string `yaml:"title" toml:"title"`
-	Description string `yaml:"description" toml:"description"`
-	Date        string `yaml:"date" toml:"date"`
+// Config is the site configuration read from config.toml
+type Config struct {
+	Title        string            `toml:"title"`
+	BaseURL      string            `toml:"baseURL"`
+	Theme        string            `toml:"theme"`
+	LanguageCode string            `toml:"languageCode"`
+	Description  string            `toml:"description"`
+	DateFormat   string            `toml:"dateFormat"`
+	Params       map[string]any    `toml:"params"`
+	Taxonomies   map[string]string `toml:"taxonomies"`
+	Slugs        string            `toml:"slugs"`
+
+	Paginate      int              `toml:"paginate"` // deprecated: pagination.pagerSize
+	Pagination    PaginationConfig `toml:"pagination"`
+	RSSLimit      int              `toml:"rssLimit"`
+	TermFeeds     bool             `toml:"termFeeds"`
+	JSONFeed      JSONFeedConfig   `toml:"jsonFeed"`
+	OPML          bool             `toml:"opml"`
+	Archives      bool             `toml:"archives"`
+	CustomOutputs []CustomOutput   `toml:"customOutputs"`
+	Outputs       OutputsConfig    `toml:"outputs"`
+	Export        ExportConfig     `toml:"export"`
+	Slides        SlidesConfig     `toml:"slides"`
+	Changelog     ChangelogConfig  `toml:"changelog"`
+	RepoData      RepoDataConfig   `toml:"repoData"`
+	TTS           TTSConfig        `toml:"tts"`
+	IndieWeb      IndieWebConfig   `toml:"indieweb"`
+	Analytics     AnalyticsConfig  `toml:"analytics"`
+	Server        ServerConfig     `toml:"server"`
+	CSP           CSPConfig        `toml:"csp"`
+	Links         LinksConfig      `toml:"links"`
+	Imaging       ImagingConfig    `toml:"imaging"`
+	// Podcasts publishes sections as podcasts, keyed by section name
+	Podcasts map[string]PodcastConfig `toml:"podcasts"`
+
+	EnableGitInfo bool              `toml:"enableGitInfo"`
+	Repository    RepositoryConfig  `toml:"repository"`
+	FrontMatter   FrontMatterConfig `toml:"frontmatter"`
+	Module        ModuleConfig      `toml:"module"`
+	Limits        LimitsConfig      `toml:"limits"`
+	Fingerprint   FingerprintConfig `toml:"fingerprint"`
+	PWA           PWAConfig         `toml:"pwa"`
+	Compress      CompressConfig    `toml:"compress"`
+	Lint          LintConfig        `toml:"lint"`
+	A11y          A11yConfig        `toml:"a11y"`
+	OGImage       OGImageConfig     `toml:"ogImage"`
+	CJK           CJKConfig         `toml:"cjk"`
+	Locations     LocationsConfig   `toml:"locations"`
+	Unlisted      UnlistedConfig    `toml:"unlisted"`
+	Mounts        []Mount           `toml:"mounts"`
+	// ContentAdapters generate pages from the entries of data files
+	ContentAdapters []ContentAdapter  `toml:"contentAdapters"`
+	Preview         PreviewConfig     `toml:"preview"`
+	Daemon          DaemonConfig      `toml:"daemon"`
+	Build           BuildConfig       `toml:"build"`
+	ActivityPub     ActivityPubConfig `toml:"activityPub"`
+	ArchiveRules    []ArchiveRule     `toml:"archiveRules"`
+	// Timeout aborts a build that takes longer, e.g. "60s"; there is no limit by default
+	Timeout string `toml:"timeout"`
 }
 
-type Config struct {
-	Title   string `toml:"title"`
-	BaseURL string `toml:"baseURL"`
-	Theme   string `toml:"theme"`
+// cacheDir holds generated files that are reused between builds
+const cacheDir = ".herocgo/cache"
+
+// scratchCacheDir receives the cache entries of a dry run, which leaves cacheDir unchanged
+var scratchCacheDir string
+
+// cacheReadPath returns the cache file of a slash-separated name such as "images/<hash>.png",
+// preferring the entry a dry run created in its scratch cache
+func cacheReadPath(name string) string {
+	if scratchCacheDir != "" {
+		scratch := filepath.Join(scratchCacheDir, filepath.FromSlash(name))
+		if _, err := os.Stat(scratch); err == nil {
+			return scratch
+		}
+	}
+	return filepath.Join(cacheDir, filepath.FromSlash(name))
+}
+
+// cacheWritePath returns the file a new cache entry is written to
+func cacheWritePath(name string) string {
+	if scratchCacheDir != "" {
+		return filepath.Join(scratchCacheDir, filepath.FromSlash(name))
+	}
+	return filepath.Join(cacheDir, filepath.FromSlash(name))
+}
+
+// taxonomyNames returns the plural taxonomy names, defaulting to tags and categories
+func (c Config) taxonomyNames() []string {
+	if c.Taxonomies == nil {
+		return []string{"categories", "tags"}
+	}
+	names := make([]string, 0, len(c.Taxonomies))
+	for _, plural := range c.Taxonomies {
+		names = append(names, plural)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func main() {
-	// Load configuration
-	config, err := loadConfig("config.toml")
+	// Subcommands come first; without one the site is built
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		switch os.Args[1] {
+		case "mod":
+			runMod(os.Args[2:])
+		case "daemon":
+			runDaemon(os.Args[2:])
+		case "diff":
+			runDiff(os.Args[2:])
+		case "export":
+			runExport(os.Args[2:])
+		case "fm":
+			runFrontMatter(os.Args[2:])
+		case "graph":
+			runGraph(os.Args[2:])
+		case "id":
+			runID(os.Args[2:])
+		case "i18n":
+			runI18n(os.Args[2:])
+		case "import":
+			runImport(os.Args[2:])
+		case "lint":
+			runLint(os.Args[2:])
+		case "list":
+			runList(os.Args[2:])
+		case "migrate":
+			runMigrate(os.Args[2:])
+		case "new":
+			runNew(os.Args[2:])
+		case "pack":
+			runPack(os.Args[2:])
+		case "preview":
+			runPreview(os.Args[2:])
+		case "serve":
+			runServe(os.Args[2:])
+		case "stats":
+			runStats(os.Args[2:])
+		case "verify":
+			runVerify(os.Args[2:])
+		default:
+			log.Fatalf("Unknown command: %s", os.Args[1])
+		}
+		return
+	}
+	runBuild(os.Args[1:])
+}
+
+// runBuild builds the site into the public directory
+func runBuild(args []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	opts := buildOptions{PublicDir: "./public/"}
+	flags.StringVar(&opts.Environment, "environment", envOr("HERO_ENVIRONMENT", "production"), "build environment exposed to templates as hero.Environment")
+	flags.BoolVar(&opts.Frozen, "frozen", false, "fail instead of updating "+lockFileName+" when a remote dependency changed")
+	flags.StringVar(&opts.LinkMode, "links", "", "rewrite internal links: absolute, relative or cdn (overrides links.mode)")
+	flags.BoolVar(&opts.Safe, "safe", false, "build an untrusted site: no external commands, symlinks or files outside the project")
+	flags.BoolVar(&opts.KeepGoing, "keep-going", false, "write an error page in place of each page that fails")
+	flags.BoolVar(&opts.Strict, "strict", false, "fail the build when a warning or error is logged, e.g. for CI")
+	dryRun := flags.Bool("dry-run", false, "build without writing public/ and list the files that would change")
+	flags.BoolVar(&opts.TemplateMetrics, "templateMetrics", false, "report the execution count and cumulative time of every template")
+	flags.BoolVar(&opts.ValidateHTML, "validate-html", false, "report unclosed tags, nesting violations and duplicate ids of every page with the template that wrote them")
+	publishFlags(flags, &opts)
+	contentFilterFlags(flags, &opts)
+	flags.Parse(args)
+
+	// Ctrl-C stops the build and reports the work in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *dryRun {
+		runDryRun(ctx, opts)
+		return
+	}
+	site, stats, err := buildSite(ctx, opts)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		log.Fatalf("Failed to build site: %v", err)
+	}
+	if site.metrics != nil {
+		site.metrics.report(os.Stdout)
 	}
 
-	// Validate configuration
-	themeDir := filepath.Join("themes", config.Theme)
-	if _, err := os.Stat(themeDir); os.IsNotExist(err) {
-		log.Fatalf("Theme directory does not exist: %s", themeDir)
+	// Print build statistics
+	fmt.Println("--- Build Statistics ---")
+	fmt.Printf("Total Pages: %d\n", stats.Pages)
+	fmt.Printf("Non-page Files: %d\n", stats.NonPageFiles)
+	fmt.Printf("Feeds: %d\n", stats.Feeds)
+	fmt.Printf("Custom Outputs: %d\n", stats.CustomOutputs)
+	if stats.PrintPages > 0 {
+		fmt.Printf("Print Pages: %d\n", stats.PrintPages)
 	}
+	if stats.AMPPages > 0 {
+		fmt.Printf("AMP Pages: %d\n", stats.AMPPages)
+	}
+	fmt.Printf("Total Build Time: %v\n", stats.Duration)
+}
 
-	postsDir := "./content/"
-	publicDir := "./public/"
+// loadSite reads the configuration of the environment and loads the content and module files
+// into a new site, returning it with the count of non-page content files
+func loadSite(opts buildOptions) (*Site, int, error) {
+	config, err := loadSiteConfig(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return loadSiteContent(context.Background(), config, opts)
+}
 
-	// Create output directory
-	if err := os.MkdirAll(publicDir, os.ModePerm); err != nil {
-		log.Fatalf("Failed to create public directory: %v", err)
+// loadSiteConfig reads the configuration of the environment with the overrides of the options
+func loadSiteConfig(opts buildOptions) (Config, error) {
+	config, err := loadEnvironmentConfig(opts.Environment)
+	if err != nil {
+		return config, fmt.Errorf("failed to load config: %w", err)
+	}
+	if opts.BaseURL != "" {
+		config.BaseURL = opts.BaseURL
 	}
+	safeMode = opts.Safe
+	if opts.Safe {
+		applySafeMode(&config)
+	}
+	return config, nil
+}
 
-	// Prepare build statistics
-	var totalPages, nonPageFiles int
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-	start := time.Now()
+// loadSiteContent loads the content and module files into a new site with the configuration.
+// The git commands and API requests of loading stop when ctx ends, which becomes the context
+// of the site.
+func loadSiteContent(ctx context.Context, config Config, opts buildOptions) (*Site, int, error) {
+	resetMountRoots()
+	postsDir := "./content/"
+	files, nonPageFiles, err := collectContent(postsDir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read content directory: %w", err)
+	}
+	if err := verifyModules(config, opts.Frozen, opts.DryRun); err != nil {
+		return nil, 0, fmt.Errorf("failed to verify modules: %w", err)
+	}
+	mountedFiles, mountedNonPageFiles, err := moduleContent(config)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read module content: %w", err)
+	}
+	files = append(files, mountedFiles...)
+	mountedFiles, mountedNonPages, err := mountedContent(config)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read mounted content: %w", err)
+	}
+	files = append(files, mountedFiles...)
+	mountedNonPageFiles += mountedNonPages
+	adapterFiles, err := adapterContent(config)
+	if err != nil {
+		return nil, 0, err
+	}
+	files = append(files, adapterFiles...)
+	releases, err := loadReleases(ctx, config.Changelog)
+	if err != nil {
+		return nil, 0, err
+	}
+	if config.Changelog.Source != "" {
+		changelog, err := changelogContent(config, releases)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create changelog page: %w", err)
+		}
+		files = append(files, changelog)
+	}
+	filter := config.Build.Filter
+	if len(opts.ContentInclude) > 0 || len(opts.ContentExclude) > 0 {
+		filter = ContentFilter{Include: opts.ContentInclude, Exclude: opts.ContentExclude}
+	}
+	if files, err = filterContent(files, filter); err != nil {
+		return nil, 0, err
+	}
 
-	files, err := os.ReadDir(postsDir)
-	if err != nil {
-		log.Fatalf("Failed to read content directory: %v", err)
-	}
-
-	// Process each file concurrently
-	for _, file := range files {
-		wg.Add(1)
-		go func(file os.DirEntry) {
-			defer wg.Done()
-			if filepath.Ext(file.Name()) == ".md" {
-				if err := processMarkdownFile(filepath.Join(postsDir, file.Name()), publicDir, themeDir); err != nil {
-					log.Printf("Failed to process file %s: %v", file.Name(), err)
-				} else {
-					mu.Lock()
-					totalPages++
-					mu.Unlock()
-				}
-			} else {
-				mu.Lock()
-				nonPageFiles++
-				mu.Unlock()
-			}
-		}(file)
+	site := newSite(config)
+	site.ctx = ctx
+	site.options = opts
+	if opts.TemplateMetrics {
+		site.metrics = newTemplateMetrics()
+	}
+	if opts.ValidateHTML {
+		site.validator = newHTMLValidator()
+	}
+	site.Hero = newHeroInfo(opts.Environment)
+	site.Releases = releases
+	site.checkConfigDeprecations(configFiles(opts.Environment))
+	if config.EnableGitInfo {
+		if err := site.useGitInfo(postsDir); err != nil {
+			warnf("Git info unavailable: %v", err)
+		}
+	}
+	site.loadContent(files)
+	site.setBacklinks()
+	if err := site.loadComments(); err != nil {
+		warnf("%v", err)
 	}
+	if err := site.loadRepoData(); err != nil {
+		return nil, 0, err
+	}
+	return site, nonPageFiles + mountedNonPageFiles, nil
+}
 
-	// Wait for all goroutines to finish
-	wg.Wait()
+// buildOptions controls a single build of the site
+type buildOptions struct {
+	Environment string
+	Frozen      bool
+	PublicDir   string
 
-	// Copy theme static files to public directory
-	if err := copyStaticFiles(themeDir, publicDir); err != nil {
-		log.Printf("Failed to copy static files: %v", err)
-	}
+	// BaseURL overrides the configured base URL, as the development server does
+	BaseURL string
 
-	// Print build statistics
-	fmt.Println("--- Build Statistics ---")
-	fmt.Printf("Total Pages: %d\n", totalPages)
-	fmt.Printf("Non-page Files: %d\n", nonPageFiles)
-	fmt.Printf("Total Build Time: %v\n", time.Since(start))
+	// Drafts, pages with a future publish date and expired pages are skipped unless enabled
+	BuildDrafts  bool
+	BuildFuture  bool
+	BuildExpired bool
+
+	// LinkMode overrides the configured links.mode
+	LinkMode string
+
+	// Safe disables external commands and reads outside of the project, see safeMode
+	Safe bool
+
+	// KeepGoing replaces pages that fail to load or render with a page showing the error
+	KeepGoing bool
+
+	// Strict fails the build when a warning or failure was logged
+	Strict bool
+
+	// DryRun leaves herocgo.lock unchanged; the dry run builds the site in a scratch directory
+	DryRun bool
+
+	// TemplateMetrics records the executions and time of every template
+	TemplateMetrics bool
+	// ValidateHTML checks the structure of every rendered page
+	ValidateHTML bool
+
+	// ContentInclude and ContentExclude replace the patterns of build.filter when set
+	ContentInclude []string
+	ContentExclude []string
 }
 
-// loadConfig reads and parses the configuration file
-func loadConfig(path string) (Config, error) {
-	var config Config
-	data, err := os.ReadFile(path)
+// buildStats counts what a build produced
+type buildStats struct {
+	Pages         int
+	NonPageFiles  int
+	Feeds         int
+	CustomOutputs int
+	PrintPages    int
+	AMPPages      int
+	Duration      time.Duration
+}
+
+// buildSite loads the configuration and content and writes the site into opts.PublicDir.
+// The build stops when ctx ends or the configured timeout passes, reporting the work in flight;
+// a template that never returns is abandoned.
+func buildSite(ctx context.Context, opts buildOptions) (*Site, buildStats, error) {
+	var stats buildStats
+
+	// Prepare build statistics
+	start := time.Now()
+	var logs *logCounter
+	if opts.Strict {
+		var restore func()
+		logs, restore = countLogs()
+		defer restore()
+	}
+
+	// The timeout covers loading, which runs git and fetches repository data, as well as writing
+	config, err := loadSiteConfig(opts)
+	if err != nil {
+		return nil, stats, err
+	}
+	timeout, err := buildTimeout(config.Timeout)
 	if err != nil {
-		return config, fmt.Errorf("could not read config: %w", err)
+		return nil, stats, err
 	}
-	if err := toml.Unmarshal(data, &config); err != nil {
-		return config, fmt.Errorf("could not parse config: %w", err)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, start.Add(timeout))
+		defer cancel()
 	}
-	return config, nil
+	ctx, tasks := withBuildTasks(ctx)
+
+	// Load every page, then render them concurrently
+	type result struct {
+		site         *Site
+		nonPageFiles int
+		stats        buildStats
+		err          error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		r.site, r.nonPageFiles, r.err = loadSiteContent(ctx, config, opts)
+		if r.err == nil {
+			r.stats, r.err = r.site.write(opts.PublicDir)
+		}
+		done <- r
+	}()
+	var r result
+	select {
+	case r = <-done:
+		if r.err == nil && ctx.Err() != nil {
+			r.err = abortedBuild(ctx, tasks, timeout)
+		}
+		if r.err != nil {
+			return nil, stats, r.err
+		}
+	case <-ctx.Done():
+		err := abortedBuild(ctx, tasks, timeout)
+		// Every step stops once the context ends and external commands are killed, so wait for
+		// the writer to keep it from changing the output after the build returned; only a
+		// template that never returns is abandoned
+		select {
+		case <-done:
+		case <-time.After(abandonAfter):
+		}
+		return nil, stats, err
+	}
+	site, stats := r.site, r.stats
+	if !opts.KeepGoing {
+		if err := site.raisedErr(); err != nil {
+			return nil, stats, err
+		}
+	}
+	if logs != nil {
+		if err := logs.err(); err != nil {
+			return nil, stats, err
+		}
+	}
+	stats.NonPageFiles = r.nonPageFiles
+	stats.Duration = time.Since(start)
+	return site, stats, nil
 }
 
-// processMarkdownFile reads a Markdown file, parses front matter, converts content, and writes an HTML file
-func processMarkdownFile(filePath, outputDir, themeDir string) error {
-	content, err := os.ReadFile(filePath)
+// write renders the loaded site into publicDir, stopping between steps once the build context ends
+func (s *Site) write(publicDir string) (buildStats, error) {
+	var stats buildStats
+	config := s.Config
+
+	// Validate configuration
+	themeDir, err := resolveThemeDir(config)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return stats, fmt.Errorf("failed to resolve theme: %w", err)
+	}
+	if _, err := os.Stat(themeDir); os.IsNotExist(err) {
+		return stats, fmt.Errorf("theme directory does not exist: %s", themeDir)
+	}
+
+	// Create output directory
+	if err := os.MkdirAll(publicDir, os.ModePerm); err != nil {
+		return stats, fmt.Errorf("failed to create public directory: %w", err)
 	}
 
-	frontMatter, markdownContent, err := extractFrontMatter(content)
+	// Static files are known before rendering so templates can fingerprint them
+	if s.staticDirs, err = siteStaticDirs(config, themeDir); err != nil {
+		return stats, err
+	}
+	s.icons.dirs = []string{filepath.Join("assets", "icons"), filepath.Join(themeDir, "assets", "icons")}
+	if s.translations, err = loadTranslations(themeDir, s.Lang()); err != nil {
+		warnf("%v", err)
+	}
+	s.processCovers(publicDir)
+	s.processImages(publicDir)
+	s.runPageHooks(s.ttsHook(), s.ampHook())
+	layoutMounts, err := mountDirs(config, mountLayouts)
 	if err != nil {
-		log.Printf("Warning: Malformed front matter in %s: %v", filePath, err)
-		// Set front matter to default values if parsing fails
-		frontMatter = FrontMatter{}
+		return stats, fmt.Errorf("failed to mount layouts: %w", err)
+	}
+	templates := newTemplateCache(themeDir, layoutMounts, s)
+	s.templates = templates
+	s.generateOGImages(publicDir, templates)
+	s.setPrintLinks()
+	stats.Pages = s.render(publicDir, templates)
+	if s.validator != nil {
+		s.validator.report()
+	}
+	if s.options.KeepGoing {
+		s.renderLoadErrors(publicDir)
+	}
+	if err := s.ctx.Err(); err != nil {
+		return stats, err
 	}
+	if err := s.renderAliases(publicDir); err != nil {
+		failf("Failed to write aliases: %v", err)
+	}
+	stats.PrintPages = s.renderPrintPages(publicDir, templates)
+	stats.AMPPages = s.renderAMPPages(publicDir, templates)
 
-	htmlContent, err := convertMarkdownToHTML(markdownContent)
+	stats.Feeds, err = s.renderFeeds(publicDir)
+	if err != nil {
+		failf("Failed to render feeds: %v", err)
+	}
+	podcasts, err := s.renderPodcasts(publicDir)
+	stats.Feeds += podcasts
+	if err != nil {
+		failf("Failed to render podcasts: %v", err)
+	}
+	calendars, err := s.renderCalendars(publicDir)
+	stats.Feeds += calendars
 	if err != nil {
-		return fmt.Errorf("failed to convert Markdown: %w", err)
+		failf("Failed to render calendars: %v", err)
+	}
+	locations, err := s.renderLocations(publicDir)
+	stats.Feeds += locations
+	if err != nil {
+		failf("Failed to render locations: %v", err)
 	}
 
-		outputFileName := filepath.Base(filePath[:len(filePath)-len(filepath.Ext(filePath))]) + ".html"
-	outputPath := filepath.Join(outputDir, outputFileName)
+	if err := s.renderSchedule(publicDir); err != nil {
+		failf("Failed to write schedule: %v", err)
+	}
 
-	if err := writeHTMLFile(outputPath, frontMatter, htmlContent, themeDir); err != nil {
-		return fmt.Errorf("failed to write HTML file: %w", err)
+	if config.OPML {
+		if err := s.renderOPML(publicDir); err != nil {
+			failf("Failed to write OPML: %v", err)
+		}
+	}
+	if err := s.renderWebmentions(publicDir); err != nil {
+		failf("Failed to export webmentions: %v", err)
+	}
+	if err := s.renderActivityPub(publicDir); err != nil {
+		failf("Failed to write ActivityPub documents: %v", err)
 	}
 
-	return nil
-}
+	// Render standalone outputs such as manifests and JSON feeds
+	stats.CustomOutputs, err = s.renderCustomOutputs(publicDir, themeDir, templates.funcs)
+	if err != nil {
+		failf("Failed to render custom outputs: %v", err)
+	}
 
-// extractFrontMatter separates the front matter from the Markdown content
-func extractFrontMatter(content []byte) (FrontMatter, []byte, error) {
-	var fm FrontMatter
-	contentStr := string(content)
+	if err := s.ctx.Err(); err != nil {
+		return stats, err
+	}
 
-	if strings.HasPrefix(contentStr, "---") || strings.HasPrefix(contentStr, "+++") {
-		var parts []string
-		if strings.HasPrefix(contentStr, "---") {
-			parts = strings.SplitN(contentStr, "\n---\n", 2)
-		} else {
-			parts = strings.SplitN(contentStr, "\n+++\n", 2)
+	// Copy theme, module and mounted static files to public directory
+	for _, staticDir := range s.staticDirs {
+		if err := copyStaticFiles(staticDir.dir, publicDir, staticDir.prefix); err != nil {
+			failf("Failed to copy static files: %v", err)
 		}
+	}
+	// Stylesheets are purged before the fingerprinted ones are written, whose hash changes
+	if err := s.purgeStylesheets(publicDir); err != nil {
+		failf("Failed to purge CSS: %v", err)
+	}
+	if err := s.writeFingerprintedAssets(publicDir); err != nil {
+		failf("Failed to write fingerprinted assets: %v", err)
+	}
+	if err := s.inlineCriticalCSS(publicDir, nil); err != nil {
+		failf("Failed to inline critical CSS: %v", err)
+	}
 
-		if len(parts) == 2 {
-			meta := strings.Trim(parts[0], "-+ \n")
-			body := parts[1]
+	if err := s.rewriteLinks(publicDir); err != nil {
+		failf("Failed to rewrite links: %v", err)
+	}
+	// The precache revisions hash the final output
+	if err := s.renderPWA(publicDir, templates); err != nil {
+		failf("Failed to write PWA files: %v", err)
+	}
 
-			if strings.HasPrefix(contentStr, "---") {
-				if err := yaml.Unmarshal([]byte(meta), &fm); err != nil {
-					return fm, []byte(body), fmt.Errorf("failed to parse YAML front matter: %w", err)
-				}
-			} else {
-				if err := toml.Unmarshal([]byte(meta), &fm); err != nil {
-					return fm, []byte(body), fmt.Errorf("failed to parse TOML front matter: %w", err)
-				}
-			}
-			return fm, []byte(body), nil
+	// Headers come last so the CSP hashes cover every HTML file of the output
+	if err := s.renderHeaders(publicDir); err != nil {
+		failf("Failed to write headers: %v", err)
+	}
+	if err := s.ctx.Err(); err != nil {
+		return stats, err
+	}
+	if err := s.auditAccessibility(publicDir); err != nil {
+		return stats, err
+	}
+	if _, err := s.precompress(publicDir); err != nil {
+		failf("Failed to precompress output: %v", err)
+	}
+	if config.Build.Manifest {
+		if err := s.writeArtifactManifest(publicDir); err != nil {
+			failf("Failed to write artifact manifest: %v", err)
 		}
-		return fm, content, fmt.Errorf("no valid front matter delimiter found")
 	}
-	return fm, content, nil
+
+	s.reportDeprecations()
+	return stats, nil
 }
 
-// convertMarkdownToHTML converts Markdown to HTML using goldmark
-func convertMarkdownToHTML(content []byte) (string, error) {
-	md := goldmark.New()
-	var buf strings.Builder
-	if err := md.Convert(content, &buf); err != nil {
-		return "", err
+// envOr returns the environment variable or the fallback when it is unset
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
-	return buf.String(), nil
+	return fallback
 }
 
-// writeHTMLFile creates an HTML file with escaped title and description to prevent XSS
-func writeHTMLFile(outputPath string, fm FrontMatter, htmlContent, themeDir string) error {
-	tmplPath := filepath.Join(themeDir, "templates", "base.html")
-	tmpl, err := template.ParseFiles(tmplPath)
-	if err != nil {
-		return fmt.Errorf("failed to load template: %w", err)
-	}
+// loadConfig reads and parses the configuration file
+func loadConfig(path string) (Config, error) {
+	var config Config
+	return config, overlayConfig(&config, path)
+}
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create HTML file: %w", err)
+// configFiles returns config.toml followed by the overlay of the environment, config.<environment>.toml, if it exists
+func configFiles(environment string) []string {
+	files := []string{"config.toml"}
+	overlay := "config." + environment + ".toml"
+	if _, err := os.Stat(overlay); environment != "" && err == nil {
+		files = append(files, overlay)
 	}
-	defer file.Close()
+	return files
+}
 
-	data := struct {
-		Title       string
-		Description string
-		Content     string
-	}{
-		Title:       html.EscapeString(fm.Title),
-		Description: html.EscapeString(fm.Description),
-		Content:     htmlContent,
+// loadEnvironmentConfig reads config.toml and applies the environment overlay on top of it.
+// Overlay values replace those of config.toml; tables such as [params] are merged key by key.
+func loadEnvironmentConfig(environment string) (Config, error) {
+	var config Config
+	for _, path := range configFiles(environment) {
+		if err := overlayConfig(&config, path); err != nil {
+			return config, err
+		}
 	}
+	return config, nil
+}
 
-	if err := tmpl.Execute(file, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+// overlayConfig parses a configuration file into config, keeping values the file does not set
+func overlayConfig(config *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config: %w", err)
+	}
+	if err := toml.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("could not parse %s: %w", path, err)
 	}
 	return nil
 }
 
-// copyStaticFiles copies static files from the theme directory to the public directory
-func copyStaticFiles(themeDir, publicDir string) error {
-	staticDir := filepath.Join(themeDir, "static")
+// copyStaticFiles copies static files from a static directory to prefix in the public directory
+func copyStaticFiles(staticDir, publicDir, prefix string) error {
 	return filepath.Walk(staticDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if !info.IsDir() {
-			dest := filepath.Join(publicDir, strings.TrimPrefix(path, staticDir))
+			rel, err := filepath.Rel(staticDir, path)
+			if err != nil {
+				return err
+			}
+			dest, err := outputFile(publicDir, filepath.ToSlash(filepath.Join(filepath.FromSlash(prefix), rel)))
+			if err != nil {
+				return err
+			}
 			if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
 				return err
 			}
@@ -235,6 +671,9 @@ func copyStaticFiles(themeDir, publicDir string) error {
 
 // copyFile is a helper to copy files from source to destination
 func copyFile(src, dest string) (int64, error) {
+	if err := checkReadPath(src); err != nil {
+		return 0, err
+	}
 	sourceFile, err := os.Open(src)
 	if err != nil {
 		return 0, err
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mediaTypes types the audio and video files missing from the builtin MIME table of Go, so bundle
+// media get the same type whatever the mime.types of the build machine
+var mediaTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".flac": "audio/flac",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mov":  "video/quicktime",
+	".webm": "video/webm",
+	".ogv":  "video/ogg",
+}
+
+// Size returns the size of the resource file in bytes
+func (r *Resource) Size() int64 {
+	return fileSize(r.SourcePath)
+}
+
+// Duration returns the playing time of an audio or video resource: the `duration` resource
+// param ("1:02:03" or seconds) or the length read from MP3, MP4/M4A, WAV or Ogg files
+func (r *Resource) Duration() time.Duration {
+	r.mediaOnce.Do(func() {
+		if v, ok := r.Params["duration"]; ok {
+			r.duration = parseDuration(fmt.Sprint(v))
+			return
+		}
+		if (r.ResourceType != "audio" && r.ResourceType != "video") || r.SourcePath == "" || checkReadPath(r.SourcePath) != nil {
+			return
+		}
+		file, err := os.Open(r.SourcePath)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		r.duration, _ = mediaDuration(file, fileSize(r.SourcePath))
+	})
+	return r.duration
+}
+
+// DurationString formats the duration as H:MM:SS, or M:SS under an hour, as podcast apps show it
+func (r *Resource) DurationString() string {
+	return formatDuration(r.Duration())
+}
+
+// formatDuration formats a duration as H:MM:SS, or M:SS under an hour
+func formatDuration(d time.Duration) string {
+	total := int(d.Round(time.Second).Seconds())
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// parseDuration reads "H:MM:SS", "M:SS" or a number of seconds
+func parseDuration(s string) time.Duration {
+	var seconds float64
+	for _, part := range strings.Split(strings.TrimSpace(s), ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// mediaDuration reads the playing time from the container or stream headers of a media file
+func mediaDuration(r io.ReadSeeker, size int64) (time.Duration, error) {
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	switch {
+	case string(head[4:8]) == "ftyp":
+		return mp4Duration(r, size)
+	case string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return wavDuration(r)
+	case string(head[:4]) == "OggS":
+		return oggDuration(r, size)
+	case string(head[:3]) == "ID3" || (head[0] == 0xff && head[1]&0xe0 == 0xe0):
+		return mp3Duration(r, size)
+	}
+	return 0, fmt.Errorf("unknown media format")
+}
+
+// mp4Duration reads the duration of the movie header (moov/mvhd) of an MP4, M4A or MOV file
+func mp4Duration(r io.ReadSeeker, size int64) (time.Duration, error) {
+	var walk func(start, end int64) (time.Duration, error)
+	walk = func(start, end int64) (time.Duration, error) {
+		header := make([]byte, 16)
+		for at := start; at+8 <= end; {
+			if _, err := r.Seek(at, io.SeekStart); err != nil {
+				return 0, err
+			}
+			if _, err := io.ReadFull(r, header[:8]); err != nil {
+				return 0, err
+			}
+			boxSize, kind, headerSize := int64(binary.BigEndian.Uint32(header)), string(header[4:8]), int64(8)
+			switch boxSize {
+			case 1:
+				if _, err := io.ReadFull(r, header[8:16]); err != nil {
+					return 0, err
+				}
+				boxSize, headerSize = int64(binary.BigEndian.Uint64(header[8:])), 16
+			case 0:
+				boxSize = end - at
+			}
+			if boxSize < headerSize {
+				break
+			}
+			switch kind {
+			case "moov":
+				return walk(at+headerSize, at+boxSize)
+			case "mvhd":
+				body := make([]byte, 32)
+				if _, err := io.ReadFull(r, body); err != nil {
+					return 0, err
+				}
+				// Version 1 headers use 64-bit times and durations
+				var timescale, duration uint64
+				if body[0] == 1 {
+					timescale, duration = uint64(binary.BigEndian.Uint32(body[20:])), binary.BigEndian.Uint64(body[24:])
+				} else {
+					timescale, duration = uint64(binary.BigEndian.Uint32(body[12:])), uint64(binary.BigEndian.Uint32(body[16:]))
+				}
+				if timescale == 0 {
+					return 0, fmt.Errorf("invalid timescale")
+				}
+				return time.Duration(float64(duration) / float64(timescale) * float64(time.Second)), nil
+			}
+			at += boxSize
+		}
+		return 0, fmt.Errorf("no movie header")
+	}
+	return walk(0, size)
+}
+
+// wavDuration divides the size of the data chunk by the byte rate of the fmt chunk
+func wavDuration(r io.ReadSeeker) (time.Duration, error) {
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var byteRate uint32
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return 0, err
+		}
+		kind, chunkSize := string(header[:4]), int64(binary.LittleEndian.Uint32(header[4:]))
+		switch kind {
+		case "fmt ":
+			format := make([]byte, 12)
+			if _, err := io.ReadFull(r, format); err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(format[8:])
+			chunkSize -= 12
+		case "data":
+			if byteRate == 0 {
+				return 0, fmt.Errorf("data chunk before fmt chunk")
+			}
+			return time.Duration(float64(chunkSize) / float64(byteRate) * float64(time.Second)), nil
+		}
+		// Chunks are padded to an even size
+		if _, err := r.Seek(chunkSize+chunkSize%2, io.SeekCurrent); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// oggDuration divides the granule position of the last page by the sample rate of the Vorbis or
+// Opus identification header
+func oggDuration(r io.ReadSeeker, size int64) (time.Duration, error) {
+	first := make([]byte, 64)
+	n, _ := io.ReadFull(r, first)
+	first = first[:n]
+	var rate uint32
+	switch {
+	case bytes.Contains(first, []byte("OpusHead")):
+		// Opus granule positions always count 48 kHz samples
+		rate = 48000
+	case bytes.Contains(first, []byte("\x01vorbis")):
+		at := bytes.Index(first, []byte("\x01vorbis")) + 12
+		if at+4 > len(first) {
+			return 0, fmt.Errorf("truncated Vorbis header")
+		}
+		rate = binary.LittleEndian.Uint32(first[at:])
+	}
+	if rate == 0 {
+		return 0, fmt.Errorf("unknown Ogg codec")
+	}
+
+	tail := min(size, 64*1024)
+	if _, err := r.Seek(size-tail, io.SeekStart); err != nil {
+		return 0, err
+	}
+	last := make([]byte, tail)
+	if _, err := io.ReadFull(r, last); err != nil {
+		return 0, err
+	}
+	at := bytes.LastIndex(last, []byte("OggS"))
+	if at < 0 || at+14 > len(last) {
+		return 0, fmt.Errorf("no Ogg page found")
+	}
+	granule := binary.LittleEndian.Uint64(last[at+6:])
+	return time.Duration(float64(granule) / float64(rate) * float64(time.Second)), nil
+}
+
+// mp3Bitrates are the MPEG-1 Layer III bitrates in kbit/s by header index; mp3Bitrates2 those of MPEG-2 and 2.5
+var (
+	mp3Bitrates     = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3Bitrates2    = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mp3SampleRates  = [4]int{44100, 48000, 32000, 0}
+	mp3VersionShift = map[byte]int{3: 0, 2: 1, 0: 2} // MPEG-1, MPEG-2, MPEG-2.5 divide the rate by 1, 2, 4
+)
+
+// mp3Duration reads the frame count of a Xing, Info or VBRI header, and otherwise assumes a
+// constant bitrate over the file after the ID3 tag
+func mp3Duration(r io.ReadSeeker, size int64) (time.Duration, error) {
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, err
+	}
+	var start int64
+	if string(head[:3]) == "ID3" {
+		// The tag size is a 28-bit synchsafe integer
+		start = 10 + (int64(head[6])<<21 | int64(head[7])<<14 | int64(head[8])<<7 | int64(head[9]))
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	frame := make([]byte, 4096)
+	n, _ := io.ReadFull(r, frame)
+	frame = frame[:n]
+	at := -1
+	for i := 0; i+4 <= len(frame); i++ {
+		if frame[i] == 0xff && frame[i+1]&0xe0 == 0xe0 && frame[i+2]>>4 != 0 && frame[i+2]>>4 != 15 {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return 0, fmt.Errorf("no MP3 frame found")
+	}
+	version := frame[at+1] >> 3 & 3
+	shift, ok := mp3VersionShift[version]
+	sampleRate := mp3SampleRates[frame[at+2]>>2&3] >> shift
+	if !ok || sampleRate == 0 {
+		return 0, fmt.Errorf("invalid MP3 frame header")
+	}
+	bitrate := mp3Bitrates[frame[at+2]>>4]
+	samplesPerFrame := 1152
+	if version != 3 {
+		bitrate, samplesPerFrame = mp3Bitrates2[frame[at+2]>>4], 576
+	}
+
+	for _, tag := range []string{"Xing", "Info", "VBRI"} {
+		i := bytes.Index(frame[at:], []byte(tag))
+		if i < 0 || i > 64 {
+			continue
+		}
+		i += at
+		var frames uint32
+		if tag == "VBRI" && i+18 <= len(frame) {
+			frames = binary.BigEndian.Uint32(frame[i+14:])
+		} else if i+12 <= len(frame) && frame[i+7]&1 == 1 {
+			frames = binary.BigEndian.Uint32(frame[i+8:])
+		}
+		if frames > 0 {
+			return time.Duration(float64(frames) * float64(samplesPerFrame) / float64(sampleRate) * float64(time.Second)), nil
+		}
+	}
+	if bitrate == 0 {
+		return 0, fmt.Errorf("free format MP3 has no bitrate")
+	}
+	audio := size - start - int64(at)
+	return time.Duration(float64(audio*8) / float64(bitrate*1000) * float64(time.Second)), nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// modulesDir holds the checkouts of remote content and theme repositories
+const modulesDir = ".herocgo/modules"
+
+// ModuleImport is a remote git repository mounted into the site.
+// Target is "content/<dir>" for content, "static" for static files or "themes/<name>" for a theme.
+type ModuleImport struct {
+	Path   string `toml:"path"`
+	Ref    string `toml:"ref"`
+	Source string `toml:"source"`
+	Target string `toml:"target"`
+}
+
+// ModuleConfig lists the remote repositories assembled into the site
+type ModuleConfig struct {
+	Imports []ModuleImport `toml:"imports"`
+}
+
+// dir returns the cache checkout directory of the module
+func (m ModuleImport) dir() string {
+	sum := sha256.Sum256([]byte(m.Path))
+	name := strings.TrimSuffix(filepath.Base(m.Path), ".git")
+	return filepath.Join(modulesDir, name+"-"+hex.EncodeToString(sum[:6]))
+}
+
+// sourceDir returns the directory of the checkout that is mounted
+func (m ModuleImport) sourceDir() string {
+	return filepath.Join(m.dir(), filepath.FromSlash(m.Source))
+}
+
+// runMod implements `mod get` (fetch missing modules and themes at their locked commits),
+// `mod get <path>@<version>` (fetch a theme at a tag, branch or commit) and `mod update`
+// (move modules and themes to the latest commit of their ref); all record herocgo.lock
+func runMod(args []string) {
+	if len(args) == 0 || (args[0] != "get" && args[0] != "update") || (args[0] == "update" && len(args) > 1) {
+		log.Fatalf("Usage: mod get [<path>@<version>...] | mod update")
+	}
+	config, err := loadConfig("config.toml")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	lock, err := loadLockFile()
+	if err != nil {
+		log.Fatalf("Failed to load lock file: %v", err)
+	}
+
+	update := args[0] == "update"
+	if len(args) > 1 {
+		for _, arg := range args[1:] {
+			m := parseModuleVersion(arg)
+			if m.Path == "" {
+				log.Fatalf("Invalid module %q, use <path>@<version>", arg)
+			}
+			// A new version is fetched; the same version keeps its locked commit
+			pinned := ""
+			if locked := lock.theme(m.Path); locked != nil && locked.Ref == m.Ref {
+				pinned = locked.Commit
+			}
+			commit, err := fetchTheme(m, pinned)
+			if err != nil {
+				log.Fatalf("Failed to fetch theme %s: %v", m.Path, err)
+			}
+			lock.setTheme(m, commit)
+			fmt.Printf("%s@%s -> %s\n", m.Path, m.Ref, commit)
+			if name := themeName(m.Path); config.Theme != m.Path && config.Theme != name {
+				fmt.Printf("Set theme = %q in config.toml to use it\n", name)
+			}
+		}
+		if err := lock.save(); err != nil {
+			log.Fatalf("Failed to write lock file: %v", err)
+		}
+		return
+	}
+
+	for _, locked := range lock.Themes {
+		m := ModuleImport{Path: locked.Path, Ref: locked.Ref}
+		pinned := locked.Commit
+		if update {
+			pinned = ""
+		}
+		commit, err := fetchTheme(m, pinned)
+		if err != nil {
+			log.Fatalf("Failed to fetch theme %s: %v", m.Path, err)
+		}
+		lock.setTheme(m, commit)
+		fmt.Printf("%s@%s -> %s\n", m.Path, m.Ref, commit)
+	}
+	for _, m := range config.Module.Imports {
+		pinned := ""
+		if locked := lock.module(m); locked != nil && !update {
+			pinned = locked.Commit
+		}
+		if err := syncModule(m, update, pinned); err != nil {
+			log.Fatalf("Failed to fetch module %s: %v", m.Path, err)
+		}
+		commit, err := gitOutput(m.dir(), "rev-parse", "HEAD")
+		if err != nil {
+			log.Fatalf("Failed to resolve module %s: %v", m.Path, err)
+		}
+		lock.setModule(m, commit)
+		fmt.Printf("%s@%s -> %s\n", m.Path, m.Ref, commit)
+	}
+	lock.prune(config.Module.Imports)
+	if err := lock.save(); err != nil {
+		log.Fatalf("Failed to write lock file: %v", err)
+	}
+}
+
+// parseModuleVersion splits <path>@<version> into a module; without a version the default
+// branch is used
+func parseModuleVersion(arg string) ModuleImport {
+	path, version, _ := strings.Cut(arg, "@")
+	if version == "latest" {
+		version = ""
+	}
+	return ModuleImport{Path: strings.TrimSuffix(path, "/"), Ref: version}
+}
+
+// themeName returns the theme name of a module path, the repository name without .git
+func themeName(path string) string {
+	return strings.TrimSuffix(filepath.Base(filepath.FromSlash(path)), ".git")
+}
+
+// remoteURL returns the URL git clones a module from. Paths like github.com/user/theme are
+// fetched over HTTPS; URLs and local repositories are used as they are.
+func remoteURL(path string) string {
+	if strings.Contains(path, "://") || strings.HasPrefix(path, "git@") || filepath.IsAbs(path) || strings.HasPrefix(path, ".") {
+		return path
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	return "https://" + path
+}
+
+// fetchTheme checks out a theme module at the pinned commit, or at its ref when there is none,
+// and returns the checked out commit
+func fetchTheme(m ModuleImport, pinned string) (string, error) {
+	if err := syncModule(m, pinned == "", pinned); err != nil {
+		return "", err
+	}
+	return gitOutput(m.dir(), "rev-parse", "HEAD")
+}
+
+// syncModule clones the module when it is missing, or fetches it when updating, then checks out
+// the pinned commit if there is one and the module ref otherwise
+func syncModule(m ModuleImport, update bool, pinned string) error {
+	dir := m.dir()
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		if err := os.MkdirAll(filepath.Dir(dir), os.ModePerm); err != nil {
+			return err
+		}
+		if _, err := gitOutput("", "clone", "--quiet", "--", remoteURL(m.Path), dir); err != nil {
+			return err
+		}
+	} else if update {
+		if _, err := gitOutput(dir, "fetch", "--quiet", "--tags", "origin"); err != nil {
+			return err
+		}
+	} else if pinned == "" {
+		return nil
+	}
+
+	// Refs are passed where git also takes options
+	if strings.HasPrefix(pinned, "-") || strings.HasPrefix(m.Ref, "-") {
+		return fmt.Errorf("invalid ref of module %s", m.Path)
+	}
+	if pinned != "" {
+		if _, err := gitOutput(dir, "cat-file", "-e", pinned+"^{commit}"); err != nil {
+			if _, err := gitOutput(dir, "fetch", "--quiet", "--tags", "origin"); err != nil {
+				return err
+			}
+		}
+		_, err := gitOutput(dir, "checkout", "--quiet", "--detach", pinned)
+		return err
+	}
+	return checkoutRef(dir, m.Ref)
+}
+
+// checkoutRef detaches the checkout at a branch, tag or commit; an empty ref means the remote default branch
+func checkoutRef(dir, ref string) error {
+	target := "origin/HEAD"
+	if ref != "" {
+		target = ref
+		// Prefer the remote branch so updates move to its latest commit
+		if _, err := gitOutput(dir, "rev-parse", "--verify", "--quiet", "origin/"+ref); err == nil {
+			target = "origin/" + ref
+		}
+	}
+	_, err := gitOutput(dir, "checkout", "--quiet", "--detach", target)
+	return err
+}
+
+// gitOutput runs git in dir and returns its trimmed output; git never runs in safe mode
+func gitOutput(dir string, args ...string) (string, error) {
+	if safeMode {
+		return "", fmt.Errorf("git %s is not run in safe mode", strings.Join(args, " "))
+	}
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// checkoutCommit returns the commit checked out in a module directory. In safe mode git does not
+// run, so the detached HEAD that `mod get` leaves is read from the repository instead.
+func checkoutCommit(dir string) (string, error) {
+	if !safeMode {
+		return gitOutput(dir, "rev-parse", "HEAD")
+	}
+	head := filepath.Join(dir, ".git", "HEAD")
+	if err := checkReadPath(head); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(head)
+	if err != nil {
+		return "", err
+	}
+	commit := strings.TrimSpace(string(data))
+	if strings.HasPrefix(commit, "ref:") {
+		return "", fmt.Errorf("%s is on a branch instead of a commit", dir)
+	}
+	return commit, nil
+}
+
+// moduleContent collects the content files of every module mounted below content/
+func moduleContent(config Config) ([]contentFile, int, error) {
+	var files []contentFile
+	var nonPageFiles int
+	for _, m := range config.Module.Imports {
+		target := filepath.ToSlash(m.Target)
+		if target != "content" && !strings.HasPrefix(target, "content/") {
+			continue
+		}
+		if _, err := os.Stat(m.sourceDir()); err != nil {
+			return nil, 0, fmt.Errorf("module %s is not downloaded, run `mod get`", m.Path)
+		}
+		moduleFiles, others, err := collectContent(m.sourceDir())
+		if err != nil {
+			return nil, 0, err
+		}
+		prefix := filepath.FromSlash(strings.TrimPrefix(strings.TrimPrefix(target, "content"), "/"))
+		for _, f := range moduleFiles {
+			f.RelPath = filepath.Join(prefix, f.RelPath)
+			files = append(files, f)
+		}
+		nonPageFiles += others
+	}
+	return files, nonPageFiles, nil
+}
+
+// moduleTheme returns the checkout of a module mounted as themes/<name>, if any
+func moduleTheme(config Config, name string) (string, bool) {
+	for _, m := range config.Module.Imports {
+		if filepath.ToSlash(m.Target) == "themes/"+name {
+			return m.sourceDir(), true
+		}
+	}
+	return "", false
+}
+
+// resolveThemeDir returns the directory of the configured theme: a module mounted as the theme, a theme
+// fetched with `mod get` and locked in herocgo.lock, or themes/<name>
+func resolveThemeDir(config Config) (string, error) {
+	if dir, ok := moduleTheme(config, config.Theme); ok {
+		return dir, nil
+	}
+	local := filepath.Join("themes", config.Theme)
+	if _, err := os.Stat(local); err == nil {
+		return local, nil
+	}
+	lock, err := loadLockFile()
+	if err != nil {
+		return "", err
+	}
+	locked := lock.theme(config.Theme)
+	if locked == nil {
+		return local, nil
+	}
+	m := ModuleImport{Path: locked.Path, Ref: locked.Ref}
+	commit, err := checkoutCommit(m.dir())
+	if err != nil {
+		return "", fmt.Errorf("theme %s is not downloaded, run `mod get`", locked.Path)
+	}
+	if commit != locked.Commit {
+		return "", fmt.Errorf("theme %s is at %s which does not match %s, run `mod get`", locked.Path, commit, lockFileName)
+	}
+	return m.dir(), nil
+}
+
+// moduleStaticDirs returns the checkouts of modules mounted as static files
+func moduleStaticDirs(config Config) []string {
+	var dirs []string
+	for _, m := range config.Module.Imports {
+		if filepath.ToSlash(m.Target) == "static" {
+			dirs = append(dirs, m.sourceDir())
+		}
+	}
+	return dirs
+}
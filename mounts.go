@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mount maps a source directory into the content, static or layouts tree of the site, see
+// [[mounts]]. Sources may be outside the project, e.g. the docs of a sibling repository.
+type Mount struct {
+	// Source is a directory relative to the project, or to the checkout of Module when set
+	Source string `toml:"source"`
+	// Target is "content", "static" or "layouts", optionally followed by a subdirectory
+	Target string `toml:"target"`
+	// Module is the path of a module import whose checkout holds Source
+	Module string `toml:"module"`
+}
+
+// Mount trees
+const (
+	mountContent = "content"
+	mountStatic  = "static"
+	mountLayouts = "layouts"
+)
+
+// mountDir is a resolved mount: the directory that supplies the files below prefix of a tree
+type mountDir struct {
+	dir    string
+	prefix string
+}
+
+// mountRoots are the mount sources read outside of the project root, see checkReadPath
+var mountRoots struct {
+	mu   sync.Mutex
+	dirs []string
+}
+
+// resolve returns the source directory and the tree and subdirectory of the target
+func (m Mount) resolve(config Config) (dir, tree, prefix string, err error) {
+	target := strings.Trim(filepath.ToSlash(m.Target), "/")
+	tree, prefix, _ = strings.Cut(target, "/")
+	if tree != mountContent && tree != mountStatic && tree != mountLayouts {
+		return "", "", "", fmt.Errorf("mount target %q must be below content, static or layouts", m.Target)
+	}
+	dir = filepath.FromSlash(m.Source)
+	if m.Module != "" {
+		found := false
+		for _, imp := range config.Module.Imports {
+			if imp.Path == m.Module {
+				dir, found = filepath.Join(imp.dir(), dir), true
+			}
+		}
+		if !found {
+			return "", "", "", fmt.Errorf("mount of %s refers to unknown module %s", m.Source, m.Module)
+		}
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return "", "", "", fmt.Errorf("mount source %s: %w", dir, err)
+	}
+	return dir, tree, prefix, nil
+}
+
+// siteStaticDirs returns the static directories of the theme, the modules and the static mounts,
+// in the order they are copied to the output
+func siteStaticDirs(config Config, themeDir string) ([]mountDir, error) {
+	dirs := []mountDir{{dir: filepath.Join(themeDir, "static")}}
+	for _, dir := range moduleStaticDirs(config) {
+		dirs = append(dirs, mountDir{dir: dir})
+	}
+	mounts, err := mountDirs(config, mountStatic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mount static files: %w", err)
+	}
+	return append(dirs, mounts...), nil
+}
+
+// staticFile returns the file that is copied to the slash-separated output path name, or "" when
+// no static directory has one. Module and mounted static files are copied after those of the
+// theme and win.
+func (s *Site) staticFile(name string) string {
+	for i := len(s.staticDirs) - 1; i >= 0; i-- {
+		candidate, ok := s.staticDirs[i].relPath(name)
+		if !ok {
+			continue
+		}
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// mountDirs resolves the mounts of one tree in configuration order and allows reading them
+func mountDirs(config Config, tree string) ([]mountDir, error) {
+	var dirs []mountDir
+	for _, m := range config.Mounts {
+		dir, mountTree, prefix, err := m.resolve(config)
+		if err != nil {
+			return nil, err
+		}
+		if mountTree != tree {
+			continue
+		}
+		if err := allowReadRoot(dir); err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, mountDir{dir: dir, prefix: prefix})
+	}
+	return dirs, nil
+}
+
+// allowReadRoot lets checkReadPath accept the files below a mounted directory
+func allowReadRoot(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return err
+	}
+	if safeMode {
+		root, err := projectRoot()
+		if err != nil {
+			return err
+		}
+		if !within(root, resolved) {
+			return fmt.Errorf("mount source %s is outside of the project, which --safe does not allow", dir)
+		}
+	}
+	mountRoots.mu.Lock()
+	defer mountRoots.mu.Unlock()
+	mountRoots.dirs = append(mountRoots.dirs, resolved)
+	return nil
+}
+
+// resetMountRoots forgets the mounted directories of the previous load, so a mount removed from
+// the configuration can no longer be read once the site is reloaded
+func resetMountRoots() {
+	mountRoots.mu.Lock()
+	defer mountRoots.mu.Unlock()
+	mountRoots.dirs = nil
+}
+
+// withinMountRoot reports whether a resolved path is below a mounted directory
+func withinMountRoot(resolved string) bool {
+	mountRoots.mu.Lock()
+	defer mountRoots.mu.Unlock()
+	for _, root := range mountRoots.dirs {
+		if within(root, resolved) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether path is dir or below it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// mountedContent collects the content files of the mounts below content/
+func mountedContent(config Config) ([]contentFile, int, error) {
+	dirs, err := mountDirs(config, mountContent)
+	if err != nil {
+		return nil, 0, err
+	}
+	var files []contentFile
+	var nonPageFiles int
+	for _, mount := range dirs {
+		mounted, others, err := collectContent(mount.dir)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, f := range mounted {
+			f.RelPath = filepath.Join(filepath.FromSlash(mount.prefix), f.RelPath)
+			files = append(files, f)
+		}
+		nonPageFiles += others
+	}
+	return files, nonPageFiles, nil
+}
+
+// relPath returns the path of a slash-separated name below the mount directory, if the name
+// is below its prefix
+func (m mountDir) relPath(name string) (string, bool) {
+	if m.prefix == "" {
+		return filepath.Join(m.dir, filepath.FromSlash(name)), true
+	}
+	rest, ok := strings.CutPrefix(name, m.prefix+"/")
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(m.dir, filepath.FromSlash(rest)), true
+}
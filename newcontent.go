@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultArchetype is used when neither the site nor the theme has a matching archetype
+const defaultArchetype = `---
+title: {{ printf "%q" .Title }}
+date: "{{ .Date }}"
+{{- with .Tags }}
+tags: [{{ range $i, $tag := . }}{{ if $i }}, {{ end }}{{ printf "%q" $tag }}{{ end }}]
+{{- end }}
+{{- if .Draft }}
+draft: true
+{{- end }}
+---
+
+{{ .Content }}
+`
+
+// archetypeData is the context of archetype templates
+type archetypeData struct {
+	Title   string
+	Date    string
+	Author  string
+	Section string
+	Name    string
+	Content string
+	Tags    []string
+	Draft   bool
+}
+
+// runNew implements `new <path>`: it creates a content file from the archetype of its section.
+// A path ending in "/" creates a leaf bundle; the file name is slugified.
+func runNew(args []string) {
+	flags := flag.NewFlagSet("new", flag.ExitOnError)
+	force := flags.Bool("force", false, "overwrite the file if it already exists")
+	title := flags.String("title", "", "title of the new page instead of one derived from the file name")
+	date := flags.String("date", "", "date of the new page (YYYY-MM-DD or RFC 3339) instead of now")
+	tags := flags.String("tags", "", "comma-separated tags of the new page")
+	draft := flags.Bool("draft", false, "mark the new page as a draft")
+	edit := flags.Bool("edit", false, "open the new file in $VISUAL or $EDITOR")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		log.Fatalf("Usage: new [--force] [--title T] [--date D] [--tags a,b] [--draft] [--edit] <section/name.md>")
+	}
+
+	config, err := loadConfig("config.toml")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	site := newSite(config)
+
+	rel, err := newContentPath(site, positional[0])
+	if err != nil {
+		log.Fatalf("Failed to create content: %v", err)
+	}
+	dest, err := outputFile("content", rel)
+	if err != nil {
+		log.Fatalf("Failed to create content: %v", err)
+	}
+	if _, err := os.Stat(dest); err == nil && !*force {
+		log.Fatalf("%s already exists, use --force to overwrite it", dest)
+	}
+
+	section := ""
+	if dir := path.Dir(rel); dir != "." {
+		section = strings.Split(dir, "/")[0]
+	}
+	// The title keeps the spelling of the name as typed, before slugification
+	typed := strings.TrimSuffix(filepath.ToSlash(positional[0]), "/")
+	if strings.HasSuffix(positional[0], "/") {
+		typed += "/index.md"
+	}
+	data := archetypeData{
+		Title:   filenameTitle(newFile(contentFile{Path: dest, RelPath: typed})),
+		Date:    time.Now().Format(time.RFC3339),
+		Section: section,
+		Name:    path.Base(strings.TrimSuffix(rel, "/index.md")),
+	}
+	data.Author, _ = config.Params["author"].(string)
+	if *title != "" {
+		data.Title = *title
+	}
+	if *date != "" {
+		t := toTime(*date)
+		if t.IsZero() {
+			log.Fatalf("Invalid --date %q, use YYYY-MM-DD or RFC 3339", *date)
+		}
+		data.Date = t.Format(time.RFC3339)
+	}
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			data.Tags = append(data.Tags, tag)
+		}
+	}
+	data.Draft = *draft
+
+	content, err := renderArchetype(site, section, data)
+	if err != nil {
+		log.Fatalf("Failed to create content: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		log.Fatalf("Failed to create content directory: %v", err)
+	}
+	if err := os.WriteFile(dest, content, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", dest, err)
+	}
+	fmt.Printf("Created %s\n", dest)
+
+	if *edit {
+		if err := openEditor(dest); err != nil {
+			log.Fatalf("Failed to open editor: %v", err)
+		}
+	}
+}
+
+// openEditor opens a file in the editor named by $VISUAL or $EDITOR, which may include arguments
+func openEditor(file string) error {
+	editor := envOr("VISUAL", os.Getenv("EDITOR"))
+	command := strings.Fields(editor)
+	if len(command) == 0 {
+		return fmt.Errorf("set $VISUAL or $EDITOR to open new content")
+	}
+	cmd := exec.Command(command[0], append(command[1:], file)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// newContentPath slugifies the file or bundle name of a content-relative path
+func newContentPath(site *Site, arg string) (string, error) {
+	arg = strings.TrimPrefix(filepath.ToSlash(arg), "content/")
+	bundle := strings.HasSuffix(arg, "/")
+	dir, name := path.Split(strings.TrimSuffix(arg, "/"))
+	name = site.slug(strings.TrimSuffix(name, ".md"))
+	if name == "" {
+		return "", fmt.Errorf("%q has no usable file name", arg)
+	}
+	if bundle {
+		return path.Join(dir, name, "index.md"), nil
+	}
+	return path.Join(dir, name+".md"), nil
+}
+
+// renderArchetype executes the archetype of the section: archetypes/<section>.md, its singular
+// form or default.md, looked up in the site and then the theme, falling back to a built-in one
+func renderArchetype(site *Site, section string, data archetypeData) ([]byte, error) {
+	var names []string
+	if section != "" {
+		names = append(names, section+".md")
+		if singular := strings.TrimSuffix(section, "s"); singular != section && singular != "" {
+			names = append(names, singular+".md")
+		}
+	}
+	names = append(names, "default.md")
+
+	source, name := defaultArchetype, "default"
+	dirs := []string{"archetypes"}
+	if themeDir, err := resolveThemeDir(site.Config); err == nil {
+		dirs = append(dirs, filepath.Join(themeDir, "archetypes"))
+	}
+search:
+	for _, dir := range dirs {
+		for _, candidate := range names {
+			data, err := os.ReadFile(filepath.Join(dir, candidate))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read archetype: %w", err)
+			}
+			source, name = string(data), filepath.Join(dir, candidate)
+			break search
+		}
+	}
+
+	tmpl, err := template.New(name).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse archetype %s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute archetype %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// parseInterspersed parses flags that may appear before or after positional arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
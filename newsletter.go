@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// newsletterOptions selects the posts of `export newsletter`
+type newsletterOptions struct {
+	Limit int
+	Since time.Time
+	// Full includes the whole content of posts instead of their summary
+	Full bool
+}
+
+// newsletterPost is a post of a newsletter
+type newsletterPost struct {
+	Title   string
+	URL     string
+	Date    time.Time
+	Summary string
+	// Content is the email-safe HTML of the post when the full content is included
+	Content template.HTML
+}
+
+// newsletterData is the context of the newsletter templates
+type newsletterData struct {
+	Title   string
+	Lang    string
+	SiteURL string
+	Posts   []newsletterPost
+}
+
+// newsletterStyles are inlined into the elements of post content, as email clients drop style
+// elements and external stylesheets
+var newsletterStyles = map[string]string{
+	"a":          "color: #2563eb;",
+	"blockquote": "margin: 0 0 16px; padding-left: 12px; border-left: 3px solid #d1d5db; color: #4b5563;",
+	"code":       "font-family: Menlo, Consolas, monospace; font-size: 14px;",
+	"h1":         "font-size: 22px; margin: 24px 0 12px;",
+	"h2":         "font-size: 20px; margin: 24px 0 12px;",
+	"h3":         "font-size: 18px; margin: 20px 0 10px;",
+	"img":        "max-width: 100%; height: auto; border: 0;",
+	"li":         "margin: 0 0 4px;",
+	"p":          "margin: 0 0 16px;",
+	"pre":        "background: #f3f4f6; padding: 12px; overflow-x: auto; white-space: pre-wrap;",
+}
+
+// openingTagPattern matches opening tags with their name and attributes
+var openingTagPattern = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9]*)((?:\s[^>]*)?)>`)
+
+const newsletterHTMLTemplate = `<!DOCTYPE html>
+<html lang="{{ .Lang }}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Title }}</title>
+</head>
+<body style="margin: 0; padding: 0; background: #f3f4f6;">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" border="0" style="background: #f3f4f6;">
+<tr><td align="center" style="padding: 24px 12px;">
+<table role="presentation" width="600" cellpadding="0" cellspacing="0" border="0" style="max-width: 600px; width: 100%; background: #ffffff; font-family: Georgia, serif; font-size: 16px; line-height: 1.5; color: #111827;">
+<tr><td style="padding: 24px 32px; border-bottom: 1px solid #e5e7eb;">
+<a href="{{ .SiteURL }}" style="font-size: 24px; font-weight: bold; color: #111827; text-decoration: none;">{{ .Title }}</a>
+</td></tr>
+{{- range .Posts }}
+<tr><td style="padding: 24px 32px; border-bottom: 1px solid #e5e7eb;">
+<h2 style="font-size: 20px; margin: 0 0 4px;"><a href="{{ .URL }}" style="color: #111827; text-decoration: none;">{{ .Title }}</a></h2>
+{{- if not .Date.IsZero }}
+<p style="margin: 0 0 12px; font-size: 14px; color: #6b7280;">{{ timeTag .Date }}</p>
+{{- end }}
+{{- if .Content }}
+{{ .Content }}
+{{- else }}
+<p style="margin: 0 0 16px;">{{ .Summary }}</p>
+{{- end }}
+<p style="margin: 0;"><a href="{{ .URL }}" style="color: #2563eb;">Read on the site →</a></p>
+</td></tr>
+{{- end }}
+<tr><td style="padding: 16px 32px; font-size: 12px; color: #6b7280;">
+<a href="{{ .SiteURL }}" style="color: #6b7280;">{{ .SiteURL }}</a>
+</td></tr>
+</table>
+</td></tr>
+</table>
+</body>
+</html>
+`
+
+const newsletterTextTemplate = `{{ .Title }}
+{{ range .Posts }}
+{{ .Title }}
+{{- if not .Date.IsZero }}
+{{ formatDate .Date }}
+{{- end }}
+
+{{ .Summary }}
+
+{{ .URL }}
+{{ end }}
+--
+{{ .SiteURL }}
+`
+
+const newsletterMJMLTemplate = `<mjml>
+<mj-head>
+<mj-title>{{ .Title }}</mj-title>
+<mj-attributes>
+<mj-all font-family="Georgia, serif" />
+<mj-text font-size="16px" line-height="1.5" color="#111827" />
+</mj-attributes>
+</mj-head>
+<mj-body background-color="#f3f4f6">
+<mj-section background-color="#ffffff">
+<mj-column>
+<mj-text font-size="24px" font-weight="bold"><a href="{{ .SiteURL }}" style="color: #111827; text-decoration: none;">{{ .Title }}</a></mj-text>
+</mj-column>
+</mj-section>
+{{- range .Posts }}
+<mj-section background-color="#ffffff">
+<mj-column>
+<mj-text font-size="20px" font-weight="bold">{{ .Title }}</mj-text>
+{{- if not .Date.IsZero }}
+<mj-text font-size="14px" color="#6b7280">{{ timeTag .Date }}</mj-text>
+{{- end }}
+<mj-text>{{ if .Content }}{{ .Content }}{{ else }}<p>{{ .Summary }}</p>{{ end }}</mj-text>
+<mj-button href="{{ .URL }}" background-color="#2563eb">Read on the site</mj-button>
+</mj-column>
+</mj-section>
+{{- end }}
+</mj-body>
+</mjml>
+`
+
+// newsletterData collects the newest posts of the sections (all when none are given)
+func (s *Site) newsletterData(sections []string, opts newsletterOptions) newsletterData {
+	data := newsletterData{Title: s.Title, Lang: s.Lang(), SiteURL: s.AbsURL("/")}
+	for _, p := range s.Pages {
+		if opts.Limit > 0 && len(data.Posts) == opts.Limit {
+			break
+		}
+		if len(sections) > 0 && !slices.Contains(sections, p.Section) {
+			continue
+		}
+		if !opts.Since.IsZero() && p.Date.Before(opts.Since) {
+			continue
+		}
+		post := newsletterPost{Title: p.Title, URL: p.Permalink, Date: p.Date, Summary: p.Summary()}
+		if opts.Full {
+			post.Content = template.HTML(inlineEmailStyles(absoluteLinks(string(p.Content), p.Permalink)))
+		}
+		data.Posts = append(data.Posts, post)
+	}
+	return data
+}
+
+// inlineEmailStyles adds the newsletter style of each element to its opening tag, after any
+// style the element already has
+func inlineEmailStyles(content string) string {
+	return openingTagPattern.ReplaceAllStringFunc(content, func(tag string) string {
+		m := openingTagPattern.FindStringSubmatch(tag)
+		style, ok := newsletterStyles[strings.ToLower(m[1])]
+		if !ok {
+			return tag
+		}
+		attrs := m[2]
+		selfClosing := strings.HasSuffix(attrs, "/")
+		attrs = strings.TrimSuffix(attrs, "/")
+		if i := strings.Index(strings.ToLower(attrs), ` style="`); i >= 0 {
+			end := i + len(` style="`)
+			attrs = attrs[:end] + style + " " + attrs[end:]
+		} else {
+			attrs = strings.TrimRight(attrs, " ") + ` style="` + style + `"`
+		}
+		if selfClosing {
+			attrs += " /"
+		}
+		return "<" + m[1] + attrs + ">"
+	})
+}
+
+// writeNewsletter writes the HTML, plain text and MJML variants of the newsletter next to each
+// other, named after base without its extension, and returns their paths. Dates are written
+// like those of the site, in its language.
+func (s *Site) writeNewsletter(base string, data newsletterData) ([]string, error) {
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	if err := os.MkdirAll(filepath.Dir(base), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	funcs := map[string]any{
+		"timeTag":    s.timeTag,
+		"formatDate": func(t time.Time) string { return s.formatDate(t, "") },
+	}
+	variants := []struct {
+		ext     string
+		execute func(w io.Writer, data any) error
+	}{
+		{".html", template.Must(template.New("newsletter.html").Funcs(funcs).Parse(newsletterHTMLTemplate)).Execute},
+		{".txt", texttemplate.Must(texttemplate.New("newsletter.txt").Funcs(funcs).Parse(newsletterTextTemplate)).Execute},
+		{".mjml", template.Must(template.New("newsletter.mjml").Funcs(funcs).Parse(newsletterMJMLTemplate)).Execute},
+	}
+	var written []string
+	for _, v := range variants {
+		file, err := os.Create(base + v.ext)
+		if err != nil {
+			return written, err
+		}
+		err = v.execute(file, data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", base+v.ext, err)
+		}
+		written = append(written, base+v.ext)
+	}
+	return written, nil
+}
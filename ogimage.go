@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// OGImageConfig configures the social preview images generated for pages without a cover:
+//
+//	[ogImage]
+//	enabled = true
+//	background = "assets/og-background.png"
+//
+// The card is the og-image.svg layout of the site or theme, or a built-in one, converted to PNG
+// by an external command. Pages keep a cover when they have one, and `ogImage: false` in front
+// matter opts a page out.
+type OGImageConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Kinds lists the page kinds that get an image, page by default
+	Kinds []string `toml:"kinds"`
+	// Background is a project image drawn behind the text
+	Background      string `toml:"background"`
+	BackgroundColor string `toml:"backgroundColor"`
+	TextColor       string `toml:"textColor"`
+	// Command converts the card, with {input} and {output}
+	Command string `toml:"command"`
+}
+
+// defaultOGImageCommand renders SVG with librsvg
+const defaultOGImageCommand = "rsvg-convert --width 1200 --height 630 --output {output} {input}"
+
+//go:embed embedded/og-image.svg
+var defaultOGImageTemplate string
+
+// ogImageData is the data of the og-image.svg layout
+type ogImageData struct {
+	Page      *Page
+	SiteTitle string
+	// Lines is the title wrapped to fit the card, at most three lines
+	Lines                      []string
+	TitleY                     int
+	Width, Height              int
+	Background                 template.URL
+	BackgroundColor, TextColor string
+}
+
+// ogImageLineLength is the number of characters of a title line of the built-in card
+const ogImageLineLength = 28
+
+// generateOGImages writes a preview card for every page of the configured kinds without an
+// OGImage and sets it. Cards are named by the hash of their SVG, so unchanged cards are not
+// converted again and social networks see the new image when a title changes.
+func (s *Site) generateOGImages(outputDir string, templates *TemplateCache) {
+	cfg := s.Config.OGImage
+	if !cfg.Enabled {
+		return
+	}
+	if len(cfg.Kinds) == 0 {
+		cfg.Kinds = []string{KindPage}
+	}
+	if cfg.BackgroundColor == "" {
+		cfg.BackgroundColor = "#1f2937"
+	}
+	if cfg.TextColor == "" {
+		cfg.TextColor = "#ffffff"
+	}
+	if cfg.Command == "" {
+		cfg.Command = defaultOGImageCommand
+	}
+
+	text := defaultOGImageTemplate
+	if file, ok := templates.lookup("og-image.svg"); ok {
+		err := checkReadPath(file)
+		var data []byte
+		if err == nil {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			warnf("Failed to read og-image.svg: %v", err)
+			return
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("og-image.svg").Funcs(templates.funcs).Parse(text)
+	if err != nil {
+		warnf("Failed to parse og-image.svg: %v", err)
+		return
+	}
+	var background template.URL
+	if cfg.Background != "" {
+		uri, err := dataURI(cfg.Background)
+		if err != nil {
+			warnf("Failed to read ogImage.background: %v", err)
+			return
+		}
+		background = template.URL(uri)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		limit = make(chan struct{}, runtime.NumCPU())
+	)
+	for _, page := range s.AllPages {
+		if page.OGImage != "" || !slices.Contains(cfg.Kinds, page.Kind) || page.Params["ogImage"] == false {
+			continue
+		}
+		lines := wrapWords(page.Title, ogImageLineLength, 3)
+		data := ogImageData{
+			Page:            page,
+			SiteTitle:       s.Title,
+			Lines:           lines,
+			TitleY:          300 - (len(lines)-1)*38,
+			Width:           socialImageWidth,
+			Height:          socialImageHeight,
+			Background:      background,
+			BackgroundColor: cfg.BackgroundColor,
+			TextColor:       cfg.TextColor,
+		}
+		var svg bytes.Buffer
+		if err := tmpl.Execute(&svg, data); err != nil {
+			warnf("Failed to create social image for %s: %v", page.RelPermalink, err)
+			continue
+		}
+		name, _, err := s.fingerprintName(path.Join("og", strings.TrimSuffix(filepath.ToSlash(page.outputPath), ".html")+".png"), svg.Bytes())
+		if err != nil {
+			warnf("Failed to create social image for %s: %v", page.RelPermalink, err)
+			continue
+		}
+		dest, err := outputFile(outputDir, name)
+		if err != nil {
+			warnf("Skipping social image for %s: %v", page.RelPermalink, err)
+			continue
+		}
+		if _, err := os.Stat(dest); err == nil {
+			page.OGImage = s.AbsURL(name)
+			continue
+		}
+
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(page *Page, svg []byte, name, dest string) {
+			defer func() { <-limit; wg.Done() }()
+			if err := convertOGImage(s, svg, dest, cfg.Command); err != nil {
+				warnf("Failed to create social image for %s: %v", page.RelPermalink, err)
+				return
+			}
+			page.OGImage = s.AbsURL(name)
+		}(page, svg.Bytes(), name, dest)
+	}
+	wg.Wait()
+}
+
+// convertOGImage writes the SVG card to a temporary file and converts it into dest
+func convertOGImage(s *Site, svg []byte, dest, command string) error {
+	tmp, err := os.CreateTemp("", "hero-og-*.svg")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(svg); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	if err := encodeFile(s.ctx, command, tmp.Name(), dest); err != nil {
+		os.Remove(dest)
+		return err
+	}
+	return nil
+}
+
+// dataURI returns a project file as a data: URI, so converters need not resolve its path
+func dataURI(name string) (string, error) {
+	file, err := projectPath(name)
+	if err != nil {
+		return "", err
+	}
+	if err := checkReadPath(file); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(file)))
+	if mediaType == "" {
+		return "", fmt.Errorf("unknown image type of %s", name)
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// wrapWords breaks text into at most maxLines lines of about width characters, ending the last
+// line with an ellipsis when the text is longer
+func wrapWords(text string, width, maxLines int) []string {
+	var lines []string
+	var line []rune
+	words := strings.Fields(text)
+	for i, word := range words {
+		w := []rune(word)
+		if len(line) > 0 && len(line)+1+len(w) > width {
+			if len(lines) == maxLines-1 {
+				return append(lines, string(line)+"…")
+			}
+			lines = append(lines, string(line))
+			line = nil
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, w...)
+		if len(line) > width && i < len(words)-1 && len(lines) == maxLines-1 {
+			return append(lines, string(line[:width])+"…")
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
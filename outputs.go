@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// CustomOutput is a standalone file such as humans.txt rendered from a theme template
+type CustomOutput struct {
+	Path     string `toml:"path"`
+	Template string `toml:"template"`
+}
+
+// renderCustomOutputs renders every configured custom output with the home page as context.
+// Templates live in the theme's layouts/outputs directory and are not HTML-escaped.
+func (s *Site) renderCustomOutputs(outputDir, themeDir string, funcs map[string]any) (int, error) {
+	var written int
+	for _, output := range s.Config.CustomOutputs {
+		if err := s.ctx.Err(); err != nil {
+			return written, err
+		}
+		if output.Path == "" {
+			return written, fmt.Errorf("custom output is missing a path")
+		}
+		name := output.Template
+		if name == "" {
+			name = filepath.Base(output.Path)
+		}
+		tmplPath := filepath.Join(themeDir, "layouts", "outputs", name)
+		if err := checkReadPath(tmplPath); err != nil {
+			return written, fmt.Errorf("failed to load template for %s: %w", output.Path, err)
+		}
+		data, err := os.ReadFile(tmplPath)
+		if err != nil {
+			return written, fmt.Errorf("failed to load template for %s: %w", output.Path, err)
+		}
+		tmpl, err := template.New(name).Funcs(funcs).Parse(string(data))
+		if err != nil {
+			return written, fmt.Errorf("failed to parse template for %s: %w", output.Path, err)
+		}
+
+		dest, err := outputFile(outputDir, output.Path)
+		if err != nil {
+			return written, err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return written, fmt.Errorf("failed to create output directory: %w", err)
+		}
+		file, err := os.Create(dest)
+		if err != nil {
+			return written, fmt.Errorf("failed to create %s: %w", output.Path, err)
+		}
+		done := trackTask(s.ctx, "output %s", output.Path)
+		err = tmpl.Execute(file, s.Home)
+		done()
+		file.Close()
+		if err != nil {
+			return written, fmt.Errorf("failed to execute template for %s: %w", output.Path, err)
+		}
+		written++
+	}
+	return written, nil
+}
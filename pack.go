@@ -0,0 +1,235 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// packEpoch is the modification time of archive entries unless SOURCE_DATE_EPOCH is set; zip
+// cannot store earlier times
+var packEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// runPack implements `pack`, which archives the output directory as a .tar.gz or .zip that is
+// byte for byte the same for the same output: entries are sorted by path and carry a fixed
+// time, mode and owner. The artifact manifest is included; when the build wrote none, one is
+// generated from the files.
+func runPack(args []string) {
+	flags := flag.NewFlagSet("pack", flag.ExitOnError)
+	dir := flags.String("dir", "public", "output directory to archive")
+	output := flags.String("output", "", "archive to write, export/site.tar.gz by default; a .zip name selects zip")
+	format := flags.String("format", "", "archive format: tar.gz or zip (default from --output, else tar.gz)")
+	environment := flags.String("environment", envOr("HERO_ENVIRONMENT", "production"), "environment whose config names the directory of unlisted pages")
+	flags.Parse(args)
+
+	if *format == "" {
+		*format = "tar.gz"
+		if strings.HasSuffix(*output, ".zip") {
+			*format = "zip"
+		}
+	}
+	if *format != "tar.gz" && *format != "zip" {
+		log.Fatalf("Unknown archive format %q, use tar.gz or zip", *format)
+	}
+	if *output == "" {
+		*output = filepath.Join(exportDir, "site."+*format)
+	}
+	mtime, err := sourceDateEpoch()
+	if err != nil {
+		log.Fatalf("Invalid SOURCE_DATE_EPOCH: %v", err)
+	}
+
+	if info, err := os.Stat(*dir); err != nil || !info.IsDir() {
+		log.Fatalf("No output directory %s; build the site first", *dir)
+	}
+	files, err := scanOutput(*dir)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *dir, err)
+	}
+	unlistedDir := UnlistedConfig{}.dir()
+	if config, err := loadEnvironmentConfig(*environment); err == nil {
+		unlistedDir = config.Unlisted.dir()
+	}
+	manifest, err := packManifest(*dir, files, unlistedDir)
+	if err != nil {
+		log.Fatalf("Failed to pack: %v", err)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if name != artifactManifestName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if err := os.MkdirAll(filepath.Dir(*output), os.ModePerm); err != nil {
+		log.Fatalf("Failed to create %s: %v", filepath.Dir(*output), err)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", *output, err)
+	}
+	sum := sha256.New()
+	w := io.MultiWriter(file, sum)
+	if *format == "zip" {
+		err = writeZipPack(w, *dir, names, manifest, mtime)
+	} else {
+		err = writeTarPack(w, *dir, names, manifest, mtime)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+		log.Fatalf("Failed to write %s: %v", *output, err)
+	}
+	fmt.Printf("Packed %d files into %s\nsha256 %s\n", len(names)+1, *output, hex.EncodeToString(sum.Sum(nil)))
+}
+
+// sourceDateEpoch returns the time of SOURCE_DATE_EPOCH, or packEpoch when it is unset
+func sourceDateEpoch() (time.Time, error) {
+	value := os.Getenv("SOURCE_DATE_EPOCH")
+	if value == "" {
+		return packEpoch, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t := time.Unix(seconds, 0).UTC()
+	if t.Before(packEpoch) {
+		t = packEpoch
+	}
+	return t, nil
+}
+
+// packManifest returns the artifact manifest of the output. A manifest written by the build must
+// list exactly the files of the directory, so archives never carry a stale one. Like the build,
+// a generated manifest leaves out the files of unlisted pages below unlistedDir.
+func packManifest(dir string, files map[string]outputFileInfo, unlistedDir string) ([]byte, error) {
+	current := make(map[string]outputFileInfo, len(files))
+	for path, f := range files {
+		if path != artifactManifestName {
+			current[path] = f
+		}
+	}
+	if _, ok := files[artifactManifestName]; !ok {
+		manifest := outputManifest{Generator: "herocgo " + version, Files: make([]outputFileInfo, 0, len(current))}
+		if dropUnlistedOutputs(current, unlistedDir) {
+			manifest.Unlisted = unlistedDir
+		}
+		for _, f := range current {
+			manifest.Files = append(manifest.Files, f)
+		}
+		sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		return append(data, '\n'), err
+	}
+	name := filepath.Join(dir, artifactManifestName)
+	manifest, err := readOutputManifest(name)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]outputFileInfo, len(manifest.Files))
+	for _, f := range manifest.Files {
+		listed[f.Path] = f
+	}
+	if manifest.Unlisted != "" {
+		dropUnlistedOutputs(current, manifest.Unlisted)
+	}
+	if changes := compareOutputs(listed, current); len(changes) > 0 {
+		return nil, fmt.Errorf("%s does not match the output (%s %s and %d more changes); rebuild the site with build.manifest set or remove it", name, changes[0].Action, changes[0].Path, len(changes)-1)
+	}
+	return os.ReadFile(name)
+}
+
+// writeTarPack writes a gzipped tar of the files followed by the manifest
+func writeTarPack(w io.Writer, dir string, names []string, manifest []byte, mtime time.Time) error {
+	gz, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(gz)
+	add := func(name string, size int64, r io.Reader) error {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     size,
+			ModTime:  mtime,
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	}
+	for _, name := range names {
+		if err := packFile(dir, name, add); err != nil {
+			return err
+		}
+	}
+	if err := add(artifactManifestName, int64(len(manifest)), bytes.NewReader(manifest)); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeZipPack writes a zip of the files followed by the manifest
+func writeZipPack(w io.Writer, dir string, names []string, manifest []byte, mtime time.Time) error {
+	zw := zip.NewWriter(w)
+	add := func(name string, size int64, r io.Reader) error {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: mtime}
+		header.SetMode(0644)
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(entry, r)
+		return err
+	}
+	for _, name := range names {
+		if err := packFile(dir, name, add); err != nil {
+			return err
+		}
+	}
+	if err := add(artifactManifestName, int64(len(manifest)), bytes.NewReader(manifest)); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// packFile adds the slash-separated file of the output directory to an archive
+func packFile(dir, name string, add func(name string, size int64, r io.Reader) error) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := add(name, info.Size(), f); err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	return nil
+}
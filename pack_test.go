@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSourceDateEpoch(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"unset", "", packEpoch, false},
+		{"set", "1700000000", time.Unix(1700000000, 0).UTC(), false},
+		{"before zip times", "0", packEpoch, false},
+		{"not a number", "yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SOURCE_DATE_EPOCH", tt.value)
+			got, err := sourceDateEpoch()
+			if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+				t.Errorf("sourceDateEpoch() = %v, %v; want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPackDeterministic(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w io.Writer, dir string, names []string, manifest []byte, mtime time.Time) error
+	}{
+		{"tar.gz", writeTarPack},
+		{"zip", writeZipPack},
+	}
+	names := []string{"a/index.html", "index.html", "style.css"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pack := func(dir string) []byte {
+				t.Helper()
+				var b bytes.Buffer
+				if err := tt.write(&b, dir, names, []byte("{}\n"), packEpoch); err != nil {
+					t.Fatal(err)
+				}
+				return b.Bytes()
+			}
+			dir := writeTestOutput(t, names...)
+			first := pack(dir)
+
+			// Another checkout with other times and permissions packs to the same bytes
+			other := writeTestOutput(t, names...)
+			for i, name := range names {
+				file := filepath.Join(other, filepath.FromSlash(name))
+				when := time.Now().Add(time.Duration(i) * time.Hour)
+				if err := os.Chtimes(file, when, when); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(file, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(first, pack(other)) {
+				t.Error("packing the same files twice gave different archives")
+			}
+
+			if err := os.WriteFile(filepath.Join(other, "style.css"), []byte("changed"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(first, pack(other)) {
+				t.Error("packing changed files gave the same archive")
+			}
+		})
+	}
+}
+
+func TestPackManifest(t *testing.T) {
+	unlisted := "unlisted/" + testUnlistedHash + "/index.html"
+	tests := []struct {
+		name    string
+		build   bool
+		change  string
+		wantErr string
+	}{
+		{name: "generated"},
+		{name: "written by the build", build: true},
+		{name: "stale", build: true, change: "index.html", wantErr: "does not match the output"},
+		{name: "unlisted file changed", build: true, change: unlisted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestOutput(t, "index.html", "style.css", unlisted)
+			if tt.build {
+				if err := newSite(Config{}).writeArtifactManifest(dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.change != "" {
+				if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(tt.change)), []byte("changed"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			files, err := scanOutput(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := packManifest(dir, files, "unlisted")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("packManifest() error = %v; want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var manifest outputManifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				t.Fatal(err)
+			}
+			if len(manifest.Files) != 2 || manifest.Unlisted != "unlisted" || strings.Contains(string(data), testUnlistedHash) {
+				t.Errorf("packManifest() =\n%s\nwant index.html and style.css without the unlisted page", data)
+			}
+		})
+	}
+}
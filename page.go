@@ -0,0 +1,388 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/yuin/goldmark"
+	"gopkg.in/yaml.v3"
+)
+
+// summaryLength is the number of words used for automatic summaries
+const summaryLength = 70
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Page kinds exposed to templates as .Kind
+const (
+	KindHome     = "home"
+	KindPage     = "page"
+	KindSection  = "section"
+	KindTaxonomy = "taxonomy"
+	KindTerm     = "term"
+)
+
+// FrontMatter holds the typed front matter fields; every key is also kept in Params
+type FrontMatter struct {
+	Title       string             `yaml:"title" toml:"title"`
+	Description string             `yaml:"description" toml:"description"`
+	Layout      string             `yaml:"layout" toml:"layout"`
+	Resources   []ResourceMetadata `yaml:"resources" toml:"resources"`
+	Params      map[string]any     `yaml:"-" toml:"-"`
+
+	// raw is the unparsed front matter between the delimiters
+	raw string
+}
+
+// contentFile is a Markdown source discovered under the content directory
+type contentFile struct {
+	Path     string
+	RelPath  string
+	IsBundle bool
+
+	// data replaces the content of Path for pages of a content adapter or the changelog, which
+	// are published at permalink; standalone pages are rendered but not listed
+	data       []byte
+	permalink  string
+	standalone bool
+}
+
+// Page is the context every template is executed with
+type Page struct {
+	Site         *Site
+	Kind         string
+	Title        string
+	Description  string
+	Date         time.Time
+	PublishDate  time.Time
+	Lastmod      time.Time
+	ExpiryDate   time.Time
+	Draft        bool
+	Unlisted     bool
+	Weight       int
+	Params       map[string]any
+	Content      template.HTML
+	Section      string
+	Layout       string
+	RelPermalink string
+	Permalink    string
+	Resources    Resources
+
+	// ID is the stable identifier of the id front matter, see runID
+	ID string
+
+	// File describes the source file; it is nil for generated list pages
+	File *File
+
+	// GitInfo is the last commit of the source file when enableGitInfo is set
+	GitInfo *GitInfo
+
+	// RawFrontMatter and RawContent are the unprocessed source of the page
+	RawFrontMatter string
+	RawContent     string
+
+	// RSSLink and JSONFeedLink are the feed URLs of home and section pages, PodcastLink that of
+	// sections configured as podcasts
+	RSSLink      string
+	JSONFeedLink string
+	PodcastLink  string
+
+	// Event is set from the event front matter; CalendarLink on sections with events
+	Event        *Event
+	CalendarLink string
+	// PrintLink and PDFLink are the URLs of the print variant and PDF of the page, when enabled
+	PrintLink string
+	PDFLink   string
+	// AMPLink is the absolute URL of the AMP variant of the page and AMPContent its content, when
+	// the page has one
+	AMPLink    string
+	AMPContent template.HTML
+	// Location is set from location front matter with coordinates
+	Location *Location
+	// backlinks are the pages linking to the page, set once every page is loaded
+	backlinks []*Page
+
+	// OGImage is the absolute URL of the social preview image, derived from the cover
+	OGImage string
+
+	// Pages lists the pages of a home, section or term page
+	Pages []*Page
+
+	// Archive is set on the generated year and month archive pages
+	Archive *ArchivePeriod
+
+	// Paginator is set on list pages when pagination is enabled
+	Paginator *Paginator
+
+	// Taxonomy and Term are set on taxonomy and term pages, Terms on taxonomy pages
+	Taxonomy string
+	Term     string
+	Terms    []*Term
+
+	// Comments are the static comments of data/comments, oldest first with replies nested
+	Comments []*Comment
+
+	source     *contentFile
+	outputPath string
+	parent     *Page
+	sections   []*Page
+	cover      *Resource
+	authors    []*Author
+	series     *Term
+	archived   bool
+}
+
+// File describes the content file a page was built from, relative to the content directory
+type File struct {
+	Path         string
+	Dir          string
+	LogicalName  string
+	BaseFileName string
+	Ext          string
+	Filename     string
+}
+
+// newFile describes a content file for templates, using forward slashes on every OS
+func newFile(file contentFile) *File {
+	rel := filepath.ToSlash(file.RelPath)
+	dir := path.Dir(rel)
+	if dir == "." {
+		dir = ""
+	} else {
+		dir += "/"
+	}
+	ext := path.Ext(rel)
+	filename, err := filepath.Abs(file.Path)
+	if err != nil {
+		filename = file.Path
+	}
+	return &File{
+		Path:         rel,
+		Dir:          dir,
+		LogicalName:  path.Base(rel),
+		BaseFileName: strings.TrimSuffix(path.Base(rel), ext),
+		Ext:          strings.TrimPrefix(ext, "."),
+		Filename:     filename,
+	}
+}
+
+// Page returns the page itself so partials can always reach it as .Page
+func (p *Page) Page() *Page {
+	return p
+}
+
+// IsHome reports whether the page is the site home page
+func (p *Page) IsHome() bool {
+	return p.Kind == KindHome
+}
+
+// IsPage reports whether the page is a regular content page
+func (p *Page) IsPage() bool {
+	return p.Kind == KindPage
+}
+
+// Lang returns the language of the page
+func (p *Page) Lang() string {
+	return p.Site.Lang()
+}
+
+// Plain returns the rendered content with HTML tags removed
+func (p *Page) Plain() string {
+	return plainify(string(p.Content))
+}
+
+// Summary returns the description, or the first words of the content when there is none; CJK
+// pages are cut by characters with cjk.enabled
+func (p *Page) Summary() string {
+	if p.Description != "" {
+		return p.Description
+	}
+	if p.cjkPage() {
+		length := p.Site.Config.CJK.SummaryLength
+		if length <= 0 {
+			length = defaultCJKSummaryLength
+		}
+		return cjkSummary(p.Plain(), length)
+	}
+	words := strings.Fields(p.Plain())
+	if len(words) <= summaryLength {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:summaryLength], " ") + "…"
+}
+
+// loadPage reads a Markdown file, parses its front matter and converts its content
+func loadPage(file contentFile) (*Page, FrontMatter, error) {
+	content := file.data
+	if content == nil {
+		if err := checkReadPath(file.Path); err != nil {
+			return nil, FrontMatter{}, err
+		}
+		var err error
+		if content, err = os.ReadFile(file.Path); err != nil {
+			return nil, FrontMatter{}, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+	content, encoding, err := decodeContent(content)
+	if err != nil {
+		return nil, FrontMatter{}, fmt.Errorf("failed to decode %s file: %w", encoding, err)
+	}
+	if encoding != "" {
+		log.Printf("Converted %s from %s", file.Path, encoding)
+	}
+	if !utf8.Valid(content) {
+		warnf("%s is not valid UTF-8", file.Path)
+	}
+
+	frontMatter, markdownContent, err := extractFrontMatter(content)
+	if err != nil {
+		warnf("Malformed front matter in %s: %v", file.Path, err)
+		// Set front matter to default values if parsing fails
+		frontMatter = FrontMatter{Params: map[string]any{}, raw: frontMatter.raw}
+	}
+
+	htmlContent, err := convertMarkdownToHTML(markdownContent)
+	if err != nil {
+		return nil, frontMatter, fmt.Errorf("failed to convert Markdown: %w", err)
+	}
+
+	draft, _ := frontMatter.Params["draft"].(bool)
+	unlisted, _ := frontMatter.Params["unlisted"].(bool)
+	weight, _ := toFloat(frontMatter.Params["weight"])
+	id, _ := frontMatter.Params[idKey].(string)
+	page := &Page{
+		Kind:        KindPage,
+		ID:          id,
+		Title:       frontMatter.Title,
+		Description: frontMatter.Description,
+		Params:      frontMatter.Params,
+		Draft:       draft,
+		Unlisted:    unlisted,
+		Weight:      int(weight),
+		Content:     template.HTML(htmlContent),
+		Layout:      frontMatter.Layout,
+		File:        newFile(file),
+		Event:       newEvent(frontMatter.Params["event"], file.Path),
+		Location:    newLocation(frontMatter.Params["location"], file.Path),
+
+		RawFrontMatter: frontMatter.raw,
+		RawContent:     string(markdownContent),
+
+		source: &file,
+	}
+	page.Section = fileSection(file)
+	return page, frontMatter, nil
+}
+
+// fileSection returns the top-level content directory of a content file, empty at the root
+func fileSection(file contentFile) string {
+	dir := filepath.Dir(file.RelPath)
+	if file.IsBundle {
+		dir = filepath.Dir(dir)
+	}
+	if dir = filepath.ToSlash(dir); dir != "." {
+		return strings.Split(dir, "/")[0]
+	}
+	return ""
+}
+
+// extractFrontMatter separates the front matter from the Markdown content
+func extractFrontMatter(content []byte) (FrontMatter, []byte, error) {
+	fm := FrontMatter{Params: map[string]any{}}
+	// Files saved on Windows use CRLF, which would hide the closing delimiter line
+	contentStr := strings.ReplaceAll(string(content), "\r\n", "\n")
+
+	if strings.HasPrefix(contentStr, "---") || strings.HasPrefix(contentStr, "+++") {
+		delimiter := contentStr[:3]
+		meta, body, found := splitFrontMatter(contentStr, delimiter)
+		if found {
+			fm.raw = meta
+
+			if delimiter == "---" {
+				if err := yaml.Unmarshal([]byte(meta), &fm); err != nil {
+					return fm, []byte(body), fmt.Errorf("failed to parse YAML front matter: %w", err)
+				}
+				if err := yaml.Unmarshal([]byte(meta), &fm.Params); err != nil {
+					return fm, []byte(body), fmt.Errorf("failed to parse YAML front matter: %w", err)
+				}
+			} else {
+				if err := toml.Unmarshal([]byte(meta), &fm); err != nil {
+					return fm, []byte(body), fmt.Errorf("failed to parse TOML front matter: %w", err)
+				}
+				if err := toml.Unmarshal([]byte(meta), &fm.Params); err != nil {
+					return fm, []byte(body), fmt.Errorf("failed to parse TOML front matter: %w", err)
+				}
+			}
+			if fm.Params == nil {
+				fm.Params = map[string]any{}
+			}
+			return fm, []byte(body), nil
+		}
+		return fm, []byte(contentStr), fmt.Errorf("no valid front matter delimiter found")
+	}
+	return fm, []byte(contentStr), nil
+}
+
+// splitFrontMatter returns the front matter between the opening delimiter line and the next
+// line holding only the delimiter, and the content after it, which may be empty
+func splitFrontMatter(content, delimiter string) (string, string, bool) {
+	_, rest, ok := strings.Cut(content, "\n")
+	if !ok || strings.TrimRight(content[:len(content)-len(rest)], " \t\n") != delimiter {
+		return "", "", false
+	}
+	var meta strings.Builder
+	for rest != "" {
+		line, next, _ := strings.Cut(rest, "\n")
+		if strings.TrimRight(line, " \t") == delimiter {
+			return meta.String(), next, true
+		}
+		meta.WriteString(line)
+		meta.WriteByte('\n')
+		rest = next
+	}
+	return "", "", false
+}
+
+// convertMarkdownToHTML converts Markdown to HTML using goldmark
+func convertMarkdownToHTML(content []byte) (string, error) {
+	md := goldmark.New()
+	var buf strings.Builder
+	if err := md.Convert(content, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// plainify strips HTML tags and unescapes entities
+func plainify(s string) string {
+	return html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))
+}
+
+// toTime converts a front matter date value into a time, returning the zero time when it cannot
+func toTime(v any) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case toml.LocalDate:
+		return t.AsTime(time.UTC)
+	case toml.LocalDateTime:
+		return t.AsTime(time.UTC)
+	case string:
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed
+			}
+		}
+	}
+	return time.Time{}
+}
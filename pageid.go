@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// idKey is the front matter key holding the stable identifier of a page
+const idKey = "id"
+
+// newUUID returns a random version 4 UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// runID implements `id assign`, which writes a new UUID into the front matter of every content
+// file without an id. The id is exposed to templates as .ID and stays with the page when the file
+// is renamed or moved, so comments, analytics and translations can refer to it.
+func runID(args []string) {
+	if len(args) == 0 || args[0] != "assign" {
+		log.Fatalf("Usage: id assign [--dry-run]")
+	}
+	flags := flag.NewFlagSet("id assign", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the files that would get an id without changing them")
+	flags.Parse(args[1:])
+
+	files, _, err := collectContent("./content/")
+	if err != nil {
+		log.Fatalf("Failed to read content directory: %v", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].RelPath < files[j].RelPath })
+
+	seen := map[string]string{}
+	assigned := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", file.Path, err)
+		}
+		if _, encoding, _ := decodeContent(data); encoding != "" {
+			warnf("Skipping %s, only UTF-8 files without a BOM are rewritten", file.RelPath)
+			continue
+		}
+		fm, _, err := extractFrontMatter(data)
+		if err != nil {
+			warnf("Skipping %s: %v", file.RelPath, err)
+			continue
+		}
+		if id := fmt.Sprint(fm.Params[idKey]); fm.Params[idKey] != nil && id != "" {
+			// A copied file keeps the id of its original, which has to be changed by hand
+			if other, ok := seen[id]; ok {
+				warnf("%s has the id of %s", file.RelPath, other)
+			}
+			seen[id] = file.RelPath
+			continue
+		}
+
+		id, err := newUUID()
+		if err != nil {
+			log.Fatalf("Failed to generate an id: %v", err)
+		}
+		fmt.Printf("%s %s\n", id, file.RelPath)
+		assigned++
+		if *dryRun {
+			continue
+		}
+		if err := os.WriteFile(file.Path, insertFrontMatterKey(data, idKey, id), 0644); err != nil {
+			log.Fatalf("Failed to write %s: %v", file.Path, err)
+		}
+	}
+	verb := "Assigned"
+	if *dryRun {
+		verb = "Would assign"
+	}
+	fmt.Printf("%s %d id(s)\n", verb, assigned)
+}
+
+// insertFrontMatterKey adds a string key as the first line of the front matter, keeping the rest
+// of the file as written; a file without front matter gets YAML front matter
+func insertFrontMatterKey(data []byte, key, value string) []byte {
+	newline := "\n"
+	if first, _, _ := bytes.Cut(data, []byte("\n")); bytes.HasSuffix(first, []byte("\r")) {
+		newline = "\r\n"
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("---")):
+		first, rest, _ := bytes.Cut(data, []byte("\n"))
+		return []byte(fmt.Sprintf("%s\n%s: %q%s%s", first, key, value, newline, rest))
+	case bytes.HasPrefix(data, []byte("+++")):
+		first, rest, _ := bytes.Cut(data, []byte("\n"))
+		return []byte(fmt.Sprintf("%s\n%s = %q%s%s", first, key, value, newline, rest))
+	}
+	header := strings.Join([]string{"---", fmt.Sprintf("%s: %q", key, value), "---", ""}, newline)
+	return append([]byte(header), data...)
+}
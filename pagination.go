@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Paginator splits the pages of a list page into numbered pagers
+type Paginator struct {
+	PageNumber int
+	TotalPages int
+	PagerSize  int
+	Pages      []*Page
+
+	// pagers holds the list page of every pager, in order
+	pagers []*Page
+}
+
+// HasPrev reports whether there is a previous pager
+func (pg *Paginator) HasPrev() bool {
+	return pg.PageNumber > 1
+}
+
+// HasNext reports whether there is a next pager
+func (pg *Paginator) HasNext() bool {
+	return pg.PageNumber < pg.TotalPages
+}
+
+// Prev returns the list page of the previous pager, or nil on the first one
+func (pg *Paginator) Prev() *Page {
+	if !pg.HasPrev() {
+		return nil
+	}
+	return pg.pagers[pg.PageNumber-2]
+}
+
+// Next returns the list page of the next pager, or nil on the last one
+func (pg *Paginator) Next() *Page {
+	if !pg.HasNext() {
+		return nil
+	}
+	return pg.pagers[pg.PageNumber]
+}
+
+// First returns the list page of the first pager
+func (pg *Paginator) First() *Page {
+	return pg.pagers[0]
+}
+
+// Last returns the list page of the last pager
+func (pg *Paginator) Last() *Page {
+	return pg.pagers[len(pg.pagers)-1]
+}
+
+// Pagers returns the list pages of every pager for numbered navigation
+func (pg *Paginator) Pagers() []*Page {
+	return pg.pagers
+}
+
+// Canonical returns the canonical URL of the page: the `canonical` front matter value or its permalink.
+// Every pager of a paginated list is its own canonical page rather than pointing at the first one.
+func (p *Page) Canonical() string {
+	if v, ok := p.Params["canonical"].(string); ok && v != "" {
+		return v
+	}
+	return p.Permalink
+}
+
+// PaginationConfig configures list pagination
+type PaginationConfig struct {
+	PagerSize int `toml:"pagerSize"`
+	// Taxonomies sets the pager size of the term pages of a taxonomy, keyed by its plural name;
+	// a negative size leaves them unpaginated
+	Taxonomies map[string]int `toml:"taxonomies"`
+}
+
+// pagerSize returns the configured pager size of a list page, 0 or less when it is not paginated
+func (s *Site) pagerSize(list *Page) int {
+	if size, ok := s.Config.Pagination.Taxonomies[list.Taxonomy]; ok && list.Kind == KindTerm && size != 0 {
+		return size
+	}
+	if size := s.Config.Pagination.PagerSize; size != 0 {
+		return size
+	}
+	return s.Config.Paginate
+}
+
+// paginate splits a list page into pagers of the configured size, served under page/N/.
+// The first pager keeps the list URL and page/1/ redirects to it.
+func (s *Site) paginate(list *Page) {
+	size := s.pagerSize(list)
+	if size <= 0 {
+		return
+	}
+	total := (len(list.Pages) + size - 1) / size
+	if total == 0 {
+		total = 1
+	}
+
+	pagers := make([]*Page, total)
+	base := strings.TrimPrefix(strings.TrimPrefix(list.RelPermalink, s.basePath()), "/")
+	for i := 0; i < total; i++ {
+		pager := list
+		if i > 0 {
+			clone := *list
+			pager = &clone
+			s.setURL(pager, urlPath(base, "page", strconv.Itoa(i+1), "/"))
+			s.AllPages = append(s.AllPages, pager)
+		}
+		end := min((i+1)*size, len(list.Pages))
+		pager.Paginator = &Paginator{
+			PageNumber: i + 1,
+			TotalPages: total,
+			PagerSize:  size,
+			Pages:      list.Pages[i*size : end],
+			pagers:     pagers,
+		}
+		pagers[i] = pager
+	}
+	s.Aliases[urlPath(base, "page", "1", "/")] = list.Permalink
+}
+
+// renderAliases writes a redirect page for every alias URL
+func (s *Site) renderAliases(outputDir string) error {
+	for from, to := range s.Aliases {
+		rel := from
+		if strings.HasSuffix(from, "/") {
+			rel += "index.html"
+		}
+		dest, err := outputFile(outputDir, rel)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create alias directory: %w", err)
+		}
+		target := html.EscapeString(to)
+		page := fmt.Sprintf(aliasTemplate, target, target, target)
+		if err := os.WriteFile(dest, []byte(page), 0644); err != nil {
+			return fmt.Errorf("failed to write alias %s: %w", from, err)
+		}
+	}
+	return nil
+}
+
+const aliasTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<title>%s</title>
+<link rel="canonical" href="%s">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url=%s">
+</head>
+</html>
+`
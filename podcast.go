@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PodcastConfig describes the podcast published from a section, see [podcasts.<section>]
+type PodcastConfig struct {
+	Title       string `toml:"title"`
+	Description string `toml:"description"`
+	Author      string `toml:"author"`
+	Email       string `toml:"email"`
+	// Image is the cover art, a site path or URL of a square image of at least 1400px
+	Image       string `toml:"image"`
+	Category    string `toml:"category"`
+	Subcategory string `toml:"subcategory"`
+	Explicit    bool   `toml:"explicit"`
+	// Type is "episodic" (newest first, the default) or "serial"
+	Type string `toml:"type"`
+}
+
+type podcastFeed struct {
+	XMLName xml.Name       `xml:"rss"`
+	Version string         `xml:"version,attr"`
+	ITunes  string         `xml:"xmlns:itunes,attr"`
+	Content string         `xml:"xmlns:content,attr"`
+	Channel podcastChannel `xml:"channel"`
+}
+
+type podcastChannel struct {
+	Title         string           `xml:"title"`
+	Link          string           `xml:"link"`
+	Description   string           `xml:"description"`
+	Language      string           `xml:"language,omitempty"`
+	Generator     string           `xml:"generator"`
+	LastBuildDate string           `xml:"lastBuildDate"`
+	Author        string           `xml:"itunes:author,omitempty"`
+	Owner         *podcastOwner    `xml:"itunes:owner,omitempty"`
+	Image         *podcastImage    `xml:"itunes:image,omitempty"`
+	Category      *podcastCategory `xml:"itunes:category,omitempty"`
+	Explicit      string           `xml:"itunes:explicit"`
+	Type          string           `xml:"itunes:type,omitempty"`
+	Items         []podcastItem    `xml:"item"`
+}
+
+type podcastOwner struct {
+	Name  string `xml:"itunes:name,omitempty"`
+	Email string `xml:"itunes:email"`
+}
+
+type podcastImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type podcastCategory struct {
+	Text        string           `xml:"text,attr"`
+	Subcategory *podcastCategory `xml:"itunes:category,omitempty"`
+}
+
+type podcastItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        string        `xml:"guid"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Description string        `xml:"description"`
+	Content     string        `xml:"content:encoded,omitempty"`
+	Enclosure   rssEnclosure  `xml:"enclosure"`
+	Duration    string        `xml:"itunes:duration,omitempty"`
+	Image       *podcastImage `xml:"itunes:image,omitempty"`
+	Episode     int           `xml:"itunes:episode,omitempty"`
+	Season      int           `xml:"itunes:season,omitempty"`
+	EpisodeType string        `xml:"itunes:episodeType,omitempty"`
+	Explicit    string        `xml:"itunes:explicit,omitempty"`
+}
+
+// podcastLink sets the podcast feed URL of a section configured as a podcast
+func (s *Site) podcastLink(list *Page) {
+	if _, ok := s.Config.Podcasts[list.Section]; ok && list.Kind == KindSection {
+		list.PodcastLink = strings.TrimSuffix(list.Permalink, "/") + "/podcast.xml"
+	}
+}
+
+// renderPodcasts writes an iTunes-compatible podcast.xml for every section configured as a
+// podcast. Each page with an audio or video resource is an episode; its first audio file wins.
+func (s *Site) renderPodcasts(outputDir string) (int, error) {
+	var written int
+	for _, list := range s.Sections {
+		cfg, ok := s.Config.Podcasts[list.Section]
+		if !ok {
+			continue
+		}
+		channel := podcastChannel{
+			Title:         cfg.Title,
+			Link:          list.Permalink,
+			Description:   cfg.Description,
+			Language:      s.LanguageCode,
+			Generator:     "herocgo " + version,
+			LastBuildDate: s.Hero.BuildDate.Format(time.RFC1123Z),
+			Author:        cfg.Author,
+			Explicit:      fmt.Sprint(cfg.Explicit),
+			Type:          cfg.Type,
+		}
+		if channel.Title == "" {
+			channel.Title = list.Title + " on " + s.Title
+		}
+		if channel.Description == "" {
+			channel.Description = s.Description
+		}
+		if cfg.Email != "" {
+			channel.Owner = &podcastOwner{Name: cfg.Author, Email: cfg.Email}
+		}
+		if cfg.Image != "" {
+			image := cfg.Image
+			if !strings.Contains(image, "://") {
+				image = s.AbsURL(image)
+			}
+			channel.Image = &podcastImage{Href: image}
+		}
+		if cfg.Category != "" {
+			channel.Category = &podcastCategory{Text: cfg.Category}
+			if cfg.Subcategory != "" {
+				channel.Category.Subcategory = &podcastCategory{Text: cfg.Subcategory}
+			}
+		}
+
+		for _, p := range currentPages(list.Pages) {
+			media := p.Resources.ByType("audio")
+			if len(media) == 0 {
+				media = p.Resources.ByType("video")
+			}
+			if len(media) == 0 {
+				continue
+			}
+			episode := media[0]
+			item := podcastItem{
+				Title:       p.Title,
+				Link:        p.Permalink,
+				GUID:        p.Permalink,
+				Description: p.Summary(),
+				Content:     string(p.Content),
+				Enclosure:   rssEnclosure{URL: episode.Permalink, Type: episode.MediaType, Length: episode.Size()},
+				EpisodeType: stringParam(p, "episodeType"),
+			}
+			if !p.Date.IsZero() {
+				item.PubDate = p.Date.Format(time.RFC1123Z)
+			}
+			if d := episode.Duration(); d > 0 {
+				item.Duration = formatDuration(d)
+			}
+			if p.OGImage != "" {
+				item.Image = &podcastImage{Href: p.OGImage}
+			}
+			n, _ := toFloat(p.Params["episode"])
+			item.Episode = int(n)
+			n, _ = toFloat(p.Params["season"])
+			item.Season = int(n)
+			if explicit, ok := p.Params["explicit"].(bool); ok {
+				item.Explicit = fmt.Sprint(explicit)
+			}
+			channel.Items = append(channel.Items, item)
+		}
+
+		feed := podcastFeed{
+			Version: "2.0",
+			ITunes:  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+			Content: "http://purl.org/rss/1.0/modules/content/",
+			Channel: channel,
+		}
+		data, err := xml.MarshalIndent(feed, "", "  ")
+		if err != nil {
+			return written, fmt.Errorf("failed to encode podcast for %s: %w", list.RelPermalink, err)
+		}
+		dest, err := outputFile(outputDir, path.Join(filepath.ToSlash(filepath.Dir(list.outputPath)), "podcast.xml"))
+		if err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(dest, append([]byte(xml.Header), data...), 0644); err != nil {
+			return written, fmt.Errorf("failed to write podcast %s: %w", dest, err)
+		}
+		written++
+	}
+	return written, nil
+}
+
+// stringParam returns a string front matter param of the page, or ""
+func stringParam(p *Page, key string) string {
+	v, _ := p.Params[key].(string)
+	return v
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// CompressConfig configures the precompressed companions written next to text files of the
+// output, for servers that serve them directly (e.g. nginx gzip_static and brotli_static)
+type CompressConfig struct {
+	// Formats lists gzip and br; nothing is precompressed when empty
+	Formats []string `toml:"formats"`
+	// MinSize is the smallest file in bytes that is compressed, 1024 by default
+	MinSize    int64    `toml:"minSize"`
+	Extensions []string `toml:"extensions"`
+	// Encoders overrides the external command of a format, with {input} and {output}.
+	// Gzip is compressed in-process unless an encoder is configured for it.
+	Encoders map[string]string `toml:"encoders"`
+}
+
+// defaultCompressExtensions are the text formats worth compressing
+var defaultCompressExtensions = []string{".html", ".css", ".js", ".mjs", ".json", ".xml", ".svg", ".txt", ".webmanifest", ".map", ".ics"}
+
+// defaultCompressEncoders write the formats Go cannot compress itself
+var defaultCompressEncoders = map[string]string{
+	"br": "brotli --best --force --output={output} {input}",
+}
+
+// compressExtensions maps a format to the extension of its companion files
+var compressExtensions = map[string]string{"gzip": ".gz", "br": ".br"}
+
+// precompress writes a .gz and/or .br companion for every text file of the output above the
+// size threshold and returns the number of files written
+func (s *Site) precompress(outputDir string) (int, error) {
+	cfg := s.Config.Compress
+	if len(cfg.Formats) == 0 {
+		return 0, nil
+	}
+	if cfg.MinSize == 0 {
+		cfg.MinSize = 1024
+	}
+	if len(cfg.Extensions) == 0 {
+		cfg.Extensions = defaultCompressExtensions
+	}
+	for _, format := range cfg.Formats {
+		if compressExtensions[format] == "" {
+			return 0, fmt.Errorf("unsupported compression format %q", format)
+		}
+	}
+	extensions := map[string]bool{}
+	for _, ext := range cfg.Extensions {
+		extensions[strings.ToLower(ext)] = true
+	}
+
+	var files []string
+	err := filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !extensions[strings.ToLower(filepath.Ext(p))] {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() >= cfg.MinSize {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan output: %w", err)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		written int
+		errs    []error
+		limit   = make(chan struct{}, runtime.NumCPU())
+	)
+	for _, file := range files {
+		for _, format := range cfg.Formats {
+			wg.Add(1)
+			limit <- struct{}{}
+			go func(file, format string) {
+				defer func() { <-limit; wg.Done() }()
+				if s.ctx.Err() != nil {
+					return
+				}
+				err := compressFile(s.ctx, file, file+compressExtensions[format], format, cfg.Encoders)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to compress %s: %w", file, err))
+					return
+				}
+				written++
+			}(file, format)
+		}
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return written, errs[0]
+	}
+	return written, nil
+}
+
+// compressFile writes the compressed companion of a file, with gzip in-process or an encoder command
+func compressFile(ctx context.Context, src, dest, format string, encoders map[string]string) error {
+	command := encoders[format]
+	if command == "" && format == "gzip" {
+		return gzipFile(src, dest)
+	}
+	if command == "" {
+		command = defaultCompressEncoders[format]
+	}
+	return encodeFile(ctx, command, src, dest)
+}
+
+// gzipFile compresses a file at the best compression level
+func gzipFile(src, dest string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w, err := gzip.NewWriterLevel(out, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// encodeFile runs an external encoder command, replacing {input} and {output} in its arguments;
+// the command is killed when ctx ends
+func encodeFile(ctx context.Context, command, input, output string) error {
+	replacer := strings.NewReplacer("{input}", input, "{output}", output)
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty encoder command")
+	}
+	args := make([]string, len(fields))
+	for i, field := range fields {
+		args[i] = replacer.Replace(field)
+	}
+	defer trackTask(ctx, "%s %s", args[0], input)()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("encoder %s failed: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
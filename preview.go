@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// PreviewConfig configures `preview`, which builds the site for a temporary URL and uploads it,
+// see [preview]. {id} in both values is replaced by the preview id, {dir} and {url} in the
+// command by the build directory and the preview URL. For example, a bucket prefix:
+//
+//	url = "https://previews.example.com/{id}/"
+//	command = "aws s3 sync {dir} s3://previews-bucket/{id}/ --delete"
+//
+// or a Netlify draft deploy:
+//
+//	url = "https://{id}--my-site.netlify.app/"
+//	command = "netlify deploy --dir={dir} --alias={id}"
+type PreviewConfig struct {
+	URL     string `toml:"url"`
+	Command string `toml:"command"`
+}
+
+// previewDir receives the build of `preview`, so public/ is left untouched
+var previewDir = filepath.Join(".herocgo", "preview")
+
+// runPreview implements `preview`: it builds the site with the preview URL as base URL, runs
+// the upload command and prints the URL
+func runPreview(args []string) {
+	flags := flag.NewFlagSet("preview", flag.ExitOnError)
+	opts := buildOptions{PublicDir: previewDir}
+	flags.StringVar(&opts.Environment, "environment", envOr("HERO_ENVIRONMENT", "production"), "build environment exposed to templates as hero.Environment")
+	id := flags.String("id", "", "preview id, by default the current git branch")
+	publishFlags(flags, &opts)
+	flags.Parse(args)
+
+	config, err := loadEnvironmentConfig(opts.Environment)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	cfg := config.Preview
+	if cfg.URL == "" || cfg.Command == "" {
+		log.Fatalf("Configure preview.url and preview.command to deploy previews")
+	}
+	if *id == "" {
+		*id = previewID()
+	}
+	*id = slugify(*id, SlugASCII)
+	if *id == "" {
+		log.Fatalf("No preview id, use --id")
+	}
+	opts.BaseURL = strings.TrimSuffix(strings.ReplaceAll(cfg.URL, "{id}", *id), "/") + "/"
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stats, _, err := scratchBuild(ctx, opts)
+	if err != nil {
+		log.Fatalf("Failed to build site: %v", err)
+	}
+	fmt.Printf("Built %d pages for %s\n", stats.Pages, opts.BaseURL)
+
+	dir, err := filepath.Abs(previewDir)
+	if err != nil {
+		log.Fatalf("Failed to resolve %s: %v", previewDir, err)
+	}
+	replacer := strings.NewReplacer("{dir}", dir, "{id}", *id, "{url}", opts.BaseURL)
+	fields := strings.Fields(cfg.Command)
+	for i, field := range fields {
+		fields[i] = replacer.Replace(field)
+	}
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Fatalf("Failed to upload preview: %v", err)
+	}
+	fmt.Printf("Preview: %s\n", opts.BaseURL)
+}
+
+// previewID returns the current git branch, or the short commit on a detached HEAD
+func previewID() string {
+	branch, err := gitOutput("", "rev-parse", "--abbrev-ref", "HEAD")
+	if err == nil && branch != "HEAD" {
+		return branch
+	}
+	commit, err := gitOutput("", "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return commit
+}
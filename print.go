@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// OutputsConfig configures the extra output formats of pages:
+//
+//	[outputs.print]
+//	enabled = true
+//
+//	[outputs.pdf]
+//	command = "chromium --headless --no-pdf-header-footer --print-to-pdf={output} {input}"
+//
+//	[outputs.amp]
+//	enabled = true
+//	sections = ["news"]
+type OutputsConfig struct {
+	Print PrintConfig `toml:"print"`
+	PDF   PDFConfig   `toml:"pdf"`
+	AMP   AMPConfig   `toml:"amp"`
+}
+
+// PrintConfig configures the print-optimized variant of pages, written below public/print/
+// from the print.html layout of the site or theme, or a built-in one
+type PrintConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Kinds lists the page kinds that get a print variant, page by default
+	Kinds []string `toml:"kinds"`
+}
+
+// PDFConfig configures the PDFs converted from the print variants into public/pdf/; setting a
+// command also enables the print variants
+type PDFConfig struct {
+	// Command converts a print variant, with {input} and {output}, such as headless Chrome or
+	// "wkhtmltopdf --quiet {input} {output}"
+	Command string `toml:"command"`
+}
+
+//go:embed embedded/print.html
+var defaultPrintTemplate string
+
+// printEnabled reports whether pages get a print variant
+func (s *Site) printEnabled() bool {
+	return s.Config.Outputs.Print.Enabled || s.Config.Outputs.PDF.Command != ""
+}
+
+// printPages returns the pages of the configured kinds that get a print variant; front matter
+// `print: false` opts a page out
+func (s *Site) printPages() []*Page {
+	if !s.printEnabled() {
+		return nil
+	}
+	kinds := s.Config.Outputs.Print.Kinds
+	if len(kinds) == 0 {
+		kinds = []string{KindPage}
+	}
+	var pages []*Page
+	for _, p := range s.AllPages {
+		if slices.Contains(kinds, p.Kind) && p.Params["print"] != false {
+			pages = append(pages, p)
+		}
+	}
+	return pages
+}
+
+// printPath returns the slash-separated output path of the print variant of a page
+func printPath(p *Page) string {
+	return path.Join("print", filepath.ToSlash(p.outputPath))
+}
+
+// pdfPath returns the slash-separated output path of the PDF of a page
+func pdfPath(p *Page) string {
+	return path.Join("pdf", strings.TrimSuffix(filepath.ToSlash(p.outputPath), ".html")+".pdf")
+}
+
+// setPrintLinks sets PrintLink and PDFLink before pages are rendered, so layouts can link them
+func (s *Site) setPrintLinks() {
+	for _, p := range s.printPages() {
+		p.PrintLink = s.RelURL(printPath(p))
+		if s.Config.Outputs.PDF.Command != "" {
+			p.PDFLink = s.RelURL(pdfPath(p))
+		}
+	}
+}
+
+// renderPrintPages writes the print variant of every print page and converts it to a PDF when a
+// command is configured. Unchanged variants are not rewritten, and PDFs newer than their variant
+// are not converted again. It returns the number of variants written.
+func (s *Site) renderPrintPages(outputDir string, templates *TemplateCache) int {
+	pages := s.printPages()
+	if len(pages) == 0 {
+		return 0
+	}
+	tmpl, err := variantTemplate(templates, "print.html", defaultPrintTemplate)
+	if err != nil {
+		warnf("%v", err)
+		return 0
+	}
+
+	var (
+		wg      sync.WaitGroup
+		written int
+		limit   = make(chan struct{}, runtime.NumCPU())
+		command = s.Config.Outputs.PDF.Command
+	)
+	for _, page := range pages {
+		if s.ctx.Err() != nil {
+			break
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, page); err != nil {
+			warnf("Failed to render print variant of %s: %v", page.RelPermalink, err)
+			continue
+		}
+		dest, err := outputFile(outputDir, printPath(page))
+		if err != nil {
+			warnf("Skipping print variant of %s: %v", page.RelPermalink, err)
+			continue
+		}
+		if err := writeVariant(dest, buf.Bytes()); err != nil {
+			warnf("Failed to write print variant of %s: %v", page.RelPermalink, err)
+			continue
+		}
+		written++
+		if command == "" {
+			continue
+		}
+
+		pdf, err := outputFile(outputDir, pdfPath(page))
+		if err != nil {
+			warnf("Skipping PDF of %s: %v", page.RelPermalink, err)
+			continue
+		}
+		if upToDate(pdf, dest) {
+			continue
+		}
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(page *Page, input, output string) {
+			defer func() { <-limit; wg.Done() }()
+			if err := convertPDF(s, command, input, output); err != nil {
+				warnf("Failed to create PDF of %s: %v", page.RelPermalink, err)
+			}
+		}(page, dest, pdf)
+	}
+	wg.Wait()
+	return written
+}
+
+// variantTemplate parses the named layout of the site or theme, or the built-in text when there
+// is none, for the variants of pages that are complete documents of their own
+func variantTemplate(templates *TemplateCache, name, builtin string) (*template.Template, error) {
+	text := builtin
+	if file, ok := templates.lookup(name); ok {
+		if err := checkReadPath(file); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New(name).Funcs(templates.funcs).Parse(text)
+	if err == nil {
+		err = parseInternalTemplates(tmpl)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// writeVariant writes a rendered variant to dest unless the file already holds it
+func writeVariant(dest string, data []byte) error {
+	if existing, err := os.ReadFile(dest); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0644)
+}
+
+// convertPDF runs the PDF command, removing a partial output when it fails
+func convertPDF(s *Site, command, input, output string) error {
+	if err := os.MkdirAll(filepath.Dir(output), os.ModePerm); err != nil {
+		return err
+	}
+	if err := encodeFile(s.ctx, command, input, output); err != nil {
+		os.Remove(output)
+		return err
+	}
+	return nil
+}
+
+// upToDate reports whether the output exists and is not older than its input
+func upToDate(output, input string) bool {
+	out, err := os.Stat(output)
+	if err != nil {
+		return false
+	}
+	in, err := os.Stat(input)
+	return err == nil && !out.ModTime().Before(in.ModTime())
+}
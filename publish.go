@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// publishFlags registers the flags that include unpublished pages in a build
+func publishFlags(flags *flag.FlagSet, opts *buildOptions) {
+	flags.BoolVar(&opts.BuildDrafts, "buildDrafts", false, "include pages marked as drafts")
+	flags.BoolVar(&opts.BuildFuture, "buildFuture", false, "include pages with a publish date in the future")
+	flags.BoolVar(&opts.BuildExpired, "buildExpired", false, "include pages past their expiry date")
+}
+
+// IsFuture reports whether the page is scheduled after the build time
+func (p *Page) IsFuture() bool {
+	return p.PublishDate.After(p.Site.Hero.BuildDate)
+}
+
+// IsExpired reports whether the expiry date of the page has passed at build time
+func (p *Page) IsExpired() bool {
+	return !p.ExpiryDate.IsZero() && p.ExpiryDate.Before(p.Site.Hero.BuildDate)
+}
+
+// PublishStatus returns "published", or the reasons the page is not published, e.g. "draft, future"
+func (p *Page) PublishStatus() string {
+	var reasons []string
+	if p.Draft {
+		reasons = append(reasons, "draft")
+	}
+	if p.IsFuture() {
+		reasons = append(reasons, "future")
+	}
+	if p.IsExpired() {
+		reasons = append(reasons, "expired")
+	}
+	if len(reasons) == 0 {
+		return "published"
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// shouldBuild reports whether the build options include the page
+func (s *Site) shouldBuild(p *Page) bool {
+	return (!p.Draft || s.options.BuildDrafts) &&
+		(!p.IsFuture() || s.options.BuildFuture) &&
+		(!p.IsExpired() || s.options.BuildExpired)
+}
+
+// listedPage is one row of `list --format=json`
+type listedPage struct {
+	Path        string    `json:"path"`
+	Title       string    `json:"title"`
+	Date        time.Time `json:"date"`
+	PublishDate time.Time `json:"publishDate"`
+	ExpiryDate  time.Time `json:"expiryDate"`
+	Draft       bool      `json:"draft"`
+	Status      string    `json:"status"`
+	Permalink   string    `json:"permalink"`
+}
+
+// runList implements `list drafts|future|expired|all`, printing the matching content as a table or JSON
+func runList(args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	format := flags.String("format", "table", "output format: table or json")
+	environment := flags.String("environment", envOr("HERO_ENVIRONMENT", "production"), "environment whose config is used")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		log.Fatalf("Usage: list drafts|future|expired|all [--format=table|json]")
+	}
+	var include func(p *Page) bool
+	switch positional[0] {
+	case "drafts":
+		include = func(p *Page) bool { return p.Draft }
+	case "future":
+		include = (*Page).IsFuture
+	case "expired":
+		include = (*Page).IsExpired
+	case "all":
+		include = func(p *Page) bool { return true }
+	default:
+		log.Fatalf("Unknown list %q, use drafts, future, expired or all", positional[0])
+	}
+	if *format != "table" && *format != "json" {
+		log.Fatalf("Unknown format %q, use table or json", *format)
+	}
+
+	site, _, err := loadSite(buildOptions{Environment: *environment, BuildDrafts: true, BuildFuture: true, BuildExpired: true})
+	if err != nil {
+		log.Fatalf("Failed to load site: %v", err)
+	}
+	var rows []listedPage
+	for _, p := range site.contentPages() {
+		if !include(p) {
+			continue
+		}
+		rows = append(rows, listedPage{
+			Path:        "content/" + p.File.Path,
+			Title:       p.Title,
+			Date:        p.Date,
+			PublishDate: p.PublishDate,
+			ExpiryDate:  p.ExpiryDate,
+			Draft:       p.Draft,
+			Status:      p.PublishStatus(),
+			Permalink:   p.Permalink,
+		})
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(rows); err != nil {
+			log.Fatalf("Failed to encode list: %v", err)
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tTITLE\tDATE\tSTATUS")
+	for _, row := range rows {
+		date := ""
+		if !row.Date.IsZero() {
+			date = row.Date.Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", row.Path, row.Title, date, row.Status)
+	}
+	w.Flush()
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// PWAConfig makes the site an installable progressive web app:
+//
+//	[pwa]
+//	enabled = true
+//	serviceWorker = true
+//	offline = "/offline/"
+//	themeColor = "#1d4ed8"
+//	icons = ["icons/icon-192.png", "icons/icon-512.png"]
+//
+// Enabled writes precache-manifest.json, listing the fingerprinted assets and the pages to keep
+// offline with their revisions, and manifest.webmanifest unless a custom output writes one.
+type PWAConfig struct {
+	Enabled bool `toml:"enabled"`
+	// ServiceWorker writes sw.js from the sw.js layout of the site or theme, or a built-in worker
+	// that precaches the manifest, and registers it through the _internal/pwa.html partial
+	ServiceWorker bool `toml:"serviceWorker"`
+	// Offline is the page served for navigations that fail while offline
+	Offline string `toml:"offline"`
+	// Precache lists more URLs to cache on install besides the home page, the offline page and
+	// the fingerprinted assets
+	Precache []string `toml:"precache"`
+
+	// Name and ShortName default to the site title
+	Name            string `toml:"name"`
+	ShortName       string `toml:"shortName"`
+	Display         string `toml:"display"`
+	ThemeColor      string `toml:"themeColor"`
+	BackgroundColor string `toml:"backgroundColor"`
+	// Icons are static image files; their sizes are read from the images
+	Icons []string `toml:"icons"`
+}
+
+const (
+	precacheManifestName = "precache-manifest.json"
+	webManifestName      = "manifest.webmanifest"
+	serviceWorkerName    = "sw.js"
+)
+
+//go:embed embedded/sw.js
+var defaultServiceWorkerTemplate string
+
+// precacheEntry is a URL cached by the service worker. Revision is empty for fingerprinted
+// assets, whose URL changes with their content.
+type precacheEntry struct {
+	URL      string `json:"url"`
+	Revision string `json:"revision,omitempty"`
+}
+
+// serviceWorkerData is the data of the sw.js layout
+type serviceWorkerData struct {
+	Site *Site
+	// Version changes whenever a precached file does, naming the cache of the worker
+	Version  string
+	Precache []precacheEntry
+	Offline  string
+}
+
+type webManifest struct {
+	Name            string            `json:"name"`
+	ShortName       string            `json:"short_name"`
+	Description     string            `json:"description,omitempty"`
+	StartURL        string            `json:"start_url"`
+	Scope           string            `json:"scope"`
+	Display         string            `json:"display"`
+	Lang            string            `json:"lang,omitempty"`
+	ThemeColor      string            `json:"theme_color,omitempty"`
+	BackgroundColor string            `json:"background_color,omitempty"`
+	Icons           []webManifestIcon `json:"icons,omitempty"`
+}
+
+type webManifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// ServiceWorkerURL returns the URL of the service worker, or "" when the site has none
+func (s *Site) ServiceWorkerURL() string {
+	if !s.Config.PWA.Enabled || !s.Config.PWA.ServiceWorker {
+		return ""
+	}
+	return s.RelURL(serviceWorkerName)
+}
+
+// renderPWA writes the web app manifest, the precache manifest and the service worker. It runs
+// after the static files and HTML are final, as the revisions hash the output.
+func (s *Site) renderPWA(outputDir string, templates *TemplateCache) error {
+	cfg := s.Config.PWA
+	if !cfg.Enabled {
+		return nil
+	}
+	custom := slices.ContainsFunc(s.Config.CustomOutputs, func(o CustomOutput) bool {
+		return strings.Trim(o.Path, "/") == webManifestName
+	})
+	if !custom {
+		if err := s.writeWebManifest(outputDir); err != nil {
+			return err
+		}
+	}
+
+	urls := []string{s.RelURL("/")}
+	if cfg.Offline != "" {
+		urls = append(urls, s.RelURL(cfg.Offline))
+	}
+	for _, u := range cfg.Precache {
+		urls = append(urls, s.RelURL(u))
+	}
+	urls = append(urls, s.RelURL(webManifestName))
+	for _, icon := range cfg.Icons {
+		urls = append(urls, s.RelURL(icon))
+	}
+	var entries []precacheEntry
+	for _, u := range urls {
+		if slices.ContainsFunc(entries, func(e precacheEntry) bool { return e.URL == u }) {
+			continue
+		}
+		revision, err := s.outputRevision(outputDir, u)
+		if err != nil {
+			warnf("Not precaching %s: %v", u, err)
+			continue
+		}
+		entries = append(entries, precacheEntry{URL: u, Revision: revision})
+	}
+	var assets []precacheEntry
+	for _, asset := range s.assets.assets {
+		assets = append(assets, precacheEntry{URL: s.RelURL(asset.name)})
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].URL < assets[j].URL })
+	entries = append(entries, assets...)
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := s.writeOutput(outputDir, precacheManifestName, data); err != nil {
+		return err
+	}
+	if !cfg.ServiceWorker {
+		return nil
+	}
+
+	text := defaultServiceWorkerTemplate
+	if file, ok := templates.lookup(serviceWorkerName); ok {
+		if err := checkReadPath(file); err != nil {
+			return fmt.Errorf("failed to read %s: %w", serviceWorkerName, err)
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", serviceWorkerName, err)
+		}
+		text = string(source)
+	}
+	tmpl, err := template.New(serviceWorkerName).Funcs(templates.funcs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", serviceWorkerName, err)
+	}
+	sum := sha256.Sum256(data)
+	worker := serviceWorkerData{Site: s, Version: hex.EncodeToString(sum[:])[:16], Precache: entries}
+	if cfg.Offline != "" {
+		worker.Offline = s.RelURL(cfg.Offline)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, worker); err != nil {
+		return fmt.Errorf("failed to execute %s: %w", serviceWorkerName, err)
+	}
+	return s.writeOutput(outputDir, serviceWorkerName, buf.Bytes())
+}
+
+// writeWebManifest writes manifest.webmanifest from the PWA configuration
+func (s *Site) writeWebManifest(outputDir string) error {
+	cfg := s.Config.PWA
+	manifest := webManifest{
+		Name:            cfg.Name,
+		ShortName:       cfg.ShortName,
+		Description:     s.Description,
+		StartURL:        s.RelURL("/"),
+		Scope:           s.RelURL("/"),
+		Display:         cfg.Display,
+		Lang:            s.LanguageCode,
+		ThemeColor:      cfg.ThemeColor,
+		BackgroundColor: cfg.BackgroundColor,
+	}
+	if manifest.Name == "" {
+		manifest.Name = s.Title
+	}
+	if manifest.ShortName == "" {
+		manifest.ShortName = manifest.Name
+	}
+	if manifest.Display == "" {
+		manifest.Display = "standalone"
+	}
+	for _, name := range cfg.Icons {
+		icon := webManifestIcon{Src: s.RelURL(name), Type: mime.TypeByExtension(path.Ext(name))}
+		file := s.staticFile(strings.TrimPrefix(name, "/"))
+		if file == "" {
+			warnf("No static file for the icon %s", name)
+		} else if f, err := os.Open(file); err == nil {
+			if config, _, err := image.DecodeConfig(f); err == nil {
+				icon.Sizes = fmt.Sprintf("%dx%d", config.Width, config.Height)
+			}
+			f.Close()
+		}
+		manifest.Icons = append(manifest.Icons, icon)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.writeOutput(outputDir, webManifestName, data)
+}
+
+// outputRevision returns a hash of the output file served at a URL of the site
+func (s *Site) outputRevision(outputDir, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	site, err := url.Parse(s.AbsURL("/"))
+	if err != nil {
+		return "", err
+	}
+	name, ok := strings.CutPrefix(u.Path, site.Path)
+	if !ok {
+		return "", fmt.Errorf("not a URL of the site")
+	}
+	if name == "" || strings.HasSuffix(name, "/") {
+		name += "index.html"
+	}
+	file, err := outputFile(outputDir, name)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
+
+// writeOutput writes a generated file to the slash-separated path of the output directory
+func (s *Site) writeOutput(outputDir, name string, data []byte) error {
+	dest, err := outputFile(outputDir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// pwaHeaders keeps browsers from caching the service worker, so updates reach visitors
+func (s *Site) pwaHeaders() map[string]map[string]string {
+	if s.ServiceWorkerURL() == "" {
+		return nil
+	}
+	return map[string]map[string]string{
+		s.ServiceWorkerURL(): {"Cache-Control": "no-cache"},
+	}
+}
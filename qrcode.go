@@ -0,0 +1,444 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// qrVersion holds the error correction layout of a QR code version at level M
+type qrVersion struct {
+	// ecPerBlock is the number of error correction codewords of every block
+	ecPerBlock int
+	// shortBlocks have dataPerBlock data codewords and longBlocks one more
+	shortBlocks, longBlocks, dataPerBlock int
+	alignment                             []int
+}
+
+// qrVersions are versions 1 to 10 at error correction level M, which hold up to 213 bytes: enough
+// for the URLs and short texts of a page, readable when 15% of the code is damaged
+var qrVersions = []qrVersion{
+	{10, 1, 0, 16, nil},
+	{16, 1, 0, 28, []int{6, 18}},
+	{26, 1, 0, 44, []int{6, 22}},
+	{18, 2, 0, 32, []int{6, 26}},
+	{24, 2, 0, 43, []int{6, 30}},
+	{16, 4, 0, 27, []int{6, 34}},
+	{18, 4, 0, 31, []int{6, 22, 38}},
+	{22, 2, 2, 38, []int{6, 24, 42}},
+	{22, 3, 2, 36, []int{6, 26, 46}},
+	{26, 4, 1, 43, []int{6, 28, 50}},
+}
+
+// dataCodewords returns the number of data codewords of the version
+func (v qrVersion) dataCodewords() int {
+	return v.shortBlocks*v.dataPerBlock + v.longBlocks*(v.dataPerBlock+1)
+}
+
+// qrCode is the matrix of a QR code, true for dark modules
+type qrCode struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// qrcodeSVG is the qrcode template function, returning an inline SVG QR code of the text, e.g.
+// {{ qrcode .Permalink }} or {{ qrcode .Permalink 200 }} for a code 200 pixels wide
+func qrcodeSVG(text string, size ...int) (template.HTML, error) {
+	if len(size) > 1 {
+		return "", fmt.Errorf("qrcode takes a text and at most one size")
+	}
+	qr, err := encodeQR([]byte(text))
+	if err != nil {
+		return "", err
+	}
+	// A quiet zone of four modules surrounds the code
+	const quiet = 4
+	n := qr.size + 2*quiet
+	width := n * 4
+	if len(size) == 1 {
+		width = size[0]
+	}
+	var d strings.Builder
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if !qr.modules[y][x] {
+				continue
+			}
+			run := 1
+			for x+run < qr.size && qr.modules[y][x+run] {
+				run++
+			}
+			fmt.Fprintf(&d, "M%d %dh%dv1h-%dz", x+quiet, y+quiet, run, run)
+			x += run - 1
+		}
+	}
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" class="qrcode" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges" role="img" aria-label="%s"><rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="%s"/></svg>`,
+		width, width, n, n, template.HTMLEscapeString("QR code: "+text), n, n, d.String())
+	return template.HTML(svg), nil
+}
+
+// encodeQR encodes data in byte mode into the smallest version that holds it, with the mask of
+// the lowest penalty
+func encodeQR(data []byte) (*qrCode, error) {
+	number := 0
+	for i, v := range qrVersions {
+		countBits := 8
+		if i+1 >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*v.dataCodewords() {
+			number = i + 1
+			break
+		}
+	}
+	if number == 0 {
+		return nil, fmt.Errorf("%d bytes are too many for a QR code, at most 213 fit", len(data))
+	}
+	version := qrVersions[number-1]
+
+	// Mode, length, data, terminator and padding
+	var bits qrBits
+	bits.append(0b0100, 4)
+	if number >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := 8 * version.dataCodewords()
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	codewords := bits.bytes()
+	for pad := 0xEC; len(codewords) < version.dataCodewords(); pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, byte(pad))
+	}
+
+	qr := newQRCode(number, version)
+	qr.drawCodewords(interleaveQR(codewords, version))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormat(mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask)
+	}
+	qr.applyMask(best)
+	qr.drawFormat(best)
+	return qr, nil
+}
+
+// qrBits is a bit stream, one bool per bit
+type qrBits []bool
+
+// append adds the n low bits of v, most significant first
+func (b *qrBits) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>i&1 == 1)
+	}
+}
+
+// bytes packs the stream, whose length is a multiple of eight
+func (b qrBits) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return out
+}
+
+// interleaveQR splits the data into blocks, adds their error correction and interleaves them
+func interleaveQR(data []byte, v qrVersion) []byte {
+	divisor := reedSolomonDivisor(v.ecPerBlock)
+	var blocks, ecBlocks [][]byte
+	for i := 0; i < v.shortBlocks+v.longBlocks; i++ {
+		n := v.dataPerBlock
+		if i >= v.shortBlocks {
+			n++
+		}
+		blocks = append(blocks, data[:n])
+		ecBlocks = append(ecBlocks, reedSolomonRemainder(data[:n], divisor))
+		data = data[n:]
+	}
+	var out []byte
+	for i := 0; i <= v.dataPerBlock; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, ec := range ecBlocks {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+// reedSolomonDivisor returns the generator polynomial of the degree, highest coefficient first
+// and without the leading 1
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// reedSolomonRemainder returns the error correction codewords of the data
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMultiply(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo the QR polynomial x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// newQRCode draws the function patterns of a version
+func newQRCode(number int, v qrVersion) *qrCode {
+	size := 17 + 4*number
+	qr := &qrCode{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for y := range qr.modules {
+		qr.modules[y] = make([]bool, size)
+		qr.function[y] = make([]bool, size)
+	}
+	for i := 0; i < size; i++ {
+		qr.set(6, i, i%2 == 0)
+		qr.set(i, 6, i%2 == 0)
+	}
+	for _, c := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x >= 0 && x < size && y >= 0 && y < size {
+					dist := max(abs(dx), abs(dy))
+					qr.set(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+	last := len(v.alignment) - 1
+	for i, ay := range v.alignment {
+		for j, ax := range v.alignment {
+			// The corners with finder patterns have no alignment pattern
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.set(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// Reserve the format areas, drawn once the mask is known
+	qr.drawFormat(0)
+	if number >= 7 {
+		rem := number
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1F25
+		}
+		bits := number<<12 | rem
+		for i := 0; i < 18; i++ {
+			bit := bits>>i&1 == 1
+			a, b := size-11+i%3, i/3
+			qr.set(a, b, bit)
+			qr.set(b, a, bit)
+		}
+	}
+	return qr
+}
+
+// set draws a function module at column x and row y
+func (qr *qrCode) set(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+// drawFormat draws both copies of the format information of level M and the mask
+func (qr *qrCode) drawFormat(mask int) {
+	// Level M is 00 in the format bits
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		qr.set(8, i, bit(i))
+	}
+	qr.set(8, 7, bit(6))
+	qr.set(8, 8, bit(7))
+	qr.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		qr.set(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.set(8, qr.size-15+i, bit(i))
+	}
+	qr.set(8, qr.size-8, true)
+}
+
+// drawCodewords places the codewords in the zigzag order of the standard, two columns at a time
+// from the bottom right
+func (qr *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < qr.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if !qr.function[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = data[i>>3]>>(7-i&7)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by the mask; applying it twice undoes it
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !qr.function[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the readability of the code by the four rules of the standard; lower is better
+func (qr *qrCode) penalty() int {
+	n := qr.size
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return qr.modules[x][y]
+		}
+		return qr.modules[y][x]
+	}
+	finder := []bool{true, false, true, true, true, false, true}
+	penalty := 0
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < n; y++ {
+			run := 1
+			for x := 1; x <= n; x++ {
+				if x < n && at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += 3 + run - 5
+				}
+				run = 1
+			}
+			// Finder-like patterns with four light modules on either side
+			for x := 0; x+7 <= n; x++ {
+				match := true
+				for k, dark := range finder {
+					if at(x+k, y, transpose) != dark {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				light := func(from, to int) bool {
+					for k := from; k < to; k++ {
+						if k >= 0 && k < n && at(k, y, transpose) {
+							return false
+						}
+					}
+					return true
+				}
+				if light(x-4, x) || light(x+7, x+11) {
+					penalty += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				c := qr.modules[y][x]
+				if c == qr.modules[y][x+1] && c == qr.modules[y+1][x] && c == qr.modules[y+1][x+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := n * n
+	return penalty + 10*(abs(dark*20-total*10)/total)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+)
+
+// LimitsConfig caps how much a build produces so an upstream data explosion cannot turn into
+// millions of files. Zero means unlimited.
+type LimitsConfig struct {
+	// MaxPages keeps the newest pages and drops the rest
+	MaxPages int `toml:"maxPages"`
+	// MaxWords drops pages, oldest first, once the kept pages hold this many words in total
+	MaxWords int `toml:"maxWords"`
+	// MaxTermPages lists only the newest pages on every taxonomy term page
+	MaxTermPages int `toml:"maxTermPages"`
+}
+
+// WordCount returns the number of words in the content; with cjk.enabled every Chinese and
+// Japanese character counts as a word
+func (p *Page) WordCount() int {
+	if p.cjkPage() {
+		return countCJKWords(p.Plain())
+	}
+	return len(strings.Fields(p.Plain()))
+}
+
+// applyPageQuotas drops the pages beyond MaxPages and MaxWords. Pages must already be sorted,
+// which makes the selection deterministic: the newest pages are kept.
+func (s *Site) applyPageQuotas() {
+	limits := s.Config.Limits
+	total := len(s.Pages)
+	if limits.MaxPages > 0 && len(s.Pages) > limits.MaxPages {
+		s.Pages = s.Pages[:limits.MaxPages]
+	}
+	if limits.MaxWords > 0 {
+		words := 0
+		for i, p := range s.Pages {
+			words += p.WordCount()
+			if words > limits.MaxWords {
+				s.Pages = s.Pages[:i]
+				break
+			}
+		}
+	}
+	if dropped := total - len(s.Pages); dropped > 0 {
+		warnf("Page quota reached, skipped %d of %d pages (maxPages %d, maxWords %d)",
+			dropped, total, limits.MaxPages, limits.MaxWords)
+	}
+}
+
+// applyTermQuota trims the pages of every term to MaxTermPages and reports the trimmed terms
+func (s *Site) applyTermQuota(plural string, terms map[string]*Term) {
+	limit := s.Config.Limits.MaxTermPages
+	if limit <= 0 {
+		return
+	}
+	var trimmed, dropped int
+	for _, term := range terms {
+		if len(term.Pages) > limit {
+			trimmed++
+			dropped += len(term.Pages) - limit
+			term.Pages = term.Pages[:limit]
+		}
+	}
+	if trimmed > 0 {
+		warnf("Term quota reached, %d %s terms list only their newest %d pages (%d entries skipped)",
+			trimmed, plural, limit, dropped)
+	}
+}
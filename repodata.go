@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RepoDataConfig fetches the metadata, releases and contributors of repositories at build
+// time into .Site.Data.github and .Site.Data.gitlab, keyed by "owner/repo":
+//
+//	[repoData]
+//	repositories = ["github.com/yuin/goldmark", "gitlab.com/gitlab-org/gitlab-runner"]
+//
+// Repositories on gitlab.com and on the self-hosted GitLab instances listed in gitlabHosts are
+// read with the GitLab API; other hosts are rejected. The GITHUB_TOKEN and GITLAB_TOKEN
+// environment variables authenticate the requests, which raises rate limits. Each token is only
+// sent to the API it belongs to.
+type RepoDataConfig struct {
+	Repositories []string `toml:"repositories"`
+	// GitLabHosts are the hosts besides gitlab.com that run GitLab, e.g. "gitlab.example.com"
+	GitLabHosts []string `toml:"gitlabHosts"`
+	// Releases and Contributors limit the lists of each repository, 10 by default
+	Releases     int `toml:"releases"`
+	Contributors int `toml:"contributors"`
+	// CacheTTL is how long fetched data is used without asking the API again, "1h" by default.
+	// Older data is revalidated with its ETag, and used when the API cannot be reached. The
+	// checksums of the responses are recorded in herocgo.lock; --frozen builds use the cached
+	// responses that match it whatever their age and fail when the API returns anything else.
+	CacheTTL string `toml:"cacheTTL"`
+}
+
+const (
+	defaultRepoDataLimit    = 10
+	defaultRepoDataCacheTTL = time.Hour
+)
+
+// Repository is the data of a repository in .Site.Data
+type Repository struct {
+	Name        string
+	Description string
+	URL         string
+	Stars       int
+	Forks       int
+	OpenIssues  int
+	Topics      []string
+	// Updated is when the repository was last pushed to
+	Updated      time.Time
+	Releases     []RepositoryRelease
+	Contributors []Contributor
+}
+
+// RepositoryRelease is a published release of a repository
+type RepositoryRelease struct {
+	Tag        string
+	Name       string
+	URL        string
+	Date       time.Time
+	Notes      string
+	Prerelease bool
+}
+
+// Contributor is a contributor of a repository; GitLab reports no avatars or profiles
+type Contributor struct {
+	Name          string
+	URL           string
+	Avatar        string
+	Contributions int
+}
+
+// repoCacheEntry is an API response cached below cacheDir/repos
+type repoCacheEntry struct {
+	URL     string          `json:"url"`
+	ETag    string          `json:"etag,omitempty"`
+	Fetched time.Time       `json:"fetched"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// repoClient reads API responses through the cache and records them in the lock file
+type repoClient struct {
+	ctx    context.Context
+	http   *http.Client
+	ttl    time.Duration
+	lock   *LockFile
+	frozen bool
+
+	mu        sync.Mutex
+	requested map[string]bool
+	changed   bool
+}
+
+// loadRepoData fetches every configured repository into s.Data. A repository that cannot be
+// read is left out with a warning, so an outage of the API does not fail the build.
+func (s *Site) loadRepoData() error {
+	cfg := s.Config.RepoData
+	if len(cfg.Repositories) == 0 {
+		return nil
+	}
+	client := &repoClient{ctx: s.ctx, http: &http.Client{Timeout: 30 * time.Second}, ttl: defaultRepoDataCacheTTL}
+	if cfg.CacheTTL != "" {
+		ttl, err := time.ParseDuration(cfg.CacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid repoData.cacheTTL %q: %w", cfg.CacheTTL, err)
+		}
+		client.ttl = ttl
+	}
+	lock, err := loadLockFile()
+	if err != nil {
+		return err
+	}
+	client.lock, client.frozen, client.requested = lock, s.options.Frozen, map[string]bool{}
+	releases, contributors := cfg.Releases, cfg.Contributors
+	if releases <= 0 {
+		releases = defaultRepoDataLimit
+	}
+	if contributors <= 0 {
+		contributors = defaultRepoDataLimit
+	}
+
+	github := map[string]*Repository{}
+	gitlab := map[string]*Repository{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var frozenErr error
+	for _, repo := range cfg.Repositories {
+		host, name, ok := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(repo, "https://"), "http://"), "/")
+		name = strings.TrimSuffix(strings.Trim(name, "/"), ".git")
+		if !ok || !strings.Contains(name, "/") {
+			return fmt.Errorf("repository %q must be <host>/<owner>/<name>", repo)
+		}
+		host = strings.ToLower(host)
+		isGitHub := host == "github.com" || host == "www.github.com"
+		if !isGitHub && host != "gitlab.com" && !slices.ContainsFunc(cfg.GitLabHosts, func(h string) bool { return strings.EqualFold(h, host) }) {
+			return fmt.Errorf("repository %q is on %s, which is neither GitHub nor GitLab; list GitLab instances in repoData.gitlabHosts", repo, host)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var data *Repository
+			var err error
+			if isGitHub {
+				data, err = client.github(name, releases, contributors)
+			} else {
+				data, err = client.gitlab(host, name, releases, contributors)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && client.frozen {
+				// A frozen build must not silently differ from the locked one
+				if frozenErr == nil {
+					frozenErr = fmt.Errorf("failed to fetch repository data of %s: %w", repo, err)
+				}
+				return
+			}
+			if err != nil {
+				warnf("Failed to fetch repository data of %s: %v", repo, err)
+				return
+			}
+			if isGitHub {
+				github[name] = data
+			} else {
+				gitlab[name] = data
+			}
+		}()
+	}
+	wg.Wait()
+	if frozenErr != nil {
+		return frozenErr
+	}
+	if err := client.saveLock(s.options.DryRun); err != nil {
+		return err
+	}
+	if s.Data == nil {
+		s.Data = map[string]any{}
+	}
+	s.Data["github"] = github
+	s.Data["gitlab"] = gitlab
+	return nil
+}
+
+// github reads a repository with the GitHub REST API
+func (c *repoClient) github(name string, releases, contributors int) (*Repository, error) {
+	base := "https://api.github.com/repos/" + name
+	header := http.Header{"Accept": {"application/vnd.github+json"}}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	var repo struct {
+		FullName    string    `json:"full_name"`
+		Description string    `json:"description"`
+		HTMLURL     string    `json:"html_url"`
+		Stars       int       `json:"stargazers_count"`
+		Forks       int       `json:"forks_count"`
+		OpenIssues  int       `json:"open_issues_count"`
+		Topics      []string  `json:"topics"`
+		PushedAt    time.Time `json:"pushed_at"`
+	}
+	if err := c.get(base, header, &repo); err != nil {
+		return nil, err
+	}
+	var rels []struct {
+		TagName     string    `json:"tag_name"`
+		Name        string    `json:"name"`
+		HTMLURL     string    `json:"html_url"`
+		PublishedAt time.Time `json:"published_at"`
+		Body        string    `json:"body"`
+		Draft       bool      `json:"draft"`
+		Prerelease  bool      `json:"prerelease"`
+	}
+	if err := c.get(fmt.Sprintf("%s/releases?per_page=%d", base, releases), header, &rels); err != nil {
+		return nil, err
+	}
+	var people []struct {
+		Login         string `json:"login"`
+		HTMLURL       string `json:"html_url"`
+		AvatarURL     string `json:"avatar_url"`
+		Contributions int    `json:"contributions"`
+	}
+	if err := c.get(fmt.Sprintf("%s/contributors?per_page=%d", base, contributors), header, &people); err != nil {
+		return nil, err
+	}
+
+	data := &Repository{
+		Name:        repo.FullName,
+		Description: repo.Description,
+		URL:         repo.HTMLURL,
+		Stars:       repo.Stars,
+		Forks:       repo.Forks,
+		OpenIssues:  repo.OpenIssues,
+		Topics:      repo.Topics,
+		Updated:     repo.PushedAt,
+	}
+	for _, r := range rels {
+		if !r.Draft {
+			data.Releases = append(data.Releases, RepositoryRelease{
+				Tag: r.TagName, Name: r.Name, URL: r.HTMLURL, Date: r.PublishedAt, Notes: r.Body, Prerelease: r.Prerelease,
+			})
+		}
+	}
+	for _, p := range people {
+		data.Contributors = append(data.Contributors, Contributor{Name: p.Login, URL: p.HTMLURL, Avatar: p.AvatarURL, Contributions: p.Contributions})
+	}
+	return data, nil
+}
+
+// gitlab reads a project with the GitLab REST API of the host
+func (c *repoClient) gitlab(host, name string, releases, contributors int) (*Repository, error) {
+	base := "https://" + host + "/api/v4/projects/" + url.PathEscape(name)
+	header := http.Header{}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		header.Set("PRIVATE-TOKEN", token)
+	}
+
+	var project struct {
+		PathWithNamespace string    `json:"path_with_namespace"`
+		Description       string    `json:"description"`
+		WebURL            string    `json:"web_url"`
+		Stars             int       `json:"star_count"`
+		Forks             int       `json:"forks_count"`
+		OpenIssues        int       `json:"open_issues_count"`
+		Topics            []string  `json:"topics"`
+		LastActivityAt    time.Time `json:"last_activity_at"`
+	}
+	if err := c.get(base, header, &project); err != nil {
+		return nil, err
+	}
+	var rels []struct {
+		TagName     string    `json:"tag_name"`
+		Name        string    `json:"name"`
+		Description string    `json:"description"`
+		ReleasedAt  time.Time `json:"released_at"`
+		Upcoming    bool      `json:"upcoming_release"`
+		Links       struct {
+			Self string `json:"self"`
+		} `json:"_links"`
+	}
+	if err := c.get(fmt.Sprintf("%s/releases?per_page=%d", base, releases), header, &rels); err != nil {
+		return nil, err
+	}
+	var people []struct {
+		Name    string `json:"name"`
+		Commits int    `json:"commits"`
+	}
+	if err := c.get(fmt.Sprintf("%s/repository/contributors?order_by=commits&sort=desc&per_page=%d", base, contributors), header, &people); err != nil {
+		return nil, err
+	}
+
+	data := &Repository{
+		Name:        project.PathWithNamespace,
+		Description: project.Description,
+		URL:         project.WebURL,
+		Stars:       project.Stars,
+		Forks:       project.Forks,
+		OpenIssues:  project.OpenIssues,
+		Topics:      project.Topics,
+		Updated:     project.LastActivityAt,
+	}
+	for _, r := range rels {
+		data.Releases = append(data.Releases, RepositoryRelease{
+			Tag: r.TagName, Name: r.Name, URL: r.Links.Self, Date: r.ReleasedAt, Notes: r.Description, Prerelease: r.Upcoming,
+		})
+	}
+	for _, p := range people {
+		data.Contributors = append(data.Contributors, Contributor{Name: p.Name, Contributions: p.Commits})
+	}
+	return data, nil
+}
+
+// get decodes the JSON response of an API URL. Cached responses younger than the TTL are used
+// as they are; older ones are revalidated with their ETag, or used when the request fails. A
+// frozen client only uses responses matching the lock file.
+func (c *repoClient) get(apiURL string, header http.Header, v any) error {
+	sum := sha256.Sum256([]byte(apiURL))
+	name := "repos/" + hex.EncodeToString(sum[:16]) + ".json"
+	var cached *repoCacheEntry
+	if data, err := os.ReadFile(cacheReadPath(name)); err == nil {
+		var entry repoCacheEntry
+		if json.Unmarshal(data, &entry) == nil && entry.URL == apiURL {
+			cached = &entry
+		}
+	}
+	c.mu.Lock()
+	c.requested[apiURL] = true
+	locked := c.lock.data(apiURL)
+	c.mu.Unlock()
+	if c.frozen && locked == "" {
+		return fmt.Errorf("%s is not in %s, build without --frozen to record it", apiURL, lockFileName)
+	}
+	if cached != nil && (c.frozen && dataChecksum(cached.Body) == locked || !c.frozen && time.Since(cached.Fetched) < c.ttl) {
+		return c.use(apiURL, cached.Body, v)
+	}
+
+	body, etag, err := c.fetch(apiURL, header, cached)
+	if err != nil {
+		if cached == nil || c.frozen {
+			return err
+		}
+		warnf("Using cached %s: %v", apiURL, err)
+		return c.use(apiURL, cached.Body, v)
+	}
+	if c.frozen && dataChecksum(body) != locked {
+		return fmt.Errorf("%s changed since it was recorded in %s", apiURL, lockFileName)
+	}
+	if err := c.use(apiURL, body, v); err != nil {
+		return err
+	}
+	cacheFile := cacheWritePath(name)
+	entry, err := json.Marshal(repoCacheEntry{URL: apiURL, ETag: etag, Fetched: time.Now().UTC(), Body: body})
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(cacheFile), os.ModePerm)
+	}
+	if err == nil {
+		err = os.WriteFile(cacheFile, entry, 0644)
+	}
+	if err != nil {
+		warnf("Failed to cache %s: %v", apiURL, err)
+	}
+	return nil
+}
+
+// use decodes the response of an API URL and records its checksum in the lock
+func (c *repoClient) use(apiURL string, body []byte, v any) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", apiURL, err)
+	}
+	if !c.frozen {
+		c.mu.Lock()
+		if c.lock.setData(apiURL, dataChecksum(body)) {
+			c.changed = true
+		}
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// saveLock records the responses the site was built with in herocgo.lock, unless this is a
+// frozen build, a dry run or a safe build
+func (c *repoClient) saveLock(dryRun bool) error {
+	pruned := c.lock.pruneData(c.requested)
+	switch {
+	case !pruned && !c.changed:
+		return nil
+	case c.frozen:
+		return fmt.Errorf("%s lists repository data that is no longer requested", lockFileName)
+	case dryRun:
+		log.Printf("Would update %s to the fetched repository data", lockFileName)
+		return nil
+	case safeMode:
+		warnf("%s does not match the fetched repository data and is not updated by --safe", lockFileName)
+		return nil
+	}
+	log.Printf("Updated %s to the fetched repository data", lockFileName)
+	return c.lock.save()
+}
+
+// dataChecksum returns the hex SHA-256 checksum of an API response
+func dataChecksum(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// fetch requests an API URL, returning the cached body when the server answers 304 Not Modified
+func (c *repoClient) fetch(apiURL string, header http.Header, cached *repoCacheEntry) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header = header.Clone()
+	req.Header.Set("User-Agent", "herocgo")
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached.Body, cached.ETag, nil
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		// GitHub lists no contributors of an empty repository
+		return []byte("[]"), "", nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, resp.Header.Get("ETag"), nil
+}
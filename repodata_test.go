@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRepoClientLock(t *testing.T) {
+	// The cache lives below the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	body := `{"stars":1}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+	apiURL := server.URL + "/repos/a/b"
+
+	lock := &LockFile{}
+	client := func(frozen bool) *repoClient {
+		return &repoClient{ctx: context.Background(), http: server.Client(), lock: lock, frozen: frozen, requested: map[string]bool{}}
+	}
+	var got struct{ Stars int }
+
+	if err := client(true).get(apiURL, http.Header{}, &got); err == nil || !strings.Contains(err.Error(), "not in") {
+		t.Errorf("frozen get() of an unlocked URL = %v, want an error", err)
+	}
+	c := client(false)
+	if err := c.get(apiURL, http.Header{}, &got); err != nil || got.Stars != 1 {
+		t.Fatalf("get() = %v, %d stars", err, got.Stars)
+	}
+	if !c.changed || lock.data(apiURL) != dataChecksum([]byte(`{"stars":1}`)) {
+		t.Errorf("get() locked %q, want the checksum of the response", lock.data(apiURL))
+	}
+
+	// The cache matching the lock is used whatever its age, and the API is not asked
+	body = `{"stars":2}`
+	if err := client(true).get(apiURL, http.Header{}, &got); err != nil || got.Stars != 1 {
+		t.Errorf("frozen get() = %v, %d stars, want the locked response", err, got.Stars)
+	}
+	if err := os.RemoveAll(cacheDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := client(true).get(apiURL, http.Header{}, &got); err == nil || !strings.Contains(err.Error(), "changed") {
+		t.Errorf("frozen get() of a changed response = %v, want an error", err)
+	}
+}
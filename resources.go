@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resource is a file that lives inside a page bundle next to its index.md
+type Resource struct {
+	Name         string
+	Title        string
+	Params       map[string]any
+	MediaType    string
+	ResourceType string
+	RelPath      string
+	SourcePath   string
+	RelPermalink string
+	Permalink    string
+
+	// image is analysed on first use by the image methods, see analyse
+	imageOnce sync.Once
+	image     *imageInfo
+	stripGPS  bool
+
+	// duration is read on first use, see Duration
+	mediaOnce sync.Once
+	duration  time.Duration
+}
+
+// ResourceMetadata is a `resources:` front matter entry assigning metadata to matching bundle files
+type ResourceMetadata struct {
+	Src    string         `yaml:"src" toml:"src"`
+	Name   string         `yaml:"name" toml:"name"`
+	Title  string         `yaml:"title" toml:"title"`
+	Params map[string]any `yaml:"params" toml:"params"`
+}
+
+// Resources is the list of files belonging to a page bundle
+type Resources []*Resource
+
+// ByType returns the resources of the given type (image, video, text, ...)
+func (r Resources) ByType(resourceType string) Resources {
+	var matches Resources
+	for _, res := range r {
+		if res.ResourceType == resourceType {
+			matches = append(matches, res)
+		}
+	}
+	return matches
+}
+
+// Match returns all resources whose name matches the glob pattern
+func (r Resources) Match(pattern string) Resources {
+	var matches Resources
+	for _, res := range r {
+		if globMatch(pattern, res.Name) {
+			matches = append(matches, res)
+		}
+	}
+	return matches
+}
+
+// GetMatch returns the first resource whose name matches the glob pattern, or nil
+func (r Resources) GetMatch(pattern string) *Resource {
+	for _, res := range r {
+		if globMatch(pattern, res.Name) {
+			return res
+		}
+	}
+	return nil
+}
+
+// collectBundleResources gathers every non-index file below a leaf bundle directory.
+// The permalinks of the returned resources are set by the site.
+func collectBundleResources(bundleDir string) (Resources, error) {
+	var resources Resources
+	err := filepath.WalkDir(bundleDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || p == filepath.Join(bundleDir, "index.md") {
+			return nil
+		}
+		rel, err := filepath.Rel(bundleDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// The fixed types come first, as the system table may type e.g. .ogg differently
+		mediaType := mediaTypes[strings.ToLower(filepath.Ext(p))]
+		if mediaType == "" {
+			mediaType = mime.TypeByExtension(filepath.Ext(p))
+		}
+		if mediaType == "" {
+			mediaType = "application/octet-stream"
+		}
+		if i := strings.Index(mediaType, ";"); i >= 0 {
+			mediaType = mediaType[:i]
+		}
+		resources = append(resources, &Resource{
+			Name:         rel,
+			Title:        rel,
+			Params:       map[string]any{},
+			MediaType:    mediaType,
+			ResourceType: strings.SplitN(mediaType, "/", 2)[0],
+			RelPath:      rel,
+			SourcePath:   p,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect bundle resources: %w", err)
+	}
+	return resources, nil
+}
+
+// applyResourceMetadata assigns front matter names, titles and params to bundle resources.
+// As with other generators, the first matching entry wins for each field and
+// `:counter` in a name or title is replaced by the match index of that entry.
+func applyResourceMetadata(resources Resources, metadata []ResourceMetadata) {
+	counters := make([]int, len(metadata))
+	for _, res := range resources {
+		original := res.Name
+		var nameSet, titleSet bool
+		for i, meta := range metadata {
+			if meta.Src == "" || !globMatch(meta.Src, original) {
+				continue
+			}
+			counters[i]++
+			counter := strconv.Itoa(counters[i])
+			if !nameSet && meta.Name != "" {
+				res.Name = strings.ReplaceAll(meta.Name, ":counter", counter)
+				nameSet = true
+			}
+			if !titleSet && meta.Title != "" {
+				res.Title = strings.ReplaceAll(meta.Title, ":counter", counter)
+				titleSet = true
+			}
+			for k, v := range meta.Params {
+				key := strings.ToLower(k)
+				if _, ok := res.Params[key]; !ok {
+					res.Params[key] = v
+				}
+			}
+		}
+		if !titleSet && nameSet {
+			res.Title = res.Name
+		}
+	}
+}
+
+// globPatterns caches the compiled globs of globMatch by pattern; invalid patterns are nil
+var globPatterns struct {
+	mu       sync.Mutex
+	compiled map[string]*regexp.Regexp
+}
+
+// globMatch reports whether name matches a case-insensitive glob where `**` crosses directories
+func globMatch(pattern, name string) bool {
+	globPatterns.mu.Lock()
+	re, ok := globPatterns.compiled[pattern]
+	if !ok {
+		re = compileGlob(pattern)
+		if globPatterns.compiled == nil {
+			globPatterns.compiled = map[string]*regexp.Regexp{}
+		}
+		globPatterns.compiled[pattern] = re
+	}
+	globPatterns.mu.Unlock()
+	return re != nil && re.MatchString(name)
+}
+
+// compileGlob translates a glob of globMatch into a regular expression, or nil when it is invalid
+func compileGlob(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil
+	}
+	return re
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.jpg", "photo.JPG", true},
+		{"*.jpg", "images/photo.jpg", false},
+		{"**.jpg", "images/photo.jpg", true},
+		{"images/?.png", "images/a.png", true},
+		{"café*.jpg", "Café-1.jpg", true},
+		{"日本*", "日本の夏.png", true},
+		{"é?", "éé", true},
+		{"[a].txt", "[a].txt", true},
+		{"[a].txt", "a.txt", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+// safeMode is set by --safe for building untrusted sites: external commands are not run,
+// nothing is read outside of the project, herocgo.lock is not written and source files may not
+// be symlinks
+var safeMode bool
+
+// applySafeMode turns off the features of the config that run external commands, warning
+// about each one that was enabled
+func applySafeMode(config *Config) {
+	disable := func(feature string) {
+		warnf("%s disabled by --safe", feature)
+	}
+	if config.EnableGitInfo {
+		config.EnableGitInfo = false
+		disable("enableGitInfo")
+	}
+	if config.TTS.Command != "" {
+		config.TTS.Command = ""
+		disable("tts.command")
+	}
+	if config.CJK.Transliterate != "" {
+		config.CJK.Transliterate = ""
+		disable("cjk.transliterate")
+	}
+	if config.Outputs.PDF.Command != "" {
+		config.Outputs.PDF.Command = ""
+		disable("outputs.pdf.command")
+	}
+	if config.Changelog.Source == "git" {
+		config.Changelog.Source = ""
+		disable("changelog from git tags")
+	}
+	if len(config.RepoData.Repositories) > 0 {
+		config.RepoData.Repositories = nil
+		disable("repoData")
+	}
+	if config.OGImage.Enabled {
+		config.OGImage.Enabled = false
+		disable("ogImage")
+	}
+	if len(config.Imaging.Formats) > 0 {
+		config.Imaging.Formats = nil
+		disable("imaging.formats")
+	}
+	// Gzip is the only format compressed in-process
+	var formats []string
+	for _, format := range config.Compress.Formats {
+		if format == "gzip" && config.Compress.Encoders[format] == "" {
+			formats = append(formats, format)
+		} else {
+			disable("compress format " + format)
+		}
+	}
+	config.Compress.Formats = formats
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// reservedNames are device names Windows refuses as file names, with or without an extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeSegment makes a single URL path segment safe to use as a file or directory name
+// on every OS: reserved characters become "-", control characters are dropped, "." and ".."
+// are emptied and Windows device names get a "-" suffix
+func sanitizeSegment(segment string) string {
+	var b strings.Builder
+	for _, r := range segment {
+		switch {
+		case unicode.IsControl(r):
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			b.WriteByte('-')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	s := strings.TrimRight(b.String(), ". ")
+	if s == "" {
+		return ""
+	}
+	stem := strings.ToUpper(strings.SplitN(s, ".", 2)[0])
+	if reservedNames[stem] {
+		s += "-"
+	}
+	return s
+}
+
+// sanitizeURLPath sanitizes every segment of a site-relative URL path, dropping empty ones
+// so a title like "../../etc" can never climb out of the output directory
+func sanitizeURLPath(urlPath string) string {
+	var segments []string
+	for _, segment := range strings.Split(urlPath, "/") {
+		if segment = sanitizeSegment(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	clean := "/" + strings.Join(segments, "/")
+	if strings.HasSuffix(urlPath, "/") && len(segments) > 0 {
+		clean += "/"
+	}
+	return clean
+}
+
+// outputFile joins a slash-separated path onto the output directory and rejects absolute
+// paths and paths that would end up outside of it
+func outputFile(outputDir, rel string) (string, error) {
+	native := filepath.FromSlash(strings.TrimPrefix(filepath.ToSlash(rel), "/"))
+	if filepath.IsAbs(native) || filepath.VolumeName(native) != "" {
+		return "", fmt.Errorf("output path %q is absolute", rel)
+	}
+	dest := filepath.Join(outputDir, native)
+	within, err := filepath.Rel(outputDir, dest)
+	if err != nil || within == ".." || strings.HasPrefix(within, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("output path %q escapes %s", rel, outputDir)
+	}
+	return dest, nil
+}
+
+// projectRoot returns the resolved working directory that every source read must stay under
+var projectRoot = sync.OnceValues(func() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(wd)
+})
+
+// checkReadPath follows symlinks and rejects source files that resolve outside of the project root
+// and of the configured mounts; in safe mode every symlink is rejected
+func checkReadPath(path string) error {
+	root, err := projectRoot()
+	if err != nil {
+		return fmt.Errorf("could not resolve project root: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return err
+	}
+	if safeMode {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil && filepath.Join(root, rel) != resolved {
+				return fmt.Errorf("%s is a symlink, which --safe does not follow", path)
+			}
+		}
+	}
+	if !within(root, resolved) && !withinMountRoot(resolved) {
+		return fmt.Errorf("%s resolves to %s outside of the project", path, resolved)
+	}
+	return nil
+}
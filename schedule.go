@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// scheduleFile lists the upcoming publish and expiry times of the build, for cron jobs and CI
+// pipelines that rebuild the site when one of them passes
+const scheduleFile = "scheduled.json"
+
+// scheduledChange is a page that appears or disappears at a later build
+type scheduledChange struct {
+	Date      time.Time `json:"date"`
+	Action    string    `json:"action"`
+	Path      string    `json:"path"`
+	Title     string    `json:"title"`
+	Permalink string    `json:"permalink"`
+}
+
+// schedule is the content of scheduled.json
+type schedule struct {
+	Generated time.Time         `json:"generated"`
+	Next      *time.Time        `json:"next,omitempty"`
+	Changes   []scheduledChange `json:"changes"`
+}
+
+// scheduledChanges returns the future publish dates of the pages left out of the build and the
+// future expiry dates of the built pages, soonest first
+func (s *Site) scheduledChanges() []scheduledChange {
+	var changes []scheduledChange
+	add := func(p *Page, action string, date time.Time) {
+		change := scheduledChange{Date: date, Action: action, Title: p.Title, Permalink: p.Permalink}
+		if p.File != nil {
+			change.Path = "content/" + p.File.Path
+		}
+		changes = append(changes, change)
+	}
+	for _, p := range s.scheduled {
+		add(p, "publish", p.PublishDate)
+	}
+	for _, p := range s.Pages {
+		if p.ExpiryDate.After(s.Hero.BuildDate) {
+			add(p, "expire", p.ExpiryDate)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Date.Before(changes[j].Date) })
+	return changes
+}
+
+// nextScheduled returns the time of the next scheduled change, or the zero time
+func (s *Site) nextScheduled() time.Time {
+	if changes := s.scheduledChanges(); len(changes) > 0 {
+		return changes[0].Date
+	}
+	return time.Time{}
+}
+
+// renderSchedule writes scheduled.json when pages are scheduled and removes a stale one otherwise
+func (s *Site) renderSchedule(outputDir string) error {
+	dest, err := outputFile(outputDir, scheduleFile)
+	if err != nil {
+		return err
+	}
+	changes := s.scheduledChanges()
+	if len(changes) == 0 {
+		if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", scheduleFile, err)
+		}
+		return nil
+	}
+	out := schedule{Generated: s.Hero.BuildDate.UTC().Truncate(time.Second), Next: &changes[0].Date, Changes: changes}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", scheduleFile, err)
+	}
+	return nil
+}
+
+// watchClock rebuilds the site once the next scheduled publish or expiry time has passed
+func (ds *devServer) watchClock(interval time.Duration) {
+	var attempted time.Time
+	for range time.Tick(interval) {
+		ds.mu.RLock()
+		next := ds.site.nextScheduled()
+		ds.mu.RUnlock()
+		// A failed rebuild is not retried for the same change
+		if next.IsZero() || time.Now().Before(next) || next.Equal(attempted) {
+			continue
+		}
+		attempted = next
+		log.Printf("Scheduled change at %s passed, rebuilding", next.Format(time.RFC3339))
+		if err := ds.rebuild(); err != nil {
+			log.Printf("Rebuild failed, still serving the previous build: %v", err)
+		}
+	}
+}
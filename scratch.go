@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Scratch is a mutable key/value store for templates, which cannot reassign variables across blocks
+type Scratch struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+// newScratch creates an empty scratch; templates get one with the newScratch function
+func newScratch() *Scratch {
+	return &Scratch{values: map[string]any{}}
+}
+
+// Set stores a value and returns an empty string so it can be called inline
+func (s *Scratch) Set(key string, value any) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return ""
+}
+
+// Get returns the stored value, or nil
+func (s *Scratch) Get(key string) any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[key]
+}
+
+// Delete removes a value
+func (s *Scratch) Delete(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	return ""
+}
+
+// Add adds numbers, concatenates strings or appends to slices; a missing key is set to the value
+func (s *Scratch) Add(key string, value any) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.values[key]
+	if !ok {
+		s.values[key] = value
+		return "", nil
+	}
+	sum, err := addValues(existing, value)
+	if err != nil {
+		return "", fmt.Errorf("scratch Add %q: %w", key, err)
+	}
+	s.values[key] = sum
+	return "", nil
+}
+
+// SetInMap stores value under mapKey in the map held at key
+func (s *Scratch) SetInMap(key, mapKey string, value any) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.values[key].(map[string]any)
+	if !ok {
+		m = map[string]any{}
+		s.values[key] = m
+	}
+	m[mapKey] = value
+	return ""
+}
+
+// GetSortedMapValues returns the values of the map at key ordered by their map keys
+func (s *Scratch) GetSortedMapValues(key string) []any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.values[key].(map[string]any)
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]any, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, m[k])
+	}
+	return values
+}
+
+// Values returns a copy of everything stored
+func (s *Scratch) Values() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	values := make(map[string]any, len(s.values))
+	for k, v := range s.values {
+		values[k] = v
+	}
+	return values
+}
+
+// addValues implements Scratch.Add for numbers, strings and slices
+func addValues(a, b any) (any, error) {
+	switch x := a.(type) {
+	case int:
+		if y, ok := b.(int); ok {
+			return x + y, nil
+		}
+		if y, ok := b.(float64); ok {
+			return float64(x) + y, nil
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			return x + y, nil
+		}
+		if y, ok := b.(int); ok {
+			return x + float64(y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return x + y, nil
+		}
+	case []any:
+		// Appending must not write into a slice a template got earlier from Get
+		x = slices.Clip(x)
+		if b != nil && reflect.TypeOf(b).Kind() == reflect.Slice {
+			v := reflect.ValueOf(b)
+			for i := 0; i < v.Len(); i++ {
+				x = append(x, v.Index(i).Interface())
+			}
+			return x, nil
+		}
+		return append(x, b), nil
+	}
+	if a != nil && reflect.TypeOf(a).Kind() == reflect.Slice {
+		v := reflect.ValueOf(a)
+		out := make([]any, 0, v.Len()+1)
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, v.Index(i).Interface())
+		}
+		return addValues(out, b)
+	}
+	return nil, fmt.Errorf("cannot add %T to %T", b, a)
+}
+
+// Scratch returns the scratch store of the page, shared by every template rendering it
+func (p *Page) Scratch() *Scratch {
+	return p.Site.scratchFor(p)
+}
+
+// scratchFor returns the scratch of a page, creating it on first use
+func (s *Site) scratchFor(p *Page) *Scratch {
+	s.scratchMu.Lock()
+	defer s.scratchMu.Unlock()
+	if s.scratches == nil {
+		s.scratches = map[*Page]*Scratch{}
+	}
+	scratch, ok := s.scratches[p]
+	if !ok {
+		scratch = newScratch()
+		s.scratches[p] = scratch
+	}
+	return scratch
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScratchAddSlices(t *testing.T) {
+	s := newScratch()
+	stored := make([]any, 1, 4)
+	stored[0] = "a"
+	s.Set("list", stored)
+	if _, err := s.Add("list", "b"); err != nil {
+		t.Fatal(err)
+	}
+	earlier := s.Get("list").([]any)
+	if _, err := s.Add("list", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add("list", []string{"d", "e"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add writes neither into the spare capacity of the set slice nor into that of one from Get
+	if got, want := stored[:cap(stored)], []any{"a", nil, nil, nil}; !slices.Equal(got, want) {
+		t.Errorf("the set slice holds %v, want %v", got, want)
+	}
+	for _, v := range earlier[len(earlier):cap(earlier)] {
+		if v != nil {
+			t.Errorf("a slice from Get holds %v beyond its length", earlier[:cap(earlier)])
+			break
+		}
+	}
+	if want := []any{"a", "b"}; !slices.Equal(earlier, want) {
+		t.Errorf("a slice from Get changed to %v, want %v", earlier, want)
+	}
+	if got, want := s.Get("list").([]any), []any{"a", "b", "c", "d", "e"}; !slices.Equal(got, want) {
+		t.Errorf("Get() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Field weights of the development search index: a title hit outranks a taxonomy hit, which outranks body text
+const (
+	searchTitleWeight = 5
+	searchTermWeight  = 3
+	searchBodyWeight  = 1
+)
+
+// searchIndex is an in-memory inverted index of the regular pages
+type searchIndex struct {
+	pages    []*Page
+	postings map[string]map[int]int
+}
+
+// searchResult is one entry of the /search response
+type searchResult struct {
+	Title        string `json:"title"`
+	Permalink    string `json:"permalink"`
+	RelPermalink string `json:"relPermalink"`
+	Section      string `json:"section,omitempty"`
+	Date         string `json:"date,omitempty"`
+	Summary      string `json:"summary"`
+	Score        int    `json:"score"`
+
+	date time.Time
+}
+
+// newSearchIndex indexes the title, taxonomy terms and plain text of every page
+func newSearchIndex(pages []*Page) *searchIndex {
+	idx := &searchIndex{pages: pages, postings: map[string]map[int]int{}}
+	for i, p := range pages {
+		idx.add(i, p.Title, searchTitleWeight)
+		for _, name := range p.Site.Config.taxonomyNames() {
+			for _, term := range toStringSlice(p.Params[name]) {
+				idx.add(i, term, searchTermWeight)
+			}
+		}
+		idx.add(i, p.Plain(), searchBodyWeight)
+	}
+	return idx
+}
+
+// add records the tokens of text for page i with the given weight
+func (idx *searchIndex) add(i int, text string, weight int) {
+	for _, token := range searchTokens(text) {
+		docs, ok := idx.postings[token]
+		if !ok {
+			docs = map[int]int{}
+			idx.postings[token] = docs
+		}
+		docs[i] += weight
+	}
+}
+
+// query returns the pages containing every query word, best match first.
+// The last word also matches as a prefix so results update while typing.
+func (idx *searchIndex) query(q string) []searchResult {
+	words := searchTokens(q)
+	if len(words) == 0 {
+		return nil
+	}
+	var scores map[int]int
+	for n, word := range words {
+		matches := map[int]int{}
+		for token, docs := range idx.postings {
+			if token != word && !(n == len(words)-1 && strings.HasPrefix(token, word)) {
+				continue
+			}
+			for doc, score := range docs {
+				matches[doc] += score
+			}
+		}
+		if scores == nil {
+			scores = matches
+			continue
+		}
+		for doc := range scores {
+			if score, ok := matches[doc]; ok {
+				scores[doc] += score
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	results := make([]searchResult, 0, len(scores))
+	for doc, score := range scores {
+		p := idx.pages[doc]
+		results = append(results, searchResult{
+			Title:        p.Title,
+			Permalink:    p.Permalink,
+			RelPermalink: p.RelPermalink,
+			Section:      p.Section,
+			Summary:      p.Summary(),
+			Score:        score,
+			date:         p.Date,
+		})
+		if !p.Date.IsZero() {
+			results[len(results)-1].Date = p.Date.Format(time.RFC3339)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].date.After(results[j].date)
+	})
+	return results
+}
+
+// searchTokens lowercases text and splits it into words of letters and digits
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// handleSearch answers /search?q=...&limit=N with JSON results from the current build
+func (ds *devServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	ds.mu.RLock()
+	results := ds.search.query(q)
+	ds.mu.RUnlock()
+
+	total := len(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(map[string]any{
+		"query":   q,
+		"total":   total,
+		"results": results,
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Sections returns the child sections of a section, ordered by weight and title; on the home
+// page they are the top-level sections
+func (p *Page) Sections() []*Page {
+	if p.Kind == KindHome {
+		return p.Site.Sections
+	}
+	return p.sections
+}
+
+// Collapsed reports whether the collapsed front matter of the section asks navigation to show it
+// closed until one of its pages is visited
+func (p *Page) Collapsed() bool {
+	collapsed, _ := p.Params["collapsed"].(bool)
+	return collapsed
+}
+
+// IsAncestor reports whether the page is an ancestor of other
+func (p *Page) IsAncestor(other *Page) bool {
+	if other == nil {
+		return false
+	}
+	for _, ancestor := range other.Ancestors() {
+		if sameListPage(ancestor, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDescendant reports whether the page is a descendant of other
+func (p *Page) IsDescendant(other *Page) bool {
+	if other == nil {
+		return false
+	}
+	return other.IsAncestor(p)
+}
+
+// sameListPage reports whether two pages are the same, counting every pager of a list as the list
+func sameListPage(a, b *Page) bool {
+	return a == b || (a.Paginator != nil && b.Paginator != nil && a.Paginator.First() == b.Paginator.First())
+}
+
+// pageDir returns the slash-separated content directory holding a page, the parent directory
+// for a bundle
+func pageDir(p *Page) string {
+	if p.source == nil {
+		return ""
+	}
+	dir := filepath.Dir(p.source.RelPath)
+	if p.source.IsBundle {
+		dir = filepath.Dir(dir)
+	}
+	if dir = filepath.ToSlash(dir); dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// buildNestedSections creates a section for every directory below a top-level section that has
+// an _index.md, listing the pages below it. Each section holds its child sections, and pages
+// sit below the deepest section containing them.
+func (s *Site) buildNestedSections(branches map[string]*Page) {
+	sections := map[string]*Page{}
+	for _, section := range s.Sections {
+		sections[section.Section] = section
+	}
+	var dirs []string
+	for dir := range branches {
+		if strings.Contains(dir, "/") {
+			dirs = append(dirs, dir)
+		}
+	}
+	// Parents come before their children
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		var pages []*Page
+		for _, p := range s.Pages {
+			if d := pageDir(p); d == dir || strings.HasPrefix(d, dir+"/") {
+				pages = append(pages, p)
+			}
+		}
+		parent := nearestSection(sections, path.Dir(dir))
+		if len(pages) == 0 || parent == nil {
+			continue
+		}
+		section := branches[dir]
+		section.Kind = KindSection
+		section.Pages = pages
+		section.parent = parent
+		s.setURL(section, urlPath(dir, "/"))
+		parent.sections = append(parent.sections, section)
+		sections[dir] = section
+		s.nestedSections = append(s.nestedSections, section)
+		s.AllPages = append(s.AllPages, section)
+	}
+
+	for _, p := range append(append([]*Page{}, s.Pages...), s.unlisted...) {
+		// Unlisted pages are not listed by their section, but still sit below it
+		if section := nearestSection(sections, pageDir(p)); section != nil {
+			p.parent = section
+		}
+	}
+	for _, section := range sections {
+		sort.SliceStable(section.sections, func(i, j int) bool {
+			a, b := section.sections[i], section.sections[j]
+			if a.Weight != b.Weight {
+				// Sections without a weight follow the weighted ones
+				return a.Weight != 0 && (b.Weight == 0 || a.Weight < b.Weight)
+			}
+			return a.Title < b.Title
+		})
+	}
+}
+
+// nearestSection returns the section of the directory or of its closest parent directory
+func nearestSection(sections map[string]*Page, dir string) *Page {
+	for dir != "" && dir != "." {
+		if section, ok := sections[dir]; ok {
+			return section
+		}
+		dir = path.Dir(dir)
+	}
+	return nil
+}
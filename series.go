@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// seriesTaxonomy is the URL path and taxonomy name of the series landing pages
+const seriesTaxonomy = "series"
+
+// Series returns the series of the page from the series front matter, or nil
+func (p *Page) Series() *Term {
+	return p.series
+}
+
+// SeriesPages returns the pages of the series of the page in reading order
+func (p *Page) SeriesPages() []*Page {
+	if p.series == nil {
+		return nil
+	}
+	return p.series.Pages
+}
+
+// SeriesIndex returns the 1-based position of the page in its series, or 0
+func (p *Page) SeriesIndex() int {
+	for i, other := range p.SeriesPages() {
+		if other == p {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// SeriesCount returns the number of pages in the series of the page
+func (p *Page) SeriesCount() int {
+	return len(p.SeriesPages())
+}
+
+// PrevInSeries returns the previous part of the series, or nil for the first
+func (p *Page) PrevInSeries() *Page {
+	if i := p.SeriesIndex(); i > 1 {
+		return p.series.Pages[i-2]
+	}
+	return nil
+}
+
+// NextInSeries returns the next part of the series, or nil for the last
+func (p *Page) NextInSeries() *Page {
+	if i := p.SeriesIndex(); i > 0 && i < p.SeriesCount() {
+		return p.series.Pages[i]
+	}
+	return nil
+}
+
+// buildSeries groups pages by their series front matter and creates the series landing pages
+// under /series/. Parts are ordered by seriesWeight (or part), then by date, oldest first.
+func (s *Site) buildSeries() {
+	series := map[string]*Term{}
+	slugs := map[string]bool{}
+	for _, p := range s.Pages {
+		names := toStringSlice(p.Params[seriesTaxonomy])
+		if len(names) == 0 || strings.TrimSpace(names[0]) == "" {
+			continue
+		}
+		// A page belongs to one series, so navigation stays unambiguous
+		name := strings.TrimSpace(names[0])
+		key := slugify(name, SlugUnicode)
+		term, ok := series[key]
+		if !ok {
+			slug := s.slug(name)
+			if slug == "" {
+				slug = "series"
+			}
+			slug = uniqueSlug(slug, func(candidate string) bool { return slugs[candidate] })
+			slugs[slug] = true
+			term = &Term{Name: name, Slug: slug}
+			series[key] = term
+		}
+		term.Pages = append(term.Pages, p)
+		p.series = term
+	}
+	if len(series) == 0 {
+		return
+	}
+
+	list := make([]*Term, 0, len(series))
+	for _, term := range series {
+		sort.SliceStable(term.Pages, func(i, j int) bool {
+			a, b := seriesWeight(term.Pages[i]), seriesWeight(term.Pages[j])
+			if a != b {
+				// Parts without a weight follow the numbered ones
+				return a != 0 && (b == 0 || a < b)
+			}
+			return term.Pages[i].Date.Before(term.Pages[j].Date)
+		})
+		term.Page = &Page{
+			Site:     s,
+			Kind:     KindTerm,
+			Title:    term.Name,
+			Params:   map[string]any{},
+			Pages:    term.Pages,
+			Taxonomy: seriesTaxonomy,
+			Term:     term.Name,
+		}
+		s.setURL(term.Page, urlPath(seriesTaxonomy, term.Slug, "/"))
+		list = append(list, term)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Slug < list[j].Slug })
+	s.Taxonomies[seriesTaxonomy] = list
+
+	taxonomy := &Page{
+		Site:     s,
+		Kind:     KindTaxonomy,
+		Title:    titleCase(seriesTaxonomy),
+		Params:   map[string]any{},
+		Taxonomy: seriesTaxonomy,
+		Terms:    list,
+	}
+	s.setURL(taxonomy, urlPath(seriesTaxonomy, "/"))
+	s.AllPages = append(s.AllPages, taxonomy)
+	for _, term := range list {
+		term.Page.parent = taxonomy
+		s.AllPages = append(s.AllPages, term.Page)
+	}
+}
+
+// seriesWeight returns the position of a page set with seriesWeight or part, or 0
+func seriesWeight(p *Page) float64 {
+	for _, key := range []string{"seriesWeight", "part"} {
+		if n, ok := toFloat(p.Params[key]); ok {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serveDir receives development builds so they never overwrite the production output in public/
+var serveDir = filepath.Join(".herocgo", "serve")
+
+// devServer serves a development build of the site together with endpoints over the in-memory pages
+type devServer struct {
+	opts buildOptions
+
+	mu     sync.RWMutex
+	site   *Site
+	search *searchIndex
+
+	// cacheHeaders keeps a manifest of the output for the ETag and Last-Modified headers
+	cacheHeaders bool
+	manifest     map[string]servedFile
+}
+
+// runServe builds the site for development and serves it over HTTP
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := flags.Int("port", 1313, "port to listen on")
+	bind := flags.String("bind", "localhost", "interface to listen on, e.g. 0.0.0.0 for every interface")
+	baseURL := flags.String("baseURL", "", "base URL of the build, by default the address served")
+	tlsCert := flags.String("tls-cert", "", "certificate file to serve HTTPS with")
+	tlsKey := flags.String("tls-key", "", "key file of --tls-cert")
+	useTLS := flags.Bool("tls", false, "serve HTTPS with a self-signed certificate kept in "+devTLSDir)
+	requestLog := flags.Bool("log-requests", false, "log every request with its status, duration and size")
+	latency := flags.Duration("latency", 0, "delay every response, e.g. 300ms, to simulate a slow network")
+	listDirs := flags.Bool("dir-listing", false, "list the files of directories without an index.html")
+	environment := flags.String("environment", envOr("HERO_ENVIRONMENT", "development"), "build environment exposed to templates as hero.Environment")
+	watchClock := flags.Bool("watch-clock", false, "rebuild when a scheduled publish or expiry time passes")
+	var opts buildOptions
+	flags.BoolVar(&opts.Safe, "safe", false, "build an untrusted site: no external commands, symlinks or files outside the project")
+	flags.BoolVar(&opts.KeepGoing, "keep-going", true, "serve an error page in place of each page that fails")
+	publishFlags(flags, &opts)
+	contentFilterFlags(flags, &opts)
+	flags.Parse(args)
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatalf("--tls-cert and --tls-key must be used together")
+	}
+	// The bind address may listen on every interface, but browsers need a host name
+	host := *bind
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	addr := net.JoinHostPort(*bind, strconv.Itoa(*port))
+	scheme := "http"
+	if *tlsCert != "" || *useTLS {
+		scheme = "https"
+	}
+	if *useTLS && *tlsCert == "" {
+		hosts := []string{"localhost", "127.0.0.1", "::1"}
+		if !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+		var err error
+		*tlsCert, *tlsKey, err = selfSignedCert(hosts)
+		if err != nil {
+			log.Fatalf("Failed to create certificate: %v", err)
+		}
+	}
+
+	opts.Environment = *environment
+	opts.PublicDir = serveDir
+	opts.BaseURL = fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(host, strconv.Itoa(*port)))
+	if *baseURL != "" {
+		opts.BaseURL = strings.TrimSuffix(*baseURL, "/") + "/"
+	}
+	server := &devServer{opts: opts, cacheHeaders: true}
+	if err := server.rebuild(); err != nil {
+		log.Fatalf("Failed to build site: %v", err)
+	}
+
+	go server.watchConfig(time.Second)
+	go server.watchTemplates(time.Second)
+	if *watchClock {
+		go server.watchClock(time.Second)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", server.handleSearch)
+	server.handleContentAPI(mux)
+	// A base URL with a path is served below that path, as it will be deployed
+	var root http.FileSystem = http.Dir(serveDir)
+	if !*listDirs {
+		root = noListingFS{root}
+	}
+	files := server.withCacheHeaders(http.FileServer(root))
+	if u, err := url.Parse(opts.BaseURL); err == nil && strings.Trim(u.Path, "/") != "" {
+		prefix := "/" + strings.Trim(u.Path, "/")
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, files))
+		mux.Handle("/{$}", http.RedirectHandler(prefix+"/", http.StatusFound))
+	} else {
+		mux.Handle("/", files)
+	}
+
+	fmt.Printf("Serving %s at %s://%s/ (base URL %s)\n", *environment, scheme, net.JoinHostPort(host, strconv.Itoa(*port)), opts.BaseURL)
+	var handler http.Handler = mux
+	if *latency > 0 {
+		handler = withLatency(*latency, handler)
+	}
+	if *requestLog {
+		handler = logRequests(handler)
+	}
+	if scheme == "https" {
+		log.Fatal(http.ListenAndServeTLS(addr, *tlsCert, *tlsKey, handler))
+	}
+	log.Fatal(http.ListenAndServe(addr, handler))
+}
+
+// rebuild builds the site and swaps in the new pages and search index
+func (ds *devServer) rebuild() error {
+	site, stats, err := buildSite(context.Background(), ds.opts)
+	if err != nil {
+		return err
+	}
+	index := newSearchIndex(site.Pages)
+
+	ds.mu.Lock()
+	ds.site, ds.search = site, index
+	ds.mu.Unlock()
+	if ds.cacheHeaders {
+		ds.refreshManifest()
+	}
+	fmt.Printf("Built %d pages in %v\n", stats.Pages, stats.Duration)
+	return nil
+}
+
+// watchConfig polls config.toml and the environment overlay and rebuilds the site when one of them
+// changes, so theme, params and taxonomy edits apply without restarting the server
+func (ds *devServer) watchConfig(interval time.Duration) {
+	last := fileStamps(configFiles(ds.opts.Environment))
+	for range time.Tick(interval) {
+		current := fileStamps(configFiles(ds.opts.Environment))
+		if maps.Equal(last, current) {
+			continue
+		}
+		last = current
+		log.Printf("Config changed, rebuilding")
+		if err := ds.rebuild(); err != nil {
+			log.Printf("Rebuild failed, still serving the previous build: %v", err)
+		}
+	}
+}
+
+// fileStamps returns the modification time and size of every existing file
+func fileStamps(paths []string) map[string]string {
+	stamps := map[string]string{}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fmt.Sprintf("%d/%d", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return stamps
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Site holds the configuration and every page of a build; templates reach it as .Site
+type Site struct {
+	Config       Config
+	Title        string
+	BaseURL      string
+	LanguageCode string
+	Description  string
+	Params       map[string]any
+	Hero         HeroInfo
+
+	// Pages holds the regular content pages, newest first
+	Pages      []*Page
+	Home       *Page
+	Sections   []*Page
+	Taxonomies map[string][]*Term
+	Archives   []*Page
+
+	// Authors holds every author with a profile or a page, sorted by name
+	Authors []*Author
+
+	// Releases are the versions of the changelog source, newest first
+	Releases []*Release
+	// Data holds the data fetched at build time, such as the repositories of repoData
+	Data map[string]any
+
+	// Aliases maps redirecting URL paths to the permalinks they point at
+	Aliases map[string]string
+
+	// AllPages holds every page that is rendered, including list pages
+	AllPages []*Page
+
+	options      buildOptions
+	gitInfos     map[string]*GitInfo
+	deprecations deprecationLog
+
+	// scheduled holds the pages left out because their publish date is still to come, unlisted
+	// the pages rendered at hashed URLs only and standalone the generated pages, such as the
+	// changelog, that are rendered but not listed
+	scheduled  []*Page
+	unlisted   []*Page
+	standalone []*Page
+
+	// romanized caches the output of cjk.transliterate by text
+	slugMu    sync.Mutex
+	romanized map[string]string
+
+	// translations are the strings of the i18n template function in the site language
+	translations map[string]string
+
+	// nestedSections are the sections below the top-level ones, see buildNestedSections
+	nestedSections []*Page
+
+	// loadErrors are the content files that failed to load, kept for --keep-going
+	loadErrors []contentError
+
+	scratchMu sync.Mutex
+	scratches map[*Page]*Scratch
+
+	// staticDirs are copied to the output in order; assets holds the files fingerprinted by templates
+	staticDirs []mountDir
+	assets     assetManifest
+
+	// ctx ends when the build is cancelled or times out
+	ctx context.Context
+	// templates is the template cache of the last render, kept for the dev server
+	templates *TemplateCache
+	// metrics is set when the build reports template metrics
+	metrics *templateMetrics
+	// raised holds the messages of errorf calls in templates
+	raisedMu sync.Mutex
+	raised   []string
+	// validator is set when the build validates the HTML of the pages
+	validator *htmlValidator
+	// icons holds the SVG icons inlined by the icon template function
+	icons iconSet
+}
+
+// Term is a single taxonomy value such as one tag, with the pages using it
+type Term struct {
+	Name  string
+	Slug  string
+	Pages []*Page
+	Page  *Page
+}
+
+// Count returns the number of pages using the term
+func (t *Term) Count() int {
+	return len(t.Pages)
+}
+
+// Permalink returns the absolute URL of the term page
+func (t *Term) Permalink() string {
+	return t.Page.Permalink
+}
+
+// RelPermalink returns the site-relative URL of the term page
+func (t *Term) RelPermalink() string {
+	return t.Page.RelPermalink
+}
+
+// newSite creates an empty site for the given configuration
+func newSite(config Config) *Site {
+	params := config.Params
+	if params == nil {
+		params = map[string]any{}
+	}
+	return &Site{
+		Config:       config,
+		Title:        config.Title,
+		BaseURL:      config.BaseURL,
+		LanguageCode: config.LanguageCode,
+		Description:  config.Description,
+		Params:       params,
+		Taxonomies:   map[string][]*Term{},
+		Aliases:      map[string]string{},
+		ctx:          context.Background(),
+	}
+}
+
+// Lang returns the site language derived from the language code ("en-us" -> "en")
+func (s *Site) Lang() string {
+	if s.LanguageCode == "" {
+		return "en"
+	}
+	return strings.ToLower(strings.SplitN(s.LanguageCode, "-", 2)[0])
+}
+
+// urlPath joins site-relative URL elements with "/" whatever the OS separator, cleaning duplicate
+// slashes and keeping a trailing slash on the last element. File paths are built with filepath instead.
+func urlPath(elems ...string) string {
+	var parts []string
+	for _, elem := range elems {
+		for _, part := range strings.Split(strings.ReplaceAll(elem, "\\", "/"), "/") {
+			if part != "" && part != "." {
+				parts = append(parts, part)
+			}
+		}
+	}
+	p := "/" + strings.Join(parts, "/")
+	if len(elems) > 0 && strings.HasSuffix(elems[len(elems)-1], "/") && p != "/" {
+		p += "/"
+	}
+	return p
+}
+
+// basePath returns the path component of the base URL, e.g. "/herocgo/"
+func (s *Site) basePath() string {
+	u, err := url.Parse(s.BaseURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return strings.TrimSuffix(u.Path, "/") + "/"
+}
+
+// RelURL prefixes a site path with the base URL path
+func (s *Site) RelURL(p string) string {
+	rel := path.Join(s.basePath(), p)
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(rel, "/") {
+		rel += "/"
+	}
+	return rel
+}
+
+// AbsURL turns a site path into an absolute URL using the base URL
+func (s *Site) AbsURL(p string) string {
+	u, err := url.Parse(s.BaseURL)
+	if err != nil || u.Host == "" {
+		return s.RelURL(p)
+	}
+	return u.Scheme + "://" + u.Host + s.RelURL(p)
+}
+
+// setURL assigns the permalinks and output path of a page from its site-relative URL
+// Every segment is sanitized so titles and term names cannot produce unsafe file names.
+func (s *Site) setURL(p *Page, urlPath string) {
+	urlPath = sanitizeURLPath(urlPath)
+	p.RelPermalink = s.RelURL(urlPath)
+	p.Permalink = s.AbsURL(urlPath)
+	p.outputPath = filepath.FromSlash(strings.TrimPrefix(urlPath, "/"))
+	if strings.HasSuffix(urlPath, "/") {
+		p.outputPath = filepath.Join(p.outputPath, "index.html")
+	}
+}
+
+// collectContent walks the content directory and returns the Markdown pages and the count of other files.
+// A subdirectory holding an index.md is a leaf bundle: its other files become page resources.
+func collectContent(contentDir string) ([]contentFile, int, error) {
+	var files []contentFile
+	var nonPageFiles int
+	err := filepath.WalkDir(contentDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(contentDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel == "." {
+				return nil
+			}
+			index := filepath.Join(path, "index.md")
+			if _, err := os.Stat(index); err == nil {
+				files = append(files, contentFile{Path: index, RelPath: filepath.Join(rel, "index.md"), IsBundle: true})
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) == ".md" {
+			files = append(files, contentFile{Path: path, RelPath: rel})
+		} else {
+			nonPageFiles++
+		}
+		return nil
+	})
+	return files, nonPageFiles, err
+}
+
+// loadContent reads every content file concurrently and adds the pages to the site
+func (s *Site) loadContent(files []contentFile) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	branches := map[string]*Page{}
+
+	for _, file := range files {
+		wg.Add(1)
+		go func(file contentFile) {
+			defer wg.Done()
+			page, frontMatter, err := loadPage(file)
+			if err != nil {
+				failf("Failed to process file %s: %v", file.RelPath, err)
+				if s.options.KeepGoing {
+					mu.Lock()
+					s.loadErrors = append(s.loadErrors, contentError{file: file, err: err})
+					mu.Unlock()
+				}
+				return
+			}
+			page.Site = s
+			page.GitInfo = s.gitInfos[page.File.Path]
+			s.checkFrontMatterDeprecations(page)
+			s.resolveTitle(page)
+			s.resolveDates(page)
+			rel := filepath.ToSlash(file.RelPath)
+			dir := path.Dir(rel)
+
+			// Bundle resources live next to the page, so an unlisted bundle keeps its hashed URL
+			pageURL := urlPath(dir, "/")
+			if page.Unlisted {
+				pageURL = s.unlistedURL(page)
+			} else if file.permalink != "" {
+				pageURL = file.permalink
+			} else if !file.IsBundle {
+				pageURL = urlPath(strings.TrimSuffix(rel, ".md") + ".html")
+			}
+			s.setURL(page, pageURL)
+
+			if file.IsBundle {
+				page.Resources, err = collectBundleResources(filepath.Dir(file.Path))
+				if err != nil {
+					failf("Failed to process file %s: %v", file.RelPath, err)
+					return
+				}
+				for _, res := range page.Resources {
+					res.RelPermalink = s.RelURL(urlPath(pageURL, res.RelPath))
+					res.Permalink = s.AbsURL(urlPath(pageURL, res.RelPath))
+					res.stripGPS = s.Config.Imaging.StripGPS
+				}
+				applyResourceMetadata(page.Resources, frontMatter.Resources)
+			}
+			s.resolveCover(page)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case rel == "index.md" || path.Base(rel) == "_index.md":
+				// Branch content supplies the title and body of the home or section list page
+				branches[dir] = page
+			case !s.shouldBuild(page):
+				// Unpublished pages are left out unless the build options include them
+				if !page.Draft && page.IsFuture() {
+					s.scheduled = append(s.scheduled, page)
+				}
+			case file.standalone:
+				s.standalone = append(s.standalone, page)
+			case page.Unlisted:
+				s.unlisted = append(s.unlisted, page)
+			default:
+				s.Pages = append(s.Pages, page)
+			}
+		}(file)
+	}
+	wg.Wait()
+	s.checkUnlistedSecret()
+
+	sortPages(s.Pages)
+	s.applyPageQuotas()
+	s.applyArchiveRules()
+	s.buildHome(branches["."])
+	s.buildSections(branches)
+	s.buildTaxonomies()
+	if err := s.buildAuthors(); err != nil {
+		warnf("%v", err)
+	}
+	s.buildSeries()
+	if s.Config.Archives {
+		s.buildArchives()
+	}
+	lists := append(append([]*Page{s.Home}, s.Sections...), s.nestedSections...)
+	for _, year := range s.Archives {
+		lists = append(append(lists, year), year.Archive.Months...)
+	}
+	for _, term := range s.termPages() {
+		if s.Config.TermFeeds {
+			s.setFeedLinks(term)
+		}
+		lists = append(lists, term)
+	}
+	for _, list := range lists {
+		s.paginate(list)
+	}
+}
+
+// buildHome creates the home page listing every regular page that is not archived
+func (s *Site) buildHome(content *Page) {
+	home := content
+	if home == nil {
+		home = &Page{Site: s, Title: s.Title, Description: s.Description, Params: map[string]any{}}
+	}
+	home.Kind = KindHome
+	home.Pages = currentPages(s.Pages)
+	s.setURL(home, "/")
+	s.setFeedLinks(home)
+	s.Home = home
+	s.AllPages = append(s.AllPages, home)
+	s.AllPages = append(s.AllPages, s.Pages...)
+	s.AllPages = append(s.AllPages, s.unlisted...)
+	s.AllPages = append(s.AllPages, s.standalone...)
+}
+
+// buildSections creates a list page for every top-level content directory, and the nested
+// sections below them
+func (s *Site) buildSections(branches map[string]*Page) {
+	sections := map[string][]*Page{}
+	for _, p := range s.Pages {
+		if p.Section != "" {
+			sections[p.Section] = append(sections[p.Section], p)
+		}
+	}
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		section := branches[name]
+		if section == nil {
+			section = &Page{Site: s, Title: titleCase(name), Params: map[string]any{}}
+		}
+		section.Kind = KindSection
+		section.Section = name
+		section.Pages = sections[name]
+		s.setURL(section, urlPath(name, "/"))
+		s.setFeedLinks(section)
+		s.Sections = append(s.Sections, section)
+		s.AllPages = append(s.AllPages, section)
+	}
+	s.buildNestedSections(branches)
+}
+
+// buildTaxonomies groups pages by the configured taxonomies and creates their list pages
+func (s *Site) buildTaxonomies() {
+	for _, plural := range s.Config.taxonomyNames() {
+		if plural == authorsTaxonomy || plural == seriesTaxonomy {
+			// Authors and series have their own pages, see buildAuthors and buildSeries
+			continue
+		}
+		// Names that only differ in case and punctuation share a term; names that merely
+		// transliterate to the same slug get numbered slugs instead of being merged
+		terms := map[string]*Term{}
+		slugs := map[string]bool{}
+		for _, p := range s.Pages {
+			for _, name := range toStringSlice(p.Params[plural]) {
+				key := slugify(name, SlugUnicode)
+				if key == "" {
+					continue
+				}
+				term, ok := terms[key]
+				if !ok {
+					slug := s.slug(name)
+					if slug == "" {
+						slug = "term"
+					}
+					slug = uniqueSlug(slug, func(candidate string) bool { return slugs[candidate] })
+					slugs[slug] = true
+					term = &Term{Name: name, Slug: slug}
+					terms[key] = term
+				}
+				term.Pages = append(term.Pages, p)
+			}
+		}
+		if len(terms) == 0 {
+			continue
+		}
+		s.applyTermQuota(plural, terms)
+
+		list := make([]*Term, 0, len(terms))
+		for _, term := range terms {
+			term.Page = &Page{
+				Site:     s,
+				Kind:     KindTerm,
+				Title:    term.Name,
+				Params:   map[string]any{},
+				Pages:    term.Pages,
+				Taxonomy: plural,
+				Term:     term.Name,
+			}
+			s.setURL(term.Page, urlPath(plural, term.Slug, "/"))
+			list = append(list, term)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Slug < list[j].Slug })
+		s.Taxonomies[plural] = list
+
+		taxonomy := &Page{
+			Site:     s,
+			Kind:     KindTaxonomy,
+			Title:    titleCase(plural),
+			Params:   map[string]any{},
+			Taxonomy: plural,
+			Terms:    list,
+		}
+		s.setURL(taxonomy, urlPath(plural, "/"))
+		s.AllPages = append(s.AllPages, taxonomy)
+		for _, term := range list {
+			term.Page.parent = taxonomy
+			s.AllPages = append(s.AllPages, term.Page)
+		}
+	}
+}
+
+// termPages returns the term pages of every taxonomy, ordered by taxonomy and slug
+func (s *Site) termPages() []*Page {
+	plurals := make([]string, 0, len(s.Taxonomies))
+	for plural := range s.Taxonomies {
+		plurals = append(plurals, plural)
+	}
+	sort.Strings(plurals)
+	var pages []*Page
+	for _, plural := range plurals {
+		for _, term := range s.Taxonomies[plural] {
+			pages = append(pages, term.Page)
+		}
+	}
+	return pages
+}
+
+// render writes every page to the output directory and returns the number of pages written
+func (s *Site) render(outputDir string, templates *TemplateCache) int {
+	var rendered int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, page := range s.AllPages {
+		wg.Add(1)
+		go func(page *Page) {
+			defer wg.Done()
+			if s.ctx.Err() != nil {
+				return
+			}
+			defer trackTask(s.ctx, "render %s", page.RelPermalink)()
+			if err := s.renderPage(page, outputDir, templates); err != nil {
+				failf("Failed to render %s: %v", page.RelPermalink, err)
+				if s.options.KeepGoing {
+					s.renderErrorPage(outputDir, page, err)
+				}
+				return
+			}
+			mu.Lock()
+			rendered++
+			mu.Unlock()
+		}(page)
+	}
+	wg.Wait()
+	return rendered
+}
+
+// renderPage writes a single page and copies its bundle resources next to it
+func (s *Site) renderPage(page *Page, outputDir string, templates *TemplateCache) error {
+	outputPath, err := outputFile(outputDir, filepath.ToSlash(page.outputPath))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	for _, res := range page.Resources {
+		dest, err := outputFile(filepath.Dir(outputPath), res.RelPath)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create resource directory: %w", err)
+		}
+		if _, err := copyFile(res.SourcePath, dest); err != nil {
+			return fmt.Errorf("failed to copy resource %s: %w", res.RelPath, err)
+		}
+		if res.stripGPS && res.MediaType == "image/jpeg" {
+			if err := stripGPS(dest); err != nil {
+				return fmt.Errorf("failed to strip GPS from %s: %w", res.RelPath, err)
+			}
+		}
+	}
+
+	write := writeHTMLFile
+	if page.Layout == slidesLayout {
+		write = writeSlidesFile
+	}
+	if err := write(outputPath, page, templates); err != nil {
+		return fmt.Errorf("failed to write HTML file: %w", err)
+	}
+	return nil
+}
+
+// sortPages orders pages newest first, falling back to the title and then the URL for equal dates
+func sortPages(pages []*Page) {
+	sort.SliceStable(pages, func(i, j int) bool {
+		if !pages[i].Date.Equal(pages[j].Date) {
+			return pages[i].Date.After(pages[j].Date)
+		}
+		if pages[i].Title != pages[j].Title {
+			return pages[i].Title < pages[j].Title
+		}
+		return pages[i].RelPermalink < pages[j].RelPermalink
+	})
+}
+
+// toStringSlice converts a front matter list (or single value) into strings
+func toStringSlice(v any) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	}
+	return nil
+}
+
+// titleCase upper-cases the first letter of every word
+func titleCase(s string) string {
+	words := strings.Fields(strings.ReplaceAll(s, "-", " "))
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[size:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestTitleCase(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"hello-world", "Hello World"},
+		{"été-à-paris", "Été À Paris"},
+		{"école", "École"},
+		{"日本-guide", "日本 Guide"},
+		{"  spaced  out ", "Spaced Out"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := titleCase(tt.in); got != tt.want {
+			t.Errorf("titleCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// slidesLayout is the front matter layout that renders a page as a reveal.js slide deck
+// instead of through base.html
+const slidesLayout = "slides"
+
+// SlidesConfig configures slide decks:
+//
+//	[slides]
+//	theme = "black"
+//	reveal = "/vendor/reveal.js"
+type SlidesConfig struct {
+	// Reveal is the URL of a reveal.js package with its dist and plugin directories, a CDN
+	// copy by default
+	Reveal string `toml:"reveal"`
+	// Theme is the reveal.js theme of decks, white by default; `slideTheme` front matter
+	// overrides it
+	Theme string `toml:"theme"`
+}
+
+const (
+	defaultRevealURL   = "https://cdn.jsdelivr.net/npm/reveal.js@5.1.0"
+	defaultSlidesTheme = "white"
+)
+
+//go:embed embedded/slides.html
+var defaultSlidesTemplate string
+
+var (
+	// slideBreakPattern matches the thematic breaks written as --- between slides
+	slideBreakPattern = regexp.MustCompile(`(?m)^<hr\s*/?>\n?`)
+	// slideHeadingPattern matches the headings that may start a slide
+	slideHeadingPattern = regexp.MustCompile(`(?m)^<h([1-6])[\s>]`)
+	// slideNotesPattern matches the "Note:" paragraph that starts the speaker notes of a slide
+	slideNotesPattern = regexp.MustCompile(`(?m)^<p>Notes?:\s*`)
+)
+
+// slidesData is the data of the slides.html layout
+type slidesData struct {
+	Page   *Page
+	Slides []template.HTML
+	Reveal string
+	Theme  string
+}
+
+// Slides splits the content into slides at every --- and before every heading up to the
+// slideLevel of the front matter, 2 by default; a slideLevel of 0 only splits at ---. Text
+// after a paragraph starting with "Note:" becomes the speaker notes of its slide.
+func (p *Page) Slides() []template.HTML {
+	level := 2
+	if v, ok := toFloat(p.Params["slideLevel"]); ok {
+		level = int(v)
+	}
+	var slides []template.HTML
+	for _, part := range slideBreakPattern.Split(string(p.Content), -1) {
+		starts := []int{0}
+		for _, m := range slideHeadingPattern.FindAllStringSubmatchIndex(part, -1) {
+			if m[0] > 0 && int(part[m[2]]-'0') <= level {
+				starts = append(starts, m[0])
+			}
+		}
+		starts = append(starts, len(part))
+		for i := 0; i < len(starts)-1; i++ {
+			slide := strings.TrimSpace(part[starts[i]:starts[i+1]])
+			if slide == "" {
+				continue
+			}
+			if loc := slideNotesPattern.FindStringIndex(slide); loc != nil {
+				slide = slide[:loc[0]] + `<aside class="notes"><p>` + slide[loc[1]:] + "</aside>"
+			}
+			slides = append(slides, template.HTML(slide))
+		}
+	}
+	return slides
+}
+
+// slidesTemplate returns the slides.html layout of the site or theme, or the built-in deck.
+// The layout is a complete document with the partials available; it is cached like the other
+// layouts so the dev server re-renders decks when it changes.
+func (tc *TemplateCache) slidesTemplate() (*template.Template, error) {
+	const name = slidesLayout + ".html"
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tmpl, ok := tc.templates[name]; ok {
+		return tmpl, nil
+	}
+
+	text := defaultSlidesTemplate
+	if file, ok := tc.lookup(name); ok {
+		if err := checkReadPath(file); err != nil {
+			return nil, fmt.Errorf("failed to load template: %w", err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load template: %w", err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New(name).Funcs(tc.funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	partials, err := tc.partials()
+	if err != nil {
+		return nil, err
+	}
+	for _, partial := range partials.names {
+		if err := parseTemplateFile(tmpl, partial, partials.files[partial]); err != nil {
+			return nil, err
+		}
+	}
+	if err := parseInternalTemplates(tmpl); err != nil {
+		return nil, err
+	}
+	tc.templates[name] = tmpl
+	tc.deps[name] = templateDeps(tmpl, name)
+	return tmpl, nil
+}
+
+// writeSlidesFile renders a page with the slides layout into outputPath
+func writeSlidesFile(outputPath string, page *Page, templates *TemplateCache) error {
+	tmpl, err := templates.slidesTemplate()
+	if err != nil {
+		return err
+	}
+	templates.use(slidesLayout+".html", page)
+
+	cfg := page.Site.Config.Slides
+	data := slidesData{Page: page, Slides: page.Slides(), Reveal: cfg.Reveal, Theme: cfg.Theme}
+	if data.Reveal == "" {
+		data.Reveal = defaultRevealURL
+	}
+	data.Reveal = strings.TrimSuffix(data.Reveal, "/")
+	if theme, ok := page.Params["slideTheme"].(string); ok && theme != "" {
+		data.Theme = theme
+	}
+	if data.Theme == "" {
+		data.Theme = defaultSlidesTheme
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	if err := os.WriteFile(outputPath, templates.icons.withIconSprite(buf.Bytes()), 0644); err != nil {
+		return fmt.Errorf("failed to create HTML file: %w", err)
+	}
+	return nil
+}
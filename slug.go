@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Slug modes, set with `slugs = "..."` in config.toml
+const (
+	// SlugTransliterate spells accented Latin, Greek and Cyrillic letters in ASCII and keeps other scripts such as CJK
+	SlugTransliterate = "transliterate"
+	// SlugASCII transliterates like SlugTransliterate and drops everything that is still not ASCII
+	SlugASCII = "ascii"
+	// SlugUnicode keeps every letter as written and only lowercases and strips punctuation
+	SlugUnicode = "unicode"
+)
+
+// transliterations spells letters without an ASCII equivalent in unicode.ToLower form
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a", 'æ': "ae",
+	'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g", 'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j", 'ķ': "k", 'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r", 'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ß': "ss",
+	'ţ': "t", 'ť': "t", 'ŧ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k",
+	'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t",
+	'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o", 'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o",
+	'ύ': "y", 'ώ': "o",
+
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya", 'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g",
+}
+
+// slugify turns text into a lowercase, hyphen-separated URL and file name segment.
+// Punctuation and whitespace collapse into single hyphens; mode is one of the Slug constants.
+func slugify(text, mode string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	write := func(s string) {
+		if pendingHyphen && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		pendingHyphen = false
+		b.WriteString(s)
+	}
+	for _, r := range strings.ToLower(text) {
+		if r == '\'' || r == '’' || unicode.Is(unicode.Mn, r) {
+			// Apostrophes and combining marks join the letters around them
+			continue
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendingHyphen = true
+			continue
+		}
+		if r < unicode.MaxASCII || mode == SlugUnicode {
+			write(string(r))
+			continue
+		}
+		if ascii, ok := transliterations[r]; ok {
+			if ascii != "" {
+				write(ascii)
+			}
+			continue
+		}
+		if mode == SlugASCII {
+			pendingHyphen = true
+			continue
+		}
+		write(string(r))
+	}
+	return b.String()
+}
+
+// uniqueSlug returns slug, or slug with the first free "-N" suffix when taken reports it as used
+func uniqueSlug(slug string, taken func(string) bool) string {
+	if !taken(slug) {
+		return slug
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d", slug, n)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// slug converts text using the slug mode of the site configuration, romanizing CJK text first
+// when cjk.transliterate is set
+func (s *Site) slug(text string) string {
+	mode := s.Config.Slugs
+	if mode == "" {
+		mode = SlugTransliterate
+	}
+	return slugify(s.transliterate(text), mode)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// contentStats is the report of the stats command
+type contentStats struct {
+	Pages      int                    `json:"pages"`
+	Words      int                    `json:"words"`
+	Sections   []sectionStats         `json:"sections"`
+	Months     []monthStats           `json:"months"`
+	Taxonomies map[string][]termStats `json:"taxonomies"`
+	Longest    []pageStats            `json:"longest"`
+	Shortest   []pageStats            `json:"shortest"`
+}
+
+type sectionStats struct {
+	Name         string `json:"name"`
+	Pages        int    `json:"pages"`
+	Words        int    `json:"words"`
+	AverageWords int    `json:"averageWords"`
+}
+
+type monthStats struct {
+	Month string `json:"month"`
+	Pages int    `json:"pages"`
+}
+
+type termStats struct {
+	Name  string `json:"name"`
+	Pages int    `json:"pages"`
+}
+
+type pageStats struct {
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	Words int    `json:"words"`
+}
+
+// runStats implements `stats`, reporting word counts, posting frequency and taxonomy usage of
+// the published content as tables or JSON
+func runStats(args []string) {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	format := flags.String("format", "table", "output format: table or json")
+	environment := flags.String("environment", envOr("HERO_ENVIRONMENT", "production"), "environment whose config is used")
+	drafts := flags.Bool("drafts", false, "include drafts and future content")
+	top := flags.Int("top", 5, "number of longest and shortest pages to list")
+	flags.Parse(args)
+	if *format != "table" && *format != "json" {
+		log.Fatalf("Unknown format %q, use table or json", *format)
+	}
+	if *top < 0 {
+		log.Fatalf("Invalid --top %d, use 0 or more", *top)
+	}
+
+	site, _, err := loadSite(buildOptions{Environment: *environment, BuildDrafts: *drafts, BuildFuture: *drafts})
+	if err != nil {
+		log.Fatalf("Failed to load site: %v", err)
+	}
+	stats := site.contentStats(*top)
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(stats); err != nil {
+			log.Fatalf("Failed to encode stats: %v", err)
+		}
+		return
+	}
+	stats.print()
+}
+
+// contentStats counts the words, months and terms of the site pages
+func (s *Site) contentStats(top int) contentStats {
+	stats := contentStats{Pages: len(s.Pages), Taxonomies: map[string][]termStats{}}
+	sections := map[string]*sectionStats{}
+	months := map[string]int{}
+	var pages []pageStats
+	for _, p := range s.Pages {
+		words := p.WordCount()
+		stats.Words += words
+		name := p.Section
+		if name == "" {
+			name = "(root)"
+		}
+		if sections[name] == nil {
+			sections[name] = &sectionStats{Name: name}
+		}
+		sections[name].Pages++
+		sections[name].Words += words
+		if !p.Date.IsZero() {
+			months[p.Date.Format("2006-01")]++
+		}
+		path := p.RelPermalink
+		if p.File != nil {
+			path = "content/" + p.File.Path
+		}
+		pages = append(pages, pageStats{Path: path, Title: p.Title, Words: words})
+	}
+
+	for _, section := range sections {
+		section.AverageWords = section.Words / section.Pages
+		stats.Sections = append(stats.Sections, *section)
+	}
+	sort.Slice(stats.Sections, func(i, j int) bool { return stats.Sections[i].Name < stats.Sections[j].Name })
+	for month, count := range months {
+		stats.Months = append(stats.Months, monthStats{Month: month, Pages: count})
+	}
+	sort.Slice(stats.Months, func(i, j int) bool { return stats.Months[i].Month < stats.Months[j].Month })
+
+	for plural, terms := range s.Taxonomies {
+		list := []termStats{}
+		for _, term := range terms {
+			list = append(list, termStats{Name: term.Name, Pages: term.Count()})
+		}
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Pages != list[j].Pages {
+				return list[i].Pages > list[j].Pages
+			}
+			return list[i].Name < list[j].Name
+		})
+		stats.Taxonomies[plural] = list
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].Words > pages[j].Words })
+	n := min(top, len(pages))
+	stats.Longest = append([]pageStats{}, pages[:n]...)
+	for i := len(pages) - 1; i >= len(pages)-n; i-- {
+		stats.Shortest = append(stats.Shortest, pages[i])
+	}
+	return stats
+}
+
+// print writes the report as console tables
+func (stats contentStats) print() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%d pages, %d words\n\n", stats.Pages, stats.Words)
+
+	fmt.Fprintln(w, "SECTION\tPAGES\tWORDS\tAVERAGE")
+	for _, s := range stats.Sections {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", s.Name, s.Pages, s.Words, s.AverageWords)
+	}
+
+	fmt.Fprintln(w, "\nMONTH\tPAGES")
+	for _, m := range stats.Months {
+		fmt.Fprintf(w, "%s\t%d\n", m.Month, m.Pages)
+	}
+
+	plurals := make([]string, 0, len(stats.Taxonomies))
+	for plural := range stats.Taxonomies {
+		plurals = append(plurals, plural)
+	}
+	sort.Strings(plurals)
+	for _, plural := range plurals {
+		fmt.Fprintf(w, "\n%s\tPAGES\n", strings.ToUpper(plural))
+		for _, term := range stats.Taxonomies[plural] {
+			fmt.Fprintf(w, "%s\t%d\n", term.Name, term.Pages)
+		}
+	}
+
+	for _, list := range []struct {
+		name  string
+		pages []pageStats
+	}{{"LONGEST", stats.Longest}, {"SHORTEST", stats.Shortest}} {
+		fmt.Fprintf(w, "\n%s\tWORDS\tTITLE\n", list.name)
+		for _, p := range list.pages {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", p.Path, p.Words, p.Title)
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// logCounter counts the warnings and failures a build logs with warnf and failf, so --strict
+// can fail a build that only logged its problems
+type logCounter struct {
+	mu       sync.Mutex
+	warnings int
+	failures int
+	first    string
+}
+
+// buildLogs is the counter of the strict build in progress, nil when there is none
+var buildLogs atomic.Pointer[logCounter]
+
+// countLogs counts the problems logged until the returned function is called
+func countLogs() (*logCounter, func()) {
+	counter := &logCounter{}
+	buildLogs.Store(counter)
+	return counter, func() { buildLogs.CompareAndSwap(counter, nil) }
+}
+
+// warnf logs a warning, which fails a strict build
+func warnf(format string, args ...any) {
+	msg := fmt.Sprintf("Warning: "+format, args...)
+	log.Output(2, msg)
+	buildLogs.Load().count(msg, false)
+}
+
+// failf logs the failure of a part of the build that the rest carries on without, which fails a
+// strict build
+func failf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Output(2, msg)
+	buildLogs.Load().count(msg, true)
+}
+
+// count records a logged problem; a nil counter records nothing
+func (c *logCounter) count(msg string, failure bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if failure {
+		c.failures++
+	} else {
+		c.warnings++
+	}
+	if c.first == "" {
+		c.first = msg
+	}
+}
+
+// err returns the error of a strict build that logged warnings or failures, or nil
+func (c *logCounter) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.warnings+c.failures == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d errors and %d warnings with --strict, the first: %s", c.failures, c.warnings, c.first)
+}
+
+// templateWarnf logs a warning raised by a template with warnf, e.g. for content a theme
+// recommends, and outputs nothing; --strict fails the build on it
+func (s *Site) templateWarnf(format string, args ...any) string {
+	warnf(format, args...)
+	return ""
+}
+
+// templateErrorf logs an error raised by a template with errorf, e.g. for content a theme
+// requires, and outputs nothing. The build still renders every page to report all errors, then
+// fails unless it keeps going.
+func (s *Site) templateErrorf(format string, args ...any) string {
+	msg := fmt.Sprintf(format, args...)
+	failf("Error: %s", msg)
+	s.raisedMu.Lock()
+	s.raised = append(s.raised, msg)
+	s.raisedMu.Unlock()
+	return ""
+}
+
+// raisedErr returns the error of a build whose templates called errorf, or nil
+func (s *Site) raisedErr() error {
+	s.raisedMu.Lock()
+	defer s.raisedMu.Unlock()
+	if len(s.raised) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d errors raised by templates, the first: %s", len(s.raised), s.raised[0])
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+func TestLogCounter(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	// Problems logged outside of a strict build are not counted
+	warnf("before")
+	counter, restore := countLogs()
+	if err := counter.err(); err != nil {
+		t.Fatalf("err() = %v before any problem", err)
+	}
+	log.Printf("Warning: a plain log line is not counted")
+	warnf("missing %s", "alt")
+	failf("Failed to render %s", "/")
+	restore()
+	warnf("after")
+
+	want := "1 errors and 1 warnings with --strict, the first: Warning: missing alt"
+	if err := counter.err(); err == nil || err.Error() != want {
+		t.Errorf("err() = %v, want %s", err, want)
+	}
+}
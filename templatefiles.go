@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxTemplateFileSize bounds the files readFile returns, so a template cannot inline a video
+const maxTemplateFileSize = 10 << 20
+
+// projectPath resolves a slash-separated path relative to the project root for the readFile,
+// readDir and fileExists template functions, rejecting absolute paths and paths that leave the
+// project. In safe mode, hidden files such as .env are out of reach too.
+func projectPath(name string) (string, error) {
+	if name == "" || filepath.IsAbs(filepath.FromSlash(name)) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%q must be a path relative to the project", name)
+	}
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is outside of the project", name)
+	}
+	if safeMode {
+		for _, segment := range strings.Split(filepath.ToSlash(clean), "/") {
+			if strings.HasPrefix(segment, ".") && segment != "." {
+				return "", fmt.Errorf("%q is a hidden file, which --safe does not read", name)
+			}
+		}
+	}
+	return clean, nil
+}
+
+// readFile returns the contents of a project file, e.g. an SVG icon for
+// {{ readFile "assets/icons/star.svg" | safeHTML }}
+func readFile(name string) (string, error) {
+	path, err := projectPath(name)
+	if err != nil {
+		return "", err
+	}
+	if err := checkReadPath(path); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory, use readDir", name)
+	}
+	if info.Size() > maxTemplateFileSize {
+		return "", fmt.Errorf("%s is larger than %d MiB", name, maxTemplateFileSize>>20)
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// readDir lists a project directory by name, with the .Name, .IsDir, .Size and .ModTime of
+// every entry
+func readDir(name string) ([]fs.FileInfo, error) {
+	path, err := projectPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReadPath(path); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if safeMode && strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// fileExists reports whether a project file or directory exists
+func fileExists(name string) (bool, error) {
+	path, err := projectPath(name)
+	if err != nil {
+		return false, err
+	}
+	if err := checkReadPath(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
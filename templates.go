@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed embedded/partials
+var embeddedTemplates embed.FS
+
+// version is the generator version exposed to templates as hero.Version
+const version = "0.1.0"
+
+// HeroInfo is returned by the `hero` template function
+type HeroInfo struct {
+	Version     string
+	Environment string
+	BuildDate   time.Time
+	CommitHash  string
+}
+
+// IsProduction reports whether the site is built for the production environment
+func (h HeroInfo) IsProduction() bool {
+	return h.Environment == "production"
+}
+
+// newHeroInfo collects the build information, reading the commit from git when available
+// and git may run
+func newHeroInfo(environment string) HeroInfo {
+	info := HeroInfo{Version: version, Environment: environment, BuildDate: time.Now()}
+	if safeMode {
+		return info
+	}
+	if out, err := exec.Command("git", "rev-parse", "HEAD").Output(); err == nil {
+		info.CommitHash = strings.TrimSpace(string(out))
+	}
+	return info
+}
+
+// TemplateCache parses each theme layout once, together with base.html and the partials
+type TemplateCache struct {
+	// roots are searched in order; mounted layouts come before those of the theme
+	roots     []mountDir
+	funcs     template.FuncMap
+	mu        sync.Mutex
+	templates map[string]*template.Template
+
+	// deps holds the template files each layout executes and pages the pages rendered with it,
+	// so the dev server re-renders only the pages affected by a template change
+	deps  map[string]map[string]bool
+	pages map[string][]*Page
+
+	// metrics records template executions for --templateMetrics
+	metrics *templateMetrics
+	// validator checks the rendered pages for --validate-html
+	validator *htmlValidator
+	// icons supplies the sprite of the icons each page uses
+	icons *iconSet
+}
+
+// newTemplateCache creates a cache over the mounted layouts and the layouts directory of a theme
+func newTemplateCache(themeDir string, mounts []mountDir, site *Site) *TemplateCache {
+	roots := append(append([]mountDir{}, mounts...), mountDir{dir: filepath.Join(themeDir, "layouts")})
+	return &TemplateCache{
+		roots:     roots,
+		funcs:     templateFuncs(site),
+		templates: map[string]*template.Template{},
+		deps:      map[string]map[string]bool{},
+		pages:     map[string][]*Page{},
+		metrics:   site.metrics,
+		validator: site.validator,
+		icons:     &site.icons,
+	}
+}
+
+// lookup returns the file of the first layout root that has the slash-separated name
+func (tc *TemplateCache) lookup(name string) (string, bool) {
+	for _, root := range tc.roots {
+		path, ok := root.relPath(name)
+		if !ok {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// templateFuncs returns the functions available to every template
+func templateFuncs(site *Site) template.FuncMap {
+	funcs := template.FuncMap{
+		"hero":    func() HeroInfo { return site.Hero },
+		"title":   titleCase,
+		"lower":   strings.ToLower,
+		"upper":   strings.ToUpper,
+		"slugify": site.slug,
+		"urlize": func(text string) string {
+			site.deprecated(deprecatedTemplate, "urlize", "a template")
+			return site.slug(text)
+		},
+		"relURL":      site.RelURL,
+		"absURL":      site.AbsURL,
+		"fingerprint": site.fingerprintURL,
+		"integrity":   site.integrity,
+		"safeHTML": func(s string) template.HTML {
+			return template.HTML(s)
+		},
+		"newScratch": newScratch,
+		"jsonIsland": jsonIsland,
+		"timeTag":    site.timeTag,
+		"slice":      func(items ...any) []any { return items },
+		"debug":      func() debugNamespace { return debugNamespace{} },
+		"time":       func() timeNamespace { return timeNamespace{site} },
+		"lang":       func() langNamespace { return langNamespace{site} },
+		"warnf":      site.templateWarnf,
+		"readFile":   readFile,
+		"readDir":    readDir,
+		"fileExists": fileExists,
+		"errorf":     site.templateErrorf,
+		"icon":       site.icon,
+		"qrcode":     qrcodeSVG,
+		"badge":      badgeSVG,
+		"i18n":       site.translate,
+		"jsonify": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}
+	for name, fn := range collectionFuncs() {
+		funcs[name] = fn
+	}
+	if site.metrics != nil {
+		for name, fn := range site.metrics.funcs() {
+			funcs[name] = fn
+		}
+	}
+	if site.validator != nil {
+		for name, fn := range site.validator.funcs() {
+			funcs[name] = fn
+		}
+	}
+	return funcs
+}
+
+// layoutFor returns the first existing layout file for the page kind
+func (tc *TemplateCache) layoutFor(p *Page) (string, error) {
+	var candidates []string
+	if p.Layout != "" {
+		candidates = append(candidates, p.Layout+".html")
+	}
+	switch p.Kind {
+	case KindHome:
+		candidates = append(candidates, "index.html", "list.html")
+	case KindSection:
+		candidates = append(candidates, p.Section+".html", "list.html")
+	case KindTaxonomy:
+		if p.Taxonomy == authorsTaxonomy {
+			candidates = append(candidates, "authors.html")
+		}
+		candidates = append(candidates, "taxonomy/terms.html", "list.html")
+	case KindTerm:
+		switch p.Taxonomy {
+		case authorsTaxonomy:
+			candidates = append(candidates, "author.html")
+		case seriesTaxonomy:
+			candidates = append(candidates, "series.html")
+		}
+		candidates = append(candidates, "taxonomy/taxonomy.html", "list.html")
+	case KindArchive:
+		candidates = append(candidates, "archive.html", "list.html")
+	default:
+		candidates = append(candidates, "single.html", "page.html")
+	}
+	for _, name := range candidates {
+		if _, ok := tc.lookup(name); ok {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no layout found for %s page (tried %s)", p.Kind, strings.Join(candidates, ", "))
+}
+
+// get returns the template set for a layout, parsing it on first use
+func (tc *TemplateCache) get(layout string) (*template.Template, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tmpl, ok := tc.templates[layout]; ok {
+		return tmpl, nil
+	}
+
+	basePath, ok := tc.lookup("base.html")
+	if !ok {
+		return nil, fmt.Errorf("failed to load template: no base.html layout")
+	}
+	if err := checkReadPath(basePath); err != nil {
+		return nil, fmt.Errorf("failed to load template: %w", err)
+	}
+	base, err := os.ReadFile(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load template: %w", err)
+	}
+	tmpl, err := template.New("base.html").Funcs(tc.funcs).Parse(string(base))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template base.html: %w", err)
+	}
+	partials, err := tc.partials()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range partials.names {
+		if err := parseTemplateFile(tmpl, name, partials.files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := parseInternalTemplates(tmpl); err != nil {
+		return nil, err
+	}
+	layoutPath, ok := tc.lookup(layout)
+	if !ok {
+		return nil, fmt.Errorf("failed to load template: no %s layout", layout)
+	}
+	if err := parseTemplateFile(tmpl, layout, layoutPath); err != nil {
+		return nil, err
+	}
+	if tc.metrics != nil {
+		if err := tc.metrics.instrument(tmpl); err != nil {
+			return nil, err
+		}
+	}
+	if tc.validator != nil {
+		if err := tc.validator.instrument(tmpl); err != nil {
+			return nil, err
+		}
+	}
+
+	tc.templates[layout] = tmpl
+	tc.deps[layout] = templateDeps(tmpl, layout)
+	return tmpl, nil
+}
+
+// templateFiles are the partials of every layout root by name, in the order they were found
+type templateFiles struct {
+	names []string
+	files map[string]string
+}
+
+// partials collects the files below partials/ of the layout roots; a partial of an earlier
+// root hides the one with the same name in a later root
+func (tc *TemplateCache) partials() (templateFiles, error) {
+	found := templateFiles{files: map[string]string{}}
+	for _, root := range tc.roots {
+		// Roots mounted below layouts/partials supply some of the partials
+		dir := filepath.Join(root.dir, "partials")
+		if root.prefix != "" {
+			if !strings.HasPrefix(root.prefix+"/", "partials/") {
+				continue
+			}
+			dir = root.dir
+		}
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(root.dir, path)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(filepath.Join(filepath.FromSlash(root.prefix), rel))
+			if _, ok := found.files[name]; !ok {
+				found.names = append(found.names, name)
+				found.files[name] = path
+			}
+			return nil
+		})
+		if err != nil {
+			return templateFiles{}, err
+		}
+	}
+	return found, nil
+}
+
+// parseInternalTemplates adds the built-in partials, available to themes as _internal/<name>
+func parseInternalTemplates(tmpl *template.Template) error {
+	entries, err := embeddedTemplates.ReadDir("embedded/partials")
+	if err != nil {
+		return fmt.Errorf("failed to read internal templates: %w", err)
+	}
+	for _, entry := range entries {
+		data, err := embeddedTemplates.ReadFile("embedded/partials/" + entry.Name())
+		if err != nil {
+			return fmt.Errorf("failed to load internal template: %w", err)
+		}
+		if _, err := tmpl.New("_internal/" + entry.Name()).Parse(string(data)); err != nil {
+			return fmt.Errorf("failed to parse internal template %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// parseTemplateFile adds the file to the template set under the given name
+func parseTemplateFile(tmpl *template.Template, name, path string) error {
+	if err := checkReadPath(path); err != nil {
+		return fmt.Errorf("failed to load template: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load template: %w", err)
+	}
+	if _, err := tmpl.New(name).Parse(string(data)); err != nil {
+		return fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeHTMLFile renders the page through its layout and base.html into outputPath
+func writeHTMLFile(outputPath string, page *Page, templates *TemplateCache) error {
+	layout, err := templates.layoutFor(page)
+	if err != nil {
+		return err
+	}
+	templates.use(layout, page)
+	tmpl, err := templates.get(layout)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if templates.validator != nil {
+		spans, err := templates.validator.execute(tmpl, &buf, page)
+		if err != nil {
+			return fmt.Errorf("failed to execute template: %w", err)
+		}
+		templates.validator.check(filepath.ToSlash(page.outputPath), buf.Bytes(), spans)
+	} else if err := tmpl.ExecuteTemplate(&buf, "base.html", page); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	if err := os.WriteFile(outputPath, templates.icons.withIconSprite(buf.Bytes()), 0644); err != nil {
+		return fmt.Errorf("failed to create HTML file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// buildTasks records the work in flight so a build that times out or is interrupted can
+// report what it was waiting for
+type buildTasks struct {
+	mu    sync.Mutex
+	next  int
+	tasks map[int]buildTask
+}
+
+// buildTask is a page render, external command or output in progress
+type buildTask struct {
+	name  string
+	start time.Time
+}
+
+// abandonAfter is how long a build that timed out or was interrupted waits for its steps to stop
+const abandonAfter = 5 * time.Second
+
+// buildTasksKey is the context key of the task tracker of a build
+type buildTasksKey struct{}
+
+// withBuildTasks returns a context carrying a new task tracker
+func withBuildTasks(ctx context.Context) (context.Context, *buildTasks) {
+	tasks := &buildTasks{tasks: map[int]buildTask{}}
+	return context.WithValue(ctx, buildTasksKey{}, tasks), tasks
+}
+
+// trackTask records a task with the tracker of the context until the returned function is called
+func trackTask(ctx context.Context, format string, args ...any) func() {
+	tasks, ok := ctx.Value(buildTasksKey{}).(*buildTasks)
+	if !ok {
+		return func() {}
+	}
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	id := tasks.next
+	tasks.next++
+	tasks.tasks[id] = buildTask{name: fmt.Sprintf(format, args...), start: time.Now()}
+	return func() {
+		tasks.mu.Lock()
+		defer tasks.mu.Unlock()
+		delete(tasks.tasks, id)
+	}
+}
+
+// inFlight describes the running tasks, longest running first
+func (t *buildTasks) inFlight() []string {
+	t.mu.Lock()
+	running := make([]buildTask, 0, len(t.tasks))
+	for _, task := range t.tasks {
+		running = append(running, task)
+	}
+	t.mu.Unlock()
+	sort.Slice(running, func(i, j int) bool { return running[i].start.Before(running[j].start) })
+	lines := make([]string, len(running))
+	for i, task := range running {
+		lines[i] = fmt.Sprintf("%s (%v)", task.name, time.Since(task.start).Round(time.Millisecond))
+	}
+	return lines
+}
+
+// abortedBuild returns the error of a build whose context ended, listing the tasks in flight
+func abortedBuild(ctx context.Context, tasks *buildTasks, timeout time.Duration) error {
+	reason := "build was cancelled"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = fmt.Sprintf("build timed out after %v", timeout)
+	}
+	running := tasks.inFlight()
+	if len(running) == 0 {
+		return errors.New(reason)
+	}
+	return fmt.Errorf("%s; in flight:\n  %s", reason, strings.Join(running, "\n  "))
+}
+
+// buildTimeout parses the timeout config value; empty or zero means no timeout
+func buildTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid timeout %q, use a duration like 60s", value)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// TTSConfig configures the text-to-speech hook that attaches an audio version to pages.
+// The page text is written to the command's stdin; `{output}` in the command is replaced
+// by the audio file path, otherwise the command's stdout is saved as the audio file.
+type TTSConfig struct {
+	Command   string   `toml:"command"`
+	Extension string   `toml:"extension"`
+	Sections  []string `toml:"sections"`
+}
+
+// pageHook runs for a regular page after its Markdown is rendered and before templates execute
+type pageHook func(p *Page) error
+
+// runPageHooks runs the hooks over every regular page, logging failures as warnings
+func (s *Site) runPageHooks(hooks ...pageHook) {
+	for _, hook := range hooks {
+		if hook == nil {
+			continue
+		}
+		for _, p := range s.contentPages() {
+			if s.ctx.Err() != nil {
+				return
+			}
+			done := trackTask(s.ctx, "page hook %s", p.RelPermalink)
+			if err := hook(p); err != nil {
+				warnf("Page hook failed for %s: %v", p.RelPermalink, err)
+			}
+			done()
+		}
+	}
+}
+
+// ttsHook returns the text-to-speech page hook, or nil when no command is configured
+func (s *Site) ttsHook() pageHook {
+	cfg := s.Config.TTS
+	if strings.TrimSpace(cfg.Command) == "" {
+		return nil
+	}
+	ext := strings.TrimPrefix(cfg.Extension, ".")
+	if ext == "" {
+		ext = "mp3"
+	}
+	return func(p *Page) error {
+		if enabled, ok := p.Params["tts"].(bool); ok && !enabled {
+			return nil
+		}
+		if len(cfg.Sections) > 0 && !slices.Contains(cfg.Sections, p.Section) {
+			return nil
+		}
+
+		text := p.Title + ".\n\n" + p.Plain()
+		sum := sha256.Sum256([]byte(cfg.Command + "\x00" + text))
+		cached := "tts/" + hex.EncodeToString(sum[:16]) + "." + ext
+		audioPath := cacheReadPath(cached)
+		if _, err := os.Stat(audioPath); err != nil {
+			audioPath = cacheWritePath(cached)
+			if err := synthesizeSpeech(s.ctx, cfg.Command, text, audioPath); err != nil {
+				return err
+			}
+		}
+
+		name := strings.TrimSuffix(filepath.Base(p.outputPath), ".html") + "." + ext
+		urlPath := strings.TrimSuffix(p.RelPermalink, filepath.Base(p.outputPath))
+		mediaType := mime.TypeByExtension("." + ext)
+		if mediaType == "" {
+			mediaType = "audio/" + ext
+		}
+		p.Resources = append(p.Resources, &Resource{
+			Name:         "tts",
+			Title:        "Audio version of " + p.Title,
+			Params:       map[string]any{},
+			MediaType:    mediaType,
+			ResourceType: "audio",
+			RelPath:      name,
+			SourcePath:   audioPath,
+			RelPermalink: urlPath + name,
+			Permalink:    strings.TrimSuffix(p.Permalink, filepath.Base(p.outputPath)) + name,
+		})
+		return nil
+	}
+}
+
+// synthesizeSpeech runs the TTS command over the text and stores the audio at dest
+func synthesizeSpeech(ctx context.Context, command, text, dest string) error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return fmt.Errorf("text-to-speech command is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	toStdout := true
+	for i, arg := range args {
+		if strings.Contains(arg, "{output}") {
+			args[i] = strings.ReplaceAll(arg, "{output}", dest)
+			toStdout = false
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(dest)
+		return fmt.Errorf("text-to-speech command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if toStdout {
+		return os.WriteFile(dest, stdout.Bytes(), 0644)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// UnlistedConfig configures the URLs of `unlisted: true` pages, which are rendered but left out
+// of lists, feeds, taxonomies and the search index so their link can be shared with reviewers
+type UnlistedConfig struct {
+	// Path is the directory of unlisted pages, "unlisted" by default
+	Path string `toml:"path"`
+	// Secret is mixed into the URL hash so the URLs cannot be derived from the content paths;
+	// builds with unlisted pages warn when it is empty
+	Secret string `toml:"secret"`
+}
+
+// unlistedHashLength is the length of the hash directory of an unlisted page
+const unlistedHashLength = 20
+
+// dir returns the slash-separated directory of unlisted pages
+func (c UnlistedConfig) dir() string {
+	if c.Path == "" {
+		return "unlisted"
+	}
+	return strings.Trim(urlPath(c.Path), "/")
+}
+
+// unlistedURL returns the hashed URL path of an unlisted page
+func (s *Site) unlistedURL(p *Page) string {
+	cfg := s.Config.Unlisted
+	sum := sha256.Sum256([]byte(cfg.Secret + "\x00" + p.File.Path))
+	return urlPath(cfg.dir(), hex.EncodeToString(sum[:])[:unlistedHashLength], "/")
+}
+
+// checkUnlistedSecret warns when there are unlisted pages but no secret, as the hashes of their
+// URLs then cover only the content paths, which anyone can guess
+func (s *Site) checkUnlistedSecret() {
+	if len(s.unlisted) > 0 && s.Config.Unlisted.Secret == "" {
+		warnf("unlisted.secret is not set, so the URLs of %d unlisted page(s) can be derived from their content paths; set a random secret to keep them private", len(s.unlisted))
+	}
+}
+
+// dropUnlistedOutputs removes the output files of unlisted pages from files and reports whether
+// there were any. The hash directories below dir name the unlisted pages; every file with one of
+// them as a path segment belongs to such a page, which covers its bundle resources and its print,
+// AMP and Open Graph variants too.
+func dropUnlistedOutputs(files map[string]outputFileInfo, dir string) bool {
+	hashes := map[string]bool{}
+	for name := range files {
+		if rest, ok := strings.CutPrefix(name, dir+"/"); ok {
+			if hash, _, ok := strings.Cut(rest, "/"); ok && isUnlistedHash(hash) {
+				hashes[hash] = true
+			}
+		}
+	}
+	if len(hashes) == 0 {
+		return false
+	}
+	for name := range files {
+		for _, segment := range strings.Split(name, "/") {
+			if hashes[segment] {
+				delete(files, name)
+				break
+			}
+		}
+	}
+	return true
+}
+
+// isUnlistedHash reports whether a path segment has the form of the hash of an unlisted page
+func isUnlistedHash(segment string) bool {
+	if len(segment) != unlistedHashLength {
+		return false
+	}
+	_, err := hex.DecodeString(segment)
+	return err == nil && strings.ToLower(segment) == segment
+}
+
+// contentPages returns the regular pages followed by the unlisted and standalone ones, for
+// processing that applies to every rendered content page
+func (s *Site) contentPages() []*Page {
+	pages := make([]*Page, 0, len(s.Pages)+len(s.unlisted)+len(s.standalone))
+	return append(append(append(pages, s.Pages...), s.unlisted...), s.standalone...)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// verifyConcurrency bounds the requests of `verify --url`
+const verifyConcurrency = 8
+
+// runVerify implements `verify`, which checks an output directory or a deployed site against
+// the artifact manifest and exits with status 1 when a file is missing, changed or, for a
+// directory, not listed. The files of unlisted pages, which the manifest leaves out, are skipped.
+// With --key the manifest must carry a valid Ed25519 signature first, made for example with
+// `openssl pkeyutl -sign -rawin -inkey key.pem -in .manifest.json`.
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	dir := flags.String("dir", "public", "output directory to check")
+	siteURL := flags.String("url", "", "check the files served below this URL instead of a directory")
+	manifestFile := flags.String("manifest", "", "artifact manifest, <dir>/"+artifactManifestName+" by default")
+	key := flags.String("key", "", "PEM Ed25519 public key that signed the manifest")
+	signature := flags.String("signature", "", "detached signature of the manifest, raw or base64, <manifest>.sig by default")
+	flags.Parse(args)
+
+	if *manifestFile == "" {
+		*manifestFile = filepath.Join(*dir, artifactManifestName)
+	}
+	data, err := os.ReadFile(*manifestFile)
+	if err != nil {
+		log.Fatalf("Failed to read manifest: %v", err)
+	}
+	if *signature != "" && *key == "" {
+		log.Fatalf("--signature needs the --key to check it with")
+	}
+	if *key != "" {
+		if *signature == "" {
+			*signature = *manifestFile + ".sig"
+		}
+		if err := verifyManifestSignature(data, *key, *signature); err != nil {
+			log.Fatalf("Invalid manifest signature: %v", err)
+		}
+		fmt.Printf("Signature of %s is valid\n", *manifestFile)
+	}
+	var manifest outputManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		log.Fatalf("Could not parse %s: %v", *manifestFile, err)
+	}
+	listed := make(map[string]outputFileInfo, len(manifest.Files))
+	for _, f := range manifest.Files {
+		listed[f.Path] = f
+	}
+
+	var problems []outputChange
+	if *siteURL != "" {
+		problems = verifyRemote(*siteURL, listed)
+	} else if problems, err = verifyDir(*dir, manifest.Unlisted, listed); err != nil {
+		log.Fatalf("Failed to read %s: %v", *dir, err)
+	}
+
+	for _, p := range problems {
+		if p.Diff != "" {
+			fmt.Printf("%s %s: %s\n", p.Action, p.Path, p.Diff)
+		} else {
+			fmt.Printf("%s %s\n", p.Action, p.Path)
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Printf("\n%d of %d files do not match %s\n", len(problems), len(listed), *manifestFile)
+		os.Exit(1)
+	}
+	fmt.Printf("All %d files match %s\n", len(listed), *manifestFile)
+}
+
+// verifyDir returns the files of the output directory that are missing, changed or unexpected
+// compared to the listed files, skipping the manifest, its signature and the files of unlisted
+// pages below unlistedDir when it is set
+func verifyDir(dir, unlistedDir string, listed map[string]outputFileInfo) ([]outputChange, error) {
+	current, err := scanOutput(dir)
+	if err != nil {
+		return nil, err
+	}
+	delete(current, artifactManifestName)
+	delete(current, artifactManifestName+".sig")
+	if unlistedDir != "" {
+		dropUnlistedOutputs(current, unlistedDir)
+	}
+	var problems []outputChange
+	for _, change := range compareOutputs(listed, current) {
+		switch change.Action {
+		case "added":
+			change.Action = "unexpected"
+		case "removed":
+			change.Action = "missing"
+		}
+		problems = append(problems, change)
+	}
+	return problems, nil
+}
+
+// verifyManifestSignature checks the detached Ed25519 signature of the manifest
+func verifyManifestSignature(data []byte, keyFile, signatureFile string) error {
+	keyData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return fmt.Errorf("%s is not a PEM public key", keyFile)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", keyFile, err)
+	}
+	publicKey, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("%s is not an Ed25519 key", keyFile)
+	}
+	signature, err := os.ReadFile(signatureFile)
+	if err != nil {
+		return err
+	}
+	if len(signature) != ed25519.SignatureSize {
+		if signature, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err != nil {
+			return fmt.Errorf("%s is neither a raw nor a base64 signature", signatureFile)
+		}
+	}
+	if !ed25519.Verify(publicKey, data, signature) {
+		return errors.New("the manifest was changed or signed with another key")
+	}
+	return nil
+}
+
+// verifyRemote fetches every listed file below the site URL and reports those that are missing
+// or differ. Files at the root starting with an underscore, such as _headers, configure the host
+// and are not served. Hosts that rewrite responses, e.g. by minifying HTML, report changes.
+func verifyRemote(siteURL string, listed map[string]outputFileInfo) []outputChange {
+	base, err := url.Parse(strings.TrimSuffix(siteURL, "/") + "/")
+	if err != nil || base.Host == "" {
+		log.Fatalf("Invalid site URL %q", siteURL)
+	}
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		// Files are compared as stored, not as encoded for the transfer
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, DisableCompression: true},
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		problems []outputChange
+		limit    = make(chan struct{}, verifyConcurrency)
+	)
+	for name, f := range listed {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(name string, f outputFileInfo) {
+			defer func() { <-limit; wg.Done() }()
+			action, detail := verifyRemoteFile(client, base.ResolveReference(&url.URL{Path: name}).String(), f)
+			if action == "" {
+				return
+			}
+			mu.Lock()
+			problems = append(problems, outputChange{Path: name, Action: action, Diff: detail})
+			mu.Unlock()
+		}(name, f)
+	}
+	wg.Wait()
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Path < problems[j].Path })
+	return problems
+}
+
+// verifyRemoteFile returns "missing", "changed" or "failed" with a detail when the served file
+// does not match its manifest entry, and "" when it does
+func verifyRemoteFile(client *http.Client, fileURL string, f outputFileInfo) (string, string) {
+	resp, err := client.Get(fileURL)
+	if err != nil {
+		return "failed", err.Error()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "missing", ""
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "failed", resp.Status
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "failed", err.Error()
+	}
+	if hex.EncodeToString(h.Sum(nil)) != f.SHA256 {
+		return "changed", ""
+	}
+	return "", ""
+}
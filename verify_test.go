@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestKey writes the PEM public key of a new Ed25519 key pair and returns the file and the
+// private key
+func writeTestKey(t *testing.T, dir, name string) (string, ed25519.PrivateKey) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file, private
+}
+
+func TestVerifyManifestSignature(t *testing.T) {
+	dir := t.TempDir()
+	keyFile, private := writeTestKey(t, dir, "key.pem")
+	otherKeyFile, _ := writeTestKey(t, dir, "other.pem")
+	notAKey := filepath.Join(dir, "notakey.pem")
+	if err := os.WriteFile(notAKey, []byte("not a key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := []byte(`{"files":[]}`)
+	signature := ed25519.Sign(private, manifest)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		key       string
+		signature []byte
+		wantErr   string
+	}{
+		{name: "raw signature", data: manifest, key: keyFile, signature: signature},
+		{name: "base64 signature", data: manifest, key: keyFile, signature: []byte(base64.StdEncoding.EncodeToString(signature) + "\n")},
+		{name: "changed manifest", data: []byte(`{"files":[{}]}`), key: keyFile, signature: signature, wantErr: "changed or signed with another key"},
+		{name: "other key", data: manifest, key: otherKeyFile, signature: signature, wantErr: "changed or signed with another key"},
+		{name: "not a PEM key", data: manifest, key: notAKey, signature: signature, wantErr: "not a PEM public key"},
+		{name: "garbage signature", data: manifest, key: keyFile, signature: []byte("!!"), wantErr: "neither a raw nor a base64 signature"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signatureFile := filepath.Join(dir, "sig"+string(rune('a'+i)))
+			if err := os.WriteFile(signatureFile, tt.signature, 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := verifyManifestSignature(tt.data, tt.key, signatureFile)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("verifyManifestSignature() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("verifyManifestSignature() error = %v; want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyDir(t *testing.T) {
+	unlisted := "unlisted/" + testUnlistedHash + "/index.html"
+	tests := []struct {
+		name   string
+		change func(dir string) error
+		want   string
+	}{
+		{name: "unchanged"},
+		{
+			name:   "changed file",
+			change: func(dir string) error { return os.WriteFile(filepath.Join(dir, "style.css"), []byte("changed"), 0644) },
+			want:   "changed style.css",
+		},
+		{
+			name:   "missing file",
+			change: func(dir string) error { return os.Remove(filepath.Join(dir, "index.html")) },
+			want:   "missing index.html",
+		},
+		{
+			name:   "unexpected file",
+			change: func(dir string) error { return os.WriteFile(filepath.Join(dir, "extra.js"), nil, 0644) },
+			want:   "unexpected extra.js",
+		},
+		{
+			name: "unlisted page changed",
+			change: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, filepath.FromSlash(unlisted)), []byte("changed"), 0644)
+			},
+		},
+		{
+			name: "signature",
+			change: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, artifactManifestName+".sig"), nil, 0644)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestOutput(t, "index.html", "style.css", unlisted)
+			if err := newSite(Config{}).writeArtifactManifest(dir); err != nil {
+				t.Fatal(err)
+			}
+			manifest, err := readOutputManifest(filepath.Join(dir, artifactManifestName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			listed := map[string]outputFileInfo{}
+			for _, f := range manifest.Files {
+				listed[f.Path] = f
+			}
+			if tt.change != nil {
+				if err := tt.change(dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+			problems, err := verifyDir(dir, manifest.Unlisted, listed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range problems {
+				got = append(got, p.Action+" "+p.Path)
+			}
+			if strings.Join(got, ", ") != tt.want {
+				t.Errorf("verifyDir() = %v; want %q", got, tt.want)
+			}
+		})
+	}
+}